@@ -6,8 +6,10 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/linkcheck"
 )
 
 func (c *Client) AddDocumentJSON(doc *document.Document) (err error) {
@@ -56,6 +58,24 @@ func (c *Client) DocumentExists(u string) (_ bool, err error) {
 	return resp.StatusCode == http.StatusOK, nil
 }
 
+// DocumentAdded returns the time the document at u was last indexed, or the
+// zero time when it is not indexed.
+func (c *Client) DocumentAdded(u string) (_ time.Time, err error) {
+	req, err := c.newRequest("HEAD", "/api/document?url="+url.QueryEscape(u), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer closeBody(resp, &err)
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, nil
+	}
+	return http.ParseTime(resp.Header.Get("Last-Modified"))
+}
+
 func (c *Client) Reindex(skipSensitive, detectLanguages bool) (err error) {
 	type reindexRequest struct {
 		SkipSensitive   bool `json:"skipSensitive"`
@@ -78,6 +98,61 @@ func (c *Client) Reindex(skipSensitive, detectLanguages bool) (err error) {
 	return checkStatus(resp)
 }
 
+// StartLinkCheck starts probing the indexed web pages not checked for
+// maxAge, or all of them when maxAge is zero, on the server. When a check is
+// already running, its progress is returned with linkcheck.ErrRunning.
+func (c *Client) StartLinkCheck(maxAge time.Duration) (_ *linkcheck.Progress, err error) {
+	data, err := json.Marshal(map[string]int64{"maxAge": int64(maxAge.Seconds())})
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.newRequest("POST", "/api/check-links", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp, &err)
+	running := resp.StatusCode == http.StatusConflict
+	if !running {
+		if err := checkStatus(resp); err != nil {
+			return nil, err
+		}
+	}
+	var p *linkcheck.Progress
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, err
+	}
+	if running {
+		return p, linkcheck.ErrRunning
+	}
+	return p, nil
+}
+
+// LinkCheckStatus returns the progress of the current or last link check.
+func (c *Client) LinkCheckStatus() (_ *linkcheck.Progress, err error) {
+	req, err := c.newRequest("GET", "/api/check-links", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp, &err)
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	var p *linkcheck.Progress
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 func (c *Client) DeleteDocument(u string) (err error) {
 	return c.DeleteDocuments("url:" + u)
 }
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// ListFeeds returns the feed subscriptions of the user.
+func (c *Client) ListFeeds() (_ []*Feed, err error) {
+	req, err := c.newRequest("GET", "/api/feeds", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp, &err)
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	var feeds []*Feed
+	if err := json.NewDecoder(resp.Body).Decode(&feeds); err != nil {
+		return nil, err
+	}
+	return feeds, nil
+}
+
+// AddFeed subscribes to the feed at u. The pages linked by the entries are
+// indexed instead of the content of the entries when fetchPages is set.
+func (c *Client) AddFeed(u string, fetchPages bool) (_ *Feed, err error) {
+	data, err := json.Marshal(map[string]any{"url": u, "fetchPages": fetchPages})
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.newRequest("POST", "/api/feeds", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp, &err)
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	var f *Feed
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// DeleteFeed removes the subscription to the feed at u.
+func (c *Client) DeleteFeed(u string) (err error) {
+	data, err := json.Marshal(map[string]string{"url": u})
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest("POST", "/api/feeds/delete", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp, &err)
+	return checkStatus(resp)
+}
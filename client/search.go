@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/url"
+	"strconv"
 
 	"github.com/asciimoo/hister/server/indexer"
 )
@@ -38,3 +39,29 @@ func (c *Client) Search(q *indexer.Query) (_ *indexer.Results, err error) {
 	}
 	return res, nil
 }
+
+// Explain explains the ranking of the first limit results of q, 0 meaning
+// the default number of results.
+func (c *Client) Explain(q string, limit int) (_ *ExplainResult, err error) {
+	params := url.Values{"q": {q}}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	req, err := c.newRequest("GET", "/api/explain?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp, &err)
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	var res *ExplainResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
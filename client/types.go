@@ -1,5 +1,14 @@
 package client
 
+import (
+	"time"
+
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/indexer"
+	"github.com/asciimoo/hister/server/indexer/querybuilder"
+	"github.com/asciimoo/hister/server/model"
+)
+
 type HistoryItem struct {
 	Query     string `json:"query"`
 	Title     string `json:"title"`
@@ -7,6 +16,17 @@ type HistoryItem struct {
 	UpdatedAt string `json:"updated_at"`
 }
 
+// Feed is a feed subscription. Error is the failure of the last poll.
+type Feed struct {
+	ID         uint      `json:"id"`
+	URL        string    `json:"url"`
+	Title      string    `json:"title"`
+	FetchPages bool      `json:"fetch_pages"`
+	Configured bool      `json:"configured"`
+	Error      string    `json:"error"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
 type historyRequest struct {
 	URL    string `json:"url"`
 	Title  string `json:"title,omitempty"`
@@ -19,3 +39,30 @@ type RulesResponse struct {
 	Priority []string          `json:"priority"`
 	Aliases  map[string]string `json:"aliases"`
 }
+
+// ExplainResult explains the ranking of the results of a query.
+// ResolvedQuery is the query searched after resolving the aliases.
+type ExplainResult struct {
+	Query         string             `json:"query"`
+	ResolvedQuery string             `json:"resolved_query"`
+	ParsedQuery   string             `json:"parsed_query"`
+	Tree          *querybuilder.Node `json:"tree"`
+	Boosts        map[string]float64 `json:"boosts"`
+	Total         uint64             `json:"total"`
+	Hits          []*ExplainHit      `json:"hits"`
+}
+
+// ExplainHit explains the rank of a result. SearchRank is its rank in the
+// index, before the results opened from the same query are listed first,
+// History is set for those. It is 0 for the listed results the index does
+// not match.
+type ExplainHit struct {
+	URL         string                `json:"url"`
+	Title       string                `json:"title"`
+	Rank        int                   `json:"rank"`
+	SearchRank  int                   `json:"search_rank"`
+	Score       float64               `json:"score"`
+	Hybrid      *document.HybridScore `json:"hybrid,omitempty"`
+	Explanation *indexer.Explanation  `json:"explanation,omitempty"`
+	History     *model.URLCount       `json:"history,omitempty"`
+}
@@ -22,7 +22,9 @@ import (
 	"regexp"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -40,6 +42,8 @@ type Config struct {
 	SensitiveContentPatterns map[string]string     `yaml:"sensitive_content_patterns" mapstructure:"sensitive_content_patterns"`
 	Rules                    *Rules                `yaml:"-" mapstructure:"-"`
 	Extractors               map[string]*Extractor `yaml:"extractors" mapstructure:"extractors"`
+	SiteExtractors           []*SiteExtractor      `yaml:"site_extractors" mapstructure:"site_extractors"`
+	ExtractorPlugins         []*ExtractorPlugin    `yaml:"extractor_plugins" mapstructure:"extractor_plugins"`
 	secretKey                []byte
 	parsedBaseURL            *url.URL
 	usesDefaultBaseURL       bool
@@ -89,10 +93,38 @@ type Directory struct {
 	IncludeHidden bool     `yaml:"include_hidden" mapstructure:"include_hidden"`
 }
 
+// Mailbox is an mbox file, a Maildir folder or a directory containing
+// either, whose messages are indexed.
+type Mailbox struct {
+	Path string `yaml:"path" mapstructure:"path"`
+}
+
+// Repository is a local git repository, or a directory containing
+// repositories, whose commits and READMEs are indexed.
+type Repository struct {
+	Path string `yaml:"path" mapstructure:"path"`
+}
+
 type Indexer struct {
-	DetectLanguages bool         `yaml:"detect_languages" mapstructure:"detect_languages"`
-	Directories     []*Directory `yaml:"directories" mapstructure:"directories"`
-	MaxFileSize     int64        `yaml:"max_file_size_mb" mapstructure:"max_file_size_mb"`
+	DetectLanguages bool          `yaml:"detect_languages" mapstructure:"detect_languages"`
+	Directories     []*Directory  `yaml:"directories" mapstructure:"directories"`
+	Mailboxes       []*Mailbox    `yaml:"mailboxes" mapstructure:"mailboxes"`
+	Repositories    []*Repository `yaml:"repositories" mapstructure:"repositories"`
+	MaxFileSize     int64         `yaml:"max_file_size_mb" mapstructure:"max_file_size_mb"`
+	// MaxArchiveSize is the size limit of the zip and tar archives whose
+	// members are indexed, and MaxArchiveMembers the limit of the number of
+	// their indexed members.
+	MaxArchiveSize    int64 `yaml:"max_archive_size_mb" mapstructure:"max_archive_size_mb"`
+	MaxArchiveMembers int   `yaml:"max_archive_members" mapstructure:"max_archive_members"`
+	KeepVersions      bool  `yaml:"keep_versions" mapstructure:"keep_versions"`
+	MaxVersions       int   `yaml:"max_versions" mapstructure:"max_versions"`
+	// ReconcileInterval is how often the documents of local files are
+	// checked against the file system, to drop files removed while the
+	// watcher was not running, and mailboxes and repositories are read
+	// again for new messages and commits. An empty interval disables the
+	// check.
+	ReconcileInterval string `yaml:"reconcile_interval" mapstructure:"reconcile_interval"`
+	reconcileInterval time.Duration
 }
 
 type CrawlerCookie struct {
@@ -103,15 +135,69 @@ type CrawlerCookie struct {
 }
 
 // CrawlerConfig holds global crawler settings and backend-specific options.
-// Timeout and Delay default to 5s and 0s respectively when zero.
+// Timeout and Delay default to 5s and 0s respectively when zero. Delay and
+// MaxHostConnections apply per host, Concurrency to the whole crawl.
 type CrawlerConfig struct {
-	Timeout        int               `yaml:"timeout"         mapstructure:"timeout"`
-	Delay          int               `yaml:"delay"           mapstructure:"delay"`
-	Backend        string            `yaml:"backend"         mapstructure:"backend"`
-	BackendOptions map[string]any    `yaml:"backend_options" mapstructure:"backend_options"`
-	UserAgent      string            `yaml:"user_agent"      mapstructure:"user_agent"`
-	Headers        map[string]string `yaml:"headers"         mapstructure:"headers"`
-	Cookies        []CrawlerCookie   `yaml:"cookies"         mapstructure:"cookies"`
+	Timeout            int               `yaml:"timeout"              mapstructure:"timeout"`
+	Delay              int               `yaml:"delay"                mapstructure:"delay"`
+	Concurrency        int               `yaml:"concurrency"          mapstructure:"concurrency"`
+	MaxHostConnections int               `yaml:"max_host_connections" mapstructure:"max_host_connections"`
+	IgnoreRobots       bool              `yaml:"ignore_robots"        mapstructure:"ignore_robots"`
+	Backend            string            `yaml:"backend"              mapstructure:"backend"`
+	BackendOptions     map[string]any    `yaml:"backend_options"      mapstructure:"backend_options"`
+	UserAgent          string            `yaml:"user_agent"           mapstructure:"user_agent"`
+	Headers            map[string]string `yaml:"headers"              mapstructure:"headers"`
+	Cookies            []CrawlerCookie   `yaml:"cookies"              mapstructure:"cookies"`
+	Refresh            RefreshConfig     `yaml:"refresh"              mapstructure:"refresh"`
+	LinkCheck          LinkCheckConfig   `yaml:"link_check"           mapstructure:"link_check"`
+	Feeds              FeedsConfig       `yaml:"feeds"                mapstructure:"feeds"`
+}
+
+// FeedsConfig configures the RSS, Atom and JSON Feed subscriptions polled by
+// `hister listen`. Every feed is fetched once per Interval, which accepts the
+// same values as the refresh intervals; an empty interval disables polling.
+// Feeds can also be subscribed to through the API, Subscriptions are the
+// ones belonging to the configuration file.
+type FeedsConfig struct {
+	Interval      string  `yaml:"interval"      mapstructure:"interval"`
+	Subscriptions []*Feed `yaml:"subscriptions" mapstructure:"subscriptions"`
+	interval      time.Duration
+}
+
+// Feed is a feed subscription. The content of the entries is indexed, or the
+// pages they link to when FetchPages is set.
+type Feed struct {
+	URL        string `yaml:"url"         mapstructure:"url"`
+	FetchPages bool   `yaml:"fetch_pages" mapstructure:"fetch_pages"`
+}
+
+// LinkCheckConfig schedules the dead link detection of `hister listen`.
+// Every indexed web page is probed once per Interval, which accepts the same
+// values as the refresh intervals; an empty interval disables the check.
+type LinkCheckConfig struct {
+	Interval string `yaml:"interval" mapstructure:"interval"`
+	interval time.Duration
+}
+
+// RefreshConfig schedules background re-crawls of indexed web pages while
+// the server is running. A page is refreshed with the interval of the first
+// rule matching it, or with Interval when no rule matches. Intervals are Go
+// durations with an additional "d" unit for days; an empty or zero interval
+// disables refreshing.
+type RefreshConfig struct {
+	Interval string         `yaml:"interval" mapstructure:"interval"`
+	Rules    []*RefreshRule `yaml:"rules"    mapstructure:"rules"`
+	interval time.Duration
+}
+
+// RefreshRule overrides the refresh interval of the pages on Domain
+// (including its subdomains) or of the URLs matching the Pattern regexp.
+type RefreshRule struct {
+	Domain   string `yaml:"domain"   mapstructure:"domain"`
+	Pattern  string `yaml:"pattern"  mapstructure:"pattern"`
+	Interval string `yaml:"interval" mapstructure:"interval"`
+	re       *regexp.Regexp
+	interval time.Duration
 }
 
 type Hotkeys struct {
@@ -130,6 +216,38 @@ type Rule struct {
 	re     *regexp.Regexp
 }
 
+// SiteExtractor declares an extractor of the pages of a website. Pages whose
+// URL matches one of the URLPatterns regexps are extracted with the CSS
+// selectors: Title and Content select the title and the main content of the
+// page, elements matching Remove are dropped first. Metadata maps metadata
+// keys to selectors; a selector ending in @name stores the name attribute of
+// the first matching element instead of its text.
+type SiteExtractor struct {
+	Name        string            `yaml:"name"         mapstructure:"name"`
+	Description string            `yaml:"description"  mapstructure:"description"`
+	URLPatterns []string          `yaml:"url_patterns" mapstructure:"url_patterns"`
+	Title       string            `yaml:"title"        mapstructure:"title"`
+	Content     string            `yaml:"content"      mapstructure:"content"`
+	Remove      []string          `yaml:"remove"       mapstructure:"remove"`
+	Metadata    map[string]string `yaml:"metadata"     mapstructure:"metadata"`
+}
+
+// ExtractorPlugin declares an extractor running the external program Command
+// with Args for the documents whose URL matches the URLPattern regexp. The
+// document is written to the standard input of the program as JSON, which
+// answers on its standard output. Each run is killed after Timeout seconds
+// (10 by default) and at most Concurrency runs (1 by default) happen at the
+// same time.
+type ExtractorPlugin struct {
+	Name        string   `yaml:"name"        mapstructure:"name"`
+	Description string   `yaml:"description" mapstructure:"description"`
+	Command     string   `yaml:"command"     mapstructure:"command"`
+	Args        []string `yaml:"args"        mapstructure:"args"`
+	URLPattern  string   `yaml:"url_pattern" mapstructure:"url_pattern"`
+	Timeout     int      `yaml:"timeout"     mapstructure:"timeout"`
+	Concurrency int      `yaml:"concurrency" mapstructure:"concurrency"`
+}
+
 type Extractor struct {
 	Enable  bool           `yaml:"enable" mapstructure:"enable"`
 	Options map[string]any `yaml:"options" mapstructure:"options"`
@@ -364,12 +482,20 @@ func CreateDefaultConfig() *Config {
 			Database: "db.sqlite3",
 		},
 		Indexer: Indexer{
-			DetectLanguages: true,
-			MaxFileSize:     1,
+			DetectLanguages:   true,
+			MaxFileSize:       1,
+			MaxArchiveSize:    50,
+			MaxArchiveMembers: 1000,
+			ReconcileInterval: "1h",
 		},
 		Crawler: CrawlerConfig{
-			Backend: "http",
-			Timeout: 5,
+			Backend:            "http",
+			Timeout:            5,
+			Concurrency:        4,
+			MaxHostConnections: 1,
+			Feeds: FeedsConfig{
+				Interval: "1h",
+			},
 		},
 		Hotkeys: Hotkeys{
 			Web: map[string]string{
@@ -487,6 +613,18 @@ func (c *Config) init() error {
 	if err := c.validateOAuth(); err != nil {
 		return err
 	}
+	if err := c.Crawler.Refresh.Compile(); err != nil {
+		return err
+	}
+	if err := c.Crawler.LinkCheck.Compile(); err != nil {
+		return err
+	}
+	if err := c.Crawler.Feeds.Compile(); err != nil {
+		return err
+	}
+	if err := c.Indexer.Compile(); err != nil {
+		return err
+	}
 	sPath := c.FullPath(secretKeyFilename)
 	b, err := os.ReadFile(sPath)
 	if err != nil {
@@ -933,6 +1071,132 @@ func (s SemanticSearch) Validate() error {
 	return nil
 }
 
+// Compile parses the refresh intervals and rule patterns.
+func (r *RefreshConfig) Compile() error {
+	var err error
+	if r.interval, err = ParseInterval(r.Interval); err != nil {
+		return fmt.Errorf("crawler.refresh.interval: %w", err)
+	}
+	for i, rule := range r.Rules {
+		if rule.Domain == "" && rule.Pattern == "" {
+			return fmt.Errorf("crawler.refresh.rules[%d]: domain or pattern is required", i)
+		}
+		if rule.Pattern != "" {
+			if rule.re, err = regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("crawler.refresh.rules[%d].pattern: %w", i, err)
+			}
+		}
+		if rule.interval, err = ParseInterval(rule.Interval); err != nil {
+			return fmt.Errorf("crawler.refresh.rules[%d].interval: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether any page can be refreshed.
+func (r *RefreshConfig) Enabled() bool {
+	if r.interval > 0 {
+		return true
+	}
+	return slices.ContainsFunc(r.Rules, func(rule *RefreshRule) bool {
+		return rule.interval > 0
+	})
+}
+
+// IntervalFor returns the refresh interval of u, zero when u must not be
+// refreshed.
+func (r *RefreshConfig) IntervalFor(u *url.URL) time.Duration {
+	host := strings.ToLower(u.Hostname())
+	for _, rule := range r.Rules {
+		if rule.Domain != "" {
+			d := strings.ToLower(rule.Domain)
+			if host != d && !strings.HasSuffix(host, "."+d) {
+				continue
+			}
+		}
+		if rule.re != nil && !rule.re.MatchString(u.String()) {
+			continue
+		}
+		return rule.interval
+	}
+	return r.interval
+}
+
+// Compile parses the link check interval.
+func (l *LinkCheckConfig) Compile() error {
+	var err error
+	if l.interval, err = ParseInterval(l.Interval); err != nil {
+		return fmt.Errorf("crawler.link_check.interval: %w", err)
+	}
+	return nil
+}
+
+// Every returns the link check interval, zero when the check is disabled.
+func (l *LinkCheckConfig) Every() time.Duration {
+	return l.interval
+}
+
+// Compile parses the feed polling interval and checks the subscribed URLs.
+func (f *FeedsConfig) Compile() error {
+	var err error
+	if f.interval, err = ParseInterval(f.Interval); err != nil {
+		return fmt.Errorf("crawler.feeds.interval: %w", err)
+	}
+	for i, feed := range f.Subscriptions {
+		u, err := url.Parse(feed.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("crawler.feeds.subscriptions[%d].url: invalid feed URL %q", i, feed.URL)
+		}
+	}
+	return nil
+}
+
+// Every returns the feed polling interval, zero when polling is disabled.
+func (f *FeedsConfig) Every() time.Duration {
+	return f.interval
+}
+
+// Compile parses the reconcile interval.
+func (i *Indexer) Compile() error {
+	var err error
+	if i.reconcileInterval, err = ParseInterval(i.ReconcileInterval); err != nil {
+		return fmt.Errorf("indexer.reconcile_interval: %w", err)
+	}
+	return nil
+}
+
+// ReconcileEvery returns the reconcile interval, zero when reconciliation is
+// disabled.
+func (i *Indexer) ReconcileEvery() time.Duration {
+	return i.reconcileInterval
+}
+
+// ParseInterval parses a time.Duration string which may also use the "d"
+// (24h) unit, e.g. "7d" or "1d12h".
+func ParseInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	var days time.Duration
+	if n, rest, ok := strings.Cut(s, "d"); ok {
+		v, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, fmt.Errorf("invalid interval %q", s)
+		}
+		days = time.Duration(v) * 24 * time.Hour
+		if rest == "" {
+			return days, nil
+		}
+		s = rest
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q", s)
+	}
+	return days + d, nil
+}
+
 func (h Hotkeys) Validate() error {
 	for k, v := range h.Web {
 		if !slices.Contains(hotkeyActions, v) {
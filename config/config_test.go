@@ -1,8 +1,10 @@
 package config
 
 import (
+	"net/url"
 	"regexp"
 	"testing"
+	"time"
 )
 
 func TestBasePathPrefix(t *testing.T) {
@@ -82,3 +84,42 @@ func TestWebSocketURLHonorsBasePath(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshInterval(t *testing.T) {
+	rc := &RefreshConfig{
+		Interval: "7d",
+		Rules: []*RefreshRule{
+			{Domain: "news.example.com", Interval: "1h30m"},
+			{Pattern: `^https://example\.com/static/`, Interval: "0"},
+			{Domain: "example.com", Interval: "1d12h"},
+		},
+	}
+	if err := rc.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url  string
+		want time.Duration
+	}{
+		{"https://news.example.com/a", 90 * time.Minute},
+		{"https://example.com/static/x.html", 0},
+		{"https://example.com/blog", 36 * time.Hour},
+		{"https://www.example.com/blog", 36 * time.Hour},
+		{"https://notexample.com/", 7 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rc.IntervalFor(u); got != tt.want {
+			t.Errorf("IntervalFor(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"7days", "d", "1x"} {
+		if _, err := ParseInterval(bad); err == nil {
+			t.Errorf("ParseInterval(%q) succeeded, want error", bad)
+		}
+	}
+}
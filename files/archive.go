@@ -0,0 +1,286 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path"
+	"strings"
+)
+
+// ArchiveSeparator separates the path of an archive from the name of a
+// member in the paths and URLs of archive members, e.g.
+// file:///home/user/docs.zip!/guide/index.md.
+const ArchiveSeparator = "!/"
+
+var (
+	ErrNotArchive       = errors.New("not an archive")
+	ErrTooManyMembers   = errors.New("too many archive members")
+	ErrMemberTooLarge   = errors.New("archive member too large")
+	ErrMemberNotFound   = errors.New("archive member not found")
+	errUnsupportedEntry = errors.New("unsupported archive entry")
+)
+
+// archiveFormats maps the suffixes of archive file names to the functions
+// reading them.
+var archiveFormats = []struct {
+	suffix string
+	read   func(f *os.File) (memberReader, error)
+}{
+	{".zip", readZip},
+	{".tar", readTar(nil)},
+	{".tar.gz", readTar(gzipReader)},
+	{".tgz", readTar(gzipReader)},
+	{".tar.bz2", readTar(bzip2Reader)},
+	{".tbz2", readTar(bzip2Reader)},
+}
+
+// ArchiveMember is a regular file read from an archive.
+type ArchiveMember struct {
+	// Name is the slash separated path of the member inside the archive.
+	Name    string
+	Content []byte
+}
+
+// memberReader returns the next entry of an archive, and a reader of its
+// content. It returns io.EOF after the last entry.
+type memberReader func() (name string, info os.FileInfo, r io.Reader, err error)
+
+// IsArchive reports whether the file name is an archive whose members can
+// be read.
+func IsArchive(name string) bool {
+	return archiveFormat(name) >= 0
+}
+
+func archiveFormat(name string) int {
+	name = strings.ToLower(name)
+	for i, f := range archiveFormats {
+		if strings.HasSuffix(name, f.suffix) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ArchiveMemberPath returns the path of the member name of the archive at
+// archivePath.
+func ArchiveMemberPath(archivePath, name string) string {
+	return archivePath + ArchiveSeparator + name
+}
+
+// SplitArchivePath splits the path of an archive member into the path of
+// the archive and the name of the member. ok is false for other paths.
+func SplitArchivePath(p string) (archivePath, name string, ok bool) {
+	for i := strings.Index(p, ArchiveSeparator); i >= 0; {
+		if IsArchive(p[:i]) {
+			return p[:i], p[i+len(ArchiveSeparator):], true
+		}
+		j := strings.Index(p[i+1:], ArchiveSeparator)
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+	return "", "", false
+}
+
+// ArchiveMembers returns the regular files of the archive at p. Hidden
+// files, the files of hidden and well-known dependency directories and
+// entries pointing outside of the archive are left out. Reading stops with
+// ErrTooManyMembers after maxMembers files, members larger than maxSize are
+// reported with ErrMemberTooLarge and skipped.
+func ArchiveMembers(p string, maxMembers int, maxSize int64) iter.Seq2[*ArchiveMember, error] {
+	return func(yield func(*ArchiveMember, error) bool) {
+		f, next, err := openArchive(p)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer f.Close()
+		n := 0
+		for {
+			name, info, r, err := next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if errors.Is(err, errUnsupportedEntry) {
+				continue
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if skipMember(name) {
+				continue
+			}
+			if n++; n > maxMembers {
+				yield(nil, fmt.Errorf("%w: more than %d in %s", ErrTooManyMembers, maxMembers, p))
+				return
+			}
+			content, err := readMember(r, info, maxSize)
+			if err != nil {
+				if !yield(nil, fmt.Errorf("%s: %w", name, err)) {
+					return
+				}
+				continue
+			}
+			if !yield(&ArchiveMember{Name: name, Content: content}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ReadArchiveMember returns the content of the member name of the archive
+// at p. Members larger than maxSize are not read.
+func ReadArchiveMember(p, name string, maxSize int64) ([]byte, error) {
+	f, next, err := openArchive(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	for {
+		n, info, r, err := next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: %s", ErrMemberNotFound, name)
+		}
+		if errors.Is(err, errUnsupportedEntry) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n == name {
+			return readMember(r, info, maxSize)
+		}
+	}
+}
+
+func openArchive(p string) (*os.File, memberReader, error) {
+	i := archiveFormat(p)
+	if i < 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotArchive, p)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	next, err := archiveFormats[i].read(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, next, nil
+}
+
+// readMember reads the content of a member, up to maxSize bytes. The size
+// recorded in the archive is not trusted.
+func readMember(r io.Reader, info os.FileInfo, maxSize int64) ([]byte, error) {
+	if info.Size() > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrMemberTooLarge, info.Size())
+	}
+	content, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > maxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrMemberTooLarge, maxSize)
+	}
+	return content, nil
+}
+
+// memberName returns the cleaned name of an archive entry, or an empty
+// string for entries pointing outside of the archive.
+func memberName(name string) string {
+	name = strings.TrimLeft(path.Clean(strings.ReplaceAll(name, "\\", "/")), "/")
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return ""
+	}
+	return name
+}
+
+// skipMember reports whether the member name is hidden or in a directory
+// skipped when walking directories.
+func skipMember(name string) bool {
+	dir, base := path.Split(name)
+	if strings.HasPrefix(base, ".") {
+		return true
+	}
+	for d := range strings.SplitSeq(strings.Trim(dir, "/"), "/") {
+		if d != "" && ShouldSkipDir(d, nil, false) {
+			return true
+		}
+	}
+	return false
+}
+
+func readZip(f *os.File) (memberReader, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	i := 0
+	var rc io.ReadCloser
+	return func() (string, os.FileInfo, io.Reader, error) {
+		if rc != nil {
+			rc.Close()
+			rc = nil
+		}
+		if i == len(zr.File) {
+			return "", nil, nil, io.EOF
+		}
+		zf := zr.File[i]
+		i++
+		name := memberName(zf.Name)
+		if name == "" || !zf.Mode().IsRegular() {
+			return "", nil, nil, errUnsupportedEntry
+		}
+		var err error
+		if rc, err = zf.Open(); err != nil {
+			return "", nil, nil, err
+		}
+		return name, zf.FileInfo(), rc, nil
+	}, nil
+}
+
+func readTar(decompress func(io.Reader) (io.Reader, error)) func(f *os.File) (memberReader, error) {
+	return func(f *os.File) (memberReader, error) {
+		var r io.Reader = f
+		if decompress != nil {
+			var err error
+			if r, err = decompress(f); err != nil {
+				return nil, err
+			}
+		}
+		tr := tar.NewReader(r)
+		return func() (string, os.FileInfo, io.Reader, error) {
+			h, err := tr.Next()
+			if err != nil {
+				return "", nil, nil, err
+			}
+			name := memberName(h.Name)
+			if name == "" || !h.FileInfo().Mode().IsRegular() {
+				return "", nil, nil, errUnsupportedEntry
+			}
+			return name, h.FileInfo(), tr, nil
+		}, nil
+	}
+}
+
+func gzipReader(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+func bzip2Reader(r io.Reader) (io.Reader, error) {
+	return bzip2.NewReader(r), nil
+}
@@ -0,0 +1,135 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var testMembers = []struct{ name, content string }{
+	{"guide/index.md", "# Guide\n"},
+	{"./notes.txt", "notes\n"},
+	{"guide/.hidden", "hidden\n"},
+	{"node_modules/lib/index.js", "ignored\n"},
+	{"../outside.txt", "outside\n"},
+	{"big.txt", "0123456789abcdef"},
+}
+
+func writeZip(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	if _, err := w.Create("guide/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range testMembers {
+		mw, err := w.Create(m.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mw.Write([]byte(m.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTarGz(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	w := tar.NewWriter(gw)
+	if err := w.WriteHeader(&tar.Header{Name: "guide/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader(&tar.Header{Name: "link.md", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range testMembers {
+		if err := w.WriteHeader(&tar.Header{Name: m.name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(m.content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(m.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveMembers(t *testing.T) {
+	dir := t.TempDir()
+	for name, write := range map[string]func(*testing.T, string){"docs.zip": writeZip, "docs.tar.gz": writeTarGz} {
+		path := filepath.Join(dir, name)
+		write(t, path)
+		var names []string
+		var tooLarge int
+		for m, err := range ArchiveMembers(path, 10, 10) {
+			if errors.Is(err, ErrMemberTooLarge) {
+				tooLarge++
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			names = append(names, m.Name)
+		}
+		if !slices.Equal(names, []string{"guide/index.md", "notes.txt"}) || tooLarge != 1 {
+			t.Fatalf("%s: unexpected members %v, %d too large", name, names, tooLarge)
+		}
+		var err error
+		for _, err = range ArchiveMembers(path, 1, 10) {
+		}
+		if !errors.Is(err, ErrTooManyMembers) {
+			t.Fatalf("%s: expected too many members error, got %v", name, err)
+		}
+		content, err := ReadArchiveMember(path, "guide/index.md", 10)
+		if err != nil || string(content) != "# Guide\n" {
+			t.Fatalf("%s: unexpected member content %q %v", name, content, err)
+		}
+		if _, err := ReadArchiveMember(path, "missing.md", 10); !errors.Is(err, ErrMemberNotFound) {
+			t.Fatalf("%s: expected not found error, got %v", name, err)
+		}
+	}
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	tests := []struct {
+		path, archive, member string
+		ok                    bool
+	}{
+		{"/docs/bundle.zip!/guide/index.md", "/docs/bundle.zip", "guide/index.md", true},
+		{"file:///docs/Bundle.TGZ!/a.md", "file:///docs/Bundle.TGZ", "a.md", true},
+		{"/docs/wow!/bundle.tar!/a.md", "/docs/wow!/bundle.tar", "a.md", true},
+		{"/docs/wow!/a.md", "", "", false},
+		{"/docs/bundle.zip", "", "", false},
+	}
+	for _, tt := range tests {
+		archive, member, ok := SplitArchivePath(tt.path)
+		if archive != tt.archive || member != tt.member || ok != tt.ok {
+			t.Errorf("%s: got %q %q %v", tt.path, archive, member, ok)
+		}
+	}
+	if p := ArchiveMemberPath("/docs/bundle.zip", "guide/index.md"); p != "/docs/bundle.zip!/guide/index.md" {
+		t.Errorf("unexpected member path %q", p)
+	}
+}
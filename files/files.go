@@ -117,41 +117,47 @@ func ShouldSkipDir(name string, excludes []string, includeHidden bool) bool {
 }
 
 // walkAndWatch registers all subdirectories of each configured directory with
-// the fsnotify watcher, skipping hidden dirs and user-configured excludes.
-func walkAndWatch(watcher *fsnotify.Watcher, dirs []*config.Directory) {
+// the fsnotify watcher, skipping hidden dirs, user-configured excludes and
+// directories matched by ignore files.
+func walkAndWatch(watcher *fsnotify.Watcher, dirs []*config.Directory, ignorers map[*config.Directory]*Ignorer) {
 	for _, dir := range dirs {
 		expanded := ExpandHome(dir.Path)
 		if err := watcher.Add(expanded); err != nil {
 			log.Error().Err(err).Str("path", expanded).Msg("Failed to add path to file watcher")
 		}
-		excludes := dir.Excludes
-		_ = filepath.WalkDir(expanded, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				log.Warn().Err(err).Str("path", path).Msg("Error walking directory")
-				return nil
-			}
-			if !d.IsDir() {
-				return nil
-			}
-			if path != expanded && shouldSkipDir(d.Name(), excludes, dir.IncludeHidden) {
-				return filepath.SkipDir
+		watchTree(watcher, expanded, dir, ignorers[dir], nil)
+	}
+}
+
+// watchTree registers the subdirectories of root with the watcher. If
+// callback is not nil, it is invoked with every matching file of the tree.
+func watchTree(watcher *fsnotify.Watcher, root string, dir *config.Directory, ig *Ignorer, callback func(string)) {
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Error walking directory")
+			return nil
+		}
+		if !d.IsDir() {
+			if callback != nil && dir.IsMatching(path) && !ig.Match(path, false) {
+				callback(path)
 			}
+			return nil
+		}
+		if path != root && (shouldSkipDir(d.Name(), dir.Excludes, dir.IncludeHidden) || ig.Match(path, true)) {
+			return filepath.SkipDir
+		}
+		if path != root {
 			if err := watcher.Add(path); err != nil {
 				log.Warn().Err(err).Str("path", path).Msg("Failed to watch subdirectory")
 			}
-			return nil
-		})
-	}
+		}
+		return nil
+	})
 }
 
-// handleWrite debounces a file-write event and invokes the callback after the
+// debounce invokes fn once no event has been seen for name during the
 // debounce period.
-func handleWrite(event fsnotify.Event, dirs []*config.Directory, mu *sync.Mutex, debounced map[string]*time.Timer, callback func(string)) {
-	dir := FindMatchingDir(dirs, event.Name)
-	if dir == nil || !dir.IsMatching(event.Name) {
-		return
-	}
-	name := event.Name
+func debounce(name string, mu *sync.Mutex, debounced map[string]*time.Timer, fn func()) {
 	mu.Lock()
 	if t, ok := debounced[name]; ok {
 		t.Reset(debounceTime)
@@ -160,22 +166,37 @@ func handleWrite(event fsnotify.Event, dirs []*config.Directory, mu *sync.Mutex,
 			mu.Lock()
 			delete(debounced, name)
 			mu.Unlock()
-			callback(name)
+			fn()
 		})
 	}
 	mu.Unlock()
 }
 
+// handleWrite debounces a file-write event and invokes the callback after the
+// debounce period.
+func handleWrite(event fsnotify.Event, dirs []*config.Directory, ignorers map[*config.Directory]*Ignorer, mu *sync.Mutex, debounced map[string]*time.Timer, callback func(string)) {
+	dir := FindMatchingDir(dirs, event.Name)
+	if dir == nil || !dir.IsMatching(event.Name) || ignorers[dir].Ignored(event.Name, false) {
+		return
+	}
+	name := event.Name
+	debounce(name, mu, debounced, func() { callback(name) })
+}
+
 // handleCreate processes a file or directory creation event: new directories
-// are added to the watcher, new files matching filters are passed to the callback.
-func handleCreate(event fsnotify.Event, dirs []*config.Directory, watcher *fsnotify.Watcher, callback func(string)) {
+// and their subdirectories are added to the watcher, new files matching
+// filters are passed to the callback.
+func handleCreate(event fsnotify.Event, dirs []*config.Directory, ignorers map[*config.Directory]*Ignorer, watcher *fsnotify.Watcher, callback func(string)) {
 	st, err := os.Stat(event.Name)
 	if err != nil {
 		return
 	}
+	dir := FindMatchingDir(dirs, event.Name)
+	if dir == nil {
+		return
+	}
 	if st.IsDir() {
-		dir := FindMatchingDir(dirs, event.Name)
-		if dir == nil || shouldSkipDir(filepath.Base(event.Name), dir.Excludes, dir.IncludeHidden) {
+		if shouldSkipDir(filepath.Base(event.Name), dir.Excludes, dir.IncludeHidden) || ignorers[dir].Ignored(event.Name, true) {
 			return
 		}
 		if !slices.Contains(watcher.WatchList(), event.Name) {
@@ -183,16 +204,68 @@ func handleCreate(event fsnotify.Event, dirs []*config.Directory, watcher *fsnot
 				log.Warn().Err(err).Str("path", event.Name).Msg("Failed to watch new directory")
 			}
 		}
+		// Directories moved into a watched directory arrive with content.
+		watchTree(watcher, event.Name, dir, ignorers[dir], callback)
 		return
 	}
-	dir := FindMatchingDir(dirs, event.Name)
-	if dir == nil || !dir.IsMatching(event.Name) {
+	if !dir.IsMatching(event.Name) || ignorers[dir].Ignored(event.Name, false) {
 		return
 	}
 	callback(event.Name)
 }
 
-func WatchDirectories(ctx context.Context, dirs []*config.Directory, callback func(string)) error {
+// handleIgnoreChange re-evaluates the directory of an ignore file after it
+// has been created, modified or removed: directories ignored now are no
+// longer watched, the directory is passed to reconcile to drop the files
+// ignored now, and directories and files no longer ignored are watched and
+// passed to the callback.
+func handleIgnoreChange(event fsnotify.Event, dirs []*config.Directory, ignorers map[*config.Directory]*Ignorer, watcher *fsnotify.Watcher, callback func(string), reconcile func(string)) {
+	dir := FindMatchingDir(dirs, event.Name)
+	if dir == nil {
+		return
+	}
+	ig := ignorers[dir]
+	parent := filepath.Dir(event.Name)
+	ig.Invalidate(parent)
+	if ig.Ignored(parent, true) {
+		return
+	}
+	log.Debug().Str("path", event.Name).Msg("Ignore file changed")
+	for _, p := range watcher.WatchList() {
+		if p != parent && HasPathPrefix(p, parent) && ig.Ignored(p, true) {
+			if err := watcher.Remove(p); err != nil {
+				log.Warn().Err(err).Str("path", p).Msg("Failed to unwatch ignored directory")
+			}
+		}
+	}
+	reconcile(parent)
+	watchTree(watcher, parent, dir, ig, callback)
+}
+
+// handleRemove stops watching a removed or renamed path and passes it to
+// the remove callback. Paths which exist again by the time the event is
+// handled, e.g. files replaced by editors saving atomically, are kept.
+func handleRemove(event fsnotify.Event, dirs []*config.Directory, watcher *fsnotify.Watcher, remove func(string)) {
+	if FindMatchingDir(dirs, event.Name) == nil {
+		return
+	}
+	if _, err := os.Lstat(event.Name); err == nil {
+		return
+	}
+	for _, p := range watcher.WatchList() {
+		if HasPathPrefix(p, event.Name) {
+			_ = watcher.Remove(p)
+		}
+	}
+	remove(event.Name)
+}
+
+// WatchDirectories watches dirs until ctx is cancelled. callback is invoked
+// with new and modified files, and remove with removed or renamed files and
+// directories. Renamed files are passed to callback under their new name.
+// reconcile is invoked with the directories whose ignore files changed, the
+// files ignored by now have to be removed from under them.
+func WatchDirectories(ctx context.Context, dirs []*config.Directory, callback func(string), remove func(string), reconcile func(string)) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return fmt.Errorf("failed to create file watcher: %w", err)
@@ -205,9 +278,13 @@ func WatchDirectories(ctx context.Context, dirs []*config.Directory, callback fu
 
 	var mu sync.Mutex
 	debounced := make(map[string]*time.Timer)
+	ignorers := make(map[*config.Directory]*Ignorer, len(dirs))
+	for _, dir := range dirs {
+		ignorers[dir] = NewIgnorer(ExpandHome(dir.Path))
+	}
 
 	log.Debug().Msg("Starting file watcher")
-	walkAndWatch(watcher, dirs)
+	walkAndWatch(watcher, dirs, ignorers)
 
 	for {
 		select {
@@ -218,10 +295,16 @@ func WatchDirectories(ctx context.Context, dirs []*config.Directory, callback fu
 				return nil
 			}
 			switch {
+			case IsIgnoreFile(event.Name) && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0:
+				debounce(event.Name, &mu, debounced, func() {
+					handleIgnoreChange(event, dirs, ignorers, watcher, callback, reconcile)
+				})
 			case event.Has(fsnotify.Write):
-				handleWrite(event, dirs, &mu, debounced, callback)
+				handleWrite(event, dirs, ignorers, &mu, debounced, callback)
 			case event.Has(fsnotify.Create):
-				handleCreate(event, dirs, watcher, callback)
+				handleCreate(event, dirs, ignorers, watcher, callback)
+			case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+				handleRemove(event, dirs, watcher, remove)
 			}
 		case err, ok := <-watcher.Errors:
 			if !ok {
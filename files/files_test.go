@@ -0,0 +1,162 @@
+package files
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/asciimoo/hister/config"
+)
+
+// newTestWatcher returns a watcher of the tree of dir.
+func newTestWatcher(t *testing.T, dir *config.Directory) *fsnotify.Watcher {
+	t.Helper()
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	walkAndWatch(w, []*config.Directory{dir}, map[*config.Directory]*Ignorer{dir: NewIgnorer(dir.Path)})
+	return w
+}
+
+func TestHandleRemove(t *testing.T) {
+	root := t.TempDir()
+	dir := &config.Directory{Path: root}
+	dirs := []*config.Directory{dir}
+	writeFile(t, filepath.Join(root, "a.txt"), "a")
+	writeFile(t, filepath.Join(root, "kept.txt"), "kept")
+	writeFile(t, filepath.Join(root, "sub", "deep", "b.txt"), "b")
+	w := newTestWatcher(t, dir)
+	if !slices.Contains(w.WatchList(), filepath.Join(root, "sub", "deep")) {
+		t.Fatalf("expected the subdirectories to be watched, got %v", w.WatchList())
+	}
+
+	var removed []string
+	remove := func(p string) { removed = append(removed, p) }
+
+	// deleted file
+	p := filepath.Join(root, "a.txt")
+	if err := os.Remove(p); err != nil {
+		t.Fatal(err)
+	}
+	handleRemove(fsnotify.Event{Name: p, Op: fsnotify.Remove}, dirs, w, remove)
+	if !slices.Equal(removed, []string{p}) {
+		t.Fatalf("expected %s to be removed, got %v", p, removed)
+	}
+
+	// files existing again, e.g. after an atomic save, are kept
+	removed = nil
+	p = filepath.Join(root, "kept.txt")
+	handleRemove(fsnotify.Event{Name: p, Op: fsnotify.Rename}, dirs, w, remove)
+	if len(removed) != 0 {
+		t.Fatalf("expected existing files to be kept, got %v", removed)
+	}
+
+	// paths outside of the configured directories are ignored
+	p = filepath.Join(t.TempDir(), "other.txt")
+	handleRemove(fsnotify.Event{Name: p, Op: fsnotify.Remove}, dirs, w, remove)
+	if len(removed) != 0 {
+		t.Fatalf("expected paths outside of the directories to be ignored, got %v", removed)
+	}
+
+	// deleted directory, its subdirectories are no longer watched
+	p = filepath.Join(root, "sub")
+	if err := os.RemoveAll(p); err != nil {
+		t.Fatal(err)
+	}
+	handleRemove(fsnotify.Event{Name: p, Op: fsnotify.Remove}, dirs, w, remove)
+	if !slices.Equal(removed, []string{p}) {
+		t.Fatalf("expected %s to be removed, got %v", p, removed)
+	}
+	for _, wp := range w.WatchList() {
+		if HasPathPrefix(wp, p) {
+			t.Fatalf("expected %s not to be watched after its removal", wp)
+		}
+	}
+}
+
+func TestHandleIgnoreChange(t *testing.T) {
+	root := t.TempDir()
+	dir := &config.Directory{Path: root}
+	dirs := []*config.Directory{dir}
+	writeFile(t, filepath.Join(root, "sub", "a.txt"), "a")
+	writeFile(t, filepath.Join(root, "sub", "build", "b.txt"), "b")
+	w := newTestWatcher(t, dir)
+	ignorers := map[*config.Directory]*Ignorer{dir: NewIgnorer(root)}
+
+	var reconciled, indexed []string
+	callback := func(p string) { indexed = append(indexed, p) }
+	reconcile := func(p string) { reconciled = append(reconciled, p) }
+
+	ignoreFile := filepath.Join(root, "sub", ".histerignore")
+	writeFile(t, ignoreFile, "build/\n")
+	handleIgnoreChange(fsnotify.Event{Name: ignoreFile, Op: fsnotify.Create}, dirs, ignorers, w, callback, reconcile)
+	if want := []string{filepath.Join(root, "sub")}; !slices.Equal(reconciled, want) {
+		t.Fatalf("expected %v to be reconciled, got %v", want, reconciled)
+	}
+	if slices.Contains(w.WatchList(), filepath.Join(root, "sub", "build")) {
+		t.Fatal("expected the ignored directory not to be watched")
+	}
+	if slices.Contains(indexed, filepath.Join(root, "sub", "build", "b.txt")) {
+		t.Fatal("expected the ignored file not to be indexed")
+	}
+}
+
+func TestWatchDirectoriesRename(t *testing.T) {
+	root := t.TempDir()
+	oldPath := filepath.Join(root, "old.txt")
+	newPath := filepath.Join(root, "new.txt")
+	writeFile(t, oldPath, "content")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	created := make(chan string, 10)
+	removed := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchDirectories(ctx, []*config.Directory{{Path: root}},
+			func(p string) { created <- p },
+			func(p string) { removed <- p },
+			func(string) {})
+	}()
+	// wait for the watcher to start by creating files until one is seen
+	deadline := time.After(5 * time.Second)
+	for n, started := 0, false; !started; n++ {
+		writeFile(t, filepath.Join(root, fmt.Sprintf("ready%d.txt", n)), "ready")
+		select {
+		case <-created:
+			started = true
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("file watcher did not start")
+		}
+	}
+
+	if err := os.Rename(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+	var gotCreated, gotRemoved bool
+	for !gotCreated || !gotRemoved {
+		select {
+		case p := <-created:
+			gotCreated = gotCreated || p == newPath
+		case p := <-removed:
+			if p != oldPath {
+				t.Fatalf("expected %s to be removed, got %s", oldPath, p)
+			}
+			gotRemoved = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected the rename to be reported, created: %v, removed: %v", gotCreated, gotRemoved)
+		}
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,227 @@
+package files
+
+import (
+	"bufio"
+	"iter"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// IgnoreFiles lists the names of the files holding ignore patterns, in order
+// of increasing precedence: patterns of .histerignore override the ones of
+// .ignore, which override .gitignore.
+var IgnoreFiles = []string{".gitignore", ".ignore", ".histerignore"}
+
+// IsIgnoreFile reports whether name is the name of an ignore file.
+func IsIgnoreFile(name string) bool {
+	return slices.Contains(IgnoreFiles, filepath.Base(name))
+}
+
+// ignoreRule is a single pattern of an ignore file.
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Ignorer matches paths against the ignore files found in a directory tree,
+// following the gitignore pattern format. The ignore files of each directory
+// are read once and cached until Invalidate is called.
+type Ignorer struct {
+	root  string
+	mu    sync.RWMutex
+	rules map[string][]ignoreRule
+}
+
+// NewIgnorer returns an Ignorer for the directory tree rooted at root. Ignore
+// files above root are not consulted.
+func NewIgnorer(root string) *Ignorer {
+	return &Ignorer{
+		root:  filepath.Clean(root),
+		rules: make(map[string][]ignoreRule),
+	}
+}
+
+// Invalidate drops the cached patterns of dir, e.g. after one of its ignore
+// files has changed.
+func (ig *Ignorer) Invalidate(dir string) {
+	ig.mu.Lock()
+	delete(ig.rules, filepath.Clean(dir))
+	ig.mu.Unlock()
+}
+
+// Match reports whether path is ignored by the ignore files of the
+// directories between the root and path. Directories containing path are
+// not checked, callers walking the tree skip ignored directories instead.
+func (ig *Ignorer) Match(path string, isDir bool) bool {
+	path = filepath.Clean(path)
+	if path == ig.root || !HasPathPrefix(path, ig.root) {
+		return false
+	}
+	ignored := false
+	// Patterns of deeper ignore files take precedence, so directories are
+	// evaluated from the root down and the last matching pattern wins.
+	for dir := range ig.parents(path) {
+		rel := filepath.ToSlash(strings.TrimPrefix(path, dir+string(filepath.Separator)))
+		for _, r := range ig.dirRules(dir) {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if r.re.MatchString(rel) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// Ignored reports whether path or one of the directories containing it is
+// ignored. Files in an ignored directory cannot be re-included, as in git.
+func (ig *Ignorer) Ignored(path string, isDir bool) bool {
+	path = filepath.Clean(path)
+	for dir := range ig.parents(path) {
+		if dir != ig.root && ig.Match(dir, true) {
+			return true
+		}
+	}
+	return ig.Match(path, isDir)
+}
+
+// parents yields the directories from the root down to the parent of path.
+func (ig *Ignorer) parents(path string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		rel, err := filepath.Rel(ig.root, filepath.Dir(path))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return
+		}
+		dir := ig.root
+		if !yield(dir) {
+			return
+		}
+		if rel == "." {
+			return
+		}
+		for part := range strings.SplitSeq(rel, string(filepath.Separator)) {
+			dir = filepath.Join(dir, part)
+			if !yield(dir) {
+				return
+			}
+		}
+	}
+}
+
+// dirRules returns the patterns of the ignore files of dir.
+func (ig *Ignorer) dirRules(dir string) []ignoreRule {
+	ig.mu.RLock()
+	rules, ok := ig.rules[dir]
+	ig.mu.RUnlock()
+	if ok {
+		return rules
+	}
+	for _, name := range IgnoreFiles {
+		rules = append(rules, readIgnoreFile(filepath.Join(dir, name))...)
+	}
+	ig.mu.Lock()
+	ig.rules[dir] = rules
+	ig.mu.Unlock()
+	return rules
+}
+
+// readIgnoreFile parses the patterns of an ignore file. Missing or
+// unreadable files have no patterns.
+func readIgnoreFile(path string) []ignoreRule {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close() //nolint:errcheck
+	var rules []ignoreRule
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if r, ok := parseIgnorePattern(s.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// parseIgnorePattern compiles a line of an ignore file. ok is false for
+// blank lines, comments and invalid patterns.
+func parseIgnorePattern(line string) (r ignoreRule, ok bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped with a backslash.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return r, false
+	}
+	if p, found := strings.CutPrefix(line, "!"); found {
+		r.negate = true
+		line = p
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if p, found := strings.CutSuffix(line, "/"); found {
+		r.dirOnly = true
+		line = p
+	}
+	if line == "" {
+		return r, false
+	}
+	// Patterns with a slash at the beginning or in the middle are relative
+	// to the directory of the ignore file, others match at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	var expr strings.Builder
+	expr.WriteString("^")
+	if !anchored {
+		expr.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "/**") && i+3 == len(line):
+			expr.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(line[i:], "**") && i+2 == len(line):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '\\' && i+1 < len(line):
+			i++
+			expr.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return r, false
+	}
+	r.re = re
+	return r, true
+}
@@ -0,0 +1,103 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseIgnorePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*.log", "debug.log", true},
+		{"*.log", "logs/debug.log", true},
+		{"*.log", "debug.log.txt", false},
+		{"/build", "build", true},
+		{"/build", "src/build", false},
+		{"doc/*.txt", "doc/notes.txt", true},
+		{"doc/*.txt", "doc/sub/notes.txt", false},
+		{"doc/**/*.txt", "doc/sub/deep/notes.txt", true},
+		{"doc/**/*.txt", "doc/notes.txt", true},
+		{"**/cache", "a/b/cache", true},
+		{"out/**", "out/a/b", true},
+		{"out/**", "out", false},
+		{"file?.md", "file1.md", true},
+		{"file[0-9].md", "file7.md", true},
+		{"file[!0-9].md", "file7.md", false},
+		{`\#notes`, "#notes", true},
+		{"name\\ ", "name ", true},
+	}
+	for _, tc := range tests {
+		r, ok := parseIgnorePattern(tc.pattern)
+		if !ok {
+			t.Fatalf("failed to parse %q", tc.pattern)
+		}
+		if got := r.re.MatchString(tc.path); got != tc.match {
+			t.Errorf("pattern %q on %q: expected %v, got %v", tc.pattern, tc.path, tc.match, got)
+		}
+	}
+	for _, line := range []string{"", "   ", "# comment", "!", "/"} {
+		if _, ok := parseIgnorePattern(line); ok {
+			t.Errorf("expected %q to be skipped", line)
+		}
+	}
+}
+
+func TestIgnorer(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), "*.log\nbuild/\n!keep.log\n")
+	writeFile(t, filepath.Join(root, "project", ".ignore"), "/generated\n")
+	writeFile(t, filepath.Join(root, "project", "sub", ".histerignore"), "!debug.log\n")
+	ig := NewIgnorer(root)
+
+	tests := []struct {
+		path  string
+		isDir bool
+		match bool
+	}{
+		{"app.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"project/generated", true, true},
+		{"project/src/generated", true, false},
+		{"project/sub/debug.log", false, false},
+		{"project/sub/other.log", false, true},
+		{"readme.md", false, false},
+	}
+	for _, tc := range tests {
+		if got := ig.Match(filepath.Join(root, tc.path), tc.isDir); got != tc.match {
+			t.Errorf("%s (dir: %v): expected %v, got %v", tc.path, tc.isDir, tc.match, got)
+		}
+	}
+
+	// Files cannot be re-included when their directory is ignored.
+	if !ig.Ignored(filepath.Join(root, "build", "keep.log"), false) {
+		t.Error("expected files of ignored directories to be ignored")
+	}
+	if ig.Ignored(filepath.Join(root, "project", "main.go"), false) {
+		t.Error("expected project/main.go not to be ignored")
+	}
+
+	writeFile(t, filepath.Join(root, ".gitignore"), "*.md\n")
+	if ig.Match(filepath.Join(root, "readme.md"), false) {
+		t.Error("expected cached patterns before invalidation")
+	}
+	ig.Invalidate(root)
+	if !ig.Match(filepath.Join(root, "readme.md"), false) || ig.Match(filepath.Join(root, "app.log"), false) {
+		t.Error("expected updated patterns after invalidation")
+	}
+}
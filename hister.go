@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"database/sql"
 	"encoding/csv"
@@ -16,8 +17,11 @@ import (
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 	_ "time/tzdata"
 
 	"github.com/asciimoo/hister/client"
@@ -27,8 +31,11 @@ import (
 	"github.com/asciimoo/hister/server/crawler"
 	"github.com/asciimoo/hister/server/document"
 	"github.com/asciimoo/hister/server/extractor"
+	"github.com/asciimoo/hister/server/feeds"
 	"github.com/asciimoo/hister/server/indexer"
+	"github.com/asciimoo/hister/server/linkcheck"
 	"github.com/asciimoo/hister/server/model"
+	"github.com/asciimoo/hister/server/refresher"
 	"github.com/asciimoo/hister/ui"
 
 	"github.com/charmbracelet/bubbles/textinput"
@@ -159,17 +166,77 @@ var listenCmd = &cobra.Command{
 			log.Warn().Msg("Using authentication token without https. Token is sent plain-text in network requests.")
 		}
 		if len(cfg.Indexer.Directories) > 0 {
-			indexer.IndexAll(cfg.Indexer.Directories)
+			go func() {
+				indexer.IndexAll(cfg.Indexer.Directories)
+				// the files deleted while the server was down are removed
+				// after the initial indexing, not to walk the directories
+				// twice at the same time
+				every := cfg.Indexer.ReconcileEvery()
+				if every <= 0 {
+					return
+				}
+				if err := indexer.RunReconcile(context.Background(), cfg.Indexer.Directories, every); err != nil {
+					log.Error().Err(err).Msg("Local file reconciliation failed")
+				}
+			}()
 			go func() {
 				if err := files.WatchDirectories(context.Background(), cfg.Indexer.Directories, func(path string) {
 					if err := indexer.IndexFile(path); err != nil {
 						log.Debug().Err(err).Str("path", path).Msg("Failed to index file")
 					}
+				}, func(path string) {
+					if n, err := indexer.RemoveFile(path); err != nil {
+						log.Warn().Err(err).Str("path", path).Msg("Failed to remove file from the index")
+					} else if n > 0 {
+						log.Debug().Int("documents", n).Str("path", path).Msg("Removed file from the index")
+					}
+				}, func(dir string) {
+					if n, err := indexer.ReconcileDirectory(cfg.Indexer.Directories, dir); err != nil {
+						log.Warn().Err(err).Str("path", dir).Msg("Failed to remove ignored files from the index")
+					} else if n > 0 {
+						log.Debug().Int("documents", n).Str("path", dir).Msg("Removed ignored files from the index")
+					}
 				}); err != nil {
 					log.Error().Err(err).Msg("File watcher failed")
 				}
 			}()
 		}
+		if len(cfg.Indexer.Mailboxes) > 0 {
+			go func() {
+				if err := indexer.RunMailboxes(context.Background(), cfg.Indexer.Mailboxes, cfg.Indexer.ReconcileEvery()); err != nil {
+					log.Error().Err(err).Msg("Mailbox indexing failed")
+				}
+			}()
+		}
+		if len(cfg.Indexer.Repositories) > 0 {
+			go func() {
+				if err := indexer.RunRepositories(context.Background(), cfg.Indexer.Repositories, cfg.Indexer.ReconcileEvery()); err != nil {
+					log.Error().Err(err).Msg("Repository indexing failed")
+				}
+			}()
+		}
+		cfg.Crawler.UserAgent = UserAgent
+		if cfg.Crawler.Refresh.Enabled() {
+			go func() {
+				if err := refresher.Run(context.Background(), cfg); err != nil {
+					log.Error().Err(err).Msg("Page refresher failed")
+				}
+			}()
+		}
+		if cfg.Crawler.LinkCheck.Every() > 0 {
+			go func() {
+				if err := linkcheck.Run(context.Background(), cfg); err != nil {
+					log.Error().Err(err).Msg("Link checker failed")
+				}
+			}()
+		}
+		if cfg.Crawler.Feeds.Every() > 0 {
+			go func() {
+				if err := feeds.Run(context.Background(), cfg); err != nil {
+					log.Error().Err(err).Msg("Feed polling failed")
+				}
+			}()
+		}
 		server.Version = Version
 		server.Listen(cfg)
 	},
@@ -311,6 +378,66 @@ func searchFilterMap(m map[string]any, fields []string) map[string]any {
 	return out
 }
 
+// printExplanation prints the ranking explanation of a query.
+func printExplanation(r *client.ExplainResult, format string) {
+	if format == "json" {
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			exit(1, "Failed to encode JSON: "+err.Error())
+		}
+		fmt.Println(string(b))
+		return
+	}
+	fmt.Printf("Query:    %s\n", r.Query)
+	if r.ResolvedQuery != r.Query {
+		fmt.Printf("Aliases:  %s\n", r.ResolvedQuery)
+	}
+	fmt.Printf("Parsed:   %s\n", r.ParsedQuery)
+	boosts := make([]string, 0, len(r.Boosts))
+	for _, f := range slices.Sorted(maps.Keys(r.Boosts)) {
+		boosts = append(boosts, fmt.Sprintf("%s^%g", f, r.Boosts[f]))
+	}
+	fmt.Printf("Boosts:   %s\n", strings.Join(boosts, " "))
+	fmt.Printf("Total:    %d\n", r.Total)
+	for _, h := range r.Hits {
+		fmt.Printf("\n%d. %s\n   %s\n", h.Rank, h.Title, h.URL)
+		if h.SearchRank > 0 {
+			fmt.Printf("   score %.4f, search rank %d\n", h.Score, h.SearchRank)
+		} else {
+			fmt.Println("   not matched by the query")
+		}
+		if h.History != nil {
+			fmt.Printf("   listed first: opened %d times from the results of this query\n", h.History.Count)
+		}
+		if hs := h.Hybrid; hs != nil {
+			fmt.Printf("   hybrid (%s): keyword %.4f, semantic %.4f, semantic weight %.2f\n", hs.Source, hs.Keyword, hs.Semantic, hs.Weight)
+		}
+		x := h.Explanation
+		if x == nil {
+			continue
+		}
+		fields := slices.SortedFunc(maps.Keys(x.Fields), func(a, b string) int {
+			return cmp.Compare(x.Fields[b], x.Fields[a])
+		})
+		parts := make([]string, 0, len(fields))
+		for _, f := range fields {
+			if f == "" {
+				parts = append(parts, fmt.Sprintf("other %.4f", x.Fields[f]))
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s %.4f", f, x.Fields[f]))
+		}
+		fmt.Printf("   fields: %s\n", strings.Join(parts, ", "))
+		for _, t := range x.Terms {
+			term := t.Field
+			if t.Term != "" {
+				term += ":" + t.Term
+			}
+			fmt.Printf("     %-40s ^%-4g %.4f\n", term, t.Boost, t.Score)
+		}
+	}
+}
+
 var searchCmd = &cobra.Command{
 	Use:   "search [search terms]",
 	Short: "Command line search interface",
@@ -326,6 +453,20 @@ var searchCmd = &cobra.Command{
 		qs := strings.Join(args, " ")
 		format, _ := cmd.Flags().GetString("format")
 		limit, _ := cmd.Flags().GetInt("limit")
+		allVersions, _ := cmd.Flags().GetBool("all-versions")
+		sortBy, _ := cmd.Flags().GetString("sort")
+
+		if explain, _ := cmd.Flags().GetBool("explain"); explain {
+			if format != "text" && format != "json" {
+				exit(1, "--explain supports the text and json formats only")
+			}
+			res, err := newClient().Explain(qs, limit)
+			if err != nil {
+				exit(1, "Explain failed: "+err.Error())
+			}
+			printExplanation(res, format)
+			return
+		}
 
 		// Parse and validate --fields.
 		var fields []string
@@ -411,7 +552,7 @@ var searchCmd = &cobra.Command{
 			done    bool
 		)
 		for !done {
-			res, err := c.Search(&indexer.Query{Text: qs, IncludeHTML: includeHTML, PageKey: pageKey})
+			res, err := c.Search(&indexer.Query{Text: qs, IncludeHTML: includeHTML, PageKey: pageKey, AllVersions: allVersions, Sort: sortBy})
 			if err != nil {
 				exit(1, "Search failed: "+err.Error())
 			}
@@ -474,6 +615,12 @@ var indexCmd = &cobra.Command{
 		jobID, _ := cmd.Flags().GetString("job-id")
 		cfg.Crawler.UserAgent = UserAgent
 		applyCrawlerBackendFlags(cmd)
+		if n, _ := cmd.Flags().GetInt("concurrency"); n > 0 {
+			cfg.Crawler.Concurrency = n
+		}
+		if ignore, _ := cmd.Flags().GetBool("ignore-robots"); ignore {
+			cfg.Crawler.IgnoreRobots = true
+		}
 
 		if recursive {
 			// Persistent crawl mode (always).
@@ -528,6 +675,11 @@ var indexCmd = &cobra.Command{
 					exit(1, "Failed to create crawl job: "+err.Error())
 				}
 				fmt.Println("Starting crawl job:", jobID)
+				if sitemap, _ := cmd.Flags().GetString("sitemap"); sitemap != "" {
+					if err := seedFromSitemaps(jobID, startURL, sitemap, clientOpts...); err != nil {
+						exit(1, "Failed to seed crawl from sitemaps: "+err.Error())
+					}
+				}
 			} else {
 				// Resume existing job.
 				startURL = existingJob.StartURL
@@ -554,6 +706,11 @@ var indexCmd = &cobra.Command{
 			}
 			validator.SetVisited(int(done + failed))
 
+			refresh, err := crawlRefreshSet(jobID)
+			if err != nil {
+				exit(1, "Failed to load the pages to refresh: "+err.Error())
+			}
+
 			cr, err := crawler.NewPersistent(&cfg.Crawler, jobID)
 			if err != nil {
 				exit(1, "Failed to initialize persistent crawler: "+err.Error())
@@ -564,7 +721,7 @@ var indexCmd = &cobra.Command{
 				}
 			}()
 
-			if err := crawlAndIndex(startURL, cr, validator, force, clientOpts...); err != nil {
+			if err := crawlAndIndex(startURL, cr, validator, force, refresh, clientOpts...); err != nil {
 				exit(1, "Crawl failed: "+err.Error())
 			}
 			return
@@ -601,6 +758,11 @@ var indexCmd = &cobra.Command{
 			}
 			validator.SetVisited(int(done + failed))
 
+			refresh, err := crawlRefreshSet(jobID)
+			if err != nil {
+				exit(1, "Failed to load the pages to refresh: "+err.Error())
+			}
+
 			cr, err := crawler.NewPersistent(&cfg.Crawler, jobID)
 			if err != nil {
 				exit(1, "Failed to initialize persistent crawler: "+err.Error())
@@ -611,7 +773,7 @@ var indexCmd = &cobra.Command{
 				}
 			}()
 
-			if err := crawlAndIndex(existingJob.StartURL, cr, validator, force, clientOpts...); err != nil {
+			if err := crawlAndIndex(existingJob.StartURL, cr, validator, force, refresh, clientOpts...); err != nil {
 				exit(1, "Crawl failed: "+err.Error())
 			}
 			return
@@ -654,6 +816,10 @@ func init() {
 	indexCmd.Flags().String("backend", "", "Crawler backend to use (\"http\" or \"chromedp\")")
 	indexCmd.Flags().StringToString("backend-option", nil, "Crawler backend option as key=value (repeatable, e.g. --backend-option exec_path=/usr/bin/chromium)")
 	indexCmd.Flags().StringToString("header", nil, "Extra HTTP header as KEY=VALUE (repeatable, e.g. --header Accept-Language=en)")
+	indexCmd.Flags().String("sitemap", "", "Seed a new recursive crawl with the pages of this sitemap; without a value the sitemaps are discovered via robots.txt")
+	indexCmd.Flags().Lookup("sitemap").NoOptDefVal = "auto"
+	indexCmd.Flags().Bool("ignore-robots", false, "Do not honour robots.txt rules and crawl delays (only use on sites you own)")
+	indexCmd.Flags().Int("concurrency", 0, "Number of pages fetched in parallel during a recursive crawl (0 = use config value)")
 	indexCmd.Flags().StringArray("cookie", nil, "HTTP cookie as Set-Cookie value (repeatable, e.g. --cookie \"session=abc; Domain=example.com\")")
 }
 
@@ -669,6 +835,7 @@ Examples:
   hister delete "url:file:///home/user/file.pdf"
   hister delete "domain:example.com"
   hister delete "language:en domain:example.com"
+  hister delete "domain:example.com added:<2024-01-01"
 
 Non-admin users are restricted to their own documents by the server.`,
 	Args: cobra.ExactArgs(1),
@@ -954,6 +1121,113 @@ var reindexCmd = &cobra.Command{
 	},
 }
 
+var checkLinksCmd = &cobra.Command{
+	Use:   "check-links",
+	Short: "Detect dead links",
+	Long: `Probe the indexed web pages and record whether they are still available.
+Dead pages can be listed afterwards with the "status:dead" query filter.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ma, _ := cmd.Flags().GetString("max-age")
+		maxAge, err := config.ParseInterval(ma)
+		if err != nil {
+			exit(1, "Invalid --max-age: "+err.Error())
+		}
+		c := newClient()
+		p, err := c.StartLinkCheck(maxAge)
+		if errors.Is(err, linkcheck.ErrRunning) {
+			fmt.Println("A link check is already running, waiting for it to finish")
+		} else if err != nil {
+			msg := "Link check error: " + err.Error()
+			if isConnectionError(err) {
+				msg += "\n  Make sure the Hister server is running before executing check-links."
+			}
+			exit(1, msg)
+		}
+		// the check runs on the server, its progress is polled until it
+		// finishes
+		for p.Running {
+			time.Sleep(time.Second)
+			if p, err = c.LinkCheckStatus(); err != nil {
+				exit(1, "Link check error: "+err.Error())
+			}
+		}
+		if p.Error != "" {
+			exit(1, "Link check error: "+p.Error)
+		}
+		fmt.Printf("%s Checked %d pages: %d alive, %d dead, %d unknown\n", cliSuccessStyle.Render("✓"), p.Checked, p.Alive, p.Dead, p.Unknown)
+	},
+}
+
+var feedCmd = &cobra.Command{
+	Use:   "feed",
+	Short: "Manage feed subscriptions",
+	Long: `Manage RSS, Atom and JSON feed subscriptions. New entries of the subscribed
+feeds are indexed by "hister listen", see crawler.feeds in the configuration.`,
+}
+
+var feedAddCmd = &cobra.Command{
+	Use:   "add URL",
+	Short: "Subscribe to a feed",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		fetchPages, _ := cmd.Flags().GetBool("fetch-pages")
+		f, err := newClient().AddFeed(args[0], fetchPages)
+		if err != nil {
+			exitFeedError(err)
+		}
+		fmt.Printf("%s Subscribed to %s\n", cliSuccessStyle.Render("✓"), f.URL)
+	},
+}
+
+var feedListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List feed subscriptions",
+	Args:  cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		feeds, err := newClient().ListFeeds()
+		if err != nil {
+			exitFeedError(err)
+		}
+		if len(feeds) == 0 {
+			fmt.Println("No feed subscriptions found.")
+			return
+		}
+		for _, f := range feeds {
+			fmt.Printf("%s  %s\n", cliInfoStyle.Render(f.URL), f.Title)
+			checked := "never"
+			if !f.CheckedAt.IsZero() {
+				checked = f.CheckedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("  fetch pages: %t  configured: %t  checked: %s\n", f.FetchPages, f.Configured, checked)
+			if f.Error != "" {
+				fmt.Printf("  error: %s\n", f.Error)
+			}
+		}
+	},
+}
+
+var feedDeleteCmd = &cobra.Command{
+	Use:   "delete URL",
+	Short: "Unsubscribe from a feed",
+	Long:  "Remove a feed subscription. The entries indexed from the feed are kept.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		if err := newClient().DeleteFeed(args[0]); err != nil {
+			exitFeedError(err)
+		}
+		fmt.Printf("%s Unsubscribed from %s\n", cliSuccessStyle.Render("✓"), args[0])
+	},
+}
+
+func exitFeedError(err error) {
+	msg := "Feed error: " + err.Error()
+	if isConnectionError(err) {
+		msg += "\n  Make sure the Hister server is running before managing feeds."
+	}
+	exit(1, msg)
+}
+
 func exit(errno int, msg string) {
 	if errno != 0 {
 		fmt.Println(cliErrorStyle.Render("Error!") + " " + msg)
@@ -984,6 +1258,11 @@ func init() {
 	rootCmd.AddCommand(importCmd)
 	rootCmd.AddCommand(searchCmd)
 	rootCmd.AddCommand(reindexCmd)
+	rootCmd.AddCommand(checkLinksCmd)
+	rootCmd.AddCommand(feedCmd)
+	feedCmd.AddCommand(feedAddCmd)
+	feedCmd.AddCommand(feedListCmd)
+	feedCmd.AddCommand(feedDeleteCmd)
 	rootCmd.AddCommand(deleteCmd)
 	rootCmd.AddCommand(createUserCmd)
 	rootCmd.AddCommand(deleteUserCmd)
@@ -1016,11 +1295,17 @@ func init() {
 
 	showUserCmd.Flags().Bool("token", false, "display the user's access token")
 
+	feedAddCmd.Flags().Bool("fetch-pages", false, "index the pages linked by the entries instead of the content of the entries")
+
+	checkLinksCmd.Flags().String("max-age", "", "Only check pages not checked within this interval (e.g. 12h or 7d); all pages are checked by default")
 	reindexCmd.Flags().BoolP("exclude-sensitive", "x", false, "don't add documents that contain sensitive content matched by config.SensitiveContentPatterns")
 
 	searchCmd.Flags().StringP("format", "f", "text", "output format: text, json, csv")
 	searchCmd.Flags().StringP("fields", "F", "", "comma-separated list of document fields to display (id, url, title, domain, score, added, language, type, text, favicon, user_id, html)")
 	searchCmd.Flags().IntP("limit", "L", 0, "maximum number of results to display (0 means no limit)")
+	searchCmd.Flags().Bool("all-versions", false, "also search stored older versions of re-indexed pages (requires indexer.keep_versions)")
+	searchCmd.Flags().String("sort", "", "sort the results by added, title, url, domain or metadata.<key> instead of relevance, prefix with - for descending order (e.g. -added for the newest first)")
+	searchCmd.Flags().Bool("explain", false, "explain the ranking of the results: parsed query, resolved aliases, field boosts and per-field scores")
 
 	cobra.OnInitialize(initialize)
 
@@ -1130,7 +1415,7 @@ func initDB() {
 }
 
 func initExtractor() {
-	if err := extractor.Init(cfg.Extractors); err != nil {
+	if err := extractor.Init(cfg.Extractors, cfg.SiteExtractors, cfg.ExtractorPlugins); err != nil {
 		exit(1, "Extractor initialization error: "+err.Error())
 	}
 }
@@ -1299,7 +1584,11 @@ func indexURL(u string, clientOpts ...client.Option) error {
 		return nil
 	}
 	cfg.Crawler.UserAgent = UserAgent
-	cr, err := crawler.New(&cfg.Crawler)
+	// Fetching a single, explicitly requested page is user-triggered, so
+	// robots.txt does not apply.
+	ccfg := cfg.Crawler
+	ccfg.IgnoreRobots = true
+	cr, err := crawler.New(&ccfg)
 	if err != nil {
 		return fmt.Errorf("failed to create crawler: %w", err)
 	}
@@ -1335,14 +1624,17 @@ func indexURL(u string, clientOpts ...client.Option) error {
 	return nil
 }
 
-func crawlAndIndex(startURL string, cr crawler.Crawler, v *crawler.Validator, force bool, clientOpts ...client.Option) error {
+// crawlAndIndex indexes the pages found by cr. Already indexed pages are
+// skipped unless force is set or their URL is in refresh.
+func crawlAndIndex(startURL string, cr crawler.Crawler, v *crawler.Validator, force bool, refresh map[string]struct{}, clientOpts ...client.Option) error {
 	ch, err := cr.Crawl(context.Background(), startURL, v)
 	if err != nil {
 		return err
 	}
 	c := newClient(clientOpts...)
 	for doc := range ch {
-		if !force {
+		_, stale := refresh[doc.URL]
+		if !force && !stale {
 			exists, err := c.DocumentExists(doc.URL)
 			if err != nil {
 				log.Warn().Err(err).Str("url", doc.URL).Msg("failed to check if URL is already indexed")
@@ -1367,6 +1659,102 @@ func crawlAndIndex(startURL string, cr crawler.Crawler, v *crawler.Validator, fo
 	return nil
 }
 
+// seedFromSitemaps queues the pages listed in the sitemaps of a new crawl
+// job. sitemap is either a sitemap URL or "auto" to discover the sitemaps
+// through robots.txt. Indexed pages without <lastmod> are requested with
+// HEAD for their Last-Modified header instead. Pages indexed after their
+// last modification are recorded as skipped, the ones modified since are
+// queued to be refreshed.
+func seedFromSitemaps(jobID, startURL, sitemap string, clientOpts ...client.Option) error {
+	ctx := context.Background()
+	sitemaps := []string{sitemap}
+	if sitemap == "auto" {
+		var err error
+		if sitemaps, err = crawler.DiscoverSitemaps(ctx, &cfg.Crawler, startURL); err != nil {
+			return err
+		}
+	}
+	entries, err := crawler.FetchSitemaps(ctx, &cfg.Crawler, sitemaps)
+	if err != nil {
+		return err
+	}
+	added := documentsAdded(newClient(clientOpts...), entries)
+	var undated []string
+	for n, e := range entries {
+		if e.LastMod.IsZero() && !added[n].IsZero() {
+			undated = append(undated, e.URL)
+		}
+	}
+	if len(undated) > 0 {
+		modified, err := crawler.LastModified(ctx, &cfg.Crawler, undated)
+		if err != nil {
+			return err
+		}
+		for n := range entries {
+			if t, ok := modified[entries[n].URL]; ok {
+				entries[n].LastMod = t
+			}
+		}
+	}
+	for n, e := range entries {
+		var err error
+		switch {
+		case e.LastMod.IsZero() || added[n].IsZero():
+			err = model.InsertCrawlURLIfNotExists(jobID, e.URL, 0)
+		case added[n].Before(e.LastMod):
+			err = model.InsertCrawlURLRefresh(jobID, e.URL, 0)
+		default:
+			err = model.InsertCrawlURLSkipped(jobID, e.URL, 0, "not modified since last indexed")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Queued %d pages from %d sitemap(s)\n", len(entries), len(sitemaps))
+	return nil
+}
+
+// crawlRefreshSet returns the pages of the crawl job queued to be indexed
+// again although they are already indexed.
+func crawlRefreshSet(jobID string) (map[string]struct{}, error) {
+	urls, err := model.ListCrawlURLsToRefresh(jobID)
+	if err != nil {
+		return nil, err
+	}
+	refresh := make(map[string]struct{}, len(urls))
+	for _, u := range urls {
+		refresh[u] = struct{}{}
+	}
+	return refresh, nil
+}
+
+// documentsAdded returns the time each sitemap entry was last indexed, zero
+// for pages which are not indexed. The server is queried by
+// cfg.Crawler.Concurrency parallel requests.
+func documentsAdded(c *client.Client, entries []crawler.SitemapEntry) []time.Time {
+	added := make([]time.Time, len(entries))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range max(cfg.Crawler.Concurrency, 1) {
+		wg.Go(func() {
+			for n := range next {
+				t, err := c.DocumentAdded(entries[n].URL)
+				if err != nil {
+					log.Warn().Err(err).Str("url", entries[n].URL).Msg("failed to check when URL was indexed")
+					continue
+				}
+				added[n] = t
+			}
+		})
+	}
+	for n := range entries {
+		next <- n
+	}
+	close(next)
+	wg.Wait()
+	return added
+}
+
 func importHistory(cmd *cobra.Command, args []string) {
 	// TODO: get skip rules from server
 	cfg.Crawler.UserAgent = UserAgent
@@ -75,6 +75,19 @@ func init() {
 			Handler:      serveSuggest,
 			Description:  "OpenSearch suggestions endpoint",
 		},
+		{
+			Name:         "Explain",
+			Path:         "/api/explain",
+			Method:       GET,
+			CSRFRequired: false,
+			Handler:      serveExplain,
+			Description:  "Explain the ranking of the results of a search query",
+			Args: []*EndpointArg{
+				{Name: "q", Type: "string", Required: true, Description: "Search query"},
+				{Name: "limit", Type: "int", Required: false, Description: "Maximum number of results to explain (default 100)"},
+				{Name: "semantic", Type: "bool", Required: false, Description: "Enable semantic search when the server supports it"},
+			},
+		},
 		// tmp added for backward compatibility
 		{
 			Name:         "Add",
@@ -183,6 +196,34 @@ func init() {
 			CSRFRequired: false,
 			Handler:      servePreview,
 			Description:  "Document preview",
+			Args: []*EndpointArg{
+				{Name: "url", Type: "string", Required: true, Description: "URL of the document"},
+				{Name: "version", Type: "int", Required: false, Description: "Preview a stored version instead of the latest one"},
+				{Name: "diff", Type: "int", Required: false, Description: "Include a line diff from the given version to the previewed one"},
+			},
+		},
+		{
+			Name:         "Versions",
+			Path:         "/api/versions",
+			Method:       GET,
+			CSRFRequired: false,
+			Handler:      serveVersions,
+			Description:  "List stored versions of a document",
+			Args: []*EndpointArg{
+				{Name: "url", Type: "string", Required: true, Description: "URL of the document"},
+			},
+		},
+		{
+			Name:         "Version",
+			Path:         "/api/version",
+			Method:       GET,
+			CSRFRequired: false,
+			Handler:      serveVersion,
+			Description:  "Get a specific version of a document",
+			Args: []*EndpointArg{
+				{Name: "url", Type: "string", Required: true, Description: "URL of the document"},
+				{Name: "version", Type: "int", Required: true, Description: "Version number as listed by /api/versions, 0 is the current document"},
+			},
 		},
 		{
 			Name:         "Extractors",
@@ -201,6 +242,14 @@ func init() {
 			Handler:      serveStats,
 			Description:  "Search engine statistics",
 		},
+		{
+			Name:         "Indexing",
+			Path:         "/api/indexing",
+			Method:       GET,
+			CSRFRequired: false,
+			Handler:      serveIndexing,
+			Description:  "Progress of indexing the configured local directories",
+		},
 		{
 			Name:         "File",
 			Path:         "/api/file",
@@ -213,7 +262,13 @@ func init() {
 					Name:        "path",
 					Type:        "string",
 					Required:    true,
-					Description: "Absolute path to the file",
+					Description: "Absolute path to the file, members of archives are addressed as archive.zip!/member/path",
+				},
+				{
+					Name:        "highlight",
+					Type:        "bool",
+					Required:    false,
+					Description: "Render source code files as syntax-highlighted HTML",
 				},
 			},
 		},
@@ -241,6 +296,58 @@ func init() {
 				{Name: "detectLanguages", Type: "bool", Required: false, Description: "Enable language detection during reindex"},
 			},
 		},
+		{
+			Name:         "Check links",
+			Path:         "/api/check-links",
+			Method:       POST,
+			CSRFRequired: true,
+			AdminOnly:    true,
+			Handler:      serveCheckLinks,
+			Description:  "Start probing indexed web pages and recording dead links, answers 409 while a check is running",
+			Args: []*EndpointArg{
+				{Name: "maxAge", Type: "int", Required: false, Description: "Only check pages not checked for this many seconds (0 checks all)"},
+			},
+		},
+		{
+			Name:         "Link check status",
+			Path:         "/api/check-links",
+			Method:       GET,
+			CSRFRequired: false,
+			AdminOnly:    true,
+			Handler:      serveLinkCheckStatus,
+			Description:  "Progress of the current or last link check",
+		},
+		{
+			Name:         "Feeds",
+			Path:         "/api/feeds",
+			Method:       GET,
+			CSRFRequired: false,
+			Handler:      serveFeeds,
+			Description:  "List feed subscriptions",
+		},
+		{
+			Name:         "Subscribe to feed",
+			Path:         "/api/feeds",
+			Method:       POST,
+			CSRFRequired: true,
+			Handler:      serveAddFeed,
+			Description:  "Subscribe to an RSS, Atom or JSON feed whose new entries are indexed",
+			Args: []*EndpointArg{
+				{Name: "url", Type: "string", Required: true, Description: "URL of the feed"},
+				{Name: "fetchPages", Type: "bool", Required: false, Description: "Index the pages linked by the entries instead of the content of the entries"},
+			},
+		},
+		{
+			Name:         "Unsubscribe from feed",
+			Path:         "/api/feeds/delete",
+			Method:       POST,
+			CSRFRequired: true,
+			Handler:      serveDeleteFeed,
+			Description:  "Remove a feed subscription, the indexed entries are kept",
+			Args: []*EndpointArg{
+				{Name: "url", Type: "string", Required: true, Description: "URL of the feed"},
+			},
+		},
 		{
 			Name:         "API",
 			Path:         "/api",
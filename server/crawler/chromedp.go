@@ -60,7 +60,7 @@ func newChromedpFetcher(cfg *config.CrawlerConfig) (*chromedpFetcher, error) {
 	}, nil
 }
 
-func (f *chromedpFetcher) fetchPage(ctx context.Context, rawURL string) (string, string, []string, error) {
+func (f *chromedpFetcher) fetchPage(ctx context.Context, rawURL string) (*page, error) {
 	taskCtx, taskCancel := chromedp.NewContext(f.allocCtx)
 	defer taskCancel()
 
@@ -113,13 +113,13 @@ func (f *chromedpFetcher) fetchPage(ctx context.Context, rawURL string) (string,
 	)
 
 	if err := chromedp.Run(timeoutCtx, actions...); err != nil {
-		return "", "", nil, err
+		return nil, err
 	}
 
 	if finalURL == "" {
 		finalURL = rawURL
 	}
-	return finalURL, htmlContent, linkHrefs, nil
+	return &page{finalURL: finalURL, html: htmlContent, links: linkHrefs}, nil
 }
 
 func (f *chromedpFetcher) close() error {
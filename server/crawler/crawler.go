@@ -28,14 +28,27 @@ type Crawler interface {
 }
 
 // fetcher is the internal interface implemented by each scraping backend.
-// fetchPage downloads rawURL and returns the final URL after any redirects,
-// its HTML content together with the raw href values of all anchor tags found
-// on the page.
+// fetchPage downloads rawURL and returns its content together with the final
+// URL after any redirects.
 type fetcher interface {
-	fetchPage(ctx context.Context, rawURL string) (finalURL string, htmlContent string, links []string, err error)
+	fetchPage(ctx context.Context, rawURL string) (*page, error)
 	close() error
 }
 
+// page is a downloaded document.
+type page struct {
+	finalURL string
+	html     string
+	// raw and contentType hold the body of binary documents, e.g. PDF
+	// files, which are left to the extractors.
+	raw         []byte
+	contentType string
+	// links are the raw href values of all anchor tags of HTML pages.
+	links []string
+	// lastModified is the Last-Modified header of HEAD responses.
+	lastModified time.Time
+}
+
 // baseCrawler wraps a fetcher with BFS traversal logic.
 type baseCrawler struct {
 	fetcher fetcher
@@ -87,59 +100,64 @@ type queueItem struct {
 }
 
 func (c *baseCrawler) bfsCrawl(ctx context.Context, startURL string, v *Validator, ch chan<- *document.Document) {
-	queue := []queueItem{{startURL, 0}}
+	ctx, cancel := context.WithCancel(ctx)
+	p := newCrawlPool(c.fetcher, c.cfg)
+	p.start(ctx)
+	defer func() {
+		// Abort fetches still in flight before waiting for the workers.
+		cancel()
+		p.close()
+	}()
+
 	seen := map[string]struct{}{startURL: {}}
+	if err := p.push(&crawlTask{queueItem: queueItem{startURL, 0}}); err != nil {
+		return
+	}
+	validate := func(t *crawlTask) URLStatus {
+		return v.Validate(t.parsed, t.depth)
+	}
 
-	for len(queue) > 0 {
-		select {
-		case <-ctx.Done():
+	stopped := false
+	for {
+		if !stopped {
+			stopped = p.dispatch(validate)
+		}
+		if p.inflight == 0 && (stopped || p.queued == 0) {
 			return
-		default:
 		}
-
-		cur := queue[0]
-		queue = queue[1:]
-
-		parsedURL, err := url.Parse(cur.rawURL)
+		r, err := p.wait(ctx)
 		if err != nil {
-			continue
-		}
-
-		switch v.Validate(parsedURL, cur.depth) {
-		case URLStop:
 			return
-		case URLSkip:
+		}
+		if r == nil {
 			continue
 		}
-
-		if c.cfg.Delay > 0 {
-			select {
-			case <-time.After(time.Duration(c.cfg.Delay) * time.Second):
-			case <-ctx.Done():
-				return
-			}
+		cur := r.task
+		if r.skip != "" {
+			log.Info().Str("url", cur.rawURL).Str("reason", r.skip).Msg("crawler: skipping page")
+			continue
 		}
-
-		finalURL, htmlContent, links, err := c.fetcher.fetchPage(ctx, cur.rawURL)
-		if err != nil {
-			log.Warn().Err(err).Str("url", cur.rawURL).Msg("crawler: failed to fetch page")
+		if r.err != nil {
+			log.Warn().Err(r.err).Str("url", cur.rawURL).Msg("crawler: failed to fetch page")
 			continue
 		}
 
 		// If the server redirected to a different URL, mark it seen so it
 		// won't be queued again (e.g. /path/ -> /path). Use the final URL
 		// for the document and as the base for link resolution.
-		if finalURL != cur.rawURL {
-			seen[finalURL] = struct{}{}
+		if r.finalURL != cur.rawURL {
+			seen[r.finalURL] = struct{}{}
 		}
-		finalParsed, err := url.Parse(finalURL)
+		finalParsed, err := url.Parse(r.finalURL)
 		if err != nil {
-			finalParsed = parsedURL
+			finalParsed = cur.parsed
 		}
 
 		doc := &document.Document{
-			URL:  finalURL,
-			HTML: htmlContent,
+			URL:         r.finalURL,
+			HTML:        r.html,
+			Raw:         r.raw,
+			ContentType: r.contentType,
 		}
 
 		select {
@@ -148,14 +166,20 @@ func (c *baseCrawler) bfsCrawl(ctx context.Context, startURL string, v *Validato
 			return
 		}
 
-		for _, link := range links {
+		if stopped {
+			continue
+		}
+		for _, link := range r.links {
 			abs, err := resolveURL(finalParsed, link)
 			if err != nil || abs == "" {
 				continue
 			}
-			if _, exists := seen[abs]; !exists {
-				seen[abs] = struct{}{}
-				queue = append(queue, queueItem{abs, cur.depth + 1})
+			if _, exists := seen[abs]; exists {
+				continue
+			}
+			seen[abs] = struct{}{}
+			if err := p.push(&crawlTask{queueItem: queueItem{abs, cur.depth + 1}}); err != nil {
+				log.Debug().Err(err).Str("url", abs).Msg("crawler: invalid link")
 			}
 		}
 	}
@@ -6,9 +6,11 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -30,12 +32,34 @@ func newHTTPFetcher(cfg *config.CrawlerConfig) (*httpFetcher, error) {
 		return nil, fmt.Errorf("http backend: unknown option %q", k)
 	}
 
+	jar, err := newCookieJar(cfg.Cookies)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	return &httpFetcher{
+		client: &http.Client{
+			Timeout: timeout,
+			Jar:     jar,
+		},
+		userAgent: cfg.UserAgent,
+		headers:   cfg.Headers,
+	}, nil
+}
+
+// newCookieJar returns a cookie jar holding the configured cookies.
+func newCookieJar(cookies []config.CrawlerCookie) (*cookiejar.Jar, error) {
 	jar, err := cookiejar.New(nil)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, ck := range cfg.Cookies {
+	for _, ck := range cookies {
 		cookiePath := ck.Path
 		if cookiePath == "" {
 			cookiePath = "/"
@@ -51,26 +75,13 @@ func newHTTPFetcher(cfg *config.CrawlerConfig) (*httpFetcher, error) {
 			Path:   cookiePath,
 		}})
 	}
-
-	timeout := time.Duration(cfg.Timeout) * time.Second
-	if timeout == 0 {
-		timeout = defaultTimeout
-	}
-
-	return &httpFetcher{
-		client: &http.Client{
-			Timeout: timeout,
-			Jar:     jar,
-		},
-		userAgent: cfg.UserAgent,
-		headers:   cfg.Headers,
-	}, nil
+	return jar, nil
 }
 
-func (f *httpFetcher) fetchPage(ctx context.Context, rawURL string) (string, string, []string, error) {
+func (f *httpFetcher) fetchPage(ctx context.Context, rawURL string) (*page, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
-		return "", "", nil, err
+		return nil, err
 	}
 
 	if f.userAgent != "" {
@@ -82,7 +93,7 @@ func (f *httpFetcher) fetchPage(ctx context.Context, rawURL string) (string, str
 
 	resp, err := f.client.Do(req)
 	if err != nil {
-		return "", "", nil, err
+		return nil, err
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -91,22 +102,83 @@ func (f *httpFetcher) fetchPage(ctx context.Context, rawURL string) (string, str
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", "", nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
 	}
 
 	ct := resp.Header.Get("Content-Type")
-	if !strings.Contains(ct, "html") {
-		return "", "", nil, fmt.Errorf("not an HTML response: %s", ct)
+	binary := isBinaryDocument(ct)
+	if !binary && !strings.Contains(ct, "html") {
+		return nil, fmt.Errorf("not an HTML response: %s", ct)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", "", nil, err
+		return nil, err
 	}
 
-	htmlContent := string(body)
-	finalURL := resp.Request.URL.String()
-	return finalURL, htmlContent, extractLinks(htmlContent), nil
+	p := &page{finalURL: resp.Request.URL.String()}
+	if binary {
+		p.raw, p.contentType = body, ct
+		return p, nil
+	}
+	p.html = string(body)
+	p.links = extractLinks(p.html)
+	return p, nil
+}
+
+// binaryDocumentTypes are the non-HTML content types fetched for the
+// extractors.
+var binaryDocumentTypes = []string{
+	"application/pdf",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.oasis.opendocument.text",
+	"application/epub+zip",
+}
+
+// isBinaryDocument reports whether a response with the Content-Type header ct
+// is a document supported by the extractors.
+func isBinaryDocument(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	return err == nil && slices.Contains(binaryDocumentTypes, mt)
 }
 
 func (f *httpFetcher) close() error { return nil }
+
+// plainClient performs requests that bypass the page fetcher, like
+// robots.txt and sitemap downloads, which never need a browser.
+type plainClient struct {
+	client    *http.Client
+	userAgent string
+	headers   map[string]string
+}
+
+func newPlainClient(cfg *config.CrawlerConfig) *plainClient {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	return &plainClient{
+		client:    &http.Client{Timeout: timeout},
+		userAgent: cfg.UserAgent,
+		headers:   cfg.Headers,
+	}
+}
+
+func (c *plainClient) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+// do sends req with the configured user agent and headers.
+func (c *plainClient) do(req *http.Request) (*http.Response, error) {
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	return c.client.Do(req)
+}
@@ -6,7 +6,6 @@ import (
 	"context"
 	"fmt"
 	"net/url"
-	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -62,6 +61,13 @@ func (c *persistentCrawler) Close() error {
 	return c.fetcher.close()
 }
 
+// claimBatch is the number of pending URLs moved from the database to the
+// in-memory queue at once. maxQueued bounds the in-memory queue.
+const (
+	claimBatch = 100
+	maxQueued  = 1000
+)
+
 func (c *persistentCrawler) persistentBFS(ctx context.Context, startURL string, v *Validator, ch chan<- *document.Document) error {
 	// Restore any URLs that were left in_progress from a previous run.
 	if err := model.ResetInProgressCrawlURLs(c.jobID); err != nil {
@@ -73,113 +79,145 @@ func (c *persistentCrawler) persistentBFS(ctx context.Context, startURL string,
 		return fmt.Errorf("insert start URL: %w", err)
 	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return model.UpdateCrawlJobStatus(c.jobID, model.CrawlJobInterrupted)
-		default:
-		}
-
-		cur, err := model.NextPendingCrawlURL(c.jobID)
-		if err != nil {
-			return fmt.Errorf("next pending URL: %w", err)
-		}
-		if cur == nil {
-			// No more pending URLs; crawl is complete.
-			break
-		}
-
-		// Mark as in_progress.
-		if err := model.UpdateCrawlURLStatus(cur.ID, model.CrawlURLInProgress, ""); err != nil {
-			return fmt.Errorf("mark in_progress: %w", err)
-		}
-
-		parsedURL, err := url.Parse(cur.URL)
-		if err != nil {
-			if err2 := model.UpdateCrawlURLStatus(cur.ID, model.CrawlURLFailed, err.Error()); err2 != nil {
-				log.Warn().Err(err2).Msg("failed to update URL status")
-			}
-			continue
-		}
+	ctx, cancel := context.WithCancel(ctx)
+	p := newCrawlPool(c.fetcher, c.cfg)
+	p.start(ctx)
+	defer func() {
+		// Abort fetches still in flight before waiting for the workers.
+		cancel()
+		p.close()
+	}()
 
-		switch v.Validate(parsedURL, cur.Depth) {
+	validate := func(t *crawlTask) URLStatus {
+		status := v.Validate(t.parsed, t.depth)
+		switch status {
 		case URLStop:
 			// Put the URL back so a resumed job can pick it up with higher limits.
-			if err := model.UpdateCrawlURLStatus(cur.ID, model.CrawlURLPending, ""); err != nil {
-				log.Warn().Err(err).Msg("failed to revert URL to pending on URLStop")
-			}
-			return model.UpdateCrawlJobStatus(c.jobID, model.CrawlJobInterrupted)
+			c.setStatus(t.id, model.CrawlURLPending, "")
 		case URLSkip:
-			if err := model.UpdateCrawlURLStatus(cur.ID, model.CrawlURLSkipped, ""); err != nil {
-				log.Warn().Err(err).Msg("failed to mark URL skipped")
-			}
-			continue
+			c.setStatus(t.id, model.CrawlURLSkipped, "excluded by crawl rules")
 		}
+		return status
+	}
 
-		if c.cfg.Delay > 0 {
-			select {
-			case <-time.After(time.Duration(c.cfg.Delay) * time.Second):
-			case <-ctx.Done():
-				if err := model.UpdateCrawlURLStatus(cur.ID, model.CrawlURLPending, ""); err != nil {
-					log.Warn().Err(err).Msg("failed to revert URL to pending on cancel")
+	stopped := false
+	exhausted := false
+	for {
+		if !stopped {
+			stopped = p.dispatch(validate)
+			// Claim more URLs while workers are idle, either because the
+			// queue ran dry or because the queued hosts are at their limits.
+			for !stopped && !exhausted && p.inflight < p.workers && p.queued < maxQueued {
+				cus, err := model.ClaimPendingCrawlURLs(c.jobID, claimBatch)
+				if err != nil {
+					return fmt.Errorf("claim pending URLs: %w", err)
 				}
-				return model.UpdateCrawlJobStatus(c.jobID, model.CrawlJobInterrupted)
+				exhausted = len(cus) == 0
+				for _, cu := range cus {
+					t := &crawlTask{queueItem: queueItem{cu.URL, cu.Depth}, id: cu.ID}
+					if err := p.push(t); err != nil {
+						c.setStatus(cu.ID, model.CrawlURLFailed, err.Error())
+					}
+				}
+				stopped = p.dispatch(validate)
 			}
 		}
+		if p.inflight == 0 && (stopped || p.queued == 0) {
+			break
+		}
 
-		finalURL, htmlContent, links, fetchErr := c.fetcher.fetchPage(ctx, cur.URL)
-		if fetchErr != nil {
-			log.Warn().Err(fetchErr).Str("url", cur.URL).Msg("crawler: failed to fetch page")
-			if err := model.UpdateCrawlURLStatus(cur.ID, model.CrawlURLFailed, fetchErr.Error()); err != nil {
-				log.Warn().Err(err).Msg("failed to mark URL failed")
-			}
+		r, err := p.wait(ctx)
+		if err != nil {
+			return c.interrupt()
+		}
+		if r == nil {
 			continue
 		}
+		if c.handleResult(ctx, r, ch) {
+			return c.interrupt()
+		}
+		// The fetched page may have added new pending URLs.
+		exhausted = false
+	}
 
-		// Handle redirects: insert the final URL as done so it won't be fetched again.
-		if finalURL != cur.URL {
-			finalParsedURL, fErr := url.Parse(finalURL)
-			if fErr == nil {
-				finalParsedURL.Fragment = ""
-				cleanFinal := finalParsedURL.String()
-				if err := model.InsertCrawlURLDone(c.jobID, cleanFinal, cur.Depth); err != nil {
-					log.Warn().Err(err).Str("url", cleanFinal).Msg("failed to insert redirect target as done")
-				}
+	if stopped {
+		return c.interrupt()
+	}
+	return model.UpdateCrawlJobStatus(c.jobID, model.CrawlJobCompleted)
+}
+
+// handleResult records the outcome of a fetch, emits the document and queues
+// the discovered links. It returns true when ctx was cancelled.
+func (c *persistentCrawler) handleResult(ctx context.Context, r *fetchResult, ch chan<- *document.Document) bool {
+	cur := r.task
+	if r.skip != "" {
+		log.Info().Str("url", cur.rawURL).Str("reason", r.skip).Msg("crawler: skipping page")
+		c.setStatus(cur.id, model.CrawlURLSkipped, r.skip)
+		return false
+	}
+	if r.err != nil {
+		log.Warn().Err(r.err).Str("url", cur.rawURL).Msg("crawler: failed to fetch page")
+		c.setStatus(cur.id, model.CrawlURLFailed, r.err.Error())
+		return false
+	}
+
+	// Handle redirects: insert the final URL as done so it won't be fetched again.
+	if r.finalURL != cur.rawURL {
+		finalParsedURL, fErr := url.Parse(r.finalURL)
+		if fErr == nil {
+			finalParsedURL.Fragment = ""
+			cleanFinal := finalParsedURL.String()
+			if err := model.InsertCrawlURLDone(c.jobID, cleanFinal, cur.depth); err != nil {
+				log.Warn().Err(err).Str("url", cleanFinal).Msg("failed to insert redirect target as done")
 			}
 		}
+	}
 
-		if err := model.UpdateCrawlURLStatus(cur.ID, model.CrawlURLDone, ""); err != nil {
-			log.Warn().Err(err).Msg("failed to mark URL done")
-		}
+	c.setStatus(cur.id, model.CrawlURLDone, "")
 
-		doc := &document.Document{
-			URL:  finalURL,
-			HTML: htmlContent,
-		}
+	doc := &document.Document{
+		URL:         r.finalURL,
+		HTML:        r.html,
+		Raw:         r.raw,
+		ContentType: r.contentType,
+	}
 
-		select {
-		case ch <- doc:
-		case <-ctx.Done():
-			return model.UpdateCrawlJobStatus(c.jobID, model.CrawlJobInterrupted)
-		}
+	select {
+	case ch <- doc:
+	case <-ctx.Done():
+		return true
+	}
 
-		// Resolve and enqueue discovered links.
-		finalParsed, err := url.Parse(finalURL)
-		if err != nil {
-			finalParsed = parsedURL
-		}
-		finalParsed.Fragment = ""
+	// Resolve and enqueue discovered links.
+	finalParsed, err := url.Parse(r.finalURL)
+	if err != nil {
+		finalParsed = cur.parsed
+	}
+	finalParsed.Fragment = ""
 
-		for _, link := range links {
-			abs, err := resolveURL(finalParsed, link)
-			if err != nil || abs == "" {
-				continue
-			}
-			if err := model.InsertCrawlURLIfNotExists(c.jobID, abs, cur.Depth+1); err != nil {
-				log.Warn().Err(err).Str("url", abs).Msg("failed to insert discovered URL")
-			}
+	for _, link := range r.links {
+		abs, err := resolveURL(finalParsed, link)
+		if err != nil || abs == "" {
+			continue
+		}
+		if err := model.InsertCrawlURLIfNotExists(c.jobID, abs, cur.depth+1); err != nil {
+			log.Warn().Err(err).Str("url", abs).Msg("failed to insert discovered URL")
 		}
 	}
+	return false
+}
 
-	return model.UpdateCrawlJobStatus(c.jobID, model.CrawlJobCompleted)
+// interrupt returns claimed but unfinished URLs to the queue and marks the
+// job as interrupted.
+func (c *persistentCrawler) interrupt() error {
+	if err := model.ResetInProgressCrawlURLs(c.jobID); err != nil {
+		log.Warn().Err(err).Msg("failed to revert in_progress URLs to pending")
+	}
+	return model.UpdateCrawlJobStatus(c.jobID, model.CrawlJobInterrupted)
+}
+
+func (c *persistentCrawler) setStatus(id uint, status, errMsg string) {
+	if err := model.UpdateCrawlURLStatus(id, status, errMsg); err != nil {
+		log.Warn().Err(err).Str("status", status).Msg("failed to update URL status")
+	}
 }
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/asciimoo/hister/config"
+)
+
+const (
+	defaultConcurrency        = 4
+	defaultMaxHostConnections = 1
+)
+
+// now returns the current time, replaced in tests.
+var now = time.Now
+
+// crawlTask is a URL scheduled for fetching.
+type crawlTask struct {
+	queueItem
+	// id is the CrawlURL row ID of persistent crawls.
+	id     uint
+	parsed *url.URL
+}
+
+// fetchResult is the outcome of fetching a crawlTask.
+type fetchResult struct {
+	task *crawlTask
+	*page
+	err error
+	// skip holds the reason the URL was not fetched.
+	skip string
+	// crawlDelay is the delay requested by the host's robots.txt.
+	crawlDelay time.Duration
+}
+
+// hostQueue holds the scheduling state of a single host.
+type hostQueue struct {
+	tasks  []*crawlTask
+	active int
+	next   time.Time
+	delay  time.Duration
+}
+
+// crawlPool fetches URLs with a fixed number of workers. Tasks are queued per
+// host and dispatched round-robin, so a slow or rate limited host never
+// blocks the others. Each host is limited to a number of parallel requests
+// and a minimum delay between request starts, raised to the Crawl-delay of
+// its robots.txt. URLs disallowed by robots.txt are reported as skipped
+// without being fetched.
+//
+// Only the crawl loop goroutine may call the pool's methods; workers
+// communicate exclusively through the tasks and results channels.
+type crawlPool struct {
+	fetcher  fetcher
+	workers  int
+	perHost  int
+	delay    time.Duration
+	robots   *robotsCache
+	hosts    map[string]*hostQueue
+	order    []string
+	rr       int
+	queued   int
+	inflight int
+	tasks    chan *crawlTask
+	results  chan *fetchResult
+	wg       sync.WaitGroup
+}
+
+func newCrawlPool(f fetcher, cfg *config.CrawlerConfig) *crawlPool {
+	workers := cfg.Concurrency
+	if workers <= 0 {
+		workers = defaultConcurrency
+	}
+	perHost := cfg.MaxHostConnections
+	if perHost <= 0 {
+		perHost = defaultMaxHostConnections
+	}
+	p := &crawlPool{
+		fetcher: f,
+		workers: workers,
+		perHost: perHost,
+		delay:   time.Duration(cfg.Delay) * time.Second,
+		hosts:   make(map[string]*hostQueue),
+		tasks:   make(chan *crawlTask, workers),
+		results: make(chan *fetchResult, workers),
+	}
+	if !cfg.IgnoreRobots {
+		p.robots = newRobotsCache(cfg)
+	}
+	return p
+}
+
+// start launches the fetch workers. They exit when the pool is closed or
+// ctx is cancelled.
+func (p *crawlPool) start(ctx context.Context) {
+	for range p.workers {
+		p.wg.Go(func() {
+			for t := range p.tasks {
+				r := &fetchResult{task: t}
+				if p.robots != nil {
+					r.skip, r.crawlDelay = p.robots.check(ctx, t.parsed)
+				}
+				if r.skip == "" {
+					r.page, r.err = p.fetcher.fetchPage(ctx, t.rawURL)
+				}
+				select {
+				case p.results <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+	}
+}
+
+// close stops the workers and waits for them to exit, discarding the
+// results of fetches still in flight.
+func (p *crawlPool) close() {
+	close(p.tasks)
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-p.results:
+		case <-done:
+			return
+		}
+	}
+}
+
+// push queues t for fetching. It returns an error when the URL of t cannot be
+// parsed.
+func (p *crawlPool) push(t *crawlTask) error {
+	if t.parsed == nil {
+		u, err := url.Parse(t.rawURL)
+		if err != nil {
+			return err
+		}
+		t.parsed = u
+	}
+	host := t.parsed.Host
+	hq, ok := p.hosts[host]
+	if !ok {
+		hq = &hostQueue{}
+		p.hosts[host] = hq
+		p.order = append(p.order, host)
+	}
+	hq.tasks = append(hq.tasks, t)
+	p.queued++
+	return nil
+}
+
+// pop returns the next task whose host has a free connection slot and whose
+// delay has elapsed, or nil when no such task exists.
+func (p *crawlPool) pop(now time.Time) (*crawlTask, *hostQueue) {
+	for n := 0; n < len(p.order); n++ {
+		hq := p.hosts[p.order[p.rr]]
+		p.rr = (p.rr + 1) % len(p.order)
+		if len(hq.tasks) == 0 || hq.active >= p.perHost || now.Before(hq.next) {
+			continue
+		}
+		t := hq.tasks[0]
+		hq.tasks = hq.tasks[1:]
+		p.queued--
+		return t, hq
+	}
+	return nil, nil
+}
+
+// dispatch hands queued tasks to idle workers. validate is called for every
+// task before it is dispatched; skipped tasks are dropped. dispatch returns
+// true when validate reported URLStop, in which case the stopping task is
+// dropped as well.
+func (p *crawlPool) dispatch(validate func(*crawlTask) URLStatus) bool {
+	t0 := now()
+	for p.inflight < p.workers {
+		t, hq := p.pop(t0)
+		if t == nil {
+			return false
+		}
+		switch validate(t) {
+		case URLStop:
+			return true
+		case URLSkip:
+			continue
+		}
+		hq.active++
+		hq.next = t0.Add(max(p.delay, hq.delay))
+		p.inflight++
+		p.tasks <- t
+	}
+	return false
+}
+
+// wait blocks until a fetch finishes or a delayed host becomes available.
+// It returns a nil result in the latter case and ctx.Err() on cancellation.
+func (p *crawlPool) wait(ctx context.Context) (*fetchResult, error) {
+	var timer <-chan time.Time
+	if d, ok := p.nextReady(); ok {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timer = t.C
+	}
+	select {
+	case r := <-p.results:
+		p.inflight--
+		hq := p.hosts[r.task.parsed.Host]
+		hq.active--
+		if r.crawlDelay > hq.delay {
+			hq.delay = r.crawlDelay
+			hq.next = now().Add(max(p.delay, hq.delay))
+		}
+		return r, nil
+	case <-timer:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// nextReady returns the time until the earliest delayed host with queued
+// tasks and a free connection slot may be dispatched.
+func (p *crawlPool) nextReady() (time.Duration, bool) {
+	if p.inflight >= p.workers {
+		return 0, false
+	}
+	var earliest time.Time
+	for _, hq := range p.hosts {
+		if len(hq.tasks) == 0 || hq.active >= p.perHost {
+			continue
+		}
+		if earliest.IsZero() || hq.next.Before(earliest) {
+			earliest = hq.next
+		}
+	}
+	if earliest.IsZero() {
+		return 0, false
+	}
+	return max(earliest.Sub(now()), 0), true
+}
@@ -0,0 +1,248 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asciimoo/hister/config"
+)
+
+// fakeFetcher returns an empty page for every URL and records the fetched
+// URLs.
+type fakeFetcher struct {
+	mu      sync.Mutex
+	fetched []string
+}
+
+func (f *fakeFetcher) fetchPage(_ context.Context, rawURL string) (*page, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetched = append(f.fetched, rawURL)
+	return &page{finalURL: rawURL}, nil
+}
+
+func (f *fakeFetcher) close() error { return nil }
+
+// setTestClock freezes the clock of the pool at a fixed time and returns a
+// function advancing it by d.
+func setTestClock(t *testing.T) func(d time.Duration) {
+	t.Helper()
+	cur := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	now = func() time.Time { return cur }
+	t.Cleanup(func() { now = time.Now })
+	return func(d time.Duration) { cur = cur.Add(d) }
+}
+
+// newTestPool starts a pool fetching with f and closes it on cleanup.
+func newTestPool(t *testing.T, f fetcher, cfg *config.CrawlerConfig) *crawlPool {
+	t.Helper()
+	cfg.IgnoreRobots = true
+	p := newCrawlPool(f, cfg)
+	ctx, cancel := context.WithCancel(t.Context())
+	p.start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		p.close()
+	})
+	return p
+}
+
+func pushURLs(t *testing.T, p *crawlPool, urls ...string) {
+	t.Helper()
+	for _, u := range urls {
+		if err := p.push(&crawlTask{queueItem: queueItem{rawURL: u}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func allowAll(*crawlTask) URLStatus { return URLAllow }
+
+// waitResult waits for the next fetch to finish and returns its URL.
+func waitResult(t *testing.T, p *crawlPool) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	for {
+		r, err := p.wait(ctx)
+		if err != nil {
+			t.Fatalf("wait() error = %v", err)
+		}
+		if r != nil {
+			return r.task.rawURL
+		}
+	}
+}
+
+func TestCrawlPoolHostLimit(t *testing.T) {
+	setTestClock(t)
+	p := newTestPool(t, &fakeFetcher{}, &config.CrawlerConfig{Concurrency: 4, MaxHostConnections: 2})
+	pushURLs(t, p, "http://a.test/1", "http://a.test/2", "http://a.test/3")
+
+	p.dispatch(allowAll)
+	if p.inflight != 2 || p.queued != 1 {
+		t.Fatalf("after the first dispatch inflight = %d, queued = %d, want 2 and 1", p.inflight, p.queued)
+	}
+	if d, ok := p.nextReady(); ok {
+		t.Errorf("nextReady() = %v with the host at its connection limit", d)
+	}
+	waitResult(t, p)
+	p.dispatch(allowAll)
+	if p.inflight != 2 || p.queued != 0 {
+		t.Errorf("after a fetch finished inflight = %d, queued = %d, want 2 and 0", p.inflight, p.queued)
+	}
+}
+
+func TestCrawlPoolWorkerLimit(t *testing.T) {
+	setTestClock(t)
+	p := newTestPool(t, &fakeFetcher{}, &config.CrawlerConfig{Concurrency: 2, MaxHostConnections: 4})
+	pushURLs(t, p, "http://a.test/1", "http://b.test/1", "http://c.test/1")
+
+	p.dispatch(allowAll)
+	if p.inflight != 2 || p.queued != 1 {
+		t.Errorf("inflight = %d, queued = %d, want 2 and 1", p.inflight, p.queued)
+	}
+}
+
+func TestCrawlPoolDelay(t *testing.T) {
+	advance := setTestClock(t)
+	f := &fakeFetcher{}
+	p := newTestPool(t, f, &config.CrawlerConfig{Concurrency: 4, MaxHostConnections: 4, Delay: 2})
+	pushURLs(t, p, "http://a.test/1", "http://a.test/2", "http://b.test/1")
+
+	// the delay applies to each host separately
+	p.dispatch(allowAll)
+	if p.inflight != 2 || p.queued != 1 {
+		t.Fatalf("inflight = %d, queued = %d, want 2 and 1", p.inflight, p.queued)
+	}
+	waitResult(t, p)
+	waitResult(t, p)
+
+	p.dispatch(allowAll)
+	if p.inflight != 0 {
+		t.Fatal("a request started right after the previous one")
+	}
+	if d, ok := p.nextReady(); !ok || d != 2*time.Second {
+		t.Errorf("nextReady() = %v, %v, want 2s", d, ok)
+	}
+	advance(time.Second)
+	p.dispatch(allowAll)
+	if p.inflight != 0 {
+		t.Fatal("a request started 1s after the previous one")
+	}
+	if d, ok := p.nextReady(); !ok || d != time.Second {
+		t.Errorf("nextReady() = %v, %v, want 1s", d, ok)
+	}
+	advance(time.Second)
+	p.dispatch(allowAll)
+	if p.inflight != 1 {
+		t.Fatal("no request started once the delay elapsed")
+	}
+	if got := waitResult(t, p); got != "http://a.test/2" {
+		t.Errorf("fetched %q, want http://a.test/2", got)
+	}
+}
+
+func TestCrawlPoolCrawlDelay(t *testing.T) {
+	advance := setTestClock(t)
+	// the workers are not started, fetches are answered by the test
+	p := newCrawlPool(&fakeFetcher{}, &config.CrawlerConfig{Concurrency: 1, MaxHostConnections: 1, Delay: 1, IgnoreRobots: true})
+	pushURLs(t, p, "http://a.test/1", "http://a.test/2")
+
+	p.dispatch(allowAll)
+	task := <-p.tasks
+	p.results <- &fetchResult{task: task, page: &page{finalURL: task.rawURL}, crawlDelay: 5 * time.Second}
+	waitResult(t, p)
+
+	advance(4 * time.Second)
+	p.dispatch(allowAll)
+	if p.inflight != 0 {
+		t.Fatal("a request started before the Crawl-delay of robots.txt elapsed")
+	}
+	advance(time.Second)
+	p.dispatch(allowAll)
+	if p.inflight != 1 {
+		t.Fatal("no request started once the Crawl-delay elapsed")
+	}
+	if task := <-p.tasks; task.rawURL != "http://a.test/2" {
+		t.Errorf("dispatched %q, want http://a.test/2", task.rawURL)
+	}
+}
+
+func TestCrawlPoolFairness(t *testing.T) {
+	setTestClock(t)
+	f := &fakeFetcher{}
+	p := newTestPool(t, f, &config.CrawlerConfig{Concurrency: 1, MaxHostConnections: 1})
+	for _, host := range []string{"a", "b", "c"} {
+		for n := range 3 {
+			pushURLs(t, p, fmt.Sprintf("http://%s.test/%d", host, n))
+		}
+	}
+
+	for p.queued > 0 {
+		p.dispatch(allowAll)
+		waitResult(t, p)
+	}
+	want := []string{
+		"http://a.test/0", "http://b.test/0", "http://c.test/0",
+		"http://a.test/1", "http://b.test/1", "http://c.test/1",
+		"http://a.test/2", "http://b.test/2", "http://c.test/2",
+	}
+	if !slices.Equal(f.fetched, want) {
+		t.Errorf("fetch order = %v, want %v", f.fetched, want)
+	}
+}
+
+func TestCrawlPoolDelayedHostDoesNotBlock(t *testing.T) {
+	advance := setTestClock(t)
+	f := &fakeFetcher{}
+	p := newTestPool(t, f, &config.CrawlerConfig{Concurrency: 1, MaxHostConnections: 1, Delay: 10})
+	pushURLs(t, p, "http://slow.test/1", "http://slow.test/2", "http://slow.test/3", "http://fast.test/1")
+
+	p.dispatch(allowAll)
+	waitResult(t, p)
+	// slow.test is delayed, fast.test is served in the meantime
+	p.dispatch(allowAll)
+	if got := waitResult(t, p); got != "http://fast.test/1" {
+		t.Fatalf("fetched %q while slow.test was delayed, want http://fast.test/1", got)
+	}
+	p.dispatch(allowAll)
+	if p.inflight != 0 {
+		t.Fatal("slow.test was fetched before its delay elapsed")
+	}
+	advance(10 * time.Second)
+	p.dispatch(allowAll)
+	if got := waitResult(t, p); got != "http://slow.test/2" {
+		t.Errorf("fetched %q, want http://slow.test/2", got)
+	}
+}
+
+func TestCrawlPoolValidate(t *testing.T) {
+	setTestClock(t)
+	f := &fakeFetcher{}
+	p := newTestPool(t, f, &config.CrawlerConfig{Concurrency: 4, MaxHostConnections: 4})
+	pushURLs(t, p, "http://a.test/skip", "http://a.test/1", "http://a.test/stop", "http://a.test/2")
+
+	stopped := p.dispatch(func(t *crawlTask) URLStatus {
+		switch t.parsed.Path {
+		case "/skip":
+			return URLSkip
+		case "/stop":
+			return URLStop
+		}
+		return URLAllow
+	})
+	if !stopped {
+		t.Error("dispatch() = false after URLStop")
+	}
+	if got := waitResult(t, p); got != "http://a.test/1" {
+		t.Errorf("fetched %q, want http://a.test/1", got)
+	}
+	if p.inflight != 0 || p.queued != 1 {
+		t.Errorf("inflight = %d, queued = %d, want 0 and 1", p.inflight, p.queued)
+	}
+}
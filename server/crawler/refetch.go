@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/asciimoo/hister/config"
+)
+
+// ErrRobotsDisallowed is returned by the Refetcher for URLs that may not
+// be fetched according to robots.txt.
+var ErrRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+// maxFeedSize is the largest response body read by FetchRaw.
+const maxFeedSize = 10 << 20
+
+// Validators are the HTTP cache validators of a previously fetched page.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// RefetchResult is the outcome of a conditional page request.
+type RefetchResult struct {
+	// NotModified is set when the server answered 304 Not Modified, URL and
+	// HTML are empty in that case.
+	NotModified bool
+	URL         string
+	HTML        string
+	// Raw and ContentType are set instead of HTML for binary documents
+	// supported by the extractors.
+	Raw         []byte
+	ContentType string
+	// Validators are the validators of the response, to be sent with the
+	// next request.
+	Validators Validators
+}
+
+// Refetcher re-downloads indexed pages with conditional requests, so that
+// unchanged pages cost a single 304 response. It always uses plain HTTP,
+// honours robots.txt unless IgnoreRobots is set and waits Delay, or the
+// host's longer Crawl-delay, between requests to the same host.
+//
+// A Refetcher is not safe for concurrent use.
+type Refetcher struct {
+	client *plainClient
+	robots *robotsCache
+	delay  time.Duration
+	last   map[string]time.Time
+}
+
+// NewRefetcher creates a Refetcher using the HTTP settings of cfg.
+func NewRefetcher(cfg *config.CrawlerConfig) (*Refetcher, error) {
+	jar, err := newCookieJar(cfg.Cookies)
+	if err != nil {
+		return nil, err
+	}
+	c := newPlainClient(cfg)
+	c.client.Jar = jar
+	r := &Refetcher{
+		client: c,
+		delay:  time.Duration(cfg.Delay) * time.Second,
+		last:   make(map[string]time.Time),
+	}
+	if !cfg.IgnoreRobots {
+		r.robots = newRobotsCache(cfg)
+	}
+	return r, nil
+}
+
+// Fetch requests rawURL with the If-None-Match and If-Modified-Since headers
+// derived from v. Responses other than 200 and 304, and pages which are
+// neither HTML nor a supported binary document, are reported as errors.
+func (r *Refetcher) Fetch(ctx context.Context, rawURL string, v Validators) (*RefetchResult, error) {
+	return r.fetch(ctx, rawURL, v, false)
+}
+
+// FetchRaw is like Fetch, but accepts responses of any content type and
+// returns their body in Raw, e.g. to download feeds. Bodies larger than
+// maxFeedSize are reported as errors.
+func (r *Refetcher) FetchRaw(ctx context.Context, rawURL string, v Validators) (*RefetchResult, error) {
+	return r.fetch(ctx, rawURL, v, true)
+}
+
+func (r *Refetcher) fetch(ctx context.Context, rawURL string, v Validators, raw bool) (*RefetchResult, error) {
+	if err := r.prepare(ctx, rawURL); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+	resp, err := r.client.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warn().Err(err).Msg("crawler: failed to close response body")
+		}
+	}()
+
+	res := &RefetchResult{
+		Validators: Validators{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		},
+	}
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		res.NotModified = true
+		// A 304 may omit the validators; keep the ones that are still valid.
+		if res.Validators.ETag == "" {
+			res.Validators.ETag = v.ETag
+		}
+		if res.Validators.LastModified == "" {
+			res.Validators.LastModified = v.LastModified
+		}
+		return res, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	ct := resp.Header.Get("Content-Type")
+	binary := raw || isBinaryDocument(ct)
+	if !binary && !strings.Contains(ct, "html") {
+		return nil, fmt.Errorf("not an HTML response: %s", ct)
+	}
+	var body []byte
+	if raw {
+		body, err = io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+		if err == nil && len(body) > maxFeedSize {
+			err = fmt.Errorf("response larger than %d bytes", maxFeedSize)
+		}
+	} else {
+		body, err = io.ReadAll(resp.Body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.URL = resp.Request.URL.String()
+	if binary {
+		res.Raw, res.ContentType = body, ct
+	} else {
+		res.HTML = string(body)
+	}
+	return res, nil
+}
+
+// Probe checks whether rawURL is still available and returns the final
+// status code. A HEAD request is sent first; servers failing it are asked
+// again with a GET, of which only the headers are read. Errors are network
+// failures, or ErrRobotsDisallowed.
+func (r *Refetcher) Probe(ctx context.Context, rawURL string) (int, error) {
+	if err := r.prepare(ctx, rawURL); err != nil {
+		return 0, err
+	}
+	code, err := r.probe(ctx, http.MethodHead, rawURL)
+	if err == nil && code < 400 {
+		return code, nil
+	}
+	return r.probe(ctx, http.MethodGet, rawURL)
+}
+
+func (r *Refetcher) probe(ctx context.Context, method, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.client.do(req)
+	if err != nil {
+		return 0, err
+	}
+	if err := resp.Body.Close(); err != nil {
+		log.Debug().Err(err).Msg("crawler: failed to close response body")
+	}
+	return resp.StatusCode, nil
+}
+
+// prepare checks robots.txt and waits for the delay of the host of rawURL.
+func (r *Refetcher) prepare(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	delay := r.delay
+	if r.robots != nil {
+		reason, crawlDelay := r.robots.check(ctx, u)
+		if reason != "" {
+			return fmt.Errorf("%w: %s", ErrRobotsDisallowed, reason)
+		}
+		delay = max(delay, crawlDelay)
+	}
+	return r.wait(ctx, u.Host, delay)
+}
+
+// wait blocks until delay has passed since the previous request to host.
+func (r *Refetcher) wait(ctx context.Context, host string, delay time.Duration) error {
+	if last, ok := r.last[host]; ok {
+		if d := time.Until(last.Add(delay)); d > 0 {
+			t := time.NewTimer(d)
+			defer t.Stop()
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	r.last[host] = time.Now()
+	return nil
+}
@@ -0,0 +1,183 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/asciimoo/hister/config"
+)
+
+const testLastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// newConditionalServer serves /page with an ETag and a Last-Modified date,
+// answering 304 to requests carrying either of them. /bare answers 304
+// without validators, /large-feed exceeds maxFeedSize and /robots.txt
+// disallows /private.
+func newConditionalServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		case "/page", "/private":
+			if r.Header.Get("If-None-Match") == `"v1"` || r.Header.Get("If-Modified-Since") == testLastModified {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Last-Modified", testLastModified)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, "<html><body>page</body></html>")
+		case "/bare":
+			w.WriteHeader(http.StatusNotModified)
+		case "/doc.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			fmt.Fprint(w, "%PDF-1.4")
+		case "/feed":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			fmt.Fprint(w, "<rss/>")
+		case "/large-feed":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			_, _ = w.Write(bytes.Repeat([]byte(" "), maxFeedSize+1))
+		case "/error":
+			http.Error(w, "failure", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestRefetcher(t *testing.T, cfg *config.CrawlerConfig) *Refetcher {
+	t.Helper()
+	r, err := NewRefetcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRefetcherConditional(t *testing.T) {
+	srv := newConditionalServer(t)
+	r := newTestRefetcher(t, &config.CrawlerConfig{IgnoreRobots: true})
+	ctx := context.Background()
+
+	res, err := r.Fetch(ctx, srv.URL+"/page", Validators{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.NotModified || res.HTML != "<html><body>page</body></html>" || res.URL != srv.URL+"/page" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	want := Validators{ETag: `"v1"`, LastModified: testLastModified}
+	if res.Validators != want {
+		t.Fatalf("expected validators %+v, got %+v", want, res.Validators)
+	}
+
+	for _, v := range []Validators{want, {ETag: `"v1"`}, {LastModified: testLastModified}} {
+		res, err = r.Fetch(ctx, srv.URL+"/page", v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.NotModified || res.HTML != "" {
+			t.Fatalf("expected 304 with validators %+v, got %+v", v, res)
+		}
+	}
+
+	// a changed ETag is answered with the page
+	res, err = r.Fetch(ctx, srv.URL+"/page", Validators{ETag: `"v0"`})
+	if err != nil || res.NotModified {
+		t.Fatalf("expected the page for an outdated ETag, got %+v, %v", res, err)
+	}
+
+	// 304 responses without validators keep the previous ones
+	res, err = r.Fetch(ctx, srv.URL+"/bare", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.NotModified || res.Validators != want {
+		t.Fatalf("expected 304 keeping the validators, got %+v", res)
+	}
+}
+
+func TestRefetcherContent(t *testing.T) {
+	srv := newConditionalServer(t)
+	r := newTestRefetcher(t, &config.CrawlerConfig{IgnoreRobots: true})
+	ctx := context.Background()
+
+	res, err := r.Fetch(ctx, srv.URL+"/doc.pdf", Validators{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.HTML != "" || string(res.Raw) != "%PDF-1.4" || res.ContentType != "application/pdf" {
+		t.Fatalf("expected a raw PDF document, got %+v", res)
+	}
+
+	if _, err := r.Fetch(ctx, srv.URL+"/feed", Validators{}); err == nil {
+		t.Fatal("expected an error for a non HTML page")
+	}
+	res, err = r.FetchRaw(ctx, srv.URL+"/feed", Validators{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Raw) != "<rss/>" {
+		t.Fatalf("expected the raw feed, got %q", res.Raw)
+	}
+	if _, err := r.FetchRaw(ctx, srv.URL+"/large-feed", Validators{}); err == nil {
+		t.Fatal("expected an error for a feed larger than maxFeedSize")
+	}
+
+	for _, p := range []string{"/error", "/missing"} {
+		if _, err := r.Fetch(ctx, srv.URL+p, Validators{}); err == nil {
+			t.Fatalf("expected an error for %s", p)
+		}
+	}
+}
+
+func TestRefetcherRobots(t *testing.T) {
+	srv := newConditionalServer(t)
+	r := newTestRefetcher(t, &config.CrawlerConfig{})
+	ctx := context.Background()
+
+	if _, err := r.Fetch(ctx, srv.URL+"/private", Validators{}); !errors.Is(err, ErrRobotsDisallowed) {
+		t.Fatalf("expected ErrRobotsDisallowed, got %v", err)
+	}
+	if _, err := r.Fetch(ctx, srv.URL+"/page", Validators{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r = newTestRefetcher(t, &config.CrawlerConfig{IgnoreRobots: true})
+	if _, err := r.Fetch(ctx, srv.URL+"/private", Validators{}); err != nil {
+		t.Fatalf("expected robots.txt to be ignored, got %v", err)
+	}
+}
+
+func TestRefetcherDelay(t *testing.T) {
+	srv := newConditionalServer(t)
+	r := newTestRefetcher(t, &config.CrawlerConfig{IgnoreRobots: true})
+	r.delay = 100 * time.Millisecond
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 3 {
+		if _, err := r.Fetch(ctx, srv.URL+"/page", Validators{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 2*r.delay {
+		t.Fatalf("expected requests to the same host to be delayed, took %v", d)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := r.Fetch(ctx, srv.URL+"/page", Validators{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the wait to be cancelled, got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/asciimoo/hister/config"
+)
+
+// maxRobotsSize is the number of bytes of a robots.txt file that are parsed,
+// as recommended by RFC 9309.
+const maxRobotsSize = 500 * 1024
+
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// robotsTxt holds the robots.txt rules applying to the crawler's user agent.
+type robotsTxt struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	// sitemaps lists the Sitemap URLs of the file; they apply to all
+	// user agents.
+	sitemaps []string
+	// unreachable is set when the file could not be retrieved due to a
+	// server or network error; the whole host is disallowed in that case.
+	unreachable bool
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// parseRobots parses a robots.txt file and returns the rules of the groups
+// matching userAgent. A group matches when its user-agent value appears in
+// userAgent (case-insensitively); the longest matching value wins and "*"
+// is used when no group matches. Groups with the same user-agent value are
+// merged.
+func parseRobots(r io.Reader, userAgent string) *robotsTxt {
+	var groups []*robotsGroup
+	var cur *robotsGroup
+	var sitemaps []string
+	inRules := false
+	sc := bufio.NewScanner(io.LimitReader(r, maxRobotsSize))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+		switch key {
+		case "user-agent":
+			if cur == nil || inRules {
+				cur = &robotsGroup{}
+				groups = append(groups, cur)
+				inRules = false
+			}
+			cur.agents = append(cur.agents, strings.ToLower(val))
+		case "allow", "disallow":
+			if cur == nil {
+				continue
+			}
+			inRules = true
+			// An empty Disallow matches nothing.
+			if val == "" {
+				continue
+			}
+			cur.rules = append(cur.rules, robotsRule{pattern: val, allow: key == "allow"})
+		case "sitemap":
+			if val != "" {
+				sitemaps = append(sitemaps, val)
+			}
+		case "crawl-delay":
+			if cur == nil {
+				continue
+			}
+			inRules = true
+			if d, err := strconv.ParseFloat(val, 64); err == nil && d > 0 {
+				cur.crawlDelay = time.Duration(d * float64(time.Second))
+			}
+		}
+	}
+
+	ua := strings.ToLower(userAgent)
+	best := -1
+	var matched []*robotsGroup
+	for _, g := range groups {
+		score := -1
+		for _, a := range g.agents {
+			switch {
+			case a == "*":
+				score = max(score, 0)
+			case a != "" && strings.Contains(ua, a):
+				score = max(score, len(a))
+			}
+		}
+		switch {
+		case score < 0 || score < best:
+			continue
+		case score > best:
+			best = score
+			matched = matched[:0]
+		}
+		matched = append(matched, g)
+	}
+	rt := &robotsTxt{sitemaps: sitemaps}
+	for _, g := range matched {
+		rt.rules = append(rt.rules, g.rules...)
+		rt.crawlDelay = max(rt.crawlDelay, g.crawlDelay)
+	}
+	return rt
+}
+
+// allowed reports whether u may be fetched. The most specific (longest)
+// matching rule decides; Allow wins ties.
+func (rt *robotsTxt) allowed(u *url.URL) bool {
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if p == "/robots.txt" {
+		return true
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	allow := true
+	longest := -1
+	for _, r := range rt.rules {
+		if !robotsMatch(r.pattern, p) {
+			continue
+		}
+		if len(r.pattern) > longest || (len(r.pattern) == longest && r.allow) {
+			longest = len(r.pattern)
+			allow = r.allow
+		}
+	}
+	return allow
+}
+
+// robotsMatch reports whether path matches a robots.txt path pattern. "*"
+// matches any sequence of characters and a trailing "$" anchors the pattern
+// to the end of the path.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+	for j, part := range parts[1:] {
+		if j == len(parts)-2 && anchored {
+			return strings.HasSuffix(path, part)
+		}
+		k := strings.Index(path, part)
+		if k < 0 {
+			return false
+		}
+		path = path[k+len(part):]
+	}
+	return !anchored || path == ""
+}
+
+type robotsEntry struct {
+	once sync.Once
+	txt  *robotsTxt
+}
+
+// robotsCache fetches and caches the robots.txt file of every host visited
+// during a crawl. It is safe for concurrent use.
+type robotsCache struct {
+	*plainClient
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+}
+
+func newRobotsCache(cfg *config.CrawlerConfig) *robotsCache {
+	return &robotsCache{
+		plainClient: newPlainClient(cfg),
+		hosts:       make(map[string]*robotsEntry),
+	}
+}
+
+// check returns an empty string when u may be fetched, the reason it may
+// not otherwise, and the crawl delay requested by the host.
+func (c *robotsCache) check(ctx context.Context, u *url.URL) (string, time.Duration) {
+	key := u.Scheme + "://" + u.Host
+	c.mu.Lock()
+	e, ok := c.hosts[key]
+	if !ok {
+		e = &robotsEntry{}
+		c.hosts[key] = e
+	}
+	c.mu.Unlock()
+	e.once.Do(func() {
+		e.txt = c.fetch(ctx, key)
+	})
+	switch {
+	case e.txt.unreachable:
+		return "robots.txt unreachable", e.txt.crawlDelay
+	case !e.txt.allowed(u):
+		return "disallowed by robots.txt", e.txt.crawlDelay
+	}
+	return "", e.txt.crawlDelay
+}
+
+// fetch retrieves the robots.txt of the given origin following RFC 9309:
+// a missing file (4xx) allows everything, while server and network errors
+// disallow the whole host.
+func (c *robotsCache) fetch(ctx context.Context, origin string) *robotsTxt {
+	resp, err := c.get(ctx, origin+"/robots.txt")
+	if err != nil {
+		log.Warn().Err(err).Str("origin", origin).Msg("crawler: failed to fetch robots.txt")
+		return &robotsTxt{unreachable: true}
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Debug().Err(err).Msg("crawler: failed to close robots.txt body")
+		}
+	}()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return parseRobots(resp.Body, c.userAgent)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return &robotsTxt{}
+	default:
+		log.Warn().Err(fmt.Errorf("unexpected status %d", resp.StatusCode)).Str("origin", origin).Msg("crawler: failed to fetch robots.txt")
+		return &robotsTxt{unreachable: true}
+	}
+}
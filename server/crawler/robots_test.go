@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRobots = `
+# comment
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+
+User-agent: OtherBot
+Disallow: /
+
+User-agent: Hister
+Disallow: /admin
+Allow: /admin/help
+Crawl-delay: 2.5
+`
+
+func TestRobots(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		url       string
+		allowed   bool
+	}{
+		{"SomeBot/1.0", "https://example.com/", true},
+		{"SomeBot/1.0", "https://example.com/private/x", false},
+		{"SomeBot/1.0", "https://example.com/private/public", true},
+		{"SomeBot/1.0", "https://example.com/doc.pdf", false},
+		{"SomeBot/1.0", "https://example.com/doc.pdf?x=1", true},
+		{"OtherBot", "https://example.com/", false},
+		{"OtherBot", "https://example.com/robots.txt", true},
+		{"Mozilla/5.0 (compatible; Hister/1.0)", "https://example.com/private/x", true},
+		{"Mozilla/5.0 (compatible; Hister/1.0)", "https://example.com/admin/users", false},
+		{"Mozilla/5.0 (compatible; Hister/1.0)", "https://example.com/admin/help", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.userAgent+" "+tt.url, func(t *testing.T) {
+			rt := parseRobots(strings.NewReader(testRobots), tt.userAgent)
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rt.allowed(u); got != tt.allowed {
+				t.Errorf("allowed() = %v, want %v", got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestRobotsCrawlDelay(t *testing.T) {
+	rt := parseRobots(strings.NewReader(testRobots), "Hister")
+	if rt.crawlDelay != 2500*time.Millisecond {
+		t.Errorf("crawlDelay = %v, want 2.5s", rt.crawlDelay)
+	}
+	rt = parseRobots(strings.NewReader(testRobots), "SomeBot")
+	if rt.crawlDelay != 0 {
+		t.Errorf("crawlDelay = %v, want 0", rt.crawlDelay)
+	}
+}
+
+func TestRobotsMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/", "/anything", true},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish", false},
+		{"/fish*.php", "/fish/salmon.php", true},
+		{"/*.php$", "/index.php", true},
+		{"/*.php$", "/index.php?x", false},
+		{"/fish$", "/fish", true},
+		{"/fish$", "/fishes", false},
+		{"/a*b*c$", "/axxbyyc", true},
+		{"/a*b*c$", "/axxbyycd", false},
+	}
+	for _, tt := range tests {
+		if got := robotsMatch(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("robotsMatch(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package crawler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/asciimoo/hister/config"
+)
+
+const (
+	// maxSitemapSize is the uncompressed size limit of a sitemap file set by
+	// the sitemaps.org protocol.
+	maxSitemapSize = 50 * 1024 * 1024
+	// maxSitemapDepth limits how deep nested sitemap indexes are followed.
+	maxSitemapDepth = 3
+	// maxSitemapEntries caps the number of pages collected from sitemaps.
+	maxSitemapEntries = 1000000
+)
+
+// SitemapEntry is a page listed in a sitemap.
+type SitemapEntry struct {
+	URL string
+	// LastMod is the last modification time of the page, zero when the
+	// sitemap does not provide it.
+	LastMod time.Time
+}
+
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// sitemapXML matches both <urlset> and <sitemapindex> documents.
+type sitemapXML struct {
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// lastModLayouts are the W3C Datetime profiles allowed in <lastmod>.
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+func parseLastMod(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, l := range lastModLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseSitemap decodes a sitemap or sitemap index, transparently
+// decompressing gzip content.
+func parseSitemap(r io.Reader) (*sitemapXML, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := gz.Close(); err != nil {
+				log.Debug().Err(err).Msg("crawler: failed to close gzip reader")
+			}
+		}()
+		r = gz
+	} else {
+		r = br
+	}
+	var sm sitemapXML
+	if err := xml.NewDecoder(io.LimitReader(r, maxSitemapSize)).Decode(&sm); err != nil {
+		return nil, err
+	}
+	return &sm, nil
+}
+
+// DiscoverSitemaps returns the sitemaps advertised by the robots.txt of the
+// host of startURL, falling back to /sitemap.xml when there are none.
+func DiscoverSitemaps(ctx context.Context, cfg *config.CrawlerConfig, startURL string) ([]string, error) {
+	u, err := url.Parse(startURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start URL: %w", err)
+	}
+	origin := u.Scheme + "://" + u.Host
+	rt := newRobotsCache(cfg).fetch(ctx, origin)
+	if len(rt.sitemaps) > 0 {
+		return rt.sitemaps, nil
+	}
+	return []string{origin + "/sitemap.xml"}, nil
+}
+
+// FetchSitemaps downloads the given sitemaps, following sitemap indexes, and
+// returns the pages they list. Sitemaps that fail to download or parse are
+// logged and skipped.
+func FetchSitemaps(ctx context.Context, cfg *config.CrawlerConfig, sitemapURLs []string) ([]SitemapEntry, error) {
+	c := newPlainClient(cfg)
+	seen := make(map[string]struct{})
+	var entries []SitemapEntry
+	var walk func(u string, depth int) error
+	walk = func(u string, depth int) error {
+		if _, ok := seen[u]; ok {
+			return nil
+		}
+		seen[u] = struct{}{}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sm, err := c.fetchSitemap(ctx, u)
+		if err != nil {
+			log.Warn().Err(err).Str("url", u).Msg("crawler: failed to fetch sitemap")
+			return nil
+		}
+		for _, l := range sm.URLs {
+			loc := strings.TrimSpace(l.Loc)
+			if loc == "" {
+				continue
+			}
+			if _, ok := seen[loc]; ok {
+				continue
+			}
+			seen[loc] = struct{}{}
+			if len(entries) >= maxSitemapEntries {
+				return nil
+			}
+			entries = append(entries, SitemapEntry{URL: loc, LastMod: parseLastMod(l.LastMod)})
+		}
+		if depth >= maxSitemapDepth {
+			return nil
+		}
+		for _, s := range sm.Sitemaps {
+			if err := walk(strings.TrimSpace(s.Loc), depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, u := range sitemapURLs {
+		if err := walk(u, 0); err != nil {
+			return entries, err
+		}
+	}
+	return entries, nil
+}
+
+func (c *plainClient) fetchSitemap(ctx context.Context, rawURL string) (*sitemapXML, error) {
+	resp, err := c.get(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Debug().Err(err).Msg("crawler: failed to close sitemap body")
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return parseSitemap(resp.Body)
+}
+
+// headFetcher requests pages with HEAD to read their headers only.
+type headFetcher struct {
+	client *plainClient
+}
+
+func (f *headFetcher) fetchPage(ctx context.Context, rawURL string) (*page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Body.Close(); err != nil {
+		log.Debug().Err(err).Msg("crawler: failed to close response body")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	p := &page{finalURL: resp.Request.URL.String()}
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		p.lastModified, _ = http.ParseTime(lm)
+	}
+	return p, nil
+}
+
+func (f *headFetcher) close() error { return nil }
+
+// LastModified requests the pages at urls with HEAD and returns the time of
+// their Last-Modified header, e.g. for sitemap entries without <lastmod>.
+// The requests go through a crawl pool, so robots.txt, the concurrency, the
+// per-host connection limit and the delay of cfg are honoured. Pages which
+// fail or have no Last-Modified header are left out.
+func LastModified(ctx context.Context, cfg *config.CrawlerConfig, urls []string) (map[string]time.Time, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	p := newCrawlPool(&headFetcher{client: newPlainClient(cfg)}, cfg)
+	p.start(ctx)
+	defer func() {
+		cancel()
+		p.close()
+	}()
+
+	for _, u := range urls {
+		if err := p.push(&crawlTask{queueItem: queueItem{rawURL: u}}); err != nil {
+			log.Debug().Err(err).Str("url", u).Msg("crawler: invalid sitemap entry")
+		}
+	}
+	allow := func(*crawlTask) URLStatus { return URLAllow }
+	times := make(map[string]time.Time)
+	for {
+		p.dispatch(allow)
+		if p.inflight == 0 && p.queued == 0 {
+			return times, nil
+		}
+		r, err := p.wait(ctx)
+		if err != nil {
+			return times, err
+		}
+		switch {
+		case r == nil:
+		case r.skip != "":
+			log.Debug().Str("url", r.task.rawURL).Str("reason", r.skip).Msg("crawler: skipping page")
+		case r.err != nil:
+			log.Debug().Err(r.err).Str("url", r.task.rawURL).Msg("crawler: failed to request page headers")
+		case !r.lastModified.IsZero():
+			times[r.task.rawURL] = r.lastModified
+		}
+	}
+}
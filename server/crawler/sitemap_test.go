@@ -0,0 +1,124 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asciimoo/hister/config"
+)
+
+const testURLSet = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/</loc><lastmod>2024-05-01</lastmod></url>
+  <url><loc> https://example.com/about </loc></url>
+</urlset>`
+
+const testSitemapIndex = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/sitemap1.xml.gz</loc></sitemap>
+  <sitemap><loc>https://example.com/sitemap2.xml</loc></sitemap>
+</sitemapindex>`
+
+func TestParseSitemap(t *testing.T) {
+	sm, err := parseSitemap(strings.NewReader(testURLSet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sm.URLs) != 2 || len(sm.Sitemaps) != 0 {
+		t.Fatalf("got %d urls and %d sitemaps, want 2 and 0", len(sm.URLs), len(sm.Sitemaps))
+	}
+	if sm.URLs[0].Loc != "https://example.com/" || sm.URLs[0].LastMod != "2024-05-01" {
+		t.Errorf("unexpected first entry: %+v", sm.URLs[0])
+	}
+
+	sm, err = parseSitemap(strings.NewReader(testSitemapIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sm.URLs) != 0 || len(sm.Sitemaps) != 2 {
+		t.Fatalf("got %d urls and %d sitemaps, want 0 and 2", len(sm.URLs), len(sm.Sitemaps))
+	}
+}
+
+func TestParseSitemapGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(testURLSet)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sm, err := parseSitemap(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sm.URLs) != 2 {
+		t.Errorf("got %d urls, want 2", len(sm.URLs))
+	}
+}
+
+func TestParseLastMod(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2024-05-01", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-05", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-05-01T10:20:30+02:00", time.Date(2024, 5, 1, 8, 20, 30, 0, time.UTC)},
+		{"2024-05-01T10:20Z", time.Date(2024, 5, 1, 10, 20, 0, 0, time.UTC)},
+		{"yesterday", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := parseLastMod(tt.in); !got.Equal(tt.want) {
+			t.Errorf("parseLastMod(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+			return
+		}
+		mu.Lock()
+		methods = append(methods, r.Method)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/dated", "/private":
+			w.Header().Set("Last-Modified", testLastModified)
+		case "/undated":
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	urls := []string{srv.URL + "/dated", srv.URL + "/undated", srv.URL + "/missing", srv.URL + "/private"}
+	times, err := LastModified(t.Context(), &config.CrawlerConfig{Concurrency: 2, MaxHostConnections: 2}, urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := http.ParseTime(testLastModified)
+	if len(times) != 1 || !times[srv.URL+"/dated"].Equal(want) {
+		t.Errorf("got %v, want only %s modified at %v", times, srv.URL+"/dated", want)
+	}
+	if len(methods) != 3 {
+		t.Errorf("got %d requests, want 3 as robots.txt disallows /private", len(methods))
+	}
+	for _, m := range methods {
+		if m != http.MethodHead {
+			t.Errorf("got a %s request, want HEAD", m)
+		}
+	}
+}
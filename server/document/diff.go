@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package document
+
+import (
+	"strings"
+)
+
+// DiffOp identifies the kind of change a DiffLine represents.
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = " "
+	DiffInsert DiffOp = "+"
+	DiffDelete DiffOp = "-"
+)
+
+// DiffLine is a single line of a line-based diff.
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffCells caps the size of the LCS table. Texts exceeding it are
+// reported as a full replacement instead of an exact minimal diff.
+const maxDiffCells = 4 * 1024 * 1024
+
+// Diff returns a line-based diff transforming oldText into newText.
+func Diff(oldText, newText string) []DiffLine {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+
+	// Trim the common prefix and suffix; revisits usually change little.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	r := make([]DiffLine, 0, len(a)+len(b))
+	for _, l := range a[:pre] {
+		r = append(r, DiffLine{Op: DiffEqual, Text: l})
+	}
+	r = append(r, diffLCS(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, l := range a[len(a)-suf:] {
+		r = append(r, DiffLine{Op: DiffEqual, Text: l})
+	}
+	return r
+}
+
+func diffLCS(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	r := make([]DiffLine, 0, n+m)
+	if n*m > maxDiffCells {
+		for _, l := range a {
+			r = append(r, DiffLine{Op: DiffDelete, Text: l})
+		}
+		for _, l := range b {
+			r = append(r, DiffLine{Op: DiffInsert, Text: l})
+		}
+		return r
+	}
+	// lcs[x][y] holds the LCS length of a[x:] and b[y:].
+	lcs := make([][]int, n+1)
+	for x := range lcs {
+		lcs[x] = make([]int, m+1)
+	}
+	for x := n - 1; x >= 0; x-- {
+		for y := m - 1; y >= 0; y-- {
+			if a[x] == b[y] {
+				lcs[x][y] = lcs[x+1][y+1] + 1
+			} else {
+				lcs[x][y] = max(lcs[x+1][y], lcs[x][y+1])
+			}
+		}
+	}
+	x, y := 0, 0
+	for x < n && y < m {
+		switch {
+		case a[x] == b[y]:
+			r = append(r, DiffLine{Op: DiffEqual, Text: a[x]})
+			x++
+			y++
+		case lcs[x+1][y] >= lcs[x][y+1]:
+			r = append(r, DiffLine{Op: DiffDelete, Text: a[x]})
+			x++
+		default:
+			r = append(r, DiffLine{Op: DiffInsert, Text: b[y]})
+			y++
+		}
+	}
+	for ; x < n; x++ {
+		r = append(r, DiffLine{Op: DiffDelete, Text: a[x]})
+	}
+	for ; y < m; y++ {
+		r = append(r, DiffLine{Op: DiffInsert, Text: b[y]})
+	}
+	return r
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,35 @@
+package document
+
+import (
+	"testing"
+)
+
+func diffString(lines []DiffLine) string {
+	s := ""
+	for _, l := range lines {
+		s += string(l.Op) + l.Text + "\n"
+	}
+	return s
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", " a\n b\n"},
+		{"empty old", "", "a\nb", "+a\n+b\n"},
+		{"empty new", "a\nb", "", "-a\n-b\n"},
+		{"append", "a\nb", "a\nb\nc", " a\n b\n+c\n"},
+		{"change middle", "a\nb\nc", "a\nx\nc", " a\n-b\n+x\n c\n"},
+		{"insert and delete", "a\nb\nc\nd", "b\nc\ne\nd", "-a\n b\n c\n+e\n d\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffString(Diff(tt.old, tt.new)); got != tt.want {
+				t.Errorf("Diff(%q, %q) =\n%s\nwant\n%s", tt.old, tt.new, got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
@@ -34,16 +35,36 @@ type Document struct {
 	Language           string         `json:"language"`
 	UserID             uint           `json:"user_id"`
 	Metadata           map[string]any `json:"metadata"`
+	Hybrid             *HybridScore   `json:"hybrid,omitempty"`
+	ContentType        string         `json:"content_type,omitempty"` // MIME type of binary documents, e.g. application/pdf
+	Raw                []byte         `json:"raw,omitempty"`          // binary content, released once processed
+	Thread             []*Document    `json:"thread,omitempty"`       // other matching messages of the e-mail thread, grouped in search results
 	faviconURL         string
 	processed          bool
 	skipSensitiveCheck bool
 }
 
+// HybridScore explains how the Score of a hybrid search result was composed.
+// Score = (1-Weight)*Keyword + Weight*Semantic.
+type HybridScore struct {
+	// Keyword is the Bleve score normalized to the best keyword match.
+	Keyword float64 `json:"keyword"`
+	// Semantic is the best vector similarity among the document's chunks.
+	Semantic     float64 `json:"semantic"`
+	Weight       float64 `json:"weight"`
+	Source       string  `json:"source"`
+	MatchedChunk string  `json:"matched_chunk,omitempty"`
+}
+
 var (
 	ErrSensitiveContent = errors.New("document contains sensitive data")
 	sensitiveContentRe  *regexp.Regexp
 )
 
+// ErrBinaryContent is returned for binary documents which no extractor can
+// turn into text.
+var ErrBinaryContent = errors.New("unsupported binary content")
+
 // ErrReadFile is the sentinel error for file read failures.
 var ErrReadFile = errors.New("cannot read file")
 
@@ -124,7 +145,13 @@ func (d *Document) Process(ld LanguageDetector, extractFn func(*Document) error)
 		return err
 	}
 	if pu.Scheme == "file" {
-		return d.processFile(ld)
+		return d.processFile(ld, extractFn)
+	}
+	if pu.Scheme == "mid" {
+		return d.processMessage(ld, extractFn)
+	}
+	if pu.Scheme == "git+file" {
+		return d.processRepository(ld)
 	}
 	if pu.Scheme == "" || pu.Host == "" {
 		return errors.New("invalid URL: missing scheme/host")
@@ -148,7 +175,11 @@ func (d *Document) Process(ld LanguageDetector, extractFn func(*Document) error)
 	}
 	d.Type = types.Web
 	d.Domain = pu.Host
-	if d.HTML != "" {
+	if len(d.Raw) > 0 {
+		if err := d.extractRaw(extractFn); err != nil {
+			return err
+		}
+	} else if d.HTML != "" {
 		if err := extractFn(d); err != nil {
 			return err
 		}
@@ -160,34 +191,43 @@ func (d *Document) Process(ld LanguageDetector, extractFn func(*Document) error)
 	return nil
 }
 
-func (d *Document) processFile(ld LanguageDetector) error {
+func (d *Document) processFile(ld LanguageDetector, extractFn func(*Document) error) error {
 	if ld == nil {
 		ld = NewNullLanguageDetector()
 	}
 	osPath := files.FileURLToPath(d.URL)
-	if d.Text == "" {
+	if d.Text == "" && len(d.Raw) == 0 {
 		content, err := os.ReadFile(osPath)
 		if err != nil {
 			return &ReadFileError{
 				Msg: err.Error(),
 			}
 		}
-		if !utf8.Valid(content) {
-			return errors.New("binary file")
+		d.SetFileContent(content)
+	}
+	if len(d.Raw) > 0 {
+		if err := d.extractRaw(extractFn); err != nil {
+			return err
+		}
+	} else if extractFn != nil {
+		// Extractors of text formats, like Markdown notes, only add metadata.
+		if err := extractFn(d); err != nil {
+			log.Debug().Err(err).Str("URL", d.URL).Msg("Indexing file as plain text")
 		}
-		d.Text = string(content)
 	}
 	if !d.skipSensitiveCheck && sensitiveContentRe != nil && sensitiveContentRe.MatchString(d.Text) {
 		return ErrSensitiveContent
 	}
 	d.Type = types.Local
 	d.Domain = "local"
-	base := filepath.Base(osPath)
-	parent := filepath.Base(filepath.Dir(osPath))
-	if parent == "." || parent == "/" {
-		d.Title = base
-	} else {
-		d.Title = parent + "/" + base
+	if d.Title == "" {
+		base := filepath.Base(osPath)
+		parent := filepath.Base(filepath.Dir(osPath))
+		if parent == "." || parent == "/" {
+			d.Title = base
+		} else {
+			d.Title = parent + "/" + base
+		}
 	}
 	if d.Added == 0 {
 		d.Added = time.Now().Unix()
@@ -197,6 +237,87 @@ func (d *Document) processFile(ld LanguageDetector) error {
 	return nil
 }
 
+// processMessage processes an e-mail message read from a mailbox, whose
+// raw content is left to the extractors.
+func (d *Document) processMessage(ld LanguageDetector, extractFn func(*Document) error) error {
+	if len(d.Raw) > 0 {
+		if err := d.extractRaw(extractFn); err != nil {
+			return err
+		}
+	} else if d.Text == "" {
+		return errors.New("missing message content")
+	}
+	if !d.skipSensitiveCheck && sensitiveContentRe != nil && sensitiveContentRe.MatchString(d.Text) {
+		return ErrSensitiveContent
+	}
+	d.Type = types.Mail
+	d.Domain = "mail"
+	if d.Added == 0 {
+		d.Added = time.Now().Unix()
+	}
+	d.Language = ld.DetectLanguage(d.Text)
+	d.processed = true
+	return nil
+}
+
+// processRepository processes a commit or a file read from a git
+// repository, whose text is set by the reader of the repository.
+func (d *Document) processRepository(ld LanguageDetector) error {
+	if d.Text == "" {
+		return errors.New("missing repository content")
+	}
+	if !d.skipSensitiveCheck && sensitiveContentRe != nil && sensitiveContentRe.MatchString(d.Text) {
+		return ErrSensitiveContent
+	}
+	d.Type = types.Git
+	d.Domain = "git"
+	if d.Added == 0 {
+		d.Added = time.Now().Unix()
+	}
+	d.Language = ld.DetectLanguage(d.Text)
+	d.processed = true
+	return nil
+}
+
+// SetFileContent sets the content of a local file. Text files are indexed
+// as they are, other files are left to the extractors.
+func (d *Document) SetFileContent(content []byte) {
+	if isText(content) {
+		d.Text = string(content)
+	} else {
+		d.Raw = content
+	}
+}
+
+// isText reports whether file content can be indexed as it is. Valid UTF-8
+// alone is not enough, as e.g. PDF files may consist of ASCII only.
+func isText(content []byte) bool {
+	return utf8.Valid(content) && strings.HasPrefix(http.DetectContentType(content), "text/")
+}
+
+// extractRaw turns the binary content of the document into text using
+// extractFn. ContentType is sniffed from the content when unset. Raw is
+// released afterwards, so it never reaches the index.
+func (d *Document) extractRaw(extractFn func(*Document) error) error {
+	if d.ContentType == "" {
+		d.ContentType = http.DetectContentType(d.Raw)
+	}
+	if mt, _, err := mime.ParseMediaType(d.ContentType); err == nil {
+		d.ContentType = mt
+	}
+	if extractFn == nil {
+		return ErrBinaryContent
+	}
+	if err := extractFn(d); err != nil {
+		return fmt.Errorf("%w (%s): %w", ErrBinaryContent, d.ContentType, err)
+	}
+	d.Raw = nil
+	if !d.skipSensitiveCheck && sensitiveContentRe != nil && sensitiveContentRe.MatchString(d.Text) {
+		return ErrSensitiveContent
+	}
+	return nil
+}
+
 // SetSkipSensitiveCheck controls whether sensitive content checks are skipped
 // during processing (e.g. during reindex with skipSensitiveChecks=true).
 func (d *Document) SetSkipSensitiveCheck(v bool) {
@@ -8,6 +8,7 @@ import (
 	"io"
 	"maps"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -17,10 +18,20 @@ import (
 
 	"github.com/asciimoo/hister/config"
 	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/extractor/extractors/code"
+	"github.com/asciimoo/hister/server/extractor/extractors/docx"
+	"github.com/asciimoo/hister/server/extractor/extractors/epub"
+	"github.com/asciimoo/hister/server/extractor/extractors/git"
 	"github.com/asciimoo/hister/server/extractor/extractors/github"
 	"github.com/asciimoo/hister/server/extractor/extractors/godoc"
 	"github.com/asciimoo/hister/server/extractor/extractors/jsonld"
 	"github.com/asciimoo/hister/server/extractor/extractors/lobsters"
+	"github.com/asciimoo/hister/server/extractor/extractors/mail"
+	"github.com/asciimoo/hister/server/extractor/extractors/notes"
+	"github.com/asciimoo/hister/server/extractor/extractors/odt"
+	"github.com/asciimoo/hister/server/extractor/extractors/pdf"
+	"github.com/asciimoo/hister/server/extractor/extractors/plugin"
+	"github.com/asciimoo/hister/server/extractor/extractors/site"
 	"github.com/asciimoo/hister/server/extractor/extractors/stackoverflow"
 	"github.com/asciimoo/hister/server/extractor/extractors/wikipedia"
 	"github.com/asciimoo/hister/server/extractor/extractors/ytdlp"
@@ -89,7 +100,18 @@ func List() []ExtractorInfo {
 	return infos
 }
 
-var extractors = []Extractor{
+// builtinExtractors are the extractors of the chain without the ones declared
+// in the configuration.
+var builtinExtractors = []Extractor{
+	&pdf.PDFExtractor{},
+	&docx.DOCXExtractor{},
+	&odt.ODTExtractor{},
+	&epub.EPUBExtractor{},
+	&mail.MailExtractor{},
+	&git.GitExtractor{},
+	&notes.MarkdownExtractor{},
+	&notes.OrgExtractor{},
+	&code.CodeExtractor{},
 	&jsonld.JSONLDExtractor{},
 	&stackoverflow.StackoverflowExtractor{},
 	&godoc.GoDocExtractor{},
@@ -101,11 +123,20 @@ var extractors = []Extractor{
 	&defaultExtractor{},
 }
 
-// Init applies user-supplied extractor configurations on top of each
-// extractor's defaults. It must be called before Extract or Preview.
-// cfgs is keyed by lowercased extractor name (as Viper lowercases YAML keys).
-func Init(cfgs map[string]*config.Extractor) error {
-	for _, e := range extractors {
+var extractors = builtinExtractors
+
+// Init builds the extractor chain from the built-in extractors, the
+// extractors declared by sites and the extractor plugins, which are tried
+// right before the generic Readability extractor, then applies
+// user-supplied extractor configurations on top of each extractor's
+// defaults. It must be called before Extract or Preview. cfgs is keyed by
+// lowercased extractor name (as Viper lowercases YAML keys).
+func Init(cfgs map[string]*config.Extractor, sites []*config.SiteExtractor, plugins []*config.ExtractorPlugin) error {
+	chain, err := buildChain(sites, plugins)
+	if err != nil {
+		return err
+	}
+	for _, e := range chain {
 		def := e.GetConfig()
 		merged := &config.Extractor{
 			Enable:  def.Enable,
@@ -120,9 +151,52 @@ func Init(cfgs map[string]*config.Extractor) error {
 			return fmt.Errorf("extractor %s: %w", e.Name(), err)
 		}
 	}
+	extractors = chain
 	return nil
 }
 
+// buildChain compiles the site extractors and the extractor plugins and
+// inserts them into the built-in chain before the Readability extractor.
+func buildChain(sites []*config.SiteExtractor, plugins []*config.ExtractorPlugin) ([]Extractor, error) {
+	names := make(map[string]bool, len(builtinExtractors)+len(sites)+len(plugins))
+	for _, e := range builtinExtractors {
+		names[strings.ToLower(e.Name())] = true
+	}
+	declared := make([]Extractor, 0, len(sites)+len(plugins))
+	add := func(section string, i int, e Extractor) error {
+		name := strings.ToLower(e.Name())
+		if names[name] {
+			return fmt.Errorf("%s[%d]: duplicate extractor name %q", section, i, e.Name())
+		}
+		names[name] = true
+		declared = append(declared, e)
+		return nil
+	}
+	for i, sc := range sites {
+		e, err := site.New(sc)
+		if err != nil {
+			return nil, fmt.Errorf("site_extractors[%d]: %w", i, err)
+		}
+		if err := add("site_extractors", i, e); err != nil {
+			return nil, err
+		}
+	}
+	for i, pc := range plugins {
+		e, err := plugin.New(pc)
+		if err != nil {
+			return nil, fmt.Errorf("extractor_plugins[%d]: %w", i, err)
+		}
+		if err := add("extractor_plugins", i, e); err != nil {
+			return nil, err
+		}
+	}
+	i := slices.IndexFunc(builtinExtractors, func(e Extractor) bool {
+		_, ok := e.(*readabilityExtractor)
+		return ok
+	})
+	return slices.Concat(builtinExtractors[:i], declared, builtinExtractors[i:]), nil
+}
+
 // Extract tries each registered extractor in order and returns the first
 // successful result. Returns ErrNoExtractor if none succeed.
 func Extract(d *document.Document) error {
@@ -219,11 +293,17 @@ func (e *defaultExtractor) Description() string {
 	return "Fallback extractor that strips HTML tags and extracts plain text from any web page."
 }
 
-func (e *defaultExtractor) Match(_ *document.Document) bool {
-	return true
+// Match accepts every document except undecoded binary content, which only
+// dedicated extractors can handle.
+func (e *defaultExtractor) Match(d *document.Document) bool {
+	return len(d.Raw) == 0
 }
 
 func (e *defaultExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
+	// Plain text files have nothing to extract.
+	if d.HTML == "" {
+		return types.ExtractorStop, nil
+	}
 	d.Title = ""
 	r := bytes.NewReader([]byte(d.HTML))
 	doc := html.NewTokenizer(r)
@@ -286,8 +366,8 @@ func (e *readabilityExtractor) Description() string {
 	return "Extracts the main article content from any web page using the go-readability library, filtering out navigation, ads, and other boilerplate."
 }
 
-func (e *readabilityExtractor) Match(_ *document.Document) bool {
-	return true
+func (e *readabilityExtractor) Match(d *document.Document) bool {
+	return len(d.Raw) == 0 && d.HTML != ""
 }
 
 func (e *readabilityExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
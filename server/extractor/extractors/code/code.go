@@ -0,0 +1,145 @@
+// Package code provides an extractor for source code files in local
+// directories. It detects the programming language of the file, records the
+// names of the package, functions, types and methods it defines as
+// searchable symbols, and renders syntax-highlighted previews.
+package code
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/sanitizer"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// Metadata keys written by the extractor.
+const (
+	MetaLanguage = "programming_language"
+	MetaPackage  = "package"
+	MetaSymbols  = "symbols"
+)
+
+// maxSymbols limits the number of symbols recorded for a single file.
+const maxSymbols = 1000
+
+// CodeExtractor extracts the symbols of source code files.
+type CodeExtractor struct {
+	cfg *config.Extractor
+}
+
+// Name returns the extractor's identifier.
+func (e *CodeExtractor) Name() string {
+	return "Code"
+}
+
+// Description returns a short summary of what this extractor does.
+func (e *CodeExtractor) Description() string {
+	return "Detects the programming language of local source files, indexes the names of the package, functions, types and methods they define and renders syntax-highlighted previews."
+}
+
+// GetConfig returns the extractor's current configuration.
+func (e *CodeExtractor) GetConfig() *config.Extractor {
+	if e.cfg == nil {
+		return &config.Extractor{Enable: true, Options: map[string]any{}}
+	}
+	return e.cfg
+}
+
+// SetConfig applies cfg to the extractor. Returns an error for unknown options.
+func (e *CodeExtractor) SetConfig(c *config.Extractor) error {
+	for k := range c.Options {
+		return fmt.Errorf("unknown option %q", k)
+	}
+	e.cfg = c
+	return nil
+}
+
+// Match returns true for local files written in a supported language.
+func (e *CodeExtractor) Match(d *document.Document) bool {
+	return strings.HasPrefix(d.URL, "file://") && Detect(d.URL) != nil
+}
+
+// Extract keeps the source as the text of the document and records its
+// language, package and symbols in the metadata.
+func (e *CodeExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
+	lang := Detect(d.URL)
+	if d.Text == "" || lang == nil {
+		return types.ExtractorContinue, nil
+	}
+	d.ContentType = lang.ContentType()
+	if d.Metadata == nil {
+		d.Metadata = make(map[string]any)
+	}
+	d.Metadata["type"] = "Source code"
+	d.Metadata[MetaLanguage] = lang.Name
+	pkg, symbols := lang.Symbols(d.Text)
+	if pkg != "" {
+		d.Metadata[MetaPackage] = pkg
+	} else {
+		delete(d.Metadata, MetaPackage)
+	}
+	if len(symbols) > 0 {
+		d.Metadata[MetaSymbols] = symbols
+	} else {
+		delete(d.Metadata, MetaSymbols)
+	}
+	return types.ExtractorStop, nil
+}
+
+// Preview renders the source with syntax highlighting.
+func (e *CodeExtractor) Preview(d *document.Document) (types.PreviewResponse, types.ExtractorState, error) {
+	h, err := Highlight(d.URL, d.Text)
+	if err != nil {
+		return types.PreviewResponse{}, types.ExtractorContinue, err
+	}
+	return types.PreviewResponse{Content: sanitizer.SanitizeHTML(h)}, types.ExtractorStop, nil
+}
+
+// Language describes a supported programming language.
+type Language struct {
+	// Name is the name of the language, also used to look up its lexer
+	// for syntax highlighting.
+	Name string
+	// Extensions are the file extensions of the language.
+	Extensions []string
+	// symbols extracts the package and symbol names from a source file.
+	symbols func(src string) (string, []string)
+}
+
+// ContentType returns the MIME type recorded for source files of l.
+func (l *Language) ContentType() string {
+	name := strings.ToLower(l.Name)
+	name = strings.NewReplacer("#", "sharp", "+", "p", " ", "-").Replace(name)
+	return "text/x-" + name
+}
+
+// Symbols returns the package and the symbol names defined by src, sorted
+// and without duplicates.
+func (l *Language) Symbols(src string) (string, []string) {
+	pkg, symbols := l.symbols(src)
+	slices.Sort(symbols)
+	symbols = slices.Compact(symbols)
+	if len(symbols) > maxSymbols {
+		symbols = symbols[:maxSymbols]
+	}
+	return pkg, symbols
+}
+
+// Detect returns the language of the file at u, a path or a URL, by its
+// extension. Returns nil for unsupported files.
+func Detect(u string) *Language {
+	ext := strings.ToLower(path.Ext(u))
+	if ext == "" {
+		return nil
+	}
+	for _, l := range Languages {
+		if slices.Contains(l.Extensions, ext) {
+			return l
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package code
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/sanitizer"
+	"github.com/asciimoo/hister/server/types"
+)
+
+const testGo = `package store
+
+type Store struct{}
+
+type Getter interface {
+	Get(key string) string
+}
+
+func New() *Store { return &Store{} }
+
+func (s *Store) Get(key string) string { return "" }
+
+func (s List[T]) Len() int { return 0 }
+`
+
+func TestExtractGo(t *testing.T) {
+	e := &CodeExtractor{}
+	d := &document.Document{URL: "file:///src/store/store.go", Text: testGo}
+	if !e.Match(d) {
+		t.Fatal("expected match for Go file")
+	}
+	state, err := e.Extract(d)
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("unexpected result %v %v", state, err)
+	}
+	if d.Text != testGo {
+		t.Fatal("expected the source to be kept as text")
+	}
+	if d.Metadata[MetaLanguage] != "Go" || d.Metadata[MetaPackage] != "store" || d.ContentType != "text/x-go" {
+		t.Fatalf("unexpected metadata %v, content type %q", d.Metadata, d.ContentType)
+	}
+	want := []string{"Get", "Getter", "Getter.Get", "Len", "List.Len", "New", "Store", "Store.Get"}
+	if got := d.Metadata[MetaSymbols]; !slices.Equal(got.([]string), want) {
+		t.Fatalf("expected symbols %v, got %v", want, got)
+	}
+}
+
+func TestHeuristicSymbols(t *testing.T) {
+	tests := []struct {
+		file string
+		src  string
+		pkg  string
+		want []string
+	}{
+		{"a.py", "class Parser:\n    def parse(self):\n        if x:\n            pass\nasync def main():\n", "", []string{"Parser", "main", "parse"}},
+		{"a.ts", "export function load() {}\nexport class Cache {\n  async get(key: string): Promise<string> {\n    if (key) {\n    }\n  }\n}\nconst add = (a, b) => a + b\nexport type ID = string\n", "", []string{"Cache", "ID", "add", "get", "load"}},
+		{"A.java", "package org.example;\npublic class App {\n    public static void main(String[] args) {\n        for (int i = 0; i < 1; i++) {\n        }\n    }\n}\n", "org.example", []string{"App", "main"}},
+		{"a.c", "#define MAX 10\nstruct node {\n};\nstatic int count_nodes(struct node *n)\n{\n    while (n) {\n    }\n}\n", "", []string{"MAX", "count_nodes", "node"}},
+		{"a.rs", "pub struct Config {}\nimpl Config {\n    pub fn new() -> Self {}\n}\nmod tests {}\n", "", []string{"Config", "new", "tests"}},
+		{"a.rb", "module Shop\n  class Cart\n    def empty?\n    end\n  end\nend\n", "", []string{"Cart", "Shop", "empty?"}},
+		{"a.sh", "build() {\n}\nfunction deploy {\n}\n", "", []string{"build", "deploy"}},
+	}
+	for _, tc := range tests {
+		l := Detect(tc.file)
+		if l == nil {
+			t.Fatalf("no language detected for %s", tc.file)
+		}
+		pkg, got := l.Symbols(tc.src)
+		if pkg != tc.pkg || !slices.Equal(got, tc.want) {
+			t.Errorf("%s: expected %q %v, got %q %v", tc.file, tc.pkg, tc.want, pkg, got)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	if l := Detect("file:///src/main.GO"); l == nil || l.Name != "Go" {
+		t.Fatalf("expected Go, got %v", l)
+	}
+	if l := Detect("file:///src/README"); l != nil {
+		t.Fatalf("expected no language, got %s", l.Name)
+	}
+	if e := (&CodeExtractor{}); e.Match(&document.Document{URL: "https://example.com/main.go"}) {
+		t.Fatal("expected no match for remote files")
+	}
+}
+
+func TestPreview(t *testing.T) {
+	d := &document.Document{URL: "file:///src/store/store.go", Text: testGo}
+	p, state, err := (&CodeExtractor{}).Preview(d)
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("unexpected result %v %v", state, err)
+	}
+	if p.Content != sanitizer.SanitizeHTML(p.Content) {
+		t.Fatal("expected sanitized preview")
+	}
+	if !strings.Contains(p.Content, "<pre") || !strings.Contains(p.Content, `style="color`) {
+		t.Fatalf("expected highlighted source, got %q", p.Content)
+	}
+}
@@ -0,0 +1,64 @@
+package code
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// goSymbols parses Go sources with go/parser. Methods are recorded both by
+// name and as Type.Method. Files which cannot be parsed fall back to the
+// heuristic patterns.
+func goSymbols(src string) (string, []string) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.SkipObjectResolution)
+	if f == nil || f.Name == nil || (err != nil && len(f.Decls) == 0) {
+		return heuristicSymbols(goPatterns)(src)
+	}
+	var symbols []string
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			symbols = append(symbols, d.Name.Name)
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				if recv := receiverType(d.Recv.List[0].Type); recv != "" {
+					symbols = append(symbols, recv+"."+d.Name.Name)
+				}
+			}
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				symbols = append(symbols, ts.Name.Name)
+				if it, ok := ts.Type.(*ast.InterfaceType); ok {
+					for _, m := range it.Methods.List {
+						for _, n := range m.Names {
+							symbols = append(symbols, n.Name, ts.Name.Name+"."+n.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return f.Name.Name, symbols
+}
+
+// receiverType returns the name of the type of a method receiver, without
+// pointer and type parameters.
+func receiverType(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
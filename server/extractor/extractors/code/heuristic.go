@@ -0,0 +1,150 @@
+package code
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// patterns are the regular expressions finding the definitions of a
+// language, applied to every line of a file. The first submatch of pkg is
+// the package, module or namespace, the first non-empty submatch of defs is
+// a defined symbol.
+type patterns struct {
+	pkg  *regexp.Regexp
+	defs []*regexp.Regexp
+}
+
+// keywords are never recorded as symbols: the method patterns of C-like
+// languages also match control statements like `if (x) {`.
+var keywords = []string{
+	"if", "else", "for", "foreach", "while", "do", "switch", "case", "catch",
+	"return", "sizeof", "typeof", "function", "try", "with",
+}
+
+var (
+	goPatterns = &patterns{
+		pkg: regexp.MustCompile(`^package\s+(\w+)`),
+		defs: []*regexp.Regexp{
+			regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?(\w+)`),
+			regexp.MustCompile(`^type\s+(\w+)`),
+		},
+	}
+	pythonPatterns = &patterns{
+		defs: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*(?:async\s+)?def\s+(\w+)`),
+			regexp.MustCompile(`^\s*class\s+(\w+)`),
+		},
+	}
+	jsPatterns = &patterns{
+		defs: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(\w+)`),
+			regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?(?:class|interface|enum)\s+(\w+)`),
+			regexp.MustCompile(`^\s*(?:export\s+)?type\s+(\w+)\s*(?:<[^=]*>)?\s*=`),
+			regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+(\w+)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|\w+\s*=>)`),
+			regexp.MustCompile(`^\s+(?:(?:public|private|protected|static|readonly|async|get|set)\s+)*\*?(\w+)\s*\([^)]*\)\s*(?::[^{]+)?\{\s*$`),
+		},
+	}
+	javaPatterns = &patterns{
+		pkg: regexp.MustCompile(`^\s*(?:package|namespace)\s+([\w.]+)`),
+		defs: []*regexp.Regexp{
+			regexp.MustCompile(`\b(?:class|interface|enum|record|struct|object|trait)\s+(\w+)`),
+			regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|static|final|abstract|synchronized|override|open|suspend|virtual|async|sealed|partial|extern|unsafe|native)\s+)*(?:fun|def)\s+(?:<[^>]*>\s*)?(?:\w+\.)?(\w+)`),
+			regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|static|final|abstract|synchronized|override|virtual|async|sealed|partial|extern|unsafe|native)\s+)*(?:<[^>]*>\s+)?[\w.<>\[\],?]+\s+(\w+)\s*\([^;]*$`),
+		},
+	}
+	cPatterns = &patterns{
+		pkg: regexp.MustCompile(`^\s*namespace\s+([\w:]+)`),
+		defs: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*(?:typedef\s+)?(?:class|struct|union|enum(?:\s+class)?)\s+(\w+)\s*(?:[:{]|$)`),
+			regexp.MustCompile(`^\s*typedef\s+.*?\b(\w+)\s*;\s*$`),
+			regexp.MustCompile(`^\s*#\s*define\s+(\w+)`),
+			regexp.MustCompile(`^(?:[\w*&:<>,]+\s+)+\**(?:\w+::)*(~?\w+)\s*\([^;]*$`),
+		},
+	}
+	rustPatterns = &patterns{
+		defs: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"[^"]*"\s+)?fn\s+(\w+)`),
+			regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|type|union|mod)\s+(\w+)`),
+			regexp.MustCompile(`^\s*macro_rules!\s*(\w+)`),
+		},
+	}
+	rubyPatterns = &patterns{
+		defs: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*def\s+(?:self\.)?(\w+[?!=]?)`),
+			regexp.MustCompile(`^\s*(?:class|module)\s+(?:\w+::)*(\w+)`),
+		},
+	}
+	phpPatterns = &patterns{
+		pkg: regexp.MustCompile(`^\s*namespace\s+([\w\\]+)`),
+		defs: []*regexp.Regexp{
+			regexp.MustCompile(`\bfunction\s+&?\s*(\w+)`),
+			regexp.MustCompile(`^\s*(?:(?:abstract|final|readonly)\s+)*(?:class|interface|trait|enum)\s+(\w+)`),
+		},
+	}
+	swiftPatterns = &patterns{
+		defs: []*regexp.Regexp{
+			regexp.MustCompile(`\bfunc\s+(\w+)`),
+			regexp.MustCompile(`^\s*(?:(?:public|private|fileprivate|internal|open|final)\s+)*(?:class|struct|enum|protocol|extension|actor)\s+(\w+)`),
+		},
+	}
+	shellPatterns = &patterns{
+		defs: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*function\s+([\w:-]+)`),
+			regexp.MustCompile(`^\s*([\w:-]+)\s*\(\)\s*\{?`),
+		},
+	}
+	luaPatterns = &patterns{
+		defs: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*(?:local\s+)?function\s+(?:[\w.]+[.:])?(\w+)`),
+			regexp.MustCompile(`^\s*(?:local\s+)?(\w+)\s*=\s*function\b`),
+		},
+	}
+)
+
+// Languages lists the supported programming languages.
+var Languages = []*Language{
+	{Name: "Go", Extensions: []string{".go"}, symbols: goSymbols},
+	{Name: "Python", Extensions: []string{".py", ".pyi"}, symbols: heuristicSymbols(pythonPatterns)},
+	{Name: "JavaScript", Extensions: []string{".js", ".mjs", ".cjs", ".jsx"}, symbols: heuristicSymbols(jsPatterns)},
+	{Name: "TypeScript", Extensions: []string{".ts", ".mts", ".cts", ".tsx"}, symbols: heuristicSymbols(jsPatterns)},
+	{Name: "Java", Extensions: []string{".java"}, symbols: heuristicSymbols(javaPatterns)},
+	{Name: "Kotlin", Extensions: []string{".kt", ".kts"}, symbols: heuristicSymbols(javaPatterns)},
+	{Name: "Scala", Extensions: []string{".scala"}, symbols: heuristicSymbols(javaPatterns)},
+	{Name: "C#", Extensions: []string{".cs"}, symbols: heuristicSymbols(javaPatterns)},
+	{Name: "C", Extensions: []string{".c", ".h"}, symbols: heuristicSymbols(cPatterns)},
+	{Name: "C++", Extensions: []string{".cc", ".cpp", ".cxx", ".hh", ".hpp", ".hxx"}, symbols: heuristicSymbols(cPatterns)},
+	{Name: "Rust", Extensions: []string{".rs"}, symbols: heuristicSymbols(rustPatterns)},
+	{Name: "Ruby", Extensions: []string{".rb"}, symbols: heuristicSymbols(rubyPatterns)},
+	{Name: "PHP", Extensions: []string{".php"}, symbols: heuristicSymbols(phpPatterns)},
+	{Name: "Swift", Extensions: []string{".swift"}, symbols: heuristicSymbols(swiftPatterns)},
+	{Name: "Bash", Extensions: []string{".sh", ".bash", ".zsh"}, symbols: heuristicSymbols(shellPatterns)},
+	{Name: "Lua", Extensions: []string{".lua"}, symbols: heuristicSymbols(luaPatterns)},
+}
+
+// heuristicSymbols returns a symbol extractor matching the lines of a file
+// against p.
+func heuristicSymbols(p *patterns) func(string) (string, []string) {
+	return func(src string) (string, []string) {
+		var pkg string
+		var symbols []string
+		for line := range strings.Lines(src) {
+			line = strings.TrimRight(line, "\r\n")
+			if pkg == "" && p.pkg != nil {
+				if m := p.pkg.FindStringSubmatch(line); m != nil {
+					pkg = m[1]
+					continue
+				}
+			}
+			for _, re := range p.defs {
+				m := re.FindStringSubmatch(line)
+				if m == nil || m[1] == "" || slices.Contains(keywords, m[1]) {
+					continue
+				}
+				symbols = append(symbols, m[1])
+				break
+			}
+		}
+		return pkg, symbols
+	}
+}
@@ -0,0 +1,39 @@
+package code
+
+import (
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// highlightStyle is the color scheme of highlighted sources.
+const highlightStyle = "github"
+
+var formatter = html.New(html.TabWidth(4), html.WithLineNumbers(true))
+
+// Highlight renders src, the content of the file at u, as syntax-highlighted
+// HTML. Styles are inlined, so the output passes the HTML sanitizer.
+func Highlight(u, src string) (string, error) {
+	var lexer chroma.Lexer
+	if l := Detect(u); l != nil {
+		lexer = lexers.Get(l.Name)
+	}
+	if lexer == nil {
+		lexer = lexers.Match(u)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, src)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := formatter.Format(&b, styles.Get(highlightStyle), it); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
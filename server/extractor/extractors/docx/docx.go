@@ -0,0 +1,256 @@
+// Package docx provides an extractor for Microsoft Word (DOCX) documents.
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/extractor/zipdoc"
+	"github.com/asciimoo/hister/server/sanitizer"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// DOCXExtractor extracts the text, headings and properties of DOCX files.
+type DOCXExtractor struct {
+	cfg *config.Extractor
+}
+
+// Name returns the extractor's identifier.
+func (e *DOCXExtractor) Name() string {
+	return "DOCX"
+}
+
+// Description returns a short summary of what this extractor does.
+func (e *DOCXExtractor) Description() string {
+	return "Extracts the text, headings, title and author of Microsoft Word (DOCX) documents."
+}
+
+// GetConfig returns the extractor's current configuration.
+func (e *DOCXExtractor) GetConfig() *config.Extractor {
+	if e.cfg == nil {
+		return &config.Extractor{Enable: true, Options: map[string]any{}}
+	}
+	return e.cfg
+}
+
+// SetConfig applies cfg to the extractor. Returns an error for unknown options.
+func (e *DOCXExtractor) SetConfig(c *config.Extractor) error {
+	for k := range c.Options {
+		return fmt.Errorf("unknown option %q", k)
+	}
+	e.cfg = c
+	return nil
+}
+
+// Match returns true for DOCX documents.
+func (e *DOCXExtractor) Match(d *document.Document) bool {
+	return zipdoc.Matches(d, zipdoc.DOCX)
+}
+
+// Extract replaces the text of the document with the paragraphs of the
+// document body, and the HTML with its outline for previews. Documents
+// already extracted are left unchanged.
+func (e *DOCXExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
+	if len(d.Raw) == 0 {
+		return types.ExtractorStop, nil
+	}
+	zr, err := zipdoc.Open(d.Raw)
+	if err != nil {
+		return types.ExtractorAbort, err
+	}
+	body, err := zipdoc.ReadFile(zr, "word/document.xml")
+	if err != nil {
+		return types.ExtractorAbort, err
+	}
+	o, err := parseBody(body, headingStyles(zr))
+	if err != nil {
+		return types.ExtractorAbort, err
+	}
+	if o.Empty() {
+		return types.ExtractorAbort, errors.New("no text found")
+	}
+	d.ContentType = zipdoc.DOCX
+	d.Text = o.Text()
+	d.HTML = o.HTML()
+	d.Title = o.FirstHeading()
+	m := &zipdoc.Meta{}
+	if b, err := zipdoc.ReadFile(zr, "docProps/core.xml"); err == nil {
+		m = parseCore(b)
+	}
+	m.Apply(d, "DOCX")
+	return types.ExtractorStop, nil
+}
+
+// Preview returns the outline rendered during extraction.
+func (e *DOCXExtractor) Preview(d *document.Document) (types.PreviewResponse, types.ExtractorState, error) {
+	if d.HTML == "" {
+		return types.PreviewResponse{}, types.ExtractorContinue, nil
+	}
+	return types.PreviewResponse{Content: sanitizer.SanitizeHTML(d.HTML)}, types.ExtractorStop, nil
+}
+
+// attr returns the value of the attribute local of se, ignoring namespaces.
+func attr(se xml.StartElement, local string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// headingStyles maps the IDs of the paragraph styles defined in
+// word/styles.xml to heading levels. Style IDs are localized, so headings
+// are recognised by their outline level or their built-in English name.
+func headingStyles(zr *zip.Reader) map[string]int {
+	levels := map[string]int{}
+	b, err := zipdoc.ReadFile(zr, "word/styles.xml")
+	if err != nil {
+		return levels
+	}
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	var id string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return levels
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "style":
+			id = attr(se, "styleId")
+		case "name":
+			if lvl := styleNameLevel(attr(se, "val")); lvl > 0 && id != "" {
+				levels[id] = lvl
+			}
+		case "outlineLvl":
+			if n, err := strconv.Atoi(attr(se, "val")); err == nil && id != "" && n < 9 {
+				levels[id] = n + 1
+			}
+		}
+	}
+}
+
+// styleNameLevel returns the heading level of the built-in styles "Title"
+// and "heading N", whose IDs are "Title" and "HeadingN" in English documents.
+func styleNameLevel(name string) int {
+	name = strings.ReplaceAll(strings.ToLower(name), " ", "")
+	if name == "title" {
+		return 1
+	}
+	if n, ok := strings.CutPrefix(name, "heading"); ok {
+		if lvl, err := strconv.Atoi(n); err == nil {
+			return lvl
+		}
+	}
+	return 0
+}
+
+// parseBody collects the paragraphs of word/document.xml. Paragraphs using
+// a style of styles are headings.
+func parseBody(b []byte, styles map[string]int) (*zipdoc.Outline, error) {
+	o := &zipdoc.Outline{}
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	var text strings.Builder
+	level := 0
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return o, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				text.Reset()
+				level = 0
+			case "pStyle":
+				id := attr(t, "val")
+				lvl, ok := styles[id]
+				if !ok {
+					lvl = styleNameLevel(id)
+				}
+				level = lvl
+			case "outlineLvl":
+				if n, err := strconv.Atoi(attr(t, "val")); err == nil && n < 9 {
+					level = n + 1
+				}
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteByte(' ')
+			case "br", "cr":
+				text.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if level > 0 {
+					o.Heading(level, text.String())
+				} else {
+					o.Paragraph(text.String())
+				}
+				text.Reset()
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		}
+	}
+}
+
+// parseCore reads the document properties of docProps/core.xml.
+func parseCore(b []byte) *zipdoc.Meta {
+	m := &zipdoc.Meta{}
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	var field *string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return m
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "title":
+				field = &m.Title
+			case "creator":
+				field = &m.Author
+			case "description", "subject":
+				if m.Description == "" {
+					field = &m.Description
+				}
+			case "language":
+				field = &m.Language
+			case "created":
+				field = &m.Published
+			case "modified":
+				field = &m.Modified
+			}
+		case xml.EndElement:
+			field = nil
+		case xml.CharData:
+			if field != nil {
+				*field += string(t)
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/types"
+)
+
+const (
+	testStyles = `<?xml version="1.0"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:style w:type="paragraph" w:styleId="berschrift1"><w:name w:val="heading 1"/></w:style>
+  <w:style w:type="paragraph" w:styleId="Custom"><w:name w:val="Custom"/><w:pPr><w:outlineLvl w:val="1"/></w:pPr></w:style>
+</w:styles>`
+	testBody = `<?xml version="1.0"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+  <w:p><w:pPr><w:pStyle w:val="berschrift1"/></w:pPr><w:r><w:t>Overview</w:t></w:r></w:p>
+  <w:p><w:r><w:t xml:space="preserve">Hello </w:t></w:r><w:r><w:t>world</w:t><w:tab/><w:t>again</w:t></w:r></w:p>
+  <w:p><w:pPr><w:pStyle w:val="Custom"/></w:pPr><w:r><w:t>Details</w:t></w:r></w:p>
+  <w:p><w:r><w:delText>removed</w:delText><w:t>Kept</w:t></w:r></w:p>
+</w:body></w:document>`
+	testCore = `<?xml version="1.0"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/">
+  <dc:title>Team Handbook</dc:title><dc:creator>Jane Doe</dc:creator>
+  <dcterms:created>2024-03-01T10:00:00Z</dcterms:created>
+</cp:coreProperties>`
+)
+
+func makeDOCX(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"[Content_Types].xml": "<Types/>",
+		"word/document.xml":   testBody,
+		"word/styles.xml":     testStyles,
+		"docProps/core.xml":   testCore,
+	} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	e := &DOCXExtractor{}
+	d := &document.Document{URL: "file:///kb/handbook.docx", ContentType: "application/zip", Raw: makeDOCX(t)}
+	if !e.Match(d) {
+		t.Fatal("expected match for DOCX content")
+	}
+	state, err := e.Extract(d)
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("Extract() = %v, %v", state, err)
+	}
+	if want := "Overview\n\nHello world again\n\nDetails\n\nKept"; d.Text != want {
+		t.Errorf("text = %q, want %q", d.Text, want)
+	}
+	if want := "<h1>Overview</h1><p>Hello world again</p><h2>Details</h2><p>Kept</p>"; d.HTML != want {
+		t.Errorf("html = %q, want %q", d.HTML, want)
+	}
+	if d.Title != "Team Handbook" {
+		t.Errorf("title = %q", d.Title)
+	}
+	if d.Metadata["author"] != "Jane Doe" || d.Metadata["published"] != "2024-03-01T10:00:00Z" {
+		t.Errorf("metadata = %v", d.Metadata)
+	}
+	if d.ContentType != "application/vnd.openxmlformats-officedocument.wordprocessingml.document" {
+		t.Errorf("content type = %q", d.ContentType)
+	}
+}
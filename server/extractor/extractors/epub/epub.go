@@ -0,0 +1,294 @@
+// Package epub provides an extractor for EPUB e-books.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/extractor/zipdoc"
+	"github.com/asciimoo/hister/server/sanitizer"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// EPUBExtractor extracts the chapters and metadata of EPUB e-books.
+type EPUBExtractor struct {
+	cfg *config.Extractor
+}
+
+// Name returns the extractor's identifier.
+func (e *EPUBExtractor) Name() string {
+	return "EPUB"
+}
+
+// Description returns a short summary of what this extractor does.
+func (e *EPUBExtractor) Description() string {
+	return "Extracts the chapters, title and author of EPUB e-books, previews list the table of contents."
+}
+
+// GetConfig returns the extractor's current configuration.
+func (e *EPUBExtractor) GetConfig() *config.Extractor {
+	if e.cfg == nil {
+		return &config.Extractor{Enable: true, Options: map[string]any{}}
+	}
+	return e.cfg
+}
+
+// SetConfig applies cfg to the extractor. Returns an error for unknown options.
+func (e *EPUBExtractor) SetConfig(c *config.Extractor) error {
+	for k := range c.Options {
+		return fmt.Errorf("unknown option %q", k)
+	}
+	e.cfg = c
+	return nil
+}
+
+// Match returns true for EPUB documents.
+func (e *EPUBExtractor) Match(d *document.Document) bool {
+	return zipdoc.Matches(d, zipdoc.EPUB)
+}
+
+// Extract replaces the text of the document with the text of the chapters
+// in reading order, and the HTML with the outline of the book for previews.
+// Documents already extracted are left unchanged.
+func (e *EPUBExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
+	if len(d.Raw) == 0 {
+		return types.ExtractorStop, nil
+	}
+	zr, err := zipdoc.Open(d.Raw)
+	if err != nil {
+		return types.ExtractorAbort, err
+	}
+	opfPath, err := rootFile(zr)
+	if err != nil {
+		return types.ExtractorAbort, err
+	}
+	b, err := zipdoc.ReadFile(zr, opfPath)
+	if err != nil {
+		return types.ExtractorAbort, err
+	}
+	var p pkg
+	if err := xml.Unmarshal(b, &p); err != nil {
+		return types.ExtractorAbort, fmt.Errorf("invalid package document: %w", err)
+	}
+	o := &zipdoc.Outline{}
+	for _, href := range p.chapters(path.Dir(opfPath)) {
+		b, err := zipdoc.ReadFile(zr, href)
+		if err != nil {
+			return types.ExtractorAbort, err
+		}
+		addChapter(o, b)
+	}
+	if o.Empty() {
+		return types.ExtractorAbort, errors.New("no text found")
+	}
+	d.ContentType = zipdoc.EPUB
+	d.Text = o.Text()
+	d.HTML = o.HTML()
+	d.Title = ""
+	m := p.Metadata.meta()
+	m.Apply(d, "EPUB")
+	return types.ExtractorStop, nil
+}
+
+// Preview returns the outline rendered during extraction.
+func (e *EPUBExtractor) Preview(d *document.Document) (types.PreviewResponse, types.ExtractorState, error) {
+	if d.HTML == "" {
+		return types.PreviewResponse{}, types.ExtractorContinue, nil
+	}
+	return types.PreviewResponse{Content: sanitizer.SanitizeHTML(d.HTML)}, types.ExtractorStop, nil
+}
+
+// rootFile returns the path of the package document listed in
+// META-INF/container.xml.
+func rootFile(zr *zip.Reader) (string, error) {
+	b, err := zipdoc.ReadFile(zr, "META-INF/container.xml")
+	if err != nil {
+		return "", err
+	}
+	var c struct {
+		RootFiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(b, &c); err != nil {
+		return "", fmt.Errorf("invalid container: %w", err)
+	}
+	if len(c.RootFiles) == 0 || c.RootFiles[0].FullPath == "" {
+		return "", errors.New("no package document found")
+	}
+	return c.RootFiles[0].FullPath, nil
+}
+
+// pkg is the package document of an EPUB, describing its metadata and the
+// files of the book in reading order.
+type pkg struct {
+	Metadata metadata `xml:"metadata"`
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+type metadata struct {
+	Title       []string `xml:"title"`
+	Creator     []string `xml:"creator"`
+	Description string   `xml:"description"`
+	Language    string   `xml:"language"`
+	Date        string   `xml:"date"`
+	Meta        []struct {
+		Property string `xml:"property,attr"`
+		Value    string `xml:",chardata"`
+	} `xml:"meta"`
+}
+
+func (m *metadata) meta() *zipdoc.Meta {
+	zm := &zipdoc.Meta{
+		Description: m.Description,
+		Language:    m.Language,
+		Published:   m.Date,
+	}
+	if len(m.Title) > 0 {
+		zm.Title = m.Title[0]
+	}
+	zm.Author = strings.Join(m.Creator, ", ")
+	for _, mt := range m.Meta {
+		if mt.Property == "dcterms:modified" {
+			zm.Modified = mt.Value
+		}
+	}
+	return zm
+}
+
+// chapters returns the archive paths of the content documents of the spine.
+// dir is the directory of the package document, which hrefs are relative to.
+func (p *pkg) chapters(dir string) []string {
+	items := make(map[string]string, len(p.Manifest))
+	for _, it := range p.Manifest {
+		if it.MediaType == "application/xhtml+xml" || it.MediaType == "text/html" {
+			items[it.ID] = it.Href
+		}
+	}
+	var paths []string
+	for _, ref := range p.Spine {
+		href, ok := items[ref.IDRef]
+		if !ok {
+			continue
+		}
+		if u, err := url.PathUnescape(href); err == nil {
+			href = u
+		}
+		if dir != "." {
+			href = path.Join(dir, href)
+		}
+		paths = append(paths, path.Clean(href))
+	}
+	return paths
+}
+
+// blockElements end the current paragraph of a chapter.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "li": true, "blockquote": true, "pre": true,
+	"dt": true, "dd": true, "td": true, "th": true, "figcaption": true,
+	"section": true, "article": true, "aside": true, "header": true, "footer": true,
+}
+
+// addChapter adds the headings and paragraphs of a chapter as a section
+// of o. The section is titled with the first heading of the chapter, or the
+// title of the content document.
+func addChapter(o *zipdoc.Outline, b []byte) {
+	type block struct {
+		level int
+		text  string
+	}
+	var blocks []block
+	var title string
+	var text strings.Builder
+	level, skip := 0, 0
+	inTitle := false
+	flush := func() {
+		if t := strings.Join(strings.Fields(text.String()), " "); t != "" {
+			blocks = append(blocks, block{level: level, text: t})
+		}
+		text.Reset()
+	}
+	z := html.NewTokenizer(bytes.NewReader(b))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if !errors.Is(z.Err(), io.EOF) {
+				return
+			}
+			break
+		}
+		tn, _ := z.TagName()
+		name := string(tn)
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch {
+			case name == "script" || name == "style":
+				if tt == html.StartTagToken {
+					skip++
+				}
+			case name == "title":
+				inTitle = tt == html.StartTagToken
+			case name == "br":
+				text.WriteByte(' ')
+			case len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6':
+				flush()
+				level = int(name[1] - '0')
+			case blockElements[name]:
+				flush()
+			}
+		case html.EndTagToken:
+			switch {
+			case name == "script" || name == "style":
+				skip = max(skip-1, 0)
+			case name == "title":
+				inTitle = false
+			case len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6':
+				flush()
+				level = 0
+			case blockElements[name]:
+				flush()
+			}
+		case html.TextToken:
+			switch {
+			case inTitle:
+				title += string(z.Text())
+			case skip == 0:
+				text.Write(z.Text())
+			}
+		}
+	}
+	flush()
+	if len(blocks) == 0 {
+		return
+	}
+	if blocks[0].level > 0 {
+		title = blocks[0].text
+		blocks = blocks[1:]
+	}
+	o.Section(strings.Join(strings.Fields(title), " "))
+	for _, bl := range blocks {
+		if bl.level > 0 {
+			o.Heading(bl.level, bl.text)
+		} else {
+			o.Paragraph(bl.text)
+		}
+	}
+}
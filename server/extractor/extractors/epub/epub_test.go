@@ -0,0 +1,89 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/types"
+)
+
+func makeEPUB(t *testing.T) []byte {
+	t.Helper()
+	files := []struct{ name, content string }{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", `<?xml version="1.0"?>
+<container xmlns="urn:oasis:names:tc:opendocument:xmlns:container" version="1.0">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`},
+		{"OEBPS/content.opf", `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>The Book</dc:title><dc:creator>Ann Author</dc:creator><dc:creator>Bob Writer</dc:creator>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-02-03T04:05:06Z</meta>
+  </metadata>
+  <manifest>
+    <item id="c2" href="text/ch%202.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c1" href="text/ch1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="css" href="style.css" media-type="text/css"/>
+  </manifest>
+  <spine><itemref idref="c1"/><itemref idref="c2"/></spine>
+</package>`},
+		{"OEBPS/text/ch1.xhtml", `<html><head><title>ch1</title><style>p {}</style></head>
+<body><h1>Beginnings</h1><p>It was a dark<br/>night.</p><h2>Later</h2><div>More text</div></body></html>`},
+		{"OEBPS/text/ch 2.xhtml", `<html><head><title>Second Chapter</title></head><body><p>The end.</p></body></html>`},
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range files {
+		fw, err := w.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	e := &EPUBExtractor{}
+	d := &document.Document{URL: "file:///books/book.epub", ContentType: "application/zip", Raw: makeEPUB(t)}
+	if !e.Match(d) {
+		t.Fatal("expected match for EPUB content")
+	}
+	state, err := e.Extract(d)
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("Extract() = %v, %v", state, err)
+	}
+	if want := "Beginnings\n\nIt was a dark night.\n\nLater\n\nMore text\n\nSecond Chapter\n\nThe end."; d.Text != want {
+		t.Errorf("text = %q, want %q", d.Text, want)
+	}
+	if d.Title != "The Book" {
+		t.Errorf("title = %q", d.Title)
+	}
+	if d.Metadata["author"] != "Ann Author, Bob Writer" || d.Metadata["modified"] != "2024-02-03T04:05:06Z" {
+		t.Errorf("metadata = %v", d.Metadata)
+	}
+	for _, s := range []string{
+		"<li>Beginnings</li><li>Second Chapter</li>",
+		"<section><h1>Beginnings</h1><p>It was a dark night.</p><h3>Later</h3>",
+		"<section><h1>Second Chapter</h1><p>The end.</p></section>",
+	} {
+		if !strings.Contains(d.HTML, s) {
+			t.Errorf("html %q does not contain %q", d.HTML, s)
+		}
+	}
+
+	resp, state, err := e.Preview(d)
+	if err != nil || state != types.ExtractorStop || !strings.Contains(resp.Content, "Second Chapter") {
+		t.Errorf("Preview() = %v, %v, %v", resp, state, err)
+	}
+}
@@ -0,0 +1,119 @@
+// Package git provides an extractor for commits read from local git
+// repositories.
+package git
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/extractor/extractors/code"
+	"github.com/asciimoo/hister/server/gitrepo"
+	"github.com/asciimoo/hister/server/sanitizer"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// Metadata keys of commits and of the files of repositories.
+const (
+	MetaAuthor     = "author"
+	MetaDate       = "date"
+	MetaHash       = "hash"
+	MetaPaths      = "paths"
+	MetaRepository = "repository"
+)
+
+// GitExtractor renders previews of commits, with the diff read from the
+// repository.
+type GitExtractor struct {
+	cfg *config.Extractor
+}
+
+// Name returns the extractor's identifier.
+func (e *GitExtractor) Name() string {
+	return "Git"
+}
+
+// Description returns a short summary of what this extractor does.
+func (e *GitExtractor) Description() string {
+	return "Previews commits of local git repositories with their message and highlighted diff."
+}
+
+// GetConfig returns the extractor's current configuration.
+func (e *GitExtractor) GetConfig() *config.Extractor {
+	if e.cfg == nil {
+		return &config.Extractor{Enable: true, Options: map[string]any{}}
+	}
+	return e.cfg
+}
+
+// SetConfig applies cfg to the extractor. Returns an error for unknown options.
+func (e *GitExtractor) SetConfig(c *config.Extractor) error {
+	for k := range c.Options {
+		return fmt.Errorf("unknown option %q", k)
+	}
+	e.cfg = c
+	return nil
+}
+
+// Match returns true for commits.
+func (e *GitExtractor) Match(d *document.Document) bool {
+	return d.ContentType == gitrepo.ContentType
+}
+
+// Extract leaves commits unchanged, their fields are set by the indexer
+// from the repository.
+func (e *GitExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
+	return types.ExtractorStop, nil
+}
+
+// Preview renders the author, the date and the message of the commit
+// followed by its diff. The diff is left out if the repository is no longer
+// available.
+func (e *GitExtractor) Preview(d *document.Document) (types.PreviewResponse, types.ExtractorState, error) {
+	var b strings.Builder
+	b.WriteString("<dl>")
+	for _, h := range []struct{ name, key string }{
+		{"Repository", MetaRepository}, {"Author", MetaAuthor}, {"Date", MetaDate}, {"Commit", MetaHash},
+	} {
+		v, _ := d.Metadata[h.key].(string)
+		if v == "" {
+			continue
+		}
+		if h.key == MetaDate {
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				v = t.Local().Format("2006-01-02 15:04")
+			}
+		}
+		b.WriteString("<dt>" + h.name + "</dt><dd>" + html.EscapeString(v) + "</dd>")
+	}
+	b.WriteString(`</dl><hr><pre style="white-space: pre-wrap">` + html.EscapeString(d.Text) + "</pre>")
+	if s := diff(d); s != "" {
+		b.WriteString("<hr>" + s)
+	}
+	return types.PreviewResponse{Content: sanitizer.SanitizeHTML(b.String())}, types.ExtractorStop, nil
+}
+
+// diff returns the highlighted diff of the commit d, or an empty string if
+// it can't be read.
+func diff(d *document.Document) string {
+	path, hash, _, err := gitrepo.ParseURL(d.URL)
+	if err != nil || hash == "" {
+		return ""
+	}
+	r, err := gitrepo.Open(path)
+	if err != nil {
+		return ""
+	}
+	patch, err := r.Diff(hash)
+	if err != nil || patch == "" {
+		return ""
+	}
+	s, err := code.Highlight("commit.diff", patch)
+	if err != nil {
+		return "<pre>" + html.EscapeString(patch) + "</pre>"
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package mail
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// blockElements end the current line of the text of HTML bodies.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "blockquote": true,
+	"pre": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"table": true, "ul": true, "ol": true, "hr": true,
+}
+
+// htmlToText returns the text of an HTML body, one line per block element.
+func htmlToText(s string) string {
+	z := html.NewTokenizer(strings.NewReader(s))
+	var lines []string
+	var line strings.Builder
+	skip := 0
+	flush := func() {
+		if t := strings.Join(strings.Fields(line.String()), " "); t != "" {
+			lines = append(lines, t)
+		}
+		line.Reset()
+	}
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tn, _ := z.TagName()
+		name := string(tn)
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch {
+			case name == "script" || name == "style" || name == "head":
+				if tt == html.StartTagToken {
+					skip++
+				}
+			case blockElements[name]:
+				flush()
+			case name == "td" || name == "th":
+				line.WriteByte(' ')
+			}
+		case html.EndTagToken:
+			switch {
+			case name == "script" || name == "style" || name == "head":
+				skip = max(skip-1, 0)
+			case blockElements[name]:
+				flush()
+			}
+		case html.TextToken:
+			if skip == 0 {
+				line.Write(z.Text())
+			}
+		}
+	}
+	flush()
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,350 @@
+// Package mail provides an extractor for e-mail messages read from
+// mailboxes.
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/mailbox"
+	"github.com/asciimoo/hister/server/sanitizer"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// Metadata keys written by the extractor.
+const (
+	MetaFrom        = "from"
+	MetaTo          = "to"
+	MetaCc          = "cc"
+	MetaDate        = "date"
+	MetaMessageID   = "message_id"
+	MetaThread      = "thread"
+	MetaList        = "list"
+	MetaAttachments = "attachments"
+)
+
+// maxDepth limits the nesting of multipart bodies.
+const maxDepth = 10
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// MailExtractor extracts the headers and the text of e-mail messages.
+type MailExtractor struct {
+	cfg *config.Extractor
+}
+
+// Name returns the extractor's identifier.
+func (e *MailExtractor) Name() string {
+	return "Mail"
+}
+
+// Description returns a short summary of what this extractor does.
+func (e *MailExtractor) Description() string {
+	return "Extracts the subject, sender, recipients, date, thread and text of e-mail messages read from mbox files and Maildir folders."
+}
+
+// GetConfig returns the extractor's current configuration.
+func (e *MailExtractor) GetConfig() *config.Extractor {
+	if e.cfg == nil {
+		return &config.Extractor{Enable: true, Options: map[string]any{}}
+	}
+	return e.cfg
+}
+
+// SetConfig applies cfg to the extractor. Returns an error for unknown options.
+func (e *MailExtractor) SetConfig(c *config.Extractor) error {
+	for k := range c.Options {
+		return fmt.Errorf("unknown option %q", k)
+	}
+	e.cfg = c
+	return nil
+}
+
+// Match returns true for e-mail messages.
+func (e *MailExtractor) Match(d *document.Document) bool {
+	return d.ContentType == mailbox.ContentType
+}
+
+// Extract parses the MIME message in the raw content of the document. The
+// subject becomes the title, the plain text body the text, and the HTML
+// body, if any, is kept for previews. Documents already extracted are left
+// unchanged.
+func (e *MailExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
+	if len(d.Raw) == 0 {
+		return types.ExtractorStop, nil
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(d.Raw))
+	if err != nil {
+		return types.ExtractorAbort, err
+	}
+	b := &body{}
+	if err := b.read(textproto.MIMEHeader(m.Header), m.Body, 0); err != nil {
+		return types.ExtractorAbort, err
+	}
+	d.Title = decodeHeader(m.Header.Get("Subject"))
+	if d.Title == "" {
+		d.Title = "(no subject)"
+	}
+	d.Text = b.text()
+	d.HTML = b.html
+	if d.Metadata == nil {
+		d.Metadata = make(map[string]any)
+	}
+	d.Metadata["type"] = "Email"
+	setMeta(d, MetaFrom, strings.Join(addresses(m.Header.Get("From")), ", "))
+	setMeta(d, MetaTo, addresses(m.Header.Get("To")))
+	setMeta(d, MetaCc, addresses(m.Header.Get("Cc")))
+	if t, err := mail.ParseDate(m.Header.Get("Date")); err == nil {
+		setMeta(d, MetaDate, t.UTC().Format(time.RFC3339))
+	}
+	id := mailbox.MessageID(m.Header.Get("Message-Id"))
+	setMeta(d, MetaMessageID, id)
+	setMeta(d, MetaThread, threadID(m.Header, id))
+	setMeta(d, MetaList, mailbox.MessageID(decodeHeader(m.Header.Get("List-Id"))))
+	setMeta(d, MetaAttachments, b.attachments)
+	return types.ExtractorStop, nil
+}
+
+// Preview renders the headers and the body of the message.
+func (e *MailExtractor) Preview(d *document.Document) (types.PreviewResponse, types.ExtractorState, error) {
+	var b strings.Builder
+	b.WriteString("<dl>")
+	for _, h := range []struct{ name, key string }{
+		{"From", MetaFrom}, {"To", MetaTo}, {"Cc", MetaCc}, {"Date", MetaDate},
+		{"List", MetaList}, {"Attachments", MetaAttachments},
+	} {
+		v := strings.Join(metaStrings(d.Metadata[h.key]), ", ")
+		if v == "" {
+			continue
+		}
+		if h.key == MetaDate {
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				v = t.Local().Format("2006-01-02 15:04")
+			}
+		}
+		b.WriteString("<dt>" + h.name + "</dt><dd>" + html.EscapeString(v) + "</dd>")
+	}
+	b.WriteString("</dl><hr>")
+	if d.HTML != "" {
+		b.WriteString(d.HTML)
+	} else {
+		b.WriteString(`<pre style="white-space: pre-wrap">` + html.EscapeString(d.Text) + "</pre>")
+	}
+	return types.PreviewResponse{Content: sanitizer.SanitizeHTML(b.String())}, types.ExtractorStop, nil
+}
+
+// body collects the parts of a message.
+type body struct {
+	plain       []string
+	htmlText    []string
+	html        string
+	attachments []string
+}
+
+// text returns the plain text parts of the body, or the text of the HTML
+// parts for messages without plain text.
+func (b *body) text() string {
+	parts := b.plain
+	if len(parts) == 0 {
+		parts = b.htmlText
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n\n"))
+}
+
+// read adds the part with header h and content r to the body. Of the
+// alternatives of multipart/alternative parts, the plain text and the HTML
+// ones are kept.
+func (b *body) read(h textproto.MIMEHeader, r io.Reader, depth int) error {
+	mt, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mt, params = "text/plain", nil
+	}
+	disposition, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	if disposition == "attachment" || (disposition == "inline" && (dparams["filename"] != "" || !strings.HasPrefix(mt, "text/"))) {
+		name := decodeHeader(dparams["filename"])
+		if name == "" {
+			name = decodeHeader(params["name"])
+		}
+		if name != "" {
+			b.attachments = append(b.attachments, name)
+		}
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(mt, "multipart/"):
+		if depth >= maxDepth || params["boundary"] == "" {
+			return nil
+		}
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				// Truncated messages keep the parts read so far.
+				return nil
+			}
+			if err := b.read(p.Header, p, depth+1); err != nil {
+				return err
+			}
+		}
+	case mt == "text/plain":
+		s, err := decodeText(h, params["charset"], r)
+		if err != nil {
+			return err
+		}
+		b.plain = append(b.plain, s)
+	case mt == "text/html":
+		s, err := decodeText(h, params["charset"], r)
+		if err != nil {
+			return err
+		}
+		if b.html == "" {
+			b.html = s
+		}
+		b.htmlText = append(b.htmlText, htmlToText(s))
+	default:
+		if name := decodeHeader(params["name"]); name != "" {
+			b.attachments = append(b.attachments, name)
+		}
+	}
+	return nil
+}
+
+// decodeText decodes the content transfer encoding and the charset of a
+// text part.
+func decodeText(h textproto.MIMEHeader, cs string, r io.Reader) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(h.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: r})
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	}
+	if cs != "" && !strings.EqualFold(cs, "utf-8") && !strings.EqualFold(cs, "us-ascii") {
+		cr, err := charset.NewReaderLabel(cs, r)
+		if err == nil {
+			r = cr
+		}
+	}
+	c, err := io.ReadAll(r)
+	if err != nil && len(c) == 0 {
+		return "", err
+	}
+	s := string(c)
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "�")
+	}
+	return strings.ReplaceAll(s, "\r\n", "\n"), nil
+}
+
+// base64Cleaner drops the line breaks and other whitespace of base64
+// encoded bodies.
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+		j := 0
+		for _, ch := range p[:n] {
+			if ch != '\r' && ch != '\n' && ch != ' ' && ch != '\t' {
+				p[j] = ch
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+// threadID returns the ID of the first message of the thread of the message
+// with the headers h: the first of its references, the message it replies
+// to, or the message itself.
+func threadID(h mail.Header, id string) string {
+	if refs := mailbox.MessageIDs(h.Get("References")); len(refs) > 0 {
+		return refs[0]
+	}
+	if r := mailbox.MessageID(h.Get("In-Reply-To")); r != "" {
+		return r
+	}
+	return id
+}
+
+// addresses returns the addresses of an address list header as
+// "Name <address>".
+func addresses(v string) []string {
+	if strings.TrimSpace(v) == "" {
+		return nil
+	}
+	p := &mail.AddressParser{WordDecoder: wordDecoder}
+	list, err := p.ParseList(v)
+	if err != nil {
+		return []string{decodeHeader(v)}
+	}
+	r := make([]string, 0, len(list))
+	for _, a := range list {
+		if a.Name == "" {
+			r = append(r, a.Address)
+		} else {
+			r = append(r, a.Name+" <"+a.Address+">")
+		}
+	}
+	return r
+}
+
+// decodeHeader decodes the RFC 2047 encoded words of a header value.
+func decodeHeader(v string) string {
+	s, err := wordDecoder.DecodeHeader(v)
+	if err != nil {
+		s = v
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// setMeta sets the metadata key of d to v, or removes it when v is empty.
+func setMeta[T string | []string](d *document.Document, key string, v T) {
+	if len(v) == 0 {
+		delete(d.Metadata, key)
+		return
+	}
+	d.Metadata[key] = v
+}
+
+// metaStrings returns the string or the strings of a metadata value. Lists
+// of a single item are loaded from the index as a plain string.
+func metaStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		r := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				r = append(r, s)
+			}
+		}
+		return r
+	}
+	return nil
+}
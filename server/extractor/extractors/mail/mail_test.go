@@ -0,0 +1,127 @@
+package mail
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/mailbox"
+	"github.com/asciimoo/hister/server/types"
+)
+
+const testMessage = "Message-ID: <reply@example.com>\r\n" +
+	"From: =?UTF-8?Q?Ren=C3=A9e_Doe?= <renee@example.com>\r\n" +
+	"To: golang-nuts@googlegroups.com\r\n" +
+	"Cc: Bob <bob@example.com>, carol@example.com\r\n" +
+	"Date: Mon, 6 Jan 2025 10:00:00 +0100\r\n" +
+	"Subject: =?ISO-8859-1?Q?Re:_caf=E9?=\r\n" +
+	"In-Reply-To: <parent@example.com>\r\n" +
+	"References: <root@example.com> <parent@example.com>\r\n" +
+	"List-Id: Go Nuts <golang-nuts.googlegroups.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=iso-8859-1\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Un caf=E9 s'il vous pla=EEt, avec une longue ligne qui est coup=\r\n" +
+	"=E9e.\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"PHA+VW4gY2Fmw6k8L3A+\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf; name=menu.pdf\r\n" +
+	"Content-Disposition: attachment; filename=menu.pdf\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0=\r\n" +
+	"--outer--\r\n"
+
+func TestExtract(t *testing.T) {
+	d := &document.Document{URL: "mid:reply@example.com", Raw: []byte(testMessage), ContentType: mailbox.ContentType}
+	e := &MailExtractor{}
+	if !e.Match(d) {
+		t.Fatal("expected match for message")
+	}
+	state, err := e.Extract(d)
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("unexpected result %v %v", state, err)
+	}
+	if d.Title != "Re: café" {
+		t.Fatalf("unexpected title %q", d.Title)
+	}
+	if d.Text != "Un café s'il vous plaît, avec une longue ligne qui est coupée." {
+		t.Fatalf("unexpected text %q", d.Text)
+	}
+	if d.HTML != "<p>Un café</p>" {
+		t.Fatalf("unexpected HTML %q", d.HTML)
+	}
+	want := map[string]any{
+		MetaFrom:        "Renée Doe <renee@example.com>",
+		MetaTo:          []string{"golang-nuts@googlegroups.com"},
+		MetaCc:          []string{"Bob <bob@example.com>", "carol@example.com"},
+		MetaDate:        "2025-01-06T09:00:00Z",
+		MetaMessageID:   "reply@example.com",
+		MetaThread:      "root@example.com",
+		MetaList:        "golang-nuts.googlegroups.com",
+		MetaAttachments: []string{"menu.pdf"},
+	}
+	for k, v := range want {
+		if got := d.Metadata[k]; !equal(got, v) {
+			t.Errorf("%s: expected %v, got %v", k, v, got)
+		}
+	}
+}
+
+func equal(a, b any) bool {
+	if s, ok := a.([]string); ok {
+		t, ok := b.([]string)
+		return ok && slices.Equal(s, t)
+	}
+	return a == b
+}
+
+func TestExtractPlain(t *testing.T) {
+	raw := "Message-ID: <root@example.com>\nFrom: jane@example.com\nContent-Type: text/html\n\n<html><head><style>p{}</style></head><body><p>Hello</p><div>World</div></body></html>\n"
+	d := &document.Document{URL: "mid:root@example.com", Raw: []byte(raw), ContentType: mailbox.ContentType}
+	if _, err := (&MailExtractor{}).Extract(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "(no subject)" || d.Text != "Hello\nWorld" {
+		t.Fatalf("unexpected title %q and text %q", d.Title, d.Text)
+	}
+	if d.Metadata[MetaThread] != "root@example.com" || d.Metadata[MetaFrom] != "jane@example.com" {
+		t.Fatalf("unexpected metadata %v", d.Metadata)
+	}
+	if _, ok := d.Metadata[MetaTo]; ok {
+		t.Fatal("expected no recipients")
+	}
+}
+
+func TestPreview(t *testing.T) {
+	d := &document.Document{
+		Text:        "<b>not bold</b>",
+		ContentType: mailbox.ContentType,
+		Metadata: map[string]any{
+			MetaFrom: "jane@example.com",
+			MetaTo:   []any{"a@example.com", "b@example.com"},
+		},
+	}
+	p, state, err := (&MailExtractor{}).Preview(d)
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("unexpected result %v %v", state, err)
+	}
+	for _, s := range []string{"jane@example.com", "a@example.com, b@example.com", "&lt;b&gt;not bold&lt;/b&gt;"} {
+		if !strings.Contains(p.Content, s) {
+			t.Fatalf("expected %q in preview %q", s, p.Content)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package notes
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+	"gopkg.in/yaml.v3"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/sanitizer"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// MarkdownContentType is the MIME type of Markdown notes.
+const MarkdownContentType = "text/markdown"
+
+var markdownExts = []string{".md", ".markdown", ".mdown", ".mkd"}
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// MarkdownExtractor extracts the front matter, title and wiki-links of
+// Markdown notes and renders them for previews.
+type MarkdownExtractor struct {
+	cfg *config.Extractor
+}
+
+// Name returns the extractor's identifier.
+func (e *MarkdownExtractor) Name() string {
+	return "Markdown"
+}
+
+// Description returns a short summary of what this extractor does.
+func (e *MarkdownExtractor) Description() string {
+	return "Indexes the YAML or TOML front matter, first heading and wiki-links of local Markdown notes and renders them in previews."
+}
+
+// GetConfig returns the extractor's current configuration.
+func (e *MarkdownExtractor) GetConfig() *config.Extractor {
+	if e.cfg == nil {
+		return &config.Extractor{Enable: true, Options: map[string]any{}}
+	}
+	return e.cfg
+}
+
+// SetConfig applies cfg to the extractor. Returns an error for unknown options.
+func (e *MarkdownExtractor) SetConfig(c *config.Extractor) error {
+	for k := range c.Options {
+		return fmt.Errorf("unknown option %q", k)
+	}
+	e.cfg = c
+	return nil
+}
+
+// Match returns true for local Markdown files.
+func (e *MarkdownExtractor) Match(d *document.Document) bool {
+	return d.ContentType == MarkdownContentType || isLocalFile(d, markdownExts...)
+}
+
+// Extract keeps the source of the note as its text, so the front matter
+// remains searchable, and fills in the title and metadata. Invalid front
+// matter is ignored.
+func (e *MarkdownExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
+	if d.Text == "" {
+		return types.ExtractorContinue, nil
+	}
+	d.ContentType = MarkdownContentType
+	fm, body := splitFrontMatter(d.Text)
+	ensureMetadata(d)
+	d.Metadata["type"] = "Markdown"
+	if fm != nil {
+		applyFrontMatter(d, fm)
+	}
+	if d.Title == "" {
+		d.Title = firstHeading([]byte(body))
+	}
+	setLinks(d, wikiLinks(body))
+	return types.ExtractorStop, nil
+}
+
+// Preview renders the note without its front matter.
+func (e *MarkdownExtractor) Preview(d *document.Document) (types.PreviewResponse, types.ExtractorState, error) {
+	_, body := splitFrontMatter(d.Text)
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(body), &buf); err != nil {
+		return types.PreviewResponse{}, types.ExtractorContinue, err
+	}
+	return types.PreviewResponse{Content: sanitizer.SanitizeHTML(buf.String())}, types.ExtractorStop, nil
+}
+
+// splitFrontMatter separates the YAML (---) or TOML (+++) front matter of
+// src from the body. fm is nil when there is no valid front matter.
+func splitFrontMatter(src string) (fm map[string]any, body string) {
+	src = strings.TrimPrefix(src, "\ufeff")
+	first, rest, ok := strings.Cut(src, "\n")
+	if !ok {
+		return nil, src
+	}
+	delim := strings.TrimSpace(first)
+	if delim != "---" && delim != "+++" {
+		return nil, src
+	}
+	var raw strings.Builder
+	for rest != "" {
+		var line string
+		line, rest, _ = strings.Cut(rest, "\n")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == delim || (delim == "---" && trimmed == "...") {
+			var err error
+			fm = map[string]any{}
+			if delim == "---" {
+				err = yaml.Unmarshal([]byte(raw.String()), &fm)
+			} else {
+				err = toml.Unmarshal([]byte(raw.String()), &fm)
+			}
+			if err != nil {
+				return nil, src
+			}
+			return fm, rest
+		}
+		raw.WriteString(line)
+		raw.WriteByte('\n')
+	}
+	return nil, src
+}
+
+// firstHeading returns the text of the first heading of a Markdown
+// document.
+func firstHeading(src []byte) string {
+	doc := markdown.Parser().Parse(text.NewReader(src))
+	var title string
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if _, ok := n.(*ast.Heading); ok {
+			title = strings.TrimSpace(nodeText(n, src))
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return title
+}
+
+// nodeText concatenates the text of the descendants of n.
+func nodeText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch t := c.(type) {
+		case *ast.Text:
+			b.Write(t.Segment.Value(src))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(t.Value)
+		default:
+			b.WriteString(nodeText(c, src))
+		}
+	}
+	return b.String()
+}
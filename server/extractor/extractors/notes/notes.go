@@ -0,0 +1,202 @@
+// Package notes provides extractors for Markdown and Org-mode notes stored
+// in local files. Front matter is copied to the metadata of the document,
+// the first heading becomes the title and wiki-links are recorded as the
+// outbound links of the note.
+package notes
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asciimoo/hister/server/document"
+)
+
+// Metadata keys written by the extractors, besides the generic author,
+// description, published and modified keys.
+const (
+	MetaTags  = "tags"
+	MetaLinks = "links"
+)
+
+// isLocalFile reports whether d is a local file with one of the extensions
+// exts.
+func isLocalFile(d *document.Document, exts ...string) bool {
+	if !strings.HasPrefix(d.URL, "file://") {
+		return false
+	}
+	return slices.Contains(exts, strings.ToLower(path.Ext(d.URL)))
+}
+
+// ensureMetadata initializes the metadata map of d.
+func ensureMetadata(d *document.Document) {
+	if d.Metadata == nil {
+		d.Metadata = make(map[string]any)
+	}
+}
+
+// The front matter keys mapped to the generic metadata keys, in order of
+// preference.
+var (
+	tagKeys         = []string{"tags", "tag", "keywords", "categories"}
+	authorKeys      = []string{"author", "authors"}
+	descriptionKeys = []string{"description", "summary", "subtitle"}
+	publishedKeys   = []string{"date", "created", "published"}
+	modifiedKeys    = []string{"updated", "lastmod", "modified"}
+
+	knownKeys = slices.Concat([]string{"title"}, tagKeys, authorKeys, descriptionKeys, publishedKeys, modifiedKeys)
+)
+
+// applyFrontMatter copies the front matter fm to d. Keys with a generic
+// meaning, like the title, tags and dates, are normalized. The other
+// scalar values are stored under their own lowercased key unless the key
+// is already in use.
+func applyFrontMatter(d *document.Document, fm map[string]any) {
+	ensureMetadata(d)
+	norm := make(map[string]any, len(fm))
+	for k, v := range fm {
+		norm[strings.ToLower(k)] = v
+	}
+	if t := strings.TrimSpace(scalar(norm["title"])); t != "" {
+		d.Title = t
+	}
+	var tags []string
+	for _, k := range tagKeys {
+		tags = append(tags, list(norm[k])...)
+	}
+	addTags(d, tags...)
+	setFirst := func(metaKey string, keys []string, format func(string) string) {
+		for _, k := range keys {
+			if v := strings.Join(list(norm[k]), ", "); v != "" {
+				d.Metadata[metaKey] = format(v)
+				return
+			}
+		}
+	}
+	identity := func(s string) string { return s }
+	setFirst("author", authorKeys, identity)
+	setFirst("description", descriptionKeys, identity)
+	setFirst("published", publishedKeys, normalizeDate)
+	setFirst("modified", modifiedKeys, normalizeDate)
+
+	for k, v := range norm {
+		if slices.Contains(knownKeys, k) {
+			continue
+		}
+		if _, ok := d.Metadata[k]; ok {
+			continue
+		}
+		if s := scalar(v); s != "" {
+			d.Metadata[k] = s
+		}
+	}
+}
+
+// addTags merges tags into the tags of d.
+func addTags(d *document.Document, tags ...string) {
+	seen := map[string]bool{}
+	var all []string
+	for _, t := range slices.Concat(list(d.Metadata[MetaTags]), tags) {
+		t = strings.TrimSpace(strings.TrimPrefix(t, "#"))
+		if t != "" && !seen[strings.ToLower(t)] {
+			seen[strings.ToLower(t)] = true
+			all = append(all, t)
+		}
+	}
+	if len(all) > 0 {
+		ensureMetadata(d)
+		d.Metadata[MetaTags] = all
+	}
+}
+
+// scalar formats strings, numbers, booleans and dates. Other values, like
+// nested maps, are dropped.
+func scalar(v any) string {
+	switch x := v.(type) {
+	case string:
+		return strings.TrimSpace(x)
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(x)
+	}
+	return ""
+}
+
+// list returns the items of a list value, or of a comma separated string.
+func list(v any) []string {
+	var out []string
+	switch x := v.(type) {
+	case []any:
+		for _, it := range x {
+			if s := scalar(it); s != "" {
+				out = append(out, s)
+			}
+		}
+	case []string:
+		for _, s := range x {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	default:
+		for s := range strings.SplitSeq(scalar(v), ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// normalizeDate formats dates as RFC 3339. Unparsable dates are returned
+// unchanged.
+func normalizeDate(s string) string {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return s
+}
+
+// setLinks records the targets of the links of a note, sorted and without
+// duplicates.
+func setLinks(d *document.Document, links []string) {
+	if len(links) == 0 {
+		delete(d.Metadata, MetaLinks)
+		return
+	}
+	sort.Strings(links)
+	ensureMetadata(d)
+	d.Metadata[MetaLinks] = slices.Compact(links)
+}
+
+// wikiLinkRe matches [[target]] links, the target may be followed by an
+// alias (|alias) in Markdown or a description ([description]) in Org.
+var wikiLinkRe = regexp.MustCompile(`\[\[([^\[\]|]+)(?:\|[^\[\]]*|\]\[[^\[\]]*)?\]\]`)
+
+// wikiLinks returns the targets of the wiki-links of src. Anchors within
+// the target, like [[note#section]], are dropped, and links to headings of
+// the note itself are skipped.
+func wikiLinks(src string) []string {
+	var links []string
+	for _, m := range wikiLinkRe.FindAllStringSubmatch(src, -1) {
+		t := strings.TrimSpace(m[1])
+		if strings.HasPrefix(t, "#") || strings.HasPrefix(t, "*") {
+			continue
+		}
+		if i := strings.Index(t, "#"); i > 0 && !strings.Contains(t, "://") {
+			t = strings.TrimSpace(t[:i])
+		}
+		if t != "" {
+			links = append(links, t)
+		}
+	}
+	return links
+}
@@ -0,0 +1,149 @@
+package notes
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/types"
+)
+
+const testMarkdown = `---
+title: Weekly review
+tags: [work, "#planning"]
+date: 2024-03-01
+status: draft
+---
+
+# Goals
+
+See [[Projects/Hister|the project]] and [[inbox#today]], not [[#Goals]].
+`
+
+const testOrg = `#+TITLE: Reading list
+#+FILETAGS: :books:reading:
+#+DATE: 2024-05-02
+#+INCLUDE: "/etc/passwd"
+
+* Fiction :novel:
+Borrow [[file:library.org][the library]] notes and [[*Fiction]].
+** Sci-fi :scifi:novel:
+`
+
+func TestSplitFrontMatter(t *testing.T) {
+	fm, body := splitFrontMatter(testMarkdown)
+	if fm["title"] != "Weekly review" {
+		t.Fatalf("unexpected front matter %v", fm)
+	}
+	if !strings.HasPrefix(body, "\n# Goals") {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	fm, body = splitFrontMatter("+++\ntitle = \"TOML\"\ntags = [\"a\"]\n+++\nBody\n")
+	if fm["title"] != "TOML" || body != "Body\n" {
+		t.Fatalf("unexpected TOML front matter %v, body %q", fm, body)
+	}
+
+	for _, src := range []string{"No front matter", "---\nunterminated: true\n", "---\n: [invalid\n---\nBody"} {
+		if fm, body := splitFrontMatter(src); fm != nil || body != src {
+			t.Fatalf("expected no front matter in %q, got %v", src, fm)
+		}
+	}
+}
+
+func TestWikiLinks(t *testing.T) {
+	got := wikiLinks("[[a]] [[b|alias]] [[c#anchor]] [[#local]] [[*Heading]] [[https://example.com/#x][link]]")
+	want := []string{"a", "b", "c", "https://example.com/#x"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestMarkdownExtract(t *testing.T) {
+	e := &MarkdownExtractor{}
+	d := &document.Document{URL: "file:///notes/review.md", Text: testMarkdown}
+	if !e.Match(d) {
+		t.Fatal("expected match for Markdown file")
+	}
+	if e.Match(&document.Document{URL: "https://example.com/review.md"}) {
+		t.Fatal("expected no match for remote Markdown")
+	}
+	state, err := e.Extract(d)
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("unexpected result %v %v", state, err)
+	}
+	if d.Title != "Weekly review" {
+		t.Fatalf("expected front matter title, got %q", d.Title)
+	}
+	if d.Text != testMarkdown {
+		t.Fatal("expected the source to be kept as text")
+	}
+	if tags := d.Metadata[MetaTags]; !slices.Equal(tags.([]string), []string{"work", "planning"}) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if d.Metadata["published"] != "2024-03-01T00:00:00Z" || d.Metadata["status"] != "draft" {
+		t.Fatalf("unexpected metadata %v", d.Metadata)
+	}
+	if links := d.Metadata[MetaLinks]; !slices.Equal(links.([]string), []string{"Projects/Hister", "inbox"}) {
+		t.Fatalf("unexpected links %v", links)
+	}
+
+	p, state, err := e.Preview(d)
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("unexpected preview result %v %v", state, err)
+	}
+	if !strings.Contains(p.Content, "<h1") || strings.Contains(p.Content, "status: draft") {
+		t.Fatalf("unexpected preview %q", p.Content)
+	}
+}
+
+func TestMarkdownFirstHeading(t *testing.T) {
+	d := &document.Document{URL: "file:///notes/todo.md", Text: "Intro\n\n## Shopping *list*\n\n# Later\n"}
+	if _, err := (&MarkdownExtractor{}).Extract(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "Shopping list" {
+		t.Fatalf("expected first heading as title, got %q", d.Title)
+	}
+}
+
+func TestOrgExtract(t *testing.T) {
+	e := &OrgExtractor{}
+	d := &document.Document{URL: "file:///notes/reading.org", Text: testOrg}
+	if !e.Match(d) {
+		t.Fatal("expected match for Org file")
+	}
+	state, err := e.Extract(d)
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("unexpected result %v %v", state, err)
+	}
+	if d.Title != "Reading list" {
+		t.Fatalf("expected #+TITLE as title, got %q", d.Title)
+	}
+	if tags := d.Metadata[MetaTags]; !slices.Equal(tags.([]string), []string{"books", "reading", "novel", "scifi"}) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if d.Metadata["published"] != "2024-05-02T00:00:00Z" || d.Metadata["type"] != "Org" {
+		t.Fatalf("unexpected metadata %v", d.Metadata)
+	}
+	if links := d.Metadata[MetaLinks]; !slices.Equal(links.([]string), []string{"library.org"}) {
+		t.Fatalf("unexpected links %v", links)
+	}
+
+	p, _, err := e.Preview(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(p.Content, "Fiction") || strings.Contains(p.Content, "root:") {
+		t.Fatalf("unexpected preview %q", p.Content)
+	}
+
+	d = &document.Document{URL: "file:///notes/untitled.org", Text: "* First headline\nText\n"}
+	if _, err := e.Extract(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "First headline" {
+		t.Fatalf("expected first headline as title, got %q", d.Title)
+	}
+}
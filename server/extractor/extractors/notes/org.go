@@ -0,0 +1,132 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/niklasfasching/go-org/org"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/sanitizer"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// OrgContentType is the MIME type of Org-mode notes.
+const OrgContentType = "text/org"
+
+var errIncludeDenied = errors.New("#+INCLUDE is not supported")
+
+// OrgExtractor extracts the keywords, title, tags and links of Org-mode
+// notes and renders them for previews.
+type OrgExtractor struct {
+	cfg *config.Extractor
+}
+
+// Name returns the extractor's identifier.
+func (e *OrgExtractor) Name() string {
+	return "Org"
+}
+
+// Description returns a short summary of what this extractor does.
+func (e *OrgExtractor) Description() string {
+	return "Indexes the keywords, headline tags, first headline and links of local Org-mode notes and renders them in previews."
+}
+
+// GetConfig returns the extractor's current configuration.
+func (e *OrgExtractor) GetConfig() *config.Extractor {
+	if e.cfg == nil {
+		return &config.Extractor{Enable: true, Options: map[string]any{}}
+	}
+	return e.cfg
+}
+
+// SetConfig applies cfg to the extractor. Returns an error for unknown options.
+func (e *OrgExtractor) SetConfig(c *config.Extractor) error {
+	for k := range c.Options {
+		return fmt.Errorf("unknown option %q", k)
+	}
+	e.cfg = c
+	return nil
+}
+
+// Match returns true for local Org files.
+func (e *OrgExtractor) Match(d *document.Document) bool {
+	return d.ContentType == OrgContentType || isLocalFile(d, ".org")
+}
+
+// Extract keeps the source of the note as its text and fills in the title
+// and metadata from the #+KEYWORD lines and the headlines.
+func (e *OrgExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
+	if d.Text == "" {
+		return types.ExtractorContinue, nil
+	}
+	doc := parseOrg(d.Text)
+	if doc.Error != nil {
+		return types.ExtractorContinue, doc.Error
+	}
+	d.ContentType = OrgContentType
+	ensureMetadata(d)
+	d.Metadata["type"] = "Org"
+	// Most keywords are export settings, only the ones describing the note
+	// are kept. #+FILETAGS: :a:b: lists tags separated by colons.
+	fm := map[string]any{}
+	for k, v := range doc.BufferSettings {
+		if slices.Contains(knownKeys, strings.ToLower(k)) {
+			fm[k] = v
+		}
+	}
+	applyFrontMatter(d, fm)
+	addTags(d, strings.Split(doc.BufferSettings["FILETAGS"], ":")...)
+	var first string
+	walkSections(doc.Outline.Section, func(h *org.Headline) {
+		if first == "" {
+			first = strings.TrimSpace(org.String(h.Title...))
+		}
+		addTags(d, h.Tags...)
+	})
+	if d.Title == "" {
+		d.Title = first
+	}
+	links := wikiLinks(d.Text)
+	for i, l := range links {
+		links[i] = strings.TrimPrefix(l, "file:")
+	}
+	setLinks(d, links)
+	return types.ExtractorStop, nil
+}
+
+// Preview renders the note as HTML.
+func (e *OrgExtractor) Preview(d *document.Document) (types.PreviewResponse, types.ExtractorState, error) {
+	out, err := parseOrg(d.Text).Write(org.NewHTMLWriter())
+	if err != nil {
+		return types.PreviewResponse{}, types.ExtractorContinue, err
+	}
+	return types.PreviewResponse{Content: sanitizer.SanitizeHTML(out)}, types.ExtractorStop, nil
+}
+
+// parseOrg parses an Org document. Included files are not read, a note must
+// not be able to pull arbitrary files into the index.
+func parseOrg(src string) *org.Document {
+	c := org.New().Silent()
+	c.ReadFile = func(string) ([]byte, error) {
+		return nil, errIncludeDenied
+	}
+	return c.Parse(strings.NewReader(src), "")
+}
+
+// walkSections calls fn with the headlines of s and its subsections in
+// document order.
+func walkSections(s *org.Section, fn func(*org.Headline)) {
+	if s == nil {
+		return
+	}
+	if s.Headline != nil {
+		fn(s.Headline)
+	}
+	for _, c := range s.Children {
+		walkSections(c, fn)
+	}
+}
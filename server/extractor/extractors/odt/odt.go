@@ -0,0 +1,233 @@
+// Package odt provides an extractor for OpenDocument text (ODT) documents.
+package odt
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/extractor/zipdoc"
+	"github.com/asciimoo/hister/server/sanitizer"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// ODTExtractor extracts the text, headings and metadata of ODT files.
+type ODTExtractor struct {
+	cfg *config.Extractor
+}
+
+// Name returns the extractor's identifier.
+func (e *ODTExtractor) Name() string {
+	return "ODT"
+}
+
+// Description returns a short summary of what this extractor does.
+func (e *ODTExtractor) Description() string {
+	return "Extracts the text, headings, title and author of OpenDocument text (ODT) documents."
+}
+
+// GetConfig returns the extractor's current configuration.
+func (e *ODTExtractor) GetConfig() *config.Extractor {
+	if e.cfg == nil {
+		return &config.Extractor{Enable: true, Options: map[string]any{}}
+	}
+	return e.cfg
+}
+
+// SetConfig applies cfg to the extractor. Returns an error for unknown options.
+func (e *ODTExtractor) SetConfig(c *config.Extractor) error {
+	for k := range c.Options {
+		return fmt.Errorf("unknown option %q", k)
+	}
+	e.cfg = c
+	return nil
+}
+
+// Match returns true for ODT documents.
+func (e *ODTExtractor) Match(d *document.Document) bool {
+	return zipdoc.Matches(d, zipdoc.ODT)
+}
+
+// Extract replaces the text of the document with the paragraphs of the
+// document body, and the HTML with its outline for previews. Documents
+// already extracted are left unchanged.
+func (e *ODTExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
+	if len(d.Raw) == 0 {
+		return types.ExtractorStop, nil
+	}
+	zr, err := zipdoc.Open(d.Raw)
+	if err != nil {
+		return types.ExtractorAbort, err
+	}
+	content, err := zipdoc.ReadFile(zr, "content.xml")
+	if err != nil {
+		return types.ExtractorAbort, err
+	}
+	o, err := parseContent(content)
+	if err != nil {
+		return types.ExtractorAbort, err
+	}
+	if o.Empty() {
+		return types.ExtractorAbort, errors.New("no text found")
+	}
+	d.ContentType = zipdoc.ODT
+	d.Text = o.Text()
+	d.HTML = o.HTML()
+	d.Title = o.FirstHeading()
+	m := &zipdoc.Meta{}
+	if b, err := zipdoc.ReadFile(zr, "meta.xml"); err == nil {
+		m = parseMeta(b)
+	}
+	m.Apply(d, "ODT")
+	return types.ExtractorStop, nil
+}
+
+// Preview returns the outline rendered during extraction.
+func (e *ODTExtractor) Preview(d *document.Document) (types.PreviewResponse, types.ExtractorState, error) {
+	if d.HTML == "" {
+		return types.PreviewResponse{}, types.ExtractorContinue, nil
+	}
+	return types.PreviewResponse{Content: sanitizer.SanitizeHTML(d.HTML)}, types.ExtractorStop, nil
+}
+
+// skippedElements are not part of the running text, notes would otherwise
+// end up in the middle of the paragraph referencing them.
+var skippedElements = map[string]bool{
+	"note":            true,
+	"annotation":      true,
+	"tracked-changes": true,
+	"sequence-decls":  true,
+}
+
+// parseContent collects the headings and paragraphs of content.xml.
+func parseContent(b []byte) (*zipdoc.Outline, error) {
+	o := &zipdoc.Outline{}
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	var text strings.Builder
+	// depth counts the nested paragraphs and headings, e.g. of list items.
+	depth, level, skip := 0, 0, 0
+	flush := func() {
+		if level > 0 {
+			o.Heading(level, text.String())
+		} else {
+			o.Paragraph(text.String())
+		}
+		text.Reset()
+	}
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return o, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 || skippedElements[t.Name.Local] {
+				skip++
+				continue
+			}
+			switch t.Name.Local {
+			case "p", "h":
+				if depth > 0 {
+					flush()
+				}
+				depth++
+				level = 0
+				if t.Name.Local == "h" {
+					level = 1
+					if n, err := strconv.Atoi(attr(t, "outline-level")); err == nil {
+						level = n
+					}
+				}
+			case "s":
+				n := 1
+				if c, err := strconv.Atoi(attr(t, "c")); err == nil {
+					n = c
+				}
+				text.WriteString(strings.Repeat(" ", n))
+			case "tab":
+				text.WriteByte(' ')
+			case "line-break":
+				text.WriteByte('\n')
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if t.Name.Local == "p" || t.Name.Local == "h" {
+				flush()
+				depth--
+				level = 0
+			}
+		case xml.CharData:
+			if depth > 0 && skip == 0 {
+				text.Write(t)
+			}
+		}
+	}
+}
+
+// attr returns the value of the attribute local of se, ignoring namespaces.
+func attr(se xml.StartElement, local string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// parseMeta reads the document metadata of meta.xml.
+func parseMeta(b []byte) *zipdoc.Meta {
+	m := &zipdoc.Meta{}
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	var field *string
+	var initialCreator string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "title":
+				field = &m.Title
+			case "creator":
+				field = &m.Author
+			case "initial-creator":
+				field = &initialCreator
+			case "description", "subject":
+				if m.Description == "" {
+					field = &m.Description
+				}
+			case "language":
+				field = &m.Language
+			case "creation-date":
+				field = &m.Published
+			case "date":
+				field = &m.Modified
+			}
+		case xml.EndElement:
+			field = nil
+		case xml.CharData:
+			if field != nil {
+				*field += string(t)
+			}
+		}
+	}
+	// The initial creator is the author, dc:creator the last editor.
+	if initialCreator != "" {
+		m.Author = initialCreator
+	}
+	return m
+}
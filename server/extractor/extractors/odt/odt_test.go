@@ -0,0 +1,41 @@
+package odt
+
+import (
+	"testing"
+)
+
+func TestParseContent(t *testing.T) {
+	content := `<?xml version="1.0"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:text>
+  <text:sequence-decls><text:sequence-decl text:name="Table"/></text:sequence-decls>
+  <text:h text:outline-level="2">Setup</text:h>
+  <text:p>Install<text:s text:c="2"/>the <text:span>tool</text:span><text:note><text:note-body><text:p>A footnote</text:p></text:note-body></text:note>.</text:p>
+  <text:list><text:list-item><text:p>First item</text:p></text:list-item></text:list>
+</office:text></office:body></office:document-content>`
+	o, err := parseContent([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Setup\n\nInstall the tool.\n\nFirst item"; o.Text() != want {
+		t.Errorf("Text() = %q, want %q", o.Text(), want)
+	}
+	if want := "<h2>Setup</h2><p>Install the tool.</p><p>First item</p>"; o.HTML() != want {
+		t.Errorf("HTML() = %q, want %q", o.HTML(), want)
+	}
+}
+
+func TestParseMeta(t *testing.T) {
+	meta := `<?xml version="1.0"?>
+<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<office:meta>
+  <dc:title>Release Notes</dc:title>
+  <meta:initial-creator>Alex Smith</meta:initial-creator>
+  <dc:creator>Someone Else</dc:creator>
+  <meta:creation-date>2023-05-04T08:30:00.123456789</meta:creation-date>
+</office:meta></office:document-meta>`
+	m := parseMeta([]byte(meta))
+	if m.Title != "Release Notes" || m.Author != "Alex Smith" || m.Published != "2023-05-04T08:30:00.123456789" {
+		t.Errorf("parseMeta() = %+v", m)
+	}
+}
@@ -0,0 +1,218 @@
+// Package pdf provides an extractor for PDF documents.
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	stdhtml "html"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ledongthuc/pdf"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// ContentType is the MIME type of the documents handled by the extractor.
+const ContentType = "application/pdf"
+
+// PDFExtractor extracts the text and document information of PDF files.
+type PDFExtractor struct {
+	cfg *config.Extractor
+}
+
+// Name returns the extractor's identifier.
+func (e *PDFExtractor) Name() string {
+	return "PDF"
+}
+
+// Description returns a short summary of what this extractor does.
+func (e *PDFExtractor) Description() string {
+	return "Extracts the text, title and author of PDF documents, both local files and web pages."
+}
+
+// GetConfig returns the extractor's current configuration.
+func (e *PDFExtractor) GetConfig() *config.Extractor {
+	if e.cfg == nil {
+		return &config.Extractor{Enable: true, Options: map[string]any{}}
+	}
+	return e.cfg
+}
+
+// SetConfig applies cfg to the extractor. Returns an error for unknown options.
+func (e *PDFExtractor) SetConfig(c *config.Extractor) error {
+	for k := range c.Options {
+		return fmt.Errorf("unknown option %q", k)
+	}
+	e.cfg = c
+	return nil
+}
+
+// Match returns true for PDF documents.
+func (e *PDFExtractor) Match(d *document.Document) bool {
+	return d.ContentType == ContentType
+}
+
+// Extract replaces the text of the document with the text of the PDF pages
+// and copies the title, author, subject and dates from the document
+// information dictionary. Documents already extracted are left unchanged.
+func (e *PDFExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
+	if len(d.Raw) == 0 {
+		return types.ExtractorStop, nil
+	}
+	info, text, pages, err := parse(d.Raw)
+	if err != nil {
+		return types.ExtractorAbort, err
+	}
+	if text == "" {
+		return types.ExtractorAbort, errors.New("no text found, the document may be scanned or encrypted")
+	}
+	d.Text = text
+	d.Title = strings.TrimSpace(info.Key("Title").Text())
+	if d.Title == "" {
+		d.Title = titleFromURL(d.URL)
+	}
+	if d.Metadata == nil {
+		d.Metadata = make(map[string]any)
+	}
+	d.Metadata["type"] = "PDF"
+	d.Metadata["pages"] = pages
+	set := func(k, v string) {
+		if v = strings.TrimSpace(v); v != "" {
+			d.Metadata[k] = v
+		}
+	}
+	set("author", info.Key("Author").Text())
+	set("description", info.Key("Subject").Text())
+	if t, ok := parseDate(info.Key("CreationDate").Text()); ok {
+		d.Metadata["published"] = t.Format(time.RFC3339)
+	}
+	if t, ok := parseDate(info.Key("ModDate").Text()); ok {
+		d.Metadata["modified"] = t.Format(time.RFC3339)
+	}
+	return types.ExtractorStop, nil
+}
+
+// Preview renders the extracted text, one paragraph per text block with a
+// separator between pages.
+func (e *PDFExtractor) Preview(d *document.Document) (types.PreviewResponse, types.ExtractorState, error) {
+	var b strings.Builder
+	for i, page := range strings.Split(d.Text, pageSeparator) {
+		if i > 0 {
+			b.WriteString("<hr>")
+		}
+		for line := range strings.SplitSeq(page, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				b.WriteString("<p>")
+				b.WriteString(stdhtml.EscapeString(line))
+				b.WriteString("</p>")
+			}
+		}
+	}
+	return types.PreviewResponse{Content: b.String()}, types.ExtractorStop, nil
+}
+
+// pageSeparator separates the text of consecutive pages.
+const pageSeparator = "\n\n\n"
+
+// parse returns the document information dictionary, the text and the
+// number of pages of a PDF file. The parser panics on some malformed files,
+// which is reported as an error.
+func parse(raw []byte) (info pdf.Value, text string, pages int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+	r, err := pdf.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return info, "", 0, err
+	}
+	fonts := make(map[string]*pdf.Font)
+	pages = r.NumPage()
+	texts := make([]string, 0, pages)
+	for n := 1; n <= pages; n++ {
+		p := r.Page(n)
+		if p.V.IsNull() {
+			continue
+		}
+		for _, name := range p.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				f := p.Font(name)
+				fonts[name] = &f
+			}
+		}
+		t, err := p.GetPlainText(fonts)
+		if err != nil {
+			return info, "", 0, fmt.Errorf("page %d: %w", n, err)
+		}
+		if t = cleanText(t); t != "" {
+			texts = append(texts, t)
+		}
+	}
+	return r.Trailer().Key("Info"), strings.Join(texts, pageSeparator), pages, nil
+}
+
+// cleanText trims the lines of a page and drops the empty ones.
+func cleanText(s string) string {
+	lines := strings.Split(s, "\n")
+	kept := lines[:0]
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" {
+			kept = append(kept, l)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// titleFromURL returns the unescaped file name of web documents.
+func titleFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "file" {
+		return ""
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return ""
+	}
+	return name
+}
+
+// parseDate parses PDF dates of the form D:YYYYMMDDHHmmSSOHH'mm', of
+// which every part after the year is optional.
+func parseDate(s string) (time.Time, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "D:")
+	digits := 0
+	for digits < len(s) && digits < 14 && s[digits] >= '0' && s[digits] <= '9' {
+		digits++
+	}
+	if digits < 4 || digits%2 != 0 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102150405"[:digits], s[:digits])
+	if err != nil {
+		return time.Time{}, false
+	}
+	tz := strings.ReplaceAll(s[digits:], "'", "")
+	if len(tz) >= 3 && (tz[0] == '+' || tz[0] == '-') {
+		h, herr := strconv.Atoi(tz[1:3])
+		m := 0
+		if len(tz) >= 5 {
+			m, _ = strconv.Atoi(tz[3:5])
+		}
+		if herr == nil {
+			offset := h*3600 + m*60
+			if tz[0] == '-' {
+				offset = -offset
+			}
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.FixedZone("", offset))
+		}
+	}
+	return t, true
+}
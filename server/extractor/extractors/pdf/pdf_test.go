@@ -0,0 +1,138 @@
+package pdf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// makePDF builds a PDF file with one page per entry of pages and the given
+// document information entries.
+func makePDF(info string, pages ...string) []byte {
+	objs := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // page tree, filled in below
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< %s >>", info),
+	}
+	var kids []string
+	for _, text := range pages {
+		content := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		objs = append(objs, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		objs = append(objs, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", len(objs)))
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objs)))
+	}
+	objs[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for n, o := range objs {
+		offsets[n] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", n+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return []byte(b.String())
+}
+
+func TestExtract(t *testing.T) {
+	e := &PDFExtractor{}
+	d := &document.Document{
+		URL:         "https://example.com/papers/report.pdf",
+		ContentType: ContentType,
+		Raw:         makePDF("/Title (Annual Report) /Author (Jane Doe) /CreationDate (D:20240131120000Z)", "First page", "Second page"),
+	}
+	if !e.Match(d) {
+		t.Fatal("expected match for PDF document")
+	}
+	state, err := e.Extract(d)
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("Extract() = %v, %v", state, err)
+	}
+	if d.Title != "Annual Report" {
+		t.Errorf("title = %q", d.Title)
+	}
+	if d.Text != "First page"+pageSeparator+"Second page" {
+		t.Errorf("text = %q", d.Text)
+	}
+	if d.Metadata["author"] != "Jane Doe" {
+		t.Errorf("author = %v", d.Metadata["author"])
+	}
+	if d.Metadata["pages"] != 2 {
+		t.Errorf("pages = %v", d.Metadata["pages"])
+	}
+	if d.Metadata["published"] != "2024-01-31T12:00:00Z" {
+		t.Errorf("published = %v", d.Metadata["published"])
+	}
+
+	resp, _, err := e.Preview(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<p>First page</p><hr><p>Second page</p>"; resp.Content != want {
+		t.Errorf("preview = %q, want %q", resp.Content, want)
+	}
+}
+
+func TestExtractTitleFallback(t *testing.T) {
+	d := &document.Document{
+		URL:         "https://example.com/files/My%20Paper.pdf",
+		ContentType: ContentType,
+		Raw:         makePDF("", "Text"),
+	}
+	if _, err := (&PDFExtractor{}).Extract(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "My Paper.pdf" {
+		t.Errorf("title = %q", d.Title)
+	}
+}
+
+func TestExtractMalformed(t *testing.T) {
+	d := &document.Document{
+		URL:         "https://example.com/broken.pdf",
+		ContentType: ContentType,
+		Raw:         []byte("%PDF-1.4\nnot really a pdf"),
+	}
+	state, err := (&PDFExtractor{}).Extract(d)
+	if err == nil || state != types.ExtractorAbort {
+		t.Errorf("Extract() = %v, %v, want abort with error", state, err)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"D:20240131120000Z", "2024-01-31T12:00:00Z"},
+		{"D:20240131120000+02'00'", "2024-01-31T12:00:00+02:00"},
+		{"D:20240131120000-05'30", "2024-01-31T12:00:00-05:30"},
+		{"D:2024", "2024-01-01T00:00:00Z"},
+		{"20240131", "2024-01-31T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		got, ok := parseDate(tt.in)
+		if !ok {
+			t.Errorf("parseDate(%q) failed", tt.in)
+			continue
+		}
+		if s := got.Format(time.RFC3339); s != tt.want {
+			t.Errorf("parseDate(%q) = %s, want %s", tt.in, s, tt.want)
+		}
+	}
+	for _, in := range []string{"", "D:", "D:202", "yesterday"} {
+		if _, ok := parseDate(in); ok {
+			t.Errorf("parseDate(%q) succeeded", in)
+		}
+	}
+}
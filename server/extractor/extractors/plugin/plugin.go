@@ -0,0 +1,297 @@
+// Package plugin provides extractors running external programs, declared in
+// the extractor_plugins section of the configuration, so extractors can be
+// written in any language.
+//
+// For every matching document the program is started and a Request is
+// written to its standard input as JSON. The program answers with a Response
+// on its standard output and exits.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/sanitizer"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// ProtocolVersion is the version of the plugin protocol sent in requests.
+const ProtocolVersion = 1
+
+// Request actions.
+const (
+	ActionExtract = "extract"
+	ActionPreview = "preview"
+)
+
+// Response states, mapped to types.ExtractorState.
+const (
+	StateStop     = "stop"
+	StateContinue = "continue"
+	StateAbort    = "abort"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	maxOutputSize  = 16 << 20 // 16 MB
+	maxStderrSize  = 4 << 10
+)
+
+// ErrOutputTooLarge is returned when a plugin writes more than maxOutputSize
+// bytes.
+var ErrOutputTooLarge = errors.New("plugin output too large")
+
+// Request is written to the standard input of the plugin.
+type Request struct {
+	Version  int                `json:"version"`
+	Action   string             `json:"action"`
+	Document *document.Document `json:"document"`
+	// Options are the options of the plugin in the extractors section of
+	// the configuration.
+	Options map[string]any `json:"options"`
+}
+
+// Response is read from the standard output of the plugin. An empty State
+// means StateStop.
+type Response struct {
+	State string `json:"state"`
+	// Error explains a continue or abort state.
+	Error string `json:"error"`
+	// Document holds the fields of the document updated by an extract
+	// action.
+	Document *Update `json:"document"`
+	// Preview is the answer to a preview action.
+	Preview *Preview `json:"preview"`
+}
+
+// Update holds the document fields a plugin can set. Fields left out are not
+// changed, Metadata is merged into the metadata of the document.
+type Update struct {
+	Title    *string        `json:"title"`
+	Text     *string        `json:"text"`
+	Favicon  *string        `json:"favicon"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+// Preview is a rendered preview. Content is HTML, which is sanitized.
+type Preview struct {
+	Content  string `json:"content"`
+	Template string `json:"template"`
+}
+
+// PluginExtractor runs a plugin program.
+type PluginExtractor struct {
+	name        string
+	description string
+	command     string
+	args        []string
+	pattern     *regexp.Regexp
+	timeout     time.Duration
+	slots       chan struct{}
+	cfg         *config.Extractor
+}
+
+// New creates the extractor of a declared plugin.
+func New(c *config.ExtractorPlugin) (*PluginExtractor, error) {
+	if strings.TrimSpace(c.Name) == "" {
+		return nil, errors.New("missing name")
+	}
+	if c.Command == "" {
+		return nil, fmt.Errorf("%s: missing command", c.Name)
+	}
+	if c.URLPattern == "" {
+		return nil, fmt.Errorf("%s: missing url_pattern", c.Name)
+	}
+	re, err := regexp.Compile(c.URLPattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid URL pattern %q: %w", c.Name, c.URLPattern, err)
+	}
+	if c.Timeout < 0 || c.Concurrency < 0 {
+		return nil, fmt.Errorf("%s: negative timeout or concurrency", c.Name)
+	}
+	e := &PluginExtractor{
+		name:        c.Name,
+		description: c.Description,
+		command:     c.Command,
+		args:        c.Args,
+		pattern:     re,
+		timeout:     defaultTimeout,
+		slots:       make(chan struct{}, max(c.Concurrency, 1)),
+	}
+	if c.Timeout > 0 {
+		e.timeout = time.Duration(c.Timeout) * time.Second
+	}
+	if e.description == "" {
+		e.description = fmt.Sprintf("Runs the external program %s.", c.Command)
+	}
+	return e, nil
+}
+
+func (e *PluginExtractor) Name() string {
+	return e.name
+}
+
+func (e *PluginExtractor) Description() string {
+	return e.description
+}
+
+func (e *PluginExtractor) GetConfig() *config.Extractor {
+	if e.cfg == nil {
+		return &config.Extractor{Enable: true, Options: map[string]any{}}
+	}
+	return e.cfg
+}
+
+// SetConfig accepts any option, the options are passed to the plugin.
+func (e *PluginExtractor) SetConfig(c *config.Extractor) error {
+	e.cfg = c
+	return nil
+}
+
+func (e *PluginExtractor) Match(d *document.Document) bool {
+	return e.pattern.MatchString(d.URL)
+}
+
+// Extract applies the fields updated by the plugin to the document.
+func (e *PluginExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
+	resp, state, err := e.call(ActionExtract, d)
+	if err != nil || state != types.ExtractorStop {
+		return state, err
+	}
+	if u := resp.Document; u != nil {
+		if u.Title != nil {
+			d.Title = *u.Title
+		}
+		if u.Text != nil {
+			d.Text = *u.Text
+		}
+		if u.Favicon != nil {
+			d.Favicon = *u.Favicon
+		}
+		if len(u.Metadata) > 0 && d.Metadata == nil {
+			d.Metadata = make(map[string]any, len(u.Metadata))
+		}
+		for k, v := range u.Metadata {
+			d.Metadata[k] = v
+		}
+	}
+	return types.ExtractorStop, nil
+}
+
+// Preview returns the sanitized preview rendered by the plugin.
+func (e *PluginExtractor) Preview(d *document.Document) (types.PreviewResponse, types.ExtractorState, error) {
+	resp, state, err := e.call(ActionPreview, d)
+	if err != nil || state != types.ExtractorStop {
+		return types.PreviewResponse{}, state, err
+	}
+	if resp.Preview == nil {
+		return types.PreviewResponse{}, types.ExtractorContinue, errors.New("missing preview")
+	}
+	return types.PreviewResponse{
+		Content:  sanitizer.SanitizeHTML(resp.Preview.Content),
+		Template: resp.Preview.Template,
+	}, types.ExtractorStop, nil
+}
+
+// call runs the plugin and returns its response together with the state
+// of the chain. Failures to run the plugin continue the chain.
+func (e *PluginExtractor) call(action string, d *document.Document) (*Response, types.ExtractorState, error) {
+	in, err := json.Marshal(&Request{
+		Version:  ProtocolVersion,
+		Action:   action,
+		Document: d,
+		Options:  e.GetConfig().Options,
+	})
+	if err != nil {
+		return nil, types.ExtractorContinue, err
+	}
+	out, err := e.run(in)
+	if err != nil {
+		return nil, types.ExtractorContinue, fmt.Errorf("plugin %s: %w", e.name, err)
+	}
+	var resp Response
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, types.ExtractorContinue, fmt.Errorf("plugin %s: invalid response: %w", e.name, err)
+	}
+	var respErr error
+	if resp.Error != "" {
+		respErr = errors.New(resp.Error)
+	}
+	switch resp.State {
+	case "", StateStop:
+		return &resp, types.ExtractorStop, nil
+	case StateContinue:
+		return &resp, types.ExtractorContinue, respErr
+	case StateAbort:
+		if respErr == nil {
+			respErr = errors.New("aborted by plugin")
+		}
+		return &resp, types.ExtractorAbort, respErr
+	default:
+		return nil, types.ExtractorContinue, fmt.Errorf("plugin %s: unknown state %q", e.name, resp.State)
+	}
+}
+
+// run starts the plugin once a slot is free and returns its standard
+// output. The timeout covers the wait for a slot.
+func (e *PluginExtractor) run(in []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	select {
+	case e.slots <- struct{}{}:
+		defer func() { <-e.slots }()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a free slot: %w", ctx.Err())
+	}
+
+	// #nosec G204 -- command and args are admin-configured, not user input.
+	cmd := exec.CommandContext(ctx, e.command, e.args...)
+	cmd.Stdin = bytes.NewReader(in)
+	stdout := &limitedBuffer{limit: maxOutputSize}
+	stderr := &limitedBuffer{limit: maxStderrSize, truncate: true}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("timed out after %s", e.timeout)
+		}
+		if stdout.exceeded {
+			return nil, ErrOutputTooLarge
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// limitedBuffer is a bytes.Buffer holding at most limit bytes. Writes past
+// the limit fail, or are dropped when truncate is set.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int
+	truncate bool
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		b.exceeded = true
+		if !b.truncate {
+			return 0, ErrOutputTooLarge
+		}
+		b.Buffer.Write(p[:b.limit-b.Len()])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
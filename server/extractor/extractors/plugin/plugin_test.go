@@ -0,0 +1,124 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// testPlugin writes a shell script plugin and returns its extractor.
+func testPlugin(t *testing.T, script string, timeout int) *PluginExtractor {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plugin.sh")
+	if err := os.WriteFile(path, []byte(script), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e, err := New(&config.ExtractorPlugin{
+		Name:       "Test",
+		Command:    "sh",
+		Args:       []string{path},
+		URLPattern: `^https://example\.com/`,
+		Timeout:    timeout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestExtract(t *testing.T) {
+	e := testPlugin(t, `
+req=$(cat)
+case "$req" in
+*'"action":"extract"'*'"url":"https://example.com/a"'*) ;;
+*) echo "unexpected request $req" >&2; exit 1 ;;
+esac
+echo '{"document": {"title": "New title", "metadata": {"author": "Ann"}}}'
+`, 0)
+	d := &document.Document{URL: "https://example.com/a", Title: "Old", Text: "Text", Metadata: map[string]any{"k": "v"}}
+	if !e.Match(d) || e.Match(&document.Document{URL: "https://example.org/"}) {
+		t.Fatal("unexpected match result")
+	}
+	state, err := e.Extract(d)
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("unexpected result %v %v", state, err)
+	}
+	if d.Title != "New title" || d.Text != "Text" {
+		t.Errorf("unexpected document %q %q", d.Title, d.Text)
+	}
+	if d.Metadata["author"] != "Ann" || d.Metadata["k"] != "v" {
+		t.Errorf("unexpected metadata %v", d.Metadata)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	e := testPlugin(t, `cat >/dev/null
+echo '{"preview": {"content": "<p>Hi</p><script>alert(1)</script>"}}'
+`, 0)
+	resp, state, err := e.Preview(&document.Document{URL: "https://example.com/a"})
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("unexpected result %v %v", state, err)
+	}
+	if !strings.Contains(resp.Content, "<p>Hi</p>") || strings.Contains(resp.Content, "script") {
+		t.Errorf("unexpected preview %q", resp.Content)
+	}
+}
+
+func TestStates(t *testing.T) {
+	tests := []struct {
+		script string
+		state  types.ExtractorState
+	}{
+		{`echo '{"state": "continue"}'`, types.ExtractorContinue},
+		{`echo '{"state": "abort", "error": "private page"}'`, types.ExtractorAbort},
+		{`echo '{"state": "bogus"}'`, types.ExtractorContinue},
+		{`echo 'not json'`, types.ExtractorContinue},
+		{`echo 'broken' >&2; exit 3`, types.ExtractorContinue},
+	}
+	for _, tc := range tests {
+		e := testPlugin(t, "cat >/dev/null\n"+tc.script+"\n", 0)
+		d := &document.Document{URL: "https://example.com/a", Title: "Old"}
+		state, err := e.Extract(d)
+		if state != tc.state {
+			t.Errorf("%s: expected state %v, got %v (%v)", tc.script, tc.state, state, err)
+		}
+		if tc.state == types.ExtractorAbort && (err == nil || err.Error() != "private page") {
+			t.Errorf("%s: unexpected error %v", tc.script, err)
+		}
+		if d.Title != "Old" {
+			t.Errorf("%s: document changed", tc.script)
+		}
+	}
+	e := testPlugin(t, "cat >/dev/null\necho 'broken' >&2; exit 3\n", 0)
+	if _, err := e.Extract(&document.Document{URL: "https://example.com/a"}); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected stderr in the error, got %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	e := testPlugin(t, "exec sleep 5\n", 1)
+	state, err := e.Extract(&document.Document{URL: "https://example.com/a"})
+	if state != types.ExtractorContinue || err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("unexpected result %v %v", state, err)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []*config.ExtractorPlugin{
+		{Command: "x", URLPattern: "."},
+		{Name: "a", URLPattern: "."},
+		{Name: "a", Command: "x"},
+		{Name: "a", Command: "x", URLPattern: "("},
+		{Name: "a", Command: "x", URLPattern: ".", Timeout: -1},
+	}
+	for i, c := range tests {
+		if _, err := New(c); err == nil {
+			t.Errorf("%d: expected error", i)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package site provides the extractors declared in the site_extractors
+// section of the configuration, which select the parts of the pages of a
+// website with CSS selectors.
+package site
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/extractor/urlutil"
+	"github.com/asciimoo/hister/server/sanitizer"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// blockElements are the elements whose content starts on a new line in the
+// extracted text.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Br: true, atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.H1: true,
+	atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true, atom.Nav: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Table: true,
+	atom.Td: true, atom.Th: true, atom.Tr: true, atom.Ul: true,
+}
+
+// attrNameRe matches the attribute names which can follow the @ of
+// metadata selectors.
+var attrNameRe = regexp.MustCompile(`^[A-Za-z_:][-A-Za-z0-9_:.]*$`)
+
+// metadataSelector selects the value of a metadata field.
+type metadataSelector struct {
+	key  string
+	sel  cascadia.Selector
+	attr string
+}
+
+// SiteExtractor extracts the pages of a website as declared by a
+// config.SiteExtractor.
+type SiteExtractor struct {
+	name        string
+	description string
+	patterns    []*regexp.Regexp
+	title       cascadia.Selector
+	content     cascadia.Selector
+	remove      []cascadia.Selector
+	metadata    []*metadataSelector
+	cfg         *config.Extractor
+}
+
+// New compiles the URL patterns and selectors of a declared extractor.
+func New(c *config.SiteExtractor) (*SiteExtractor, error) {
+	if strings.TrimSpace(c.Name) == "" {
+		return nil, errors.New("missing name")
+	}
+	if len(c.URLPatterns) == 0 {
+		return nil, fmt.Errorf("%s: missing url_patterns", c.Name)
+	}
+	if c.Content == "" {
+		return nil, fmt.Errorf("%s: missing content selector", c.Name)
+	}
+	e := &SiteExtractor{
+		name:        c.Name,
+		description: c.Description,
+	}
+	if e.description == "" {
+		e.description = fmt.Sprintf("Extracts the pages of %s using the CSS selectors of the configuration.", c.Name)
+	}
+	for _, p := range c.URLPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid URL pattern %q: %w", c.Name, p, err)
+		}
+		e.patterns = append(e.patterns, re)
+	}
+	var err error
+	if e.content, err = compile(c.Name, c.Content); err != nil {
+		return nil, err
+	}
+	if c.Title != "" {
+		if e.title, err = compile(c.Name, c.Title); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range c.Remove {
+		sel, err := compile(c.Name, r)
+		if err != nil {
+			return nil, err
+		}
+		e.remove = append(e.remove, sel)
+	}
+	for k, v := range c.Metadata {
+		ms := &metadataSelector{key: k}
+		if i := strings.LastIndex(v, "@"); i >= 0 && attrNameRe.MatchString(v[i+1:]) {
+			v, ms.attr = v[:i], v[i+1:]
+		}
+		if ms.sel, err = compile(c.Name, v); err != nil {
+			return nil, err
+		}
+		e.metadata = append(e.metadata, ms)
+	}
+	return e, nil
+}
+
+func compile(name, sel string) (cascadia.Selector, error) {
+	s, err := cascadia.Compile(sel)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid selector %q: %w", name, sel, err)
+	}
+	return s, nil
+}
+
+func (e *SiteExtractor) Name() string {
+	return e.name
+}
+
+func (e *SiteExtractor) Description() string {
+	return e.description
+}
+
+func (e *SiteExtractor) GetConfig() *config.Extractor {
+	if e.cfg == nil {
+		return &config.Extractor{Enable: true, Options: map[string]any{}}
+	}
+	return e.cfg
+}
+
+func (e *SiteExtractor) SetConfig(c *config.Extractor) error {
+	for k := range c.Options {
+		return fmt.Errorf("unknown option %q", k)
+	}
+	e.cfg = c
+	return nil
+}
+
+// Match accepts the HTML pages whose URL matches one of the URL patterns.
+func (e *SiteExtractor) Match(d *document.Document) bool {
+	if len(d.Raw) > 0 || d.HTML == "" {
+		return false
+	}
+	for _, re := range e.patterns {
+		if re.MatchString(d.URL) {
+			return true
+		}
+	}
+	return false
+}
+
+// Extract sets the title, text and metadata of the document. Pages without
+// content matching the content selector are left to the next extractor.
+func (e *SiteExtractor) Extract(d *document.Document) (types.ExtractorState, error) {
+	doc, content, err := e.parse(d)
+	if err != nil {
+		return types.ExtractorContinue, err
+	}
+	var b strings.Builder
+	for _, n := range content.Nodes {
+		writeText(&b, n)
+	}
+	d.Text = normalizeText(b.String())
+	d.Title = e.pageTitle(doc)
+	for _, ms := range e.metadata {
+		s := doc.FindMatcher(ms.sel).First()
+		v := ""
+		if ms.attr != "" {
+			v = s.AttrOr(ms.attr, "")
+		} else {
+			v = s.Text()
+		}
+		if v = strings.Join(strings.Fields(v), " "); v == "" {
+			continue
+		}
+		if d.Metadata == nil {
+			d.Metadata = make(map[string]any)
+		}
+		d.Metadata[ms.key] = v
+	}
+	return types.ExtractorStop, nil
+}
+
+// Preview renders the content of the page as sanitized HTML.
+func (e *SiteExtractor) Preview(d *document.Document) (types.PreviewResponse, types.ExtractorState, error) {
+	_, content, err := e.parse(d)
+	if err != nil {
+		return types.PreviewResponse{}, types.ExtractorContinue, err
+	}
+	if base, err := url.Parse(d.URL); err == nil {
+		urlutil.RewriteURLs(content, base)
+	}
+	var b strings.Builder
+	for _, n := range content.Nodes {
+		if err := html.Render(&b, n); err != nil {
+			return types.PreviewResponse{}, types.ExtractorContinue, err
+		}
+	}
+	return types.PreviewResponse{Content: sanitizer.SanitizeHTML(b.String())}, types.ExtractorStop, nil
+}
+
+// parse parses the page, drops the removed elements and returns the
+// content. Nested matches of the content selector are left out.
+func (e *SiteExtractor) parse(d *document.Document) (*goquery.Document, *goquery.Selection, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(d.HTML))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, sel := range e.remove {
+		doc.FindMatcher(sel).Remove()
+	}
+	content := doc.FindMatcher(e.content)
+	content = content.NotNodes(content.Find("*").FilterMatcher(e.content).Nodes...)
+	if content.Length() == 0 {
+		return nil, nil, errors.New("no content found")
+	}
+	return doc, content, nil
+}
+
+func (e *SiteExtractor) pageTitle(doc *goquery.Document) string {
+	if e.title != nil {
+		if t := strings.Join(strings.Fields(doc.FindMatcher(e.title).First().Text()), " "); t != "" {
+			return t
+		}
+	}
+	return strings.Join(strings.Fields(doc.Find("title").First().Text()), " ")
+}
+
+// writeText writes the text of n, starting block elements on a new line.
+func writeText(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		switch n.DataAtom {
+		case atom.Script, atom.Style, atom.Noscript, atom.Template:
+			return
+		}
+	}
+	block := n.Type == html.ElementNode && blockElements[n.DataAtom]
+	if block {
+		b.WriteByte('\n')
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeText(b, c)
+	}
+	if block {
+		b.WriteByte('\n')
+	}
+}
+
+// normalizeText collapses the whitespace of every line and drops the empty
+// lines.
+func normalizeText(s string) string {
+	var lines []string
+	for l := range strings.SplitSeq(s, "\n") {
+		if l = strings.Join(strings.Fields(l), " "); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,107 @@
+package site
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/types"
+)
+
+const testPage = `<html><head><title>Page title - Example</title>
+<meta name="author" content="Jane Doe"></head>
+<body>
+<nav>Navigation</nav>
+<h1 class="headline">  Article
+  headline </h1>
+<div class="post">
+  <p>First   paragraph with <a href="/docs">a link</a>.</p>
+  <div class="ad">Buy now</div>
+  <ul><li>one</li><li>two</li></ul>
+  <script>var x = 1;</script>
+  <div class="post"><p>Nested post</p></div>
+</div>
+<span class="date">2024-01-02</span>
+</body></html>`
+
+func testExtractor(t *testing.T) *SiteExtractor {
+	t.Helper()
+	e, err := New(&config.SiteExtractor{
+		Name:        "Example",
+		URLPatterns: []string{`^https://example\.com/posts/`},
+		Title:       "h1.headline",
+		Content:     ".post",
+		Remove:      []string{".ad"},
+		Metadata: map[string]string{
+			"author": "meta[name=author]@content",
+			"date":   "span.date",
+			"empty":  ".missing",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestExtract(t *testing.T) {
+	e := testExtractor(t)
+	d := &document.Document{URL: "https://example.com/posts/1", HTML: testPage}
+	if !e.Match(d) || e.Match(&document.Document{URL: "https://example.com/about", HTML: testPage}) {
+		t.Fatal("unexpected match result")
+	}
+	state, err := e.Extract(d)
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("unexpected result %v %v", state, err)
+	}
+	if d.Title != "Article headline" {
+		t.Errorf("unexpected title %q", d.Title)
+	}
+	if want := "First paragraph with a link.\none\ntwo\nNested post"; d.Text != want {
+		t.Errorf("unexpected text %q", d.Text)
+	}
+	if d.Metadata["author"] != "Jane Doe" || d.Metadata["date"] != "2024-01-02" {
+		t.Errorf("unexpected metadata %v", d.Metadata)
+	}
+	if _, ok := d.Metadata["empty"]; ok {
+		t.Errorf("unexpected empty metadata field")
+	}
+
+	d = &document.Document{URL: "https://example.com/posts/2", HTML: "<html><head><title>T</title></head><body><p>x</p></body></html>"}
+	if state, _ := e.Extract(d); state != types.ExtractorContinue {
+		t.Errorf("expected the chain to continue without content, got %v", state)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	e := testExtractor(t)
+	resp, state, err := e.Preview(&document.Document{URL: "https://example.com/posts/1", HTML: testPage})
+	if err != nil || state != types.ExtractorStop {
+		t.Fatalf("unexpected result %v %v", state, err)
+	}
+	if !strings.Contains(resp.Content, `href="https://example.com/docs"`) {
+		t.Errorf("relative link not rewritten: %s", resp.Content)
+	}
+	for _, s := range []string{"Buy now", "var x", "Navigation"} {
+		if strings.Contains(resp.Content, s) {
+			t.Errorf("preview contains %q: %s", s, resp.Content)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []*config.SiteExtractor{
+		{URLPatterns: []string{"."}, Content: "p"},
+		{Name: "a", Content: "p"},
+		{Name: "a", URLPatterns: []string{"."}},
+		{Name: "a", URLPatterns: []string{"("}, Content: "p"},
+		{Name: "a", URLPatterns: []string{"."}, Content: "p["},
+		{Name: "a", URLPatterns: []string{"."}, Content: "p", Metadata: map[string]string{"k": "::@x"}},
+	}
+	for i, c := range tests {
+		if _, err := New(c); err == nil {
+			t.Errorf("%d: expected error", i)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Package zipdoc provides shared helpers for extractors of zip based
+// document formats like DOCX, ODT and EPUB.
+package zipdoc
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	stdhtml "html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/asciimoo/hister/server/document"
+)
+
+// MIME types of the supported formats.
+const (
+	DOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	ODT  = "application/vnd.oasis.opendocument.text"
+	EPUB = "application/epub+zip"
+)
+
+// zipContentType is the type sniffed from the content of zip archives.
+const zipContentType = "application/zip"
+
+// maxEntrySize limits the decompressed size of a single archive member, so
+// that zip bombs cannot exhaust the memory.
+const maxEntrySize = 64 * 1024 * 1024
+
+// ErrTooLarge is returned for archive members exceeding maxEntrySize.
+var ErrTooLarge = errors.New("archive member too large")
+
+// Open returns a reader for the zip archive raw.
+func Open(raw []byte) (*zip.Reader, error) {
+	return zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+}
+
+// ReadFile returns the decompressed content of the member name of zr.
+func ReadFile(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck // read-only archive member
+	b, err := io.ReadAll(io.LimitReader(f, maxEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxEntrySize {
+		return nil, fmt.Errorf("%w: %s", ErrTooLarge, name)
+	}
+	return b, nil
+}
+
+// Detect returns the MIME type of the document format stored in the zip
+// archive raw, or an empty string for other archives.
+func Detect(raw []byte) string {
+	zr, err := Open(raw)
+	if err != nil {
+		return ""
+	}
+	// ODF and EPUB files start with an uncompressed mimetype member.
+	if b, err := ReadFile(zr, "mimetype"); err == nil {
+		switch mt := strings.TrimSpace(string(b)); mt {
+		case ODT, EPUB:
+			return mt
+		}
+	}
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			return DOCX
+		}
+	}
+	return ""
+}
+
+// Matches reports whether d is a document of the type mimeType, either
+// declared in its ContentType or detected in its raw zip content.
+func Matches(d *document.Document, mimeType string) bool {
+	if d.ContentType == mimeType {
+		return true
+	}
+	return d.ContentType == zipContentType && len(d.Raw) > 0 && Detect(d.Raw) == mimeType
+}
+
+// Meta is the document information found in the metadata of a file.
+type Meta struct {
+	Title       string
+	Author      string
+	Description string
+	Language    string
+	Published   string
+	Modified    string
+}
+
+// Apply sets the title of d, unless m has none, and copies the other fields
+// to the metadata of d. typ is the human-readable name of the format.
+func (m *Meta) Apply(d *document.Document, typ string) {
+	if t := strings.TrimSpace(m.Title); t != "" {
+		d.Title = t
+	}
+	if d.Metadata == nil {
+		d.Metadata = make(map[string]any)
+	}
+	d.Metadata["type"] = typ
+	set := func(k, v string) {
+		if v = strings.TrimSpace(v); v != "" {
+			d.Metadata[k] = v
+		}
+	}
+	set("author", m.Author)
+	set("description", m.Description)
+	set("language", m.Language)
+	set("published", normalizeDate(m.Published))
+	set("modified", normalizeDate(m.Modified))
+}
+
+// normalizeDate formats the ISO 8601 dates used by the document formats as
+// RFC 3339. Unparsable dates are returned unchanged.
+func normalizeDate(s string) string {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return s
+}
+
+type block struct {
+	// level is the heading level, zero for paragraphs.
+	level int
+	text  string
+}
+
+// Outline collects the headings and paragraphs of a document, and renders
+// them as plain text for the index and as HTML for previews.
+type Outline struct {
+	blocks []block
+}
+
+// Heading adds a heading of the given level, 1 being the highest.
+func (o *Outline) Heading(level int, text string) {
+	o.add(min(max(level, 1), 6), text)
+}
+
+// Paragraph adds a paragraph.
+func (o *Outline) Paragraph(text string) {
+	o.add(0, text)
+}
+
+// Section starts a new part of the document, e.g. a chapter of a book, whose
+// title is added as a top level heading.
+func (o *Outline) Section(title string) {
+	o.blocks = append(o.blocks, block{level: -1, text: strings.TrimSpace(title)})
+}
+
+func (o *Outline) add(level int, text string) {
+	text = strings.Join(strings.Fields(text), " ")
+	if text != "" {
+		o.blocks = append(o.blocks, block{level: level, text: text})
+	}
+}
+
+// Empty reports whether the outline has no text.
+func (o *Outline) Empty() bool {
+	for _, b := range o.blocks {
+		if b.text != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// FirstHeading returns the text of the first heading, or an empty string.
+func (o *Outline) FirstHeading() string {
+	for _, b := range o.blocks {
+		if b.level != 0 && b.text != "" {
+			return b.text
+		}
+	}
+	return ""
+}
+
+// Text returns the blocks separated by blank lines.
+func (o *Outline) Text() string {
+	parts := make([]string, 0, len(o.blocks))
+	for _, b := range o.blocks {
+		if b.text != "" {
+			parts = append(parts, b.text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// HTML renders the outline. Sections are preceded by a table of contents
+// listing their titles.
+func (o *Outline) HTML() string {
+	var b strings.Builder
+	var toc []string
+	for _, bl := range o.blocks {
+		if bl.level < 0 && bl.text != "" {
+			toc = append(toc, bl.text)
+		}
+	}
+	if len(toc) > 1 {
+		b.WriteString("<nav><h2>Contents</h2><ol>")
+		for _, t := range toc {
+			b.WriteString("<li>" + stdhtml.EscapeString(t) + "</li>")
+		}
+		b.WriteString("</ol></nav>")
+	}
+	inSection := false
+	for _, bl := range o.blocks {
+		text := stdhtml.EscapeString(bl.text)
+		switch {
+		case bl.level < 0:
+			if inSection {
+				b.WriteString("</section><hr>")
+			}
+			b.WriteString("<section>")
+			inSection = true
+			if text != "" {
+				b.WriteString("<h1>" + text + "</h1>")
+			}
+		case bl.level == 0:
+			b.WriteString("<p>" + text + "</p>")
+		default:
+			// Headings within sections are demoted below the section title.
+			level := bl.level
+			if inSection {
+				level = min(level+1, 6)
+			}
+			fmt.Fprintf(&b, "<h%d>%s</h%d>", level, text, level)
+		}
+	}
+	if inSection {
+		b.WriteString("</section>")
+	}
+	return b.String()
+}
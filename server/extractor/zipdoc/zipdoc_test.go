@@ -0,0 +1,78 @@
+package zipdoc
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/asciimoo/hister/server/document"
+)
+
+func makeZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		files map[string]string
+		want  string
+	}{
+		{map[string]string{"mimetype": ODT, "content.xml": ""}, ODT},
+		{map[string]string{"mimetype": EPUB + "\n"}, EPUB},
+		{map[string]string{"[Content_Types].xml": "", "word/document.xml": ""}, DOCX},
+		{map[string]string{"readme.txt": "hello"}, ""},
+	}
+	for _, tt := range tests {
+		if got := Detect(makeZip(t, tt.files)); got != tt.want {
+			t.Errorf("Detect(%v) = %q, want %q", tt.files, got, tt.want)
+		}
+	}
+	if got := Detect([]byte("not a zip")); got != "" {
+		t.Errorf("Detect(non-zip) = %q", got)
+	}
+
+	d := &document.Document{ContentType: "application/zip", Raw: makeZip(t, map[string]string{"mimetype": ODT})}
+	if !Matches(d, ODT) || Matches(d, EPUB) {
+		t.Error("Matches failed to detect ODT content")
+	}
+}
+
+func TestOutline(t *testing.T) {
+	o := &Outline{}
+	if !o.Empty() {
+		t.Fatal("new outline is not empty")
+	}
+	o.Section("Chapter <1>")
+	o.Heading(1, "Intro")
+	o.Paragraph("  first\n paragraph ")
+	o.Section("Chapter 2")
+	o.Paragraph("second")
+
+	if got, want := o.Text(), "Chapter <1>\n\nIntro\n\nfirst paragraph\n\nChapter 2\n\nsecond"; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+	want := "<nav><h2>Contents</h2><ol><li>Chapter &lt;1&gt;</li><li>Chapter 2</li></ol></nav>" +
+		"<section><h1>Chapter &lt;1&gt;</h1><h2>Intro</h2><p>first paragraph</p></section><hr>" +
+		"<section><h1>Chapter 2</h1><p>second</p></section>"
+	if got := o.HTML(); got != want {
+		t.Errorf("HTML() = %q, want %q", got, want)
+	}
+	if got := o.FirstHeading(); got != "Chapter <1>" {
+		t.Errorf("FirstHeading() = %q", got)
+	}
+}
@@ -0,0 +1,283 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+// Package feeds polls RSS, Atom and JSON feed subscriptions and indexes
+// their new entries. The subscriptions and the entries indexed so far are
+// stored in the database, so every entry is indexed only once.
+package feeds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/crawler"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/indexer"
+	"github.com/asciimoo/hister/server/model"
+)
+
+// Metadata keys of the documents indexed from feeds.
+const (
+	MetaFeed      = "feed"
+	MetaFeedURL   = "feed_url"
+	MetaAuthor    = "feed_author"
+	MetaPublished = "feed_published"
+)
+
+// checkInterval is how often the background job looks for feeds due for a
+// poll.
+const checkInterval = time.Minute
+
+// Run subscribes to the feeds of cfg.Crawler.Feeds and polls every feed
+// once per cfg.Crawler.Feeds.Interval until ctx is cancelled. It returns
+// immediately when feed polling is disabled.
+func Run(ctx context.Context, cfg *config.Config) error {
+	every := cfg.Crawler.Feeds.Every()
+	if every <= 0 {
+		return nil
+	}
+	if err := syncConfigured(cfg.Crawler.Feeds.Subscriptions); err != nil {
+		return err
+	}
+	p, err := NewPoller(&cfg.Crawler)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	log.Info().Msg("Starting background feed polling")
+	for {
+		if err := pollDue(ctx, p, every); err != nil && !errors.Is(err, context.Canceled) {
+			log.Error().Err(err).Msg("feeds: failed to poll feeds")
+		}
+		t := time.NewTimer(checkInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// syncConfigured subscribes to the feeds of the configuration file and
+// removes the configured feeds which are no longer in it.
+func syncConfigured(subs []*config.Feed) error {
+	var uid uint
+	feeds, err := model.ListFeeds(&uid)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(subs))
+	for _, s := range subs {
+		if _, err := model.AddFeed(0, s.URL, s.FetchPages, true); err != nil {
+			return err
+		}
+		keep[s.URL] = true
+	}
+	for _, f := range feeds {
+		if f.Configured && !keep[f.URL] {
+			if _, err := model.DeleteFeed(0, f.URL); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pollDue polls the feeds not checked for every.
+func pollDue(ctx context.Context, p *Poller, every time.Duration) error {
+	feeds, err := model.ListFeeds(nil)
+	if err != nil {
+		return err
+	}
+	for _, f := range feeds {
+		if time.Since(f.CheckedAt) < every {
+			continue
+		}
+		n, err := p.Poll(ctx, f)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("url", f.URL).Msg("feeds: failed to poll feed")
+			continue
+		}
+		if n > 0 {
+			log.Info().Int("entries", n).Str("url", f.URL).Msg("Indexed new feed entries")
+		}
+	}
+	return nil
+}
+
+// Poller fetches feeds and indexes their entries. Feeds are requested with
+// the conditional requests of crawler.Refetcher, the pages of entries are
+// downloaded with the configured crawler backend.
+//
+// A Poller is not safe for concurrent use.
+type Poller struct {
+	cfg     *config.CrawlerConfig
+	rf      *crawler.Refetcher
+	crawler crawler.Crawler
+}
+
+// NewPoller creates a Poller using the crawler settings of cfg.
+func NewPoller(cfg *config.CrawlerConfig) (*Poller, error) {
+	rf, err := crawler.NewRefetcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Poller{cfg: cfg, rf: rf}, nil
+}
+
+// Close releases the crawler backend used to fetch the pages of entries.
+func (p *Poller) Close() {
+	if p.crawler == nil {
+		return
+	}
+	if err := p.crawler.Close(); err != nil {
+		log.Warn().Err(err).Msg("feeds: failed to close crawler")
+	}
+	p.crawler = nil
+}
+
+// Poll fetches the feed f and indexes its new entries for the owner of the
+// feed. It returns the number of indexed entries. The outcome is saved in
+// the database, the error is recorded in f.Error as well.
+func (p *Poller) Poll(ctx context.Context, f *model.Feed) (int, error) {
+	n, err := p.poll(ctx, f)
+	if ctx.Err() != nil {
+		return n, ctx.Err()
+	}
+	f.CheckedAt = time.Now()
+	f.Error = ""
+	if err != nil {
+		f.Error = err.Error()
+	}
+	if serr := model.SaveFeed(f); serr != nil {
+		return n, serr
+	}
+	return n, err
+}
+
+func (p *Poller) poll(ctx context.Context, f *model.Feed) (int, error) {
+	res, err := p.rf.FetchRaw(ctx, f.URL, crawler.Validators{ETag: f.ETag, LastModified: f.LastModified})
+	if err != nil {
+		return 0, err
+	}
+	if res.NotModified {
+		f.ETag, f.LastModified = res.Validators.ETag, res.Validators.LastModified
+		return 0, nil
+	}
+	parsed, err := Parse(res.Raw, res.URL)
+	if err != nil {
+		return 0, err
+	}
+	if parsed.Title != "" {
+		f.Title = parsed.Title
+	}
+	n := 0
+	for _, e := range parsed.Entries {
+		known, err := model.HasFeedEntry(f.ID, e.ID)
+		if err != nil {
+			return n, err
+		}
+		if known {
+			continue
+		}
+		// Entries without a link cannot be indexed, and pages indexed
+		// before, e.g. because they were visited, are left alone.
+		if e.URL != "" && indexer.GetByURLAndUser(e.URL, f.UserID) == nil {
+			d := p.entryDocument(ctx, f, e)
+			if ctx.Err() != nil {
+				return n, ctx.Err()
+			}
+			if err := indexer.Add(d); err != nil {
+				log.Debug().Err(err).Str("url", e.URL).Msg("feeds: failed to index entry")
+			} else {
+				n++
+			}
+		}
+		if err := model.AddFeedEntry(f.ID, e.ID, e.URL); err != nil {
+			return n, err
+		}
+	}
+	// The validators are only kept once every entry has been processed, so
+	// an interrupted poll is repeated in full.
+	f.ETag, f.LastModified = res.Validators.ETag, res.Validators.LastModified
+	return n, nil
+}
+
+// entryDocument returns the document of a feed entry. The linked page is
+// fetched when the feed has FetchPages set, the content of the entry is
+// used otherwise, or when the page cannot be fetched.
+func (p *Poller) entryDocument(ctx context.Context, f *model.Feed, e *Entry) *document.Document {
+	var d *document.Document
+	if f.FetchPages {
+		var err error
+		if d, err = p.fetchPage(ctx, e.URL); err != nil {
+			log.Debug().Err(err).Str("url", e.URL).Msg("feeds: failed to fetch entry page, indexing the feed content")
+		}
+	}
+	if d == nil {
+		d = &document.Document{
+			URL:  e.URL,
+			HTML: entryHTML(e),
+		}
+	}
+	d.UserID = f.UserID
+	d.Metadata = map[string]any{
+		MetaFeedURL: f.URL,
+	}
+	if f.Title != "" {
+		d.Metadata[MetaFeed] = f.Title
+	}
+	if e.Author != "" {
+		d.Metadata[MetaAuthor] = e.Author
+	}
+	if !e.Published.IsZero() {
+		d.Metadata[MetaPublished] = e.Published.Unix()
+	}
+	return d
+}
+
+// fetchPage downloads the page at u with the crawler backend.
+func (p *Poller) fetchPage(ctx context.Context, u string) (*document.Document, error) {
+	if p.crawler == nil {
+		cr, err := crawler.New(p.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create crawler: %w", err)
+		}
+		p.crawler = cr
+	}
+	v, err := crawler.NewValidator(&crawler.ValidatorRules{MaxLinks: 1})
+	if err != nil {
+		return nil, err
+	}
+	ch, err := p.crawler.Crawl(ctx, u, v)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := <-ch
+	if !ok {
+		return nil, errors.New("no response")
+	}
+	for range ch {
+	}
+	return d, nil
+}
+
+// entryHTML wraps the content of an entry into an HTML page for the
+// extractors.
+func entryHTML(e *Entry) string {
+	author := ""
+	if e.Author != "" {
+		author = `<meta name="author" content="` + html.EscapeString(e.Author) + `">`
+	}
+	return "<!DOCTYPE html><html><head><title>" + html.EscapeString(e.Title) + "</title>" + author +
+		"</head><body><article>" + e.Content + "</article></body></html>"
+}
@@ -0,0 +1,384 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package feeds
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// ErrUnknownFormat is returned by Parse for documents which are not RSS,
+// Atom or JSON feeds.
+var ErrUnknownFormat = errors.New("unknown feed format")
+
+// Feed is a parsed feed.
+type Feed struct {
+	Title string
+	// URL is the address of the website the feed belongs to.
+	URL     string
+	Entries []*Entry
+}
+
+// Entry is an item of a feed.
+type Entry struct {
+	// ID identifies the entry within the feed. It falls back to the URL
+	// for entries without an identifier.
+	ID    string
+	URL   string
+	Title string
+	// Content is the HTML content of the entry, or its summary when the
+	// full content is not included.
+	Content   string
+	Author    string
+	Published time.Time
+}
+
+// dateLayouts are the date formats accepted besides the RFC 5322 dates of
+// RSS.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Parse parses an RSS 2.0, RSS 1.0 (RDF), Atom or JSON feed. Relative entry
+// URLs are resolved against base, the URL the feed was fetched from.
+func Parse(data []byte, base string) (*Feed, error) {
+	var f *Feed
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		f, err = parseJSON(trimmed)
+	} else {
+		f, err = parseXML(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	bu, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	f.Title = strings.TrimSpace(f.Title)
+	f.URL = resolve(bu, f.URL)
+	if f.URL != "" {
+		if fu, err := url.Parse(f.URL); err == nil {
+			bu = fu
+		}
+	}
+	entries := f.Entries[:0]
+	for _, e := range f.Entries {
+		e.URL = resolve(bu, e.URL)
+		e.ID = strings.TrimSpace(e.ID)
+		if e.ID == "" {
+			e.ID = e.URL
+		}
+		if e.ID == "" {
+			continue
+		}
+		e.Title = strings.TrimSpace(e.Title)
+		e.Author = strings.TrimSpace(e.Author)
+		entries = append(entries, e)
+	}
+	f.Entries = entries
+	return f, nil
+}
+
+type xmlLink struct {
+	Href  string `xml:"href,attr"`
+	Rel   string `xml:"rel,attr"`
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// pickLink returns the alternate link of a list of RSS or Atom links.
+func pickLink(links []xmlLink) string {
+	for _, l := range links {
+		if v := strings.TrimSpace(l.Value); v != "" {
+			return v
+		}
+	}
+	for _, l := range links {
+		if l.Href != "" && (l.Rel == "" || l.Rel == "alternate") {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+type rssItem struct {
+	Title       string    `xml:"title"`
+	Links       []xmlLink `xml:"link"`
+	GUID        string    `xml:"guid"`
+	About       string    `xml:"about,attr"`
+	Description string    `xml:"description"`
+	Content     string    `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Author      string    `xml:"author"`
+	Creator     string    `xml:"creator"`
+	PubDate     string    `xml:"pubDate"`
+	Date        string    `xml:"date"`
+}
+
+type rssChannel struct {
+	Title string    `xml:"title"`
+	Links []xmlLink `xml:"link"`
+	Items []rssItem `xml:"item"`
+}
+
+// rssFeed is an RSS 2.0 or RSS 1.0 document. The items of RSS 1.0 are
+// siblings of the channel.
+type rssFeed struct {
+	Channel rssChannel `xml:"channel"`
+	Items   []rssItem  `xml:"item"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// HTML returns the content of an Atom text construct as HTML.
+func (t atomText) HTML() string {
+	switch t.Type {
+	case "html", "text/html":
+		return t.Text
+	case "xhtml":
+		return t.Inner
+	default:
+		return html.EscapeString(t.Text)
+	}
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     atomText     `xml:"title"`
+	Links     []xmlLink    `xml:"link"`
+	Summary   atomText     `xml:"summary"`
+	Content   atomText     `xml:"content"`
+	Authors   []atomPerson `xml:"author"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+}
+
+type atomFeed struct {
+	Title   atomText     `xml:"title"`
+	Links   []xmlLink    `xml:"link"`
+	Authors []atomPerson `xml:"author"`
+	Entries []atomEntry  `xml:"entry"`
+}
+
+func parseXML(data []byte) (*Feed, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnknownFormat, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "rss", "RDF":
+			var r rssFeed
+			if err := dec.DecodeElement(&r, &start); err != nil {
+				return nil, err
+			}
+			return r.feed(), nil
+		case "feed":
+			var a atomFeed
+			if err := dec.DecodeElement(&a, &start); err != nil {
+				return nil, err
+			}
+			return a.feed(), nil
+		default:
+			return nil, fmt.Errorf("%w: <%s> root element", ErrUnknownFormat, start.Name.Local)
+		}
+	}
+}
+
+func (r *rssFeed) feed() *Feed {
+	f := &Feed{
+		Title: r.Channel.Title,
+		URL:   pickLink(r.Channel.Links),
+	}
+	for _, it := range append(r.Channel.Items, r.Items...) {
+		e := &Entry{
+			ID:        it.GUID,
+			URL:       pickLink(it.Links),
+			Title:     html.UnescapeString(it.Title),
+			Content:   it.Content,
+			Author:    it.Creator,
+			Published: parseDate(it.PubDate, it.Date),
+		}
+		if e.ID == "" {
+			e.ID = it.About
+		}
+		if e.Content == "" {
+			e.Content = it.Description
+		}
+		if e.Author == "" {
+			e.Author = it.Author
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	return f
+}
+
+func (a *atomFeed) feed() *Feed {
+	f := &Feed{
+		Title: html.UnescapeString(a.Title.Text),
+		URL:   pickLink(a.Links),
+	}
+	for _, ae := range a.Entries {
+		e := &Entry{
+			ID:        ae.ID,
+			URL:       pickLink(ae.Links),
+			Title:     html.UnescapeString(ae.Title.Text),
+			Content:   ae.Content.HTML(),
+			Published: parseDate(ae.Published, ae.Updated),
+		}
+		if strings.TrimSpace(e.Content) == "" {
+			e.Content = ae.Summary.HTML()
+		}
+		authors := ae.Authors
+		if len(authors) == 0 {
+			authors = a.Authors
+		}
+		if len(authors) > 0 {
+			e.Author = authors[0].Name
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	return f
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonItem struct {
+	// ID is a string in JSON Feed 1.1, but numbers are common in the wild.
+	ID            any          `json:"id"`
+	URL           string       `json:"url"`
+	ExternalURL   string       `json:"external_url"`
+	Title         string       `json:"title"`
+	ContentHTML   string       `json:"content_html"`
+	ContentText   string       `json:"content_text"`
+	Summary       string       `json:"summary"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified"`
+	Author        *jsonAuthor  `json:"author"`
+	Authors       []jsonAuthor `json:"authors"`
+}
+
+type jsonFeed struct {
+	Version     string       `json:"version"`
+	Title       string       `json:"title"`
+	HomePageURL string       `json:"home_page_url"`
+	Authors     []jsonAuthor `json:"authors"`
+	Items       []jsonItem   `json:"items"`
+}
+
+func parseJSON(data []byte) (*Feed, error) {
+	var j jsonFeed
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&j); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnknownFormat, err)
+	}
+	if !strings.Contains(j.Version, "jsonfeed.org") {
+		return nil, fmt.Errorf("%w: missing JSON Feed version", ErrUnknownFormat)
+	}
+	f := &Feed{
+		Title: j.Title,
+		URL:   j.HomePageURL,
+	}
+	for _, it := range j.Items {
+		e := &Entry{
+			URL:       it.URL,
+			Title:     it.Title,
+			Content:   it.ContentHTML,
+			Published: parseDate(it.DatePublished, it.DateModified),
+		}
+		if it.ID != nil {
+			e.ID = fmt.Sprint(it.ID)
+		}
+		if e.URL == "" {
+			e.URL = it.ExternalURL
+		}
+		switch {
+		case e.Content != "":
+		case it.ContentText != "":
+			e.Content = html.EscapeString(it.ContentText)
+		default:
+			e.Content = html.EscapeString(it.Summary)
+		}
+		authors := it.Authors
+		if it.Author != nil {
+			authors = append(authors, *it.Author)
+		}
+		if len(authors) == 0 {
+			authors = j.Authors
+		}
+		if len(authors) > 0 {
+			e.Author = authors[0].Name
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	return f, nil
+}
+
+// parseDate returns the first of the dates which can be parsed, or the zero
+// time.
+func parseDate(dates ...string) time.Time {
+	for _, s := range dates {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if t, err := mail.ParseDate(s); err == nil {
+			return t
+		}
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// resolve returns the absolute form of the URL ref relative to base. Only
+// http and https URLs are kept.
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
@@ -0,0 +1,146 @@
+package feeds
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+  <title>Example Blog</title>
+  <link>https://example.com/</link>
+  <atom:link href="https://example.com/feed.xml" rel="self" type="application/rss+xml"/>
+  <item>
+    <title>First &amp;amp; only</title>
+    <link>/posts/first</link>
+    <guid isPermaLink="false">post-1</guid>
+    <description>Summary</description>
+    <content:encoded><![CDATA[<p>Full <b>content</b></p>]]></content:encoded>
+    <dc:creator>Jane</dc:creator>
+    <pubDate>Mon, 02 Jan 2006 15:04:05 +0000</pubDate>
+  </item>
+  <item>
+    <title>No guid</title>
+    <link>https://example.com/posts/second</link>
+    <description>&lt;p&gt;Escaped&lt;/p&gt;</description>
+  </item>
+  <item>
+    <title>Nothing to identify</title>
+  </item>
+</channel>
+</rss>`
+
+const testAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Changelog</title>
+  <link href="https://example.org/"/>
+  <link rel="self" href="https://example.org/atom.xml"/>
+  <author><name>Team</name></author>
+  <entry>
+    <id>urn:uuid:1</id>
+    <title type="html">v1.0 &lt;released&gt;</title>
+    <link rel="alternate" href="https://example.org/v1"/>
+    <updated>2024-05-01T10:00:00Z</updated>
+    <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Notes</p></div></content>
+  </entry>
+  <entry>
+    <id>urn:uuid:2</id>
+    <title>Plain</title>
+    <link href="v2"/>
+    <published>2024-06-01T10:00:00+02:00</published>
+    <summary>a &lt; b</summary>
+    <author><name>Joe</name></author>
+  </entry>
+</feed>`
+
+const testJSON = `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "JSON Blog",
+  "home_page_url": "https://example.net/",
+  "authors": [{"name": "Ann"}],
+  "items": [
+    {"id": 12345678, "url": "https://example.net/a", "title": "A", "content_text": "x < y", "date_published": "2024-01-02T03:04:05Z"},
+    {"id": "b", "external_url": "https://other.example/b", "title": "B", "content_html": "<p>B</p>"}
+  ]
+}`
+
+func TestParseRSS(t *testing.T) {
+	f, err := Parse([]byte(testRSS), "https://example.com/feed.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Title != "Example Blog" || f.URL != "https://example.com/" {
+		t.Fatalf("unexpected feed %q %q", f.Title, f.URL)
+	}
+	if len(f.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(f.Entries))
+	}
+	e := f.Entries[0]
+	if e.ID != "post-1" || e.URL != "https://example.com/posts/first" || e.Title != "First & only" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e.Content != "<p>Full <b>content</b></p>" || e.Author != "Jane" {
+		t.Errorf("unexpected content %q by %q", e.Content, e.Author)
+	}
+	if !e.Published.Equal(time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)) {
+		t.Errorf("unexpected date %v", e.Published)
+	}
+	e = f.Entries[1]
+	if e.ID != "https://example.com/posts/second" || e.Content != "<p>Escaped</p>" || !e.Published.IsZero() {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	f, err := Parse([]byte(testAtom), "https://example.org/atom.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Title != "Changelog" || f.URL != "https://example.org/" || len(f.Entries) != 2 {
+		t.Fatalf("unexpected feed %+v", f)
+	}
+	e := f.Entries[0]
+	if e.ID != "urn:uuid:1" || e.URL != "https://example.org/v1" || e.Title != "v1.0 <released>" || e.Author != "Team" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e.Content != `<div xmlns="http://www.w3.org/1999/xhtml"><p>Notes</p></div>` {
+		t.Errorf("unexpected content %q", e.Content)
+	}
+	if !e.Published.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected date %v", e.Published)
+	}
+	e = f.Entries[1]
+	if e.URL != "https://example.org/v2" || e.Content != "a &lt; b" || e.Author != "Joe" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if !e.Published.Equal(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected date %v", e.Published)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	f, err := Parse([]byte(testJSON), "https://example.net/feed.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Title != "JSON Blog" || len(f.Entries) != 2 {
+		t.Fatalf("unexpected feed %+v", f)
+	}
+	e := f.Entries[0]
+	if e.ID != "12345678" || e.Content != "x &lt; y" || e.Author != "Ann" || e.Published.IsZero() {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e = f.Entries[1]; e.URL != "https://other.example/b" || e.Content != "<p>B</p>" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
+
+func TestParseUnknown(t *testing.T) {
+	for _, data := range []string{"<html><body>hi</body></html>", `{"title": "not a feed"}`, ""} {
+		if _, err := Parse([]byte(data), "https://example.com/"); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("%q: expected unknown format error, got %v", data, err)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+// Package gitrepo reads the commits and READMEs of local git repositories.
+package gitrepo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/asciimoo/hister/files"
+)
+
+// ContentType is the content type of indexed commits.
+const ContentType = "text/x-git-commit"
+
+// Scheme is the URL scheme of the documents of repositories. The URL of a
+// commit is the path of the repository with a commit query parameter, e.g.
+// git+file:///home/user/src/hister?commit=<hash>, the URL of a file has a
+// file parameter instead.
+const Scheme = "git+file"
+
+const (
+	// maxPaths limits the number of changed paths recorded for a commit.
+	maxPaths = 1000
+	// maxDiffSize limits the size of rendered diffs.
+	maxDiffSize = 512 * 1024
+)
+
+// ErrNotRepository is returned for paths which are not a git repository.
+var ErrNotRepository = errors.New("not a git repository")
+
+// Commit is a commit read from a repository.
+type Commit struct {
+	Hash string
+	// Author is the name and the e-mail address of the author as
+	// "Name <address>".
+	Author  string
+	Date    time.Time
+	Message string
+	// Paths are the files changed by the commit, compared to its first
+	// parent.
+	Paths []string
+}
+
+// Subject returns the first line of the commit message.
+func (c *Commit) Subject() string {
+	s, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+	return strings.TrimSpace(s)
+}
+
+// File is a file of the tree of the HEAD commit.
+type File struct {
+	Name string
+	// Hash is the hash of the blob of the file.
+	Hash    string
+	Content []byte
+}
+
+// Repo is a local git repository opened for reading.
+type Repo struct {
+	path string
+	r    *git.Repository
+}
+
+// Open opens the repository at path, the directory of a working tree or a
+// bare repository.
+func Open(path string) (*Repo, error) {
+	r, err := git.PlainOpen(path)
+	if err != nil {
+		if errors.Is(err, git.ErrRepositoryNotExists) {
+			return nil, fmt.Errorf("%w: %s", ErrNotRepository, path)
+		}
+		return nil, err
+	}
+	return &Repo{path: path, r: r}, nil
+}
+
+// Path returns the path the repository was opened at.
+func (r *Repo) Path() string {
+	return r.path
+}
+
+// Find returns the repositories at or below root. The directories of found
+// repositories are not searched further, hidden directories are skipped.
+func Find(root string) ([]string, error) {
+	if isRepository(root) {
+		return []string{root}, nil
+	}
+	var repos []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() || path == root {
+			return nil
+		}
+		if files.ShouldSkipDir(d.Name(), nil, false) {
+			return filepath.SkipDir
+		}
+		if isRepository(path) {
+			repos = append(repos, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotRepository, root)
+	}
+	return repos, nil
+}
+
+// isRepository reports whether dir is the working tree of a repository or a
+// bare repository.
+func isRepository(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return true
+	}
+	for _, f := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Heads returns the sorted hashes of the commits of HEAD and of the local
+// branches.
+func (r *Repo) Heads() ([]string, error) {
+	var heads []string
+	if h, err := r.r.Head(); err == nil {
+		heads = append(heads, h.Hash().String())
+	} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, err
+	}
+	refs, err := r.r.Branches()
+	if err != nil {
+		return nil, err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		heads = append(heads, ref.Hash().String())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(heads)
+	return slices.Compact(heads), nil
+}
+
+// Commits returns the commits reachable from the heads of the repository,
+// from the oldest to the newest. The history behind commits for which known
+// returns true is not read, so only the commits added since the last scan
+// are returned when known reports the indexed ones.
+func (r *Repo) Commits(known func(hash string) bool) iter.Seq2[*Commit, error] {
+	return func(yield func(*Commit, error) bool) {
+		heads, err := r.Heads()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		var pending []*object.Commit
+		seen := make(map[plumbing.Hash]bool)
+		queue := make([]plumbing.Hash, 0, len(heads))
+		for _, h := range heads {
+			queue = append(queue, plumbing.NewHash(h))
+		}
+		for len(queue) > 0 {
+			h := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			if seen[h] {
+				continue
+			}
+			seen[h] = true
+			if known != nil && known(h.String()) {
+				continue
+			}
+			c, err := r.r.CommitObject(h)
+			if err != nil {
+				// Shallow clones miss the parents of their oldest commits.
+				if errors.Is(err, plumbing.ErrObjectNotFound) {
+					continue
+				}
+				yield(nil, err)
+				return
+			}
+			pending = append(pending, c)
+			queue = append(queue, c.ParentHashes...)
+		}
+		slices.SortStableFunc(pending, func(a, b *object.Commit) int {
+			return a.Committer.When.Compare(b.Committer.When)
+		})
+		for _, c := range pending {
+			paths, err := changedPaths(c)
+			if err != nil {
+				if !yield(nil, fmt.Errorf("commit %s: %w", c.Hash, err)) {
+					return
+				}
+				continue
+			}
+			commit := &Commit{
+				Hash:    c.Hash.String(),
+				Author:  c.Author.Name + " <" + c.Author.Email + ">",
+				Date:    c.Author.When,
+				Message: c.Message,
+				Paths:   paths,
+			}
+			if !yield(commit, nil) {
+				return
+			}
+		}
+	}
+}
+
+// changedPaths returns the paths changed by c compared to its first parent.
+func changedPaths(c *object.Commit) ([]string, error) {
+	changes, err := changes(c)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, min(len(changes), maxPaths))
+	for _, ch := range changes {
+		if len(paths) == maxPaths {
+			break
+		}
+		if ch.To.Name != "" {
+			paths = append(paths, ch.To.Name)
+		} else {
+			paths = append(paths, ch.From.Name)
+		}
+	}
+	return paths, nil
+}
+
+// changes returns the changes of c compared to its first parent, or to the
+// empty tree for root commits.
+func changes(c *object.Commit) (object.Changes, error) {
+	to, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var from *object.Tree
+	if c.NumParents() > 0 {
+		p, err := c.Parent(0)
+		if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, err
+		}
+		if p != nil {
+			if from, err = p.Tree(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return object.DiffTree(from, to)
+}
+
+// Readmes returns the README files in the root of the tree of HEAD.
+func (r *Repo) Readmes() ([]*File, error) {
+	h, err := r.r.Head()
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	c, err := r.r.CommitObject(h.Hash())
+	if err != nil {
+		return nil, err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var readmes []*File
+	for _, e := range tree.Entries {
+		if !e.Mode.IsFile() || !IsReadme(e.Name) {
+			continue
+		}
+		f, err := tree.TreeEntryFile(&e)
+		if err != nil {
+			return nil, err
+		}
+		content, err := f.Contents()
+		if err != nil {
+			return nil, err
+		}
+		readmes = append(readmes, &File{Name: e.Name, Hash: e.Hash.String(), Content: []byte(content)})
+	}
+	return readmes, nil
+}
+
+// IsReadme reports whether name is the name of a README file.
+func IsReadme(name string) bool {
+	base := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+	return base == "readme" || strings.EqualFold(name, "readme")
+}
+
+// Diff returns the changes of the commit hash compared to its first parent
+// as a unified diff. Diffs larger than maxDiffSize are truncated.
+func (r *Repo) Diff(hash string) (string, error) {
+	c, err := r.r.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return "", err
+	}
+	ch, err := changes(c)
+	if err != nil {
+		return "", err
+	}
+	p, err := ch.Patch()
+	if err != nil {
+		return "", err
+	}
+	s := p.String()
+	if len(s) > maxDiffSize {
+		s = s[:strings.LastIndexByte(s[:maxDiffSize], '\n')+1] + "[diff truncated]\n"
+	}
+	return s, nil
+}
+
+// CommitURL returns the URL of the commit hash of the repository at path.
+func CommitURL(path, hash string) string {
+	return repoURL(path) + "?commit=" + hash
+}
+
+// FileURL returns the URL of the file name in the tree of HEAD of the
+// repository at path.
+func FileURL(path, name string) string {
+	return repoURL(path) + "?file=" + name
+}
+
+func repoURL(path string) string {
+	return Scheme + strings.TrimPrefix(files.PathToFileURL(path), "file")
+}
+
+// ParseURL returns the path of the repository and the commit hash or the
+// file name of a URL returned by CommitURL or FileURL.
+func ParseURL(u string) (path, commit, file string, err error) {
+	rest, ok := strings.CutPrefix(u, Scheme+"://")
+	if !ok {
+		return "", "", "", fmt.Errorf("not a %s URL: %s", Scheme, u)
+	}
+	i := strings.LastIndexByte(rest, '?')
+	if i < 0 {
+		return "", "", "", fmt.Errorf("invalid %s URL: %s", Scheme, u)
+	}
+	path = files.FileURLToPath("file://" + rest[:i])
+	if commit, ok = strings.CutPrefix(rest[i+1:], "commit="); ok && plumbing.IsHash(commit) {
+		return path, commit, "", nil
+	}
+	if file, ok = strings.CutPrefix(rest[i+1:], "file="); ok && file != "" {
+		return path, "", file, nil
+	}
+	return "", "", "", fmt.Errorf("invalid %s URL: %s", Scheme, u)
+}
@@ -0,0 +1,144 @@
+package gitrepo
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func commitFile(t *testing.T, dir, name, content, msg string, when time.Time) string {
+	t.Helper()
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add(name); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "Jane Doe", Email: "jane@example.com", When: when}
+	h, err := w.Commit(msg, &git.CommitOptions{Author: sig, Committer: sig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h.String()
+}
+
+func collect(t *testing.T, r *Repo, known func(string) bool) []*Commit {
+	t.Helper()
+	var commits []*Commit
+	for c, err := range r.Commits(known) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
+
+func TestCommits(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)
+	first := commitFile(t, dir, "README.md", "# Hello\n", "Initial commit\n", start)
+	second := commitFile(t, dir, "main.go", "package main\n", "Add main\n\nWith a longer description.\n", start.Add(time.Hour))
+	r, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits := collect(t, r, nil)
+	if len(commits) != 2 || commits[0].Hash != first || commits[1].Hash != second {
+		t.Fatalf("unexpected commits %v", commits)
+	}
+	c := commits[1]
+	if c.Subject() != "Add main" || c.Author != "Jane Doe <jane@example.com>" || !c.Date.Equal(start.Add(time.Hour)) {
+		t.Fatalf("unexpected commit %+v", c)
+	}
+	if !slices.Equal(c.Paths, []string{"main.go"}) || !slices.Equal(commits[0].Paths, []string{"README.md"}) {
+		t.Fatalf("unexpected paths %v %v", commits[0].Paths, c.Paths)
+	}
+	third := commitFile(t, dir, "main.go", "package main\n\nfunc main() {}\n", "Add main function\n", start.Add(2*time.Hour))
+	known := func(h string) bool { return h == first || h == second }
+	if commits := collect(t, r, known); len(commits) != 1 || commits[0].Hash != third {
+		t.Fatalf("expected only the new commit, got %v", commits)
+	}
+	diff, err := r.Diff(third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "+func main() {}") || !strings.Contains(diff, "main.go") {
+		t.Fatalf("unexpected diff %q", diff)
+	}
+	readmes, err := r.Readmes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readmes) != 1 || readmes[0].Name != "README.md" || string(readmes[0].Content) != "# Hello\n" {
+		t.Fatalf("unexpected readmes %v", readmes)
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a", "b", filepath.Join("a", "vendor", "c"), filepath.Join(".hidden", "d")} {
+		if _, err := git.PlainInit(filepath.Join(root, name), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	repos, err := Find(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(repos, []string{filepath.Join(root, "a"), filepath.Join(root, "b")}) {
+		t.Fatalf("unexpected repositories %v", repos)
+	}
+	if _, err := Find(filepath.Join(root, "empty")); err == nil {
+		t.Fatal("expected error for directory without repositories")
+	}
+}
+
+func TestURL(t *testing.T) {
+	hash := "0123456789abcdef0123456789abcdef01234567"
+	u := CommitURL("/home/jane/src/my repo", hash)
+	if u != "git+file:///home/jane/src/my repo?commit="+hash {
+		t.Fatalf("unexpected URL %q", u)
+	}
+	path, commit, file, err := ParseURL(u)
+	if err != nil || path != "/home/jane/src/my repo" || commit != hash || file != "" {
+		t.Fatalf("unexpected parse result %q %q %q %v", path, commit, file, err)
+	}
+	path, commit, file, err = ParseURL(FileURL("/src/hister", "README.md"))
+	if err != nil || path != "/src/hister" || commit != "" || file != "README.md" {
+		t.Fatalf("unexpected parse result %q %q %q %v", path, commit, file, err)
+	}
+	for _, u := range []string{"file:///src/hister", "git+file:///src/hister", "git+file:///src/hister?commit=abc"} {
+		if _, _, _, err := ParseURL(u); err == nil {
+			t.Fatalf("expected error for %q", u)
+		}
+	}
+}
+
+func TestIsReadme(t *testing.T) {
+	for name, want := range map[string]bool{"README": true, "README.md": true, "readme.rst": true, "README-dev.md": false, "main.go": false} {
+		if IsReadme(name) != want {
+			t.Errorf("%s: expected %v", name, want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/rs/zerolog/log"
+
+	"github.com/asciimoo/hister/files"
+	"github.com/asciimoo/hister/server/document"
+)
+
+// indexArchive indexes the members of the archive at path.
+func indexArchive(path string) error {
+	docs, err := loadArchive(path)
+	if err != nil || len(docs) == 0 {
+		return err
+	}
+	b := NewMultiBatch()
+	for _, d := range docs {
+		if err := b.Add(d); err != nil {
+			log.Debug().Err(err).Str("URL", d.URL).Msg("Skipping archive member")
+		}
+	}
+	return b.Save()
+}
+
+// loadArchive reads and processes the members of the archive at path. Every
+// member is a document with the URL of the archive followed by
+// files.ArchiveSeparator and the name of the member. No documents are
+// returned when the archive is unchanged since it was last indexed, members
+// whose content did not change are left out as well. The documents of
+// members no longer in the archive are deleted.
+func loadArchive(path string) ([]*document.Document, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxArchiveSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFileTooLarge, info.Size())
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	archiveURL := files.PathToFileURL(absPath)
+	modTime := info.ModTime().Unix()
+	if archiveAdded(archiveURL) == modTime {
+		return nil, nil
+	}
+	var docs []*document.Document
+	members := make(map[string]bool)
+	for m, err := range files.ArchiveMembers(absPath, maxArchiveMembers, maxFileSize) {
+		if errors.Is(err, files.ErrTooManyMembers) {
+			log.Warn().Err(err).Msg("Indexing the first members of archive")
+			continue
+		}
+		if err != nil {
+			log.Debug().Err(err).Str("path", absPath).Msg("Skipping archive member")
+			continue
+		}
+		if len(m.Content) == 0 {
+			continue
+		}
+		u := files.PathToFileURL(files.ArchiveMemberPath(absPath, m.Name))
+		members[u] = true
+		d, err := processFileContent(u, m.Content, modTime, GetByURLAndUser(u, 0))
+		if err != nil {
+			log.Debug().Err(err).Str("URL", u).Msg("Skipping archive member")
+			continue
+		}
+		if d != nil {
+			docs = append(docs, d)
+		}
+	}
+	prefix := archiveURL + files.ArchiveSeparator
+	q := bleve.NewPrefixQuery(strings.ToLower(prefix))
+	q.SetField("url")
+	ids, err := localDocuments(q, func(u string) bool {
+		return strings.HasPrefix(u, prefix) && !members[u]
+	})
+	if err == nil {
+		_, err = deleteDocuments(ids)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("path", absPath).Msg("Failed to remove deleted archive members")
+	}
+	return docs, nil
+}
+
+// archiveAdded returns the modification time of the archive at archiveURL
+// recorded when its members were last indexed, or 0 when none are indexed.
+func archiveAdded(archiveURL string) int64 {
+	prefix := archiveURL + files.ArchiveSeparator
+	q := bleve.NewPrefixQuery(strings.ToLower(prefix))
+	q.SetField("url")
+	req := bleve.NewSearchRequest(q)
+	req.Fields = []string{"url", "added"}
+	req.Size = 10
+	res, err := i.idx.Search(req)
+	if err != nil {
+		return 0
+	}
+	for _, h := range res.Hits {
+		if u, ok := h.Fields["url"].(string); ok && strings.HasPrefix(u, prefix) {
+			added, _ := h.Fields["added"].(float64)
+			return int64(added)
+		}
+	}
+	return 0
+}
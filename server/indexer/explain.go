@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package indexer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2/search"
+)
+
+// termWeightRe matches the message of the Bleve explanation of a term score.
+var termWeightRe = regexp.MustCompile(`^weight\(([^:]+):(.*)\^([0-9.eE+-]+) in .*\), product of:$`)
+
+// numericFields are indexed as encoded terms, which are left out of the
+// explanations.
+var numericFields = map[string]bool{"added": true, "type": true, "user_id": true}
+
+// Explanation explains the keyword score of a search result, returned when
+// Query.Explain is set.
+type Explanation struct {
+	// Fields holds the score contributed by each field. Scores not tied to
+	// a field, like the ones of constant score queries, are under "".
+	Fields map[string]float64 `json:"fields"`
+	// Terms holds the score contributed by each matching term.
+	Terms []*TermScore `json:"terms"`
+	// Details is the full scoring tree of Bleve.
+	Details *search.Explanation `json:"details"`
+}
+
+// TermScore is the score contributed by a term matching a field. Boost is
+// the boost of the term, Score includes it together with the coordination
+// factors of the enclosing queries. Term is empty for numeric fields.
+type TermScore struct {
+	Field string  `json:"field"`
+	Term  string  `json:"term"`
+	Boost float64 `json:"boost"`
+	Score float64 `json:"score"`
+}
+
+func newExplanation(e *search.Explanation) *Explanation {
+	if e == nil {
+		return nil
+	}
+	x := &Explanation{
+		Fields:  make(map[string]float64),
+		Terms:   []*TermScore{},
+		Details: e,
+	}
+	x.add(e, 1)
+	return x
+}
+
+// add attributes the score of e multiplied by scale to the fields of its
+// terms.
+func (x *Explanation) add(e *search.Explanation, scale float64) {
+	if m := termWeightRe.FindStringSubmatch(e.Message); m != nil {
+		ts := &TermScore{Field: m[1], Term: m[2], Score: scale * e.Value}
+		ts.Boost, _ = strconv.ParseFloat(m[3], 64)
+		if numericFields[ts.Field] {
+			ts.Term = ""
+		}
+		x.Fields[ts.Field] += ts.Score
+		x.Terms = append(x.Terms, ts)
+		return
+	}
+	switch {
+	case e.Message == "sum of:":
+		for _, c := range e.Children {
+			x.add(c, scale)
+		}
+		return
+	case strings.HasSuffix(e.Message, "product of:"):
+		// a product of a single subquery score and constant factors, like
+		// the coordination factor of disjunctions
+		var sub *search.Explanation
+		factor := 1.0
+		for _, c := range e.Children {
+			if len(c.Children) == 0 {
+				factor *= c.Value
+				continue
+			}
+			if sub != nil {
+				sub = nil
+				break
+			}
+			sub = c
+		}
+		if sub != nil {
+			x.add(sub, scale*factor)
+			return
+		}
+	}
+	x.Fields[""] += scale * e.Value
+}
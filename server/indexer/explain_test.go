@@ -0,0 +1,73 @@
+package indexer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/indexer/querybuilder"
+)
+
+func TestSearchExplain(t *testing.T) {
+	newTestIndexer(t)
+	addTestDocs(t,
+		&document.Document{URL: "https://go.dev/", Title: "Golang", Text: "Golang is an open source language"},
+		&document.Document{URL: "https://example.com/", Title: "Example", Text: "an example mentioning golang"},
+	)
+
+	r, err := Search(nil, &Query{Text: "golang", Explain: true})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(r.Documents) != 2 || len(r.Explanations) != 2 {
+		t.Fatalf("expected 2 results with explanations, got %d and %d", len(r.Documents), len(r.Explanations))
+	}
+	boosts := querybuilder.Boosts()
+	cases := map[string]map[string]bool{
+		"https://go.dev/":      {"title": true, "text": true},
+		"https://example.com/": {"text": true},
+	}
+	for _, d := range r.Documents {
+		x := r.Explanations[d.ID()]
+		if x == nil {
+			t.Fatalf("no explanation for %s", d.URL)
+		}
+		if x.Details == nil {
+			t.Errorf("%s: missing scoring details", d.URL)
+		}
+		fields := cases[d.URL]
+		if len(x.Fields) != len(fields) {
+			t.Errorf("%s: expected the fields %v, got %v", d.URL, fields, x.Fields)
+		}
+		sum := 0.0
+		for f, s := range x.Fields {
+			if !fields[f] || s <= 0 {
+				t.Errorf("%s: unexpected score %v of field %q", d.URL, s, f)
+			}
+			sum += s
+		}
+		if math.Abs(sum-d.Score) > 1e-9 {
+			t.Errorf("%s: field scores add up to %v, expected the score %v", d.URL, sum, d.Score)
+		}
+		for _, ts := range x.Terms {
+			if ts.Term != "golang" || ts.Boost != boosts[ts.Field] || ts.Score != x.Fields[ts.Field] {
+				t.Errorf("%s: unexpected term score %+v", d.URL, ts)
+			}
+		}
+	}
+	// the title match of the boosted field ranks first
+	if r.Documents[0].URL != "https://go.dev/" {
+		t.Errorf("expected https://go.dev/ first, got %v", resultURLs(r))
+	}
+	if x := r.Explanations[r.Documents[0].ID()]; x.Fields["title"] <= x.Fields["text"] {
+		t.Errorf("expected the title to outweigh the text, got %v", x.Fields)
+	}
+
+	r, err = Search(nil, &Query{Text: "golang"})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if r.Explanations != nil {
+		t.Errorf("expected no explanations without Query.Explain, got %v", r.Explanations)
+	}
+}
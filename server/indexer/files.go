@@ -1,18 +1,28 @@
 package indexer
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
-	"unicode/utf8"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
 	"github.com/rs/zerolog/log"
 
 	"github.com/asciimoo/hister/config"
 	"github.com/asciimoo/hister/files"
 	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/extractor"
+	"github.com/asciimoo/hister/server/types"
 )
 
 var (
@@ -20,19 +30,170 @@ var (
 	ErrBinaryFile   = errors.New("binary file")
 	ErrFileTooLarge = errors.New("file too large")
 
-	maxFileSize int64 = 1024 * 1024 // 1MB default
+	maxFileSize       int64 = 1024 * 1024      // 1MB default
+	maxArchiveSize    int64 = 50 * 1024 * 1024 // 50MB default
+	maxArchiveMembers       = 1000
 )
 
+// MetaContentHash is the metadata key of the SHA-256 hash of the content of
+// local files, used to skip files touched without being changed.
+const MetaContentHash = "content_hash"
+
+// fileBatchSize is the number of files written to the index at once by
+// IndexAll.
+const fileBatchSize = 100
+
+// progressInterval is how often IndexAll logs its progress.
+const progressInterval = 10 * time.Second
+
+// FileProgress reports the progress of indexing the configured directories.
+type FileProgress struct {
+	Running   bool      `json:"running"`
+	Directory string    `json:"directory,omitempty"`
+	Started   time.Time `json:"started,omitzero"`
+	Finished  time.Time `json:"finished,omitzero"`
+	// Found is the number of matching files found so far.
+	Found int64 `json:"found"`
+	// Indexed counts the new and changed files, Unchanged the files already
+	// up to date and Skipped the files which could not be indexed. Like
+	// Found, they count an archive as a single file.
+	Indexed   int64 `json:"indexed"`
+	Unchanged int64 `json:"unchanged"`
+	Skipped   int64 `json:"skipped"`
+}
+
+var (
+	progressMu sync.Mutex
+	progress   FileProgress
+)
+
+// IndexProgress returns the progress of the current or last run of
+// IndexAll.
+func IndexProgress() FileProgress {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	return progress
+}
+
+// updateProgress applies fn to the progress of IndexAll.
+func updateProgress(fn func(p *FileProgress)) {
+	progressMu.Lock()
+	fn(&progress)
+	progressMu.Unlock()
+}
+
+// IndexAll indexes the new and changed files of dirs. Files are read and
+// processed by a pool of workers and written to the index in batches.
 func IndexAll(dirs []*config.Directory) {
-	for _, dir := range dirs {
-		expanded := files.ExpandHome(dir.Path)
-		if err := indexDirectory(expanded, dir); err != nil {
-			log.Error().Err(err).Str("directory", expanded).Msg("Failed to index directory")
+	updateProgress(func(p *FileProgress) {
+		*p = FileProgress{Running: true, Started: time.Now()}
+	})
+	paths := make(chan string)
+	// the documents of a file, the members of archives are sent together
+	docs := make(chan []*document.Document)
+	var workers sync.WaitGroup
+	for range runtime.NumCPU() {
+		workers.Go(func() {
+			for path := range paths {
+				loaded, err := loadPath(path)
+				// files with documents are counted once they are written
+				updateProgress(func(p *FileProgress) {
+					switch {
+					case len(loaded) > 0:
+					case err != nil:
+						p.Skipped++
+					default:
+						p.Unchanged++
+					}
+				})
+				if err != nil {
+					log.Debug().Err(err).Str("path", path).Msg("Skipping file")
+				}
+				if len(loaded) > 0 {
+					docs <- loaded
+				}
+			}
+		})
+	}
+	go func() {
+		for _, dir := range dirs {
+			expanded := files.ExpandHome(dir.Path)
+			updateProgress(func(p *FileProgress) { p.Directory = expanded })
+			if err := walkDirectory(expanded, dir, paths); err != nil {
+				log.Error().Err(err).Str("directory", expanded).Msg("Failed to index directory")
+			}
+		}
+		close(paths)
+		workers.Wait()
+		close(docs)
+	}()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	b := NewMultiBatch()
+	pending := 0
+	save := func() {
+		if err := b.Save(); err != nil {
+			log.Error().Err(err).Msg("Failed to save indexed files")
 		}
+		b = NewMultiBatch()
+		pending = 0
 	}
+	for done := false; !done; {
+		select {
+		case loaded, ok := <-docs:
+			if !ok {
+				done = true
+				break
+			}
+			added := 0
+			for _, d := range loaded {
+				if err := b.Add(d); err != nil {
+					log.Debug().Err(err).Str("URL", d.URL).Msg("Skipping file")
+					continue
+				}
+				added++
+				if pending++; pending == fileBatchSize {
+					save()
+				}
+			}
+			updateProgress(func(p *FileProgress) {
+				if added > 0 {
+					p.Indexed++
+				} else {
+					p.Skipped++
+				}
+			})
+		case <-ticker.C:
+			logProgress("Indexing local files")
+		}
+	}
+	save()
+	updateProgress(func(p *FileProgress) {
+		p.Running = false
+		p.Directory = ""
+		p.Finished = time.Now()
+	})
+	logProgress("Local file indexing complete")
 }
 
-func indexDirectory(dir string, cfg *config.Directory) error {
+// logProgress logs the progress of IndexAll.
+func logProgress(msg string) {
+	p := IndexProgress()
+	e := log.Info()
+	if p.Directory != "" {
+		e = e.Str("directory", p.Directory)
+	}
+	e.Int64("found", p.Found).
+		Int64("indexed", p.Indexed).
+		Int64("unchanged", p.Unchanged).
+		Int64("skipped", p.Skipped).
+		Dur("duration", time.Since(p.Started).Round(time.Second)).
+		Msg(msg)
+}
+
+// walkDirectory sends the files of dir matching cfg to paths.
+func walkDirectory(dir string, cfg *config.Directory, paths chan<- string) error {
 	info, err := os.Stat(dir)
 	if err != nil {
 		return fmt.Errorf("cannot access directory: %w", err)
@@ -41,77 +202,273 @@ func indexDirectory(dir string, cfg *config.Directory) error {
 		return fmt.Errorf("not a directory: %s", dir)
 	}
 
-	indexed := 0
-	skipped := 0
-
 	log.Debug().Str("directory", dir).Msg("Indexing directory")
 
-	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+	ignorer := files.NewIgnorer(dir)
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			log.Warn().Err(err).Str("path", path).Msg("Error accessing path")
 			return nil
 		}
 		if d.IsDir() {
-			if path != dir && files.ShouldSkipDir(d.Name(), cfg.Excludes, cfg.IncludeHidden) {
+			if path != dir && (files.ShouldSkipDir(d.Name(), cfg.Excludes, cfg.IncludeHidden) || ignorer.Match(path, true)) {
 				return filepath.SkipDir
 			}
 			return nil
 		}
-		if !cfg.IsMatching(d.Name()) {
+		if !cfg.IsMatching(d.Name()) || ignorer.Match(path, false) {
 			return nil
 		}
-		if err := IndexFile(path); err != nil {
-			log.Debug().Err(err).Str("path", path).Msg("Skipping file")
-			skipped++
-		} else {
-			indexed++
-		}
+		updateProgress(func(p *FileProgress) { p.Found++ })
+		paths <- path
 		return nil
 	})
-
-	log.Debug().Str("directory", dir).Int("indexed", indexed).Int("skipped", skipped).Msg("Directory indexing complete")
-	return err
 }
 
+// IndexFile indexes the local file at path unless it is unchanged since it
+// was last indexed. The members of archives are indexed as separate
+// documents.
 func IndexFile(path string) error {
+	if files.IsArchive(path) {
+		return indexArchive(path)
+	}
+	d, err := loadFile(path)
+	if err != nil || d == nil {
+		return err
+	}
+	return i.AddDocument(d)
+}
+
+// loadPath reads and processes the local file at path, or the members of
+// the archive at path.
+func loadPath(path string) ([]*document.Document, error) {
+	if files.IsArchive(path) {
+		return loadArchive(path)
+	}
+	d, err := loadFile(path)
+	if d == nil {
+		return nil, err
+	}
+	return []*document.Document{d}, err
+}
+
+// loadFile reads and processes the local file at path. It returns a nil
+// document when the file is unchanged since it was last indexed, which is
+// the case when its modification time or the hash of its content match the
+// indexed document.
+func loadFile(path string) (*document.Document, error) {
 	info, err := os.Stat(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if info.Size() == 0 {
-		return ErrEmptyFile
+		return nil, ErrEmptyFile
 	}
 	if info.Size() > maxFileSize {
-		return fmt.Errorf("%w: %d bytes", ErrFileTooLarge, info.Size())
+		return nil, fmt.Errorf("%w: %d bytes", ErrFileTooLarge, info.Size())
 	}
 
 	absPath, err := filepath.Abs(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	fileURL := files.PathToFileURL(absPath)
+	modTime := info.ModTime().Unix()
 
-	// Skip if already indexed with the same modification time
 	existing := GetByURLAndUser(fileURL, 0)
-	if existing != nil && existing.Added == info.ModTime().Unix() {
-		return nil
+	if existing != nil && existing.Added == modTime {
+		return nil, nil
 	}
-
-	content, err := os.ReadFile(path)
+	content, err := os.ReadFile(absPath)
 	if err != nil {
-		return &document.ReadFileError{
-			Msg: err.Error(),
-		}
+		return nil, err
 	}
-	if !utf8.Valid(content) {
-		return ErrBinaryFile
+	return processFileContent(fileURL, content, modTime, existing)
+}
+
+// processFileContent processes the content of the local file at fileURL,
+// modified at modTime. It returns a nil document when the hash of the
+// content matches the indexed document existing.
+func processFileContent(fileURL string, content []byte, modTime int64, existing *document.Document) (*document.Document, error) {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	if existing != nil && existing.Metadata[MetaContentHash] == hash {
+		// Only the modification time changed, it is recorded so the file
+		// is not read again on the next run.
+		if err := setAdded(existing.ID(), modTime); err != nil {
+			log.Warn().Err(err).Str("URL", fileURL).Msg("Failed to update modification time")
+		}
+		return nil, nil
 	}
 
-	doc := &document.Document{
+	// Binary files like PDF documents are left to the extractors.
+	d := &document.Document{
 		URL:   fileURL,
-		Text:  string(content),
-		Added: info.ModTime().Unix(),
+		Added: modTime,
+	}
+	d.SetFileContent(content)
+	if err := d.Process(i.langDetector, extractor.Extract); err != nil {
+		if errors.Is(err, document.ErrBinaryContent) {
+			return nil, fmt.Errorf("%w: %w", ErrBinaryFile, err)
+		}
+		return nil, err
 	}
+	if d.Metadata == nil {
+		d.Metadata = make(map[string]any)
+	}
+	d.Metadata[MetaContentHash] = hash
+	return d, nil
+}
+
+// RemoveFile deletes the documents of the local file or directory at path,
+// e.g. after it has been removed or renamed. Returns the number of deleted
+// documents.
+func RemoveFile(path string) (int, error) {
+	q, under, err := pathQuery(path)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := localDocuments(q, under)
+	if err != nil {
+		return 0, err
+	}
+	return deleteDocuments(ids)
+}
+
+// pathQuery returns the query of the documents of the local file or
+// directory at path, and a function telling whether a URL is under path.
+func pathQuery(path string) (query.Query, func(u string) bool, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileURL := files.PathToFileURL(absPath)
+	dirURL := strings.TrimSuffix(fileURL, "/") + "/"
+	prefix := bleve.NewPrefixQuery(strings.ToLower(dirURL))
+	prefix.SetField("url")
+	exact := bleve.NewTermQuery(strings.ToLower(fileURL))
+	exact.SetField("url")
+	membersURL := fileURL + files.ArchiveSeparator
+	members := bleve.NewPrefixQuery(strings.ToLower(membersURL))
+	members.SetField("url")
+	return bleve.NewDisjunctionQuery(exact, prefix, members), func(u string) bool {
+		// The url field is lowercased, paths differing in case only are
+		// told apart here.
+		return u == fileURL || strings.HasPrefix(u, dirURL) || strings.HasPrefix(u, membersURL)
+	}, nil
+}
+
+// Reconcile deletes the documents of local files which no longer exist, are
+// no longer in one of dirs or are excluded or ignored by now, along with the
+// members of such archives. It catches the
+// changes the file watcher missed, e.g. while the server was not running.
+// Returns the number of deleted documents.
+func Reconcile(dirs []*config.Directory) (int, error) {
+	return reconcile(dirs, query.NewMatchAllQuery(), func(string) bool { return true })
+}
+
+// ReconcileDirectory is Reconcile limited to the files under the directory
+// at path, e.g. after the ignore rules of the directory changed.
+func ReconcileDirectory(dirs []*config.Directory, path string) (int, error) {
+	q, under, err := pathQuery(path)
+	if err != nil {
+		return 0, err
+	}
+	return reconcile(dirs, q, under)
+}
+
+// reconcile deletes the documents of the local files matching q and
+// accepted by under which Reconcile would delete.
+func reconcile(dirs []*config.Directory, q query.Query, under func(u string) bool) (int, error) {
+	ignorers := make(map[*config.Directory]*files.Ignorer, len(dirs))
+	for _, dir := range dirs {
+		ignorers[dir] = files.NewIgnorer(files.ExpandHome(dir.Path))
+	}
+	ids, err := localDocuments(q, func(u string) bool {
+		if !under(u) {
+			return false
+		}
+		// Archive members are checked by their archive.
+		if archiveURL, _, ok := files.SplitArchivePath(u); ok {
+			u = archiveURL
+		}
+		path := files.FileURLToPath(u)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return true
+		}
+		dir := files.FindMatchingDir(dirs, path)
+		if dir == nil {
+			return true
+		}
+		// Excluded and hidden directories are not walked.
+		root := filepath.Clean(files.ExpandHome(dir.Path))
+		for p := filepath.Dir(path); p != root && files.HasPathPrefix(p, root); p = filepath.Dir(p) {
+			if files.ShouldSkipDir(filepath.Base(p), dir.Excludes, dir.IncludeHidden) {
+				return true
+			}
+		}
+		return !dir.IsMatching(path) || ignorers[dir].Ignored(path, false)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleteDocuments(ids)
+}
+
+// RunReconcile reconciles the local files of dirs with the index right away
+// and then once per every, until ctx is cancelled. A zero every reconciles
+// them once.
+func RunReconcile(ctx context.Context, dirs []*config.Directory, every time.Duration) error {
+	return runEvery(ctx, every, func() {
+		if n, err := Reconcile(dirs); err != nil {
+			log.Error().Err(err).Msg("Failed to reconcile local files")
+		} else if n > 0 {
+			log.Info().Int("deleted", n).Msg("Removed missing local files from the index")
+		}
+	})
+}
+
+// localDocuments returns the IDs of the local file documents matching q
+// whose URL is accepted by keep.
+func localDocuments(q query.Query, keep func(u string) bool) ([]string, error) {
+	from, to := float64(types.Local), float64(types.Local+1)
+	typeQ := bleve.NewNumericRangeQuery(&from, &to)
+	typeQ.SetField("type")
+	var ids []string
+	const pageSize = 500
+	var searchAfter []string
+	for {
+		req := bleve.NewSearchRequest(bleve.NewConjunctionQuery(q, typeQ))
+		req.Fields = []string{"url"}
+		req.Size = pageSize
+		req.SortBy([]string{"_id"})
+		if len(searchAfter) > 0 {
+			req.SetSearchAfter(searchAfter)
+		}
+		res, err := i.idx.Search(req)
+		if err != nil {
+			return ids, err
+		}
+		n := len(res.Hits)
+		if n == 0 {
+			return ids, nil
+		}
+		for _, h := range res.Hits {
+			if u, ok := h.Fields["url"].(string); ok && keep(u) {
+				ids = append(ids, h.ID)
+			}
+		}
+		searchAfter = res.Hits[n-1].Sort
+	}
+}
 
-	return i.AddDocument(doc)
+// deleteDocuments deletes the documents ids along with their vectors and
+// versions.
+func deleteDocuments(ids []string) (int, error) {
+	for n, id := range ids {
+		if err := Delete(id); err != nil {
+			return n, err
+		}
+	}
+	return len(ids), nil
 }
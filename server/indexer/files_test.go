@@ -0,0 +1,396 @@
+package indexer
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/files"
+	"github.com/asciimoo/hister/server/document"
+)
+
+// writeTestFile writes content to the file name of dir, modified at mtime.
+func writeTestFile(t *testing.T, dir, name, content string, mtime time.Time) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touchTestFile(t, p, mtime)
+	return p
+}
+
+// touchTestFile sets the modification time of the file at p.
+func touchTestFile(t *testing.T, p string, mtime time.Time) {
+	t.Helper()
+	if err := os.Chtimes(p, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getTestFile returns the indexed document of the file at p.
+func getTestFile(t *testing.T, p string) *document.Document {
+	t.Helper()
+	d := GetByURLAndUser(files.PathToFileURL(p), 0)
+	if d == nil {
+		t.Fatalf("%s is not indexed", p)
+	}
+	return d
+}
+
+// markTestFile sets a metadata key on the indexed document of the file at
+// p, which is lost when the file is processed again.
+func markTestFile(t *testing.T, p string) {
+	t.Helper()
+	if err := SetMetadata(getTestFile(t, p).ID(), map[string]any{"marker": "x"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func isMarked(d *document.Document) bool {
+	return d.Metadata["marker"] == "x"
+}
+
+// indexTestDirs runs IndexAll and returns its final progress.
+func indexTestDirs(t *testing.T, dirs ...*config.Directory) FileProgress {
+	t.Helper()
+	IndexAll(dirs)
+	p := IndexProgress()
+	if p.Running || p.Finished.IsZero() {
+		t.Fatalf("expected a finished run, got %+v", p)
+	}
+	return p
+}
+
+func checkProgress(t *testing.T, p FileProgress, found, indexed, unchanged, skipped int64) {
+	t.Helper()
+	if p.Found != found || p.Indexed != indexed || p.Unchanged != unchanged || p.Skipped != skipped {
+		t.Fatalf("expected found/indexed/unchanged/skipped %d/%d/%d/%d, got %d/%d/%d/%d",
+			found, indexed, unchanged, skipped, p.Found, p.Indexed, p.Unchanged, p.Skipped)
+	}
+}
+
+func TestIndexAll(t *testing.T) {
+	newTestIndexer(t)
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	unchanged := writeTestFile(t, dir, "unchanged.txt", "left alone", mtime)
+	touched := writeTestFile(t, dir, "sub/touched.txt", "same content", mtime)
+	changed := writeTestFile(t, dir, "changed.txt", "old content", mtime)
+	writeTestFile(t, dir, "empty.txt", "", mtime)
+	writeTestFile(t, dir, "skipped.bin", "not matching", mtime)
+	dirs := []*config.Directory{{Path: dir, Filetypes: []string{"txt"}}}
+
+	checkProgress(t, indexTestDirs(t, dirs...), 4, 3, 0, 1)
+	for _, p := range []string{unchanged, touched, changed} {
+		d := getTestFile(t, p)
+		if d.Added != mtime.Unix() {
+			t.Fatalf("expected %s to be added at its modification time %d, got %d", p, mtime.Unix(), d.Added)
+		}
+		if d.Metadata[MetaContentHash] == nil {
+			t.Fatalf("expected the content hash of %s to be stored", p)
+		}
+		markTestFile(t, p)
+	}
+
+	newMtime := mtime.Add(time.Minute)
+	touchTestFile(t, touched, newMtime)
+	writeTestFile(t, dir, "changed.txt", "new content", newMtime)
+	checkProgress(t, indexTestDirs(t, dirs...), 4, 1, 2, 1)
+
+	d := getTestFile(t, unchanged)
+	if !isMarked(d) || d.Added != mtime.Unix() {
+		t.Fatalf("expected the unchanged file not to be processed again")
+	}
+	d = getTestFile(t, touched)
+	if !isMarked(d) {
+		t.Fatalf("expected the touched file not to be processed again")
+	}
+	if d.Added != newMtime.Unix() {
+		t.Fatalf("expected the modification time of the touched file to be updated to %d, got %d", newMtime.Unix(), d.Added)
+	}
+	d = getTestFile(t, changed)
+	if isMarked(d) || d.Text != "new content" || d.Added != newMtime.Unix() {
+		t.Fatalf("expected the changed file to be indexed again, got %q added at %d", d.Text, d.Added)
+	}
+
+	// the touched file is skipped by its modification time from now on
+	checkProgress(t, indexTestDirs(t, dirs...), 4, 0, 3, 1)
+}
+
+func TestIndexAllArchive(t *testing.T) {
+	newTestIndexer(t)
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTestFile(t, dir, "plain.txt", "a plain file", mtime)
+	archive := filepath.Join(dir, "notes.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("member " + name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	touchTestFile(t, archive, mtime)
+	dirs := []*config.Directory{{Path: dir, Filetypes: []string{"txt", "zip"}}}
+
+	// the archive counts as a single file, whatever its number of members
+	checkProgress(t, indexTestDirs(t, dirs...), 2, 2, 0, 0)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		getTestFile(t, files.ArchiveMemberPath(archive, name))
+	}
+	checkProgress(t, indexTestDirs(t, dirs...), 2, 0, 2, 0)
+}
+
+func TestLoadFile(t *testing.T) {
+	newTestIndexer(t)
+	dir := t.TempDir()
+	mtime := time.Now().Truncate(time.Second)
+
+	if _, err := loadFile(writeTestFile(t, dir, "empty.txt", "", mtime)); !errors.Is(err, ErrEmptyFile) {
+		t.Fatalf("expected ErrEmptyFile, got %v", err)
+	}
+
+	prev := maxFileSize
+	maxFileSize = 4
+	_, err := loadFile(writeTestFile(t, dir, "large.txt", "too large", mtime))
+	maxFileSize = prev
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("expected ErrFileTooLarge, got %v", err)
+	}
+
+	p := writeTestFile(t, dir, "notes.txt", "some notes", mtime)
+	d, err := loadFile(p)
+	if err != nil || d == nil {
+		t.Fatalf("expected a document, got %v, %v", d, err)
+	}
+	if d.URL != files.PathToFileURL(p) || d.Text != "some notes" || d.Added != mtime.Unix() {
+		t.Fatalf("unexpected document %q %q %d", d.URL, d.Text, d.Added)
+	}
+	addTestDocs(t, d)
+	if d, err := loadFile(p); d != nil || err != nil {
+		t.Fatalf("expected an unchanged file to be skipped, got %v, %v", d, err)
+	}
+}
+
+func TestProcessFileContent(t *testing.T) {
+	newTestIndexer(t)
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	p := writeTestFile(t, dir, "notes.txt", "some notes", mtime)
+	fileURL := files.PathToFileURL(p)
+
+	d, err := processFileContent(fileURL, []byte("some notes"), mtime.Unix(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestDocs(t, d)
+	existing := getTestFile(t, p)
+
+	// same content, only the modification time is updated
+	d, err = processFileContent(fileURL, []byte("some notes"), mtime.Unix()+60, existing)
+	if d != nil || err != nil {
+		t.Fatalf("expected no document for unchanged content, got %v, %v", d, err)
+	}
+	if added := getTestFile(t, p).Added; added != mtime.Unix()+60 {
+		t.Fatalf("expected the modification time to be updated, got %d", added)
+	}
+
+	d, err = processFileContent(fileURL, []byte("other notes"), mtime.Unix()+120, existing)
+	if err != nil || d == nil || d.Text != "other notes" {
+		t.Fatalf("expected a document of the changed content, got %v, %v", d, err)
+	}
+
+	if _, err := processFileContent(fileURL, []byte{0, 1, 2, 0xff, 0xfe}, mtime.Unix(), nil); !errors.Is(err, ErrBinaryFile) {
+		t.Fatalf("expected ErrBinaryFile, got %v", err)
+	}
+}
+
+func TestSetAdded(t *testing.T) {
+	newTestIndexer(t)
+	addTestDocs(t, &document.Document{URL: "https://example.com/", Title: "Example", Text: "example page"})
+	d := GetByURLAndUser("https://example.com/", 0)
+	if err := setAdded(d.ID(), 1234); err != nil {
+		t.Fatal(err)
+	}
+	got := GetByURLAndUser("https://example.com/", 0)
+	if got.Added != 1234 || got.Title != "Example" || got.Text != "example page" {
+		t.Fatalf("expected only the added time to change, got %q %q %d", got.Title, got.Text, got.Added)
+	}
+	if DocumentCount() != 1 {
+		t.Fatalf("expected 1 document, got %d", DocumentCount())
+	}
+	if err := setAdded("missing", 1); err != nil {
+		t.Fatalf("expected no error for missing documents, got %v", err)
+	}
+}
+
+// isTestFileIndexed reports whether the file at p is indexed.
+func isTestFileIndexed(p string) bool {
+	return GetByURLAndUser(files.PathToFileURL(p), 0) != nil
+}
+
+// indexTestFiles indexes the files at paths.
+func indexTestFiles(t *testing.T, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		if err := IndexFile(p); err != nil {
+			t.Fatalf("failed to index %s: %v", p, err)
+		}
+	}
+}
+
+func checkIndexedFiles(t *testing.T, indexed, removed []string) {
+	t.Helper()
+	for _, p := range indexed {
+		if !isTestFileIndexed(p) {
+			t.Errorf("expected %s to be indexed", p)
+		}
+	}
+	for _, p := range removed {
+		if isTestFileIndexed(p) {
+			t.Errorf("expected %s to be removed", p)
+		}
+	}
+}
+
+func TestRemoveFile(t *testing.T) {
+	newTestIndexer(t)
+	dir := t.TempDir()
+	mtime := time.Now().Truncate(time.Second)
+	file := writeTestFile(t, dir, "a.txt", "a", mtime)
+	upper := writeTestFile(t, dir, "Notes.txt", "upper", mtime)
+	lower := writeTestFile(t, dir, "notes.txt", "lower", mtime)
+	sub := writeTestFile(t, dir, "sub/b.txt", "b", mtime)
+	deep := writeTestFile(t, dir, "sub/deep/c.txt", "c", mtime)
+	sibling := writeTestFile(t, dir, "subway.txt", "sibling", mtime)
+	indexTestFiles(t, file, upper, lower, sub, deep, sibling)
+
+	if n, err := RemoveFile(file); err != nil || n != 1 {
+		t.Fatalf("expected 1 removed document, got %d, %v", n, err)
+	}
+	checkIndexedFiles(t, []string{upper, lower, sub, deep, sibling}, []string{file})
+
+	// paths differing in case only are told apart
+	if n, err := RemoveFile(lower); err != nil || n != 1 {
+		t.Fatalf("expected 1 removed document, got %d, %v", n, err)
+	}
+	checkIndexedFiles(t, []string{upper}, []string{lower})
+
+	// removing a directory removes the files under it only
+	if n, err := RemoveFile(filepath.Join(dir, "sub")); err != nil || n != 2 {
+		t.Fatalf("expected 2 removed documents, got %d, %v", n, err)
+	}
+	checkIndexedFiles(t, []string{upper, sibling}, []string{sub, deep})
+
+	if n, err := RemoveFile(filepath.Join(dir, "missing.txt")); err != nil || n != 0 {
+		t.Fatalf("expected no removed documents, got %d, %v", n, err)
+	}
+}
+
+func TestRemoveFileRename(t *testing.T) {
+	newTestIndexer(t)
+	dir := t.TempDir()
+	oldPath := writeTestFile(t, dir, "old.txt", "renamed content", time.Now().Truncate(time.Second))
+	indexTestFiles(t, oldPath)
+
+	// the file watcher reports a rename as the removal of the old path and
+	// the creation of the new one
+	newPath := filepath.Join(dir, "new.txt")
+	if err := os.Rename(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RemoveFile(oldPath); err != nil {
+		t.Fatal(err)
+	}
+	indexTestFiles(t, newPath)
+	checkIndexedFiles(t, []string{newPath}, []string{oldPath})
+	if d := getTestFile(t, newPath); d.Text != "renamed content" {
+		t.Fatalf("unexpected text of the renamed file %q", d.Text)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	newTestIndexer(t)
+	root := t.TempDir()
+	other := t.TempDir()
+	mtime := time.Now().Truncate(time.Second)
+	kept := writeTestFile(t, root, "kept.txt", "kept", mtime)
+	deleted := writeTestFile(t, root, "deleted.txt", "deleted", mtime)
+	excluded := writeTestFile(t, root, "build/out.txt", "excluded", mtime)
+	ignored := writeTestFile(t, root, "ignored.txt", "ignored", mtime)
+	unmatched := writeTestFile(t, root, "notes.md", "unmatched", mtime)
+	hidden := writeTestFile(t, root, ".cache/hidden.txt", "hidden", mtime)
+	outside := writeTestFile(t, other, "outside.txt", "outside", mtime)
+	indexTestFiles(t, kept, deleted, excluded, ignored, unmatched, hidden, outside)
+	// web pages are left alone
+	addTestDocs(t, &document.Document{URL: "https://example.com/", Title: "Example", Text: "example"})
+
+	if err := os.Remove(deleted); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, root, ".histerignore", "ignored.txt\n", mtime)
+	dirs := []*config.Directory{{Path: root, Filetypes: []string{"txt"}, Excludes: []string{"build"}}}
+
+	n, err := Reconcile(dirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 {
+		t.Fatalf("expected 6 removed documents, got %d", n)
+	}
+	checkIndexedFiles(t, []string{kept}, []string{deleted, excluded, ignored, unmatched, hidden, outside})
+	if GetByURLAndUser("https://example.com/", 0) == nil {
+		t.Fatal("expected web pages to be kept")
+	}
+
+	if n, err := Reconcile(dirs); err != nil || n != 0 {
+		t.Fatalf("expected nothing to remove, got %d, %v", n, err)
+	}
+}
+
+func TestReconcileDirectory(t *testing.T) {
+	newTestIndexer(t)
+	root := t.TempDir()
+	mtime := time.Now().Truncate(time.Second)
+	ignored := writeTestFile(t, root, "sub/ignored.txt", "ignored", mtime)
+	kept := writeTestFile(t, root, "sub/kept.txt", "kept", mtime)
+	deleted := writeTestFile(t, root, "deleted.txt", "deleted", mtime)
+	indexTestFiles(t, ignored, kept, deleted)
+
+	if err := os.Remove(deleted); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, root, "sub/.histerignore", "ignored.txt\n", mtime)
+	dirs := []*config.Directory{{Path: root, Filetypes: []string{"txt"}}}
+
+	// the files outside of the directory are left to Reconcile
+	n, err := ReconcileDirectory(dirs, filepath.Join(root, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 removed document, got %d", n)
+	}
+	checkIndexedFiles(t, []string{kept, deleted}, []string{ignored})
+}
@@ -0,0 +1,177 @@
+package indexer
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/files"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/extractor/extractors/git"
+	"github.com/asciimoo/hister/server/gitrepo"
+)
+
+var (
+	repositoryHeadsMu sync.Mutex
+	// repositoryHeads records the heads of the repositories read by
+	// IndexRepositories, so they are not read again until they change.
+	repositoryHeads = make(map[string]string)
+)
+
+// IndexRepositories indexes the commits of repos which are not indexed yet
+// and the READMEs of their HEAD which changed since they were indexed.
+// Returns the number of indexed documents.
+func IndexRepositories(repos []*config.Repository) (int, error) {
+	indexed, unchanged, skipped := 0, 0, 0
+	read := make(map[string]string)
+	b := NewMultiBatch()
+	pending := 0
+	add := func(d *document.Document) error {
+		if err := d.Process(i.langDetector, nil); err != nil {
+			log.Debug().Err(err).Str("URL", d.URL).Msg("Skipping repository document")
+			skipped++
+			return nil
+		}
+		if err := b.Add(d); err != nil {
+			log.Debug().Err(err).Str("URL", d.URL).Msg("Skipping repository document")
+			skipped++
+			return nil
+		}
+		indexed++
+		if pending++; pending == fileBatchSize {
+			if err := b.Save(); err != nil {
+				return err
+			}
+			b = NewMultiBatch()
+			pending = 0
+		}
+		return nil
+	}
+	for _, repo := range repos {
+		root, err := filepath.Abs(files.ExpandHome(repo.Path))
+		if err != nil {
+			log.Warn().Err(err).Str("repository", repo.Path).Msg("Invalid repository path")
+			continue
+		}
+		paths, err := gitrepo.Find(root)
+		if err != nil {
+			log.Warn().Err(err).Str("repository", root).Msg("Failed to find repositories")
+			continue
+		}
+		for _, path := range paths {
+			r, err := gitrepo.Open(path)
+			if err != nil {
+				log.Warn().Err(err).Str("repository", path).Msg("Failed to open repository")
+				continue
+			}
+			heads, err := r.Heads()
+			if err != nil {
+				log.Warn().Err(err).Str("repository", path).Msg("Failed to read repository")
+				continue
+			}
+			key := strings.Join(heads, " ")
+			repositoryHeadsMu.Lock()
+			same := repositoryHeads[path] == key
+			repositoryHeadsMu.Unlock()
+			if same {
+				continue
+			}
+			read[path] = key
+			known := func(hash string) bool {
+				return GetByURLAndUser(gitrepo.CommitURL(path, hash), 0) != nil
+			}
+			for c, err := range r.Commits(known) {
+				if err != nil {
+					log.Warn().Err(err).Str("repository", path).Msg("Failed to read commit")
+					skipped++
+					continue
+				}
+				if err := add(commitDocument(path, c)); err != nil {
+					return indexed, err
+				}
+			}
+			readmes, err := r.Readmes()
+			if err != nil {
+				log.Warn().Err(err).Str("repository", path).Msg("Failed to read README")
+				continue
+			}
+			for _, f := range readmes {
+				u := gitrepo.FileURL(path, f.Name)
+				if old := GetByURLAndUser(u, 0); old != nil && old.Metadata[git.MetaHash] == f.Hash {
+					unchanged++
+					continue
+				}
+				d := &document.Document{
+					URL:   u,
+					Title: filepath.Base(path) + "/" + f.Name,
+					Metadata: map[string]any{
+						"type":             "README",
+						git.MetaHash:       f.Hash,
+						git.MetaRepository: path,
+					},
+				}
+				d.SetFileContent(f.Content)
+				if err := add(d); err != nil {
+					return indexed, err
+				}
+			}
+		}
+	}
+	if err := b.Save(); err != nil {
+		return indexed, err
+	}
+	repositoryHeadsMu.Lock()
+	for path, key := range read {
+		repositoryHeads[path] = key
+	}
+	repositoryHeadsMu.Unlock()
+	e := log.Debug()
+	if indexed > 0 {
+		e = log.Info()
+	}
+	e.Int("indexed", indexed).Int("unchanged", unchanged).Int("skipped", skipped).Msg("Repositories indexed")
+	return indexed, nil
+}
+
+// commitDocument returns the document of the commit c of the repository at
+// path.
+func commitDocument(path string, c *gitrepo.Commit) *document.Document {
+	d := &document.Document{
+		URL:         gitrepo.CommitURL(path, c.Hash),
+		Title:       c.Subject(),
+		Text:        strings.TrimSpace(c.Message),
+		ContentType: gitrepo.ContentType,
+		Added:       c.Date.Unix(),
+		Metadata: map[string]any{
+			"type":             "Commit",
+			git.MetaAuthor:     c.Author,
+			git.MetaDate:       c.Date.UTC().Format(time.RFC3339),
+			git.MetaHash:       c.Hash,
+			git.MetaRepository: path,
+		},
+	}
+	if d.Title == "" {
+		d.Title = c.Hash[:12]
+		d.Text = c.Hash
+	}
+	if len(c.Paths) > 0 {
+		d.Metadata[git.MetaPaths] = c.Paths
+	}
+	return d
+}
+
+// RunRepositories indexes the new commits of repos right away and then once
+// per every, until ctx is cancelled. A zero every reads the repositories
+// once.
+func RunRepositories(ctx context.Context, repos []*config.Repository, every time.Duration) error {
+	return runEvery(ctx, every, func() {
+		if _, err := IndexRepositories(repos); err != nil {
+			log.Error().Err(err).Msg("Failed to index repositories")
+		}
+	})
+}
@@ -0,0 +1,292 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package indexer
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/asciimoo/hister/server/document"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// hybridCandidateLimit caps the number of keyword hits considered for
+	// fusion. Keyword matches ranked below it are not reachable in hybrid
+	// mode.
+	hybridCandidateLimit = 1000
+	// hybridPageKeyPrefix marks page keys holding an offset into the fused
+	// result list instead of Bleve sort values.
+	hybridPageKeyPrefix = "hybrid:"
+)
+
+type fusedHit struct {
+	id         string
+	keyword    float64
+	semantic   float64
+	chunk      string
+	inKeyword  bool
+	inSemantic bool
+	score      float64
+}
+
+func (fh *fusedHit) source() string {
+	switch {
+	case fh.inKeyword && fh.inSemantic:
+		return "both"
+	case fh.inKeyword:
+		return "keyword"
+	default:
+		return "semantic"
+	}
+}
+
+// semanticWeight returns the weight of the vector similarity in the fused
+// score, falling back to the configured default when unset.
+func (q *Query) semanticWeight() float64 {
+	var w float64
+	if q.SemanticWeight != nil {
+		w = *q.SemanticWeight
+	} else if q.cfg != nil {
+		w = q.cfg.SemanticSearch.SemanticWeight
+	}
+	return min(max(w, 0), 1)
+}
+
+func (q *Query) hybridOffset() int {
+	s, ok := strings.CutPrefix(q.PageKey, hybridPageKeyPrefix)
+	if !ok {
+		return 0
+	}
+	o, err := strconv.Atoi(s)
+	if err != nil || o < 0 {
+		return 0
+	}
+	return o
+}
+
+// hybridSearch ranks the union of keyword and semantic matches by a weighted
+// sum of the max-normalized Bleve score and the vector similarity.
+// Pagination walks the fused list using offset based page keys.
+func (i *indexer) hybridSearch(q *Query, req *bleve.SearchRequest, idx bleve.Index) (*Results, error) {
+	creq := bleve.NewSearchRequest(req.Query)
+	creq.Size = hybridCandidateLimit
+	creq.SortBy([]string{"-_score", "_id"})
+	creq.Facets = req.Facets
+	creq.Explain = req.Explain
+	res, err := idx.Search(creq)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make(map[string]*fusedHit, len(res.Hits))
+	maxScore := res.MaxScore
+	for _, h := range res.Hits {
+		fh := &fusedHit{id: h.ID, inKeyword: true}
+		if maxScore > 0 {
+			fh.keyword = h.Score / maxScore
+		}
+		hits[h.ID] = fh
+	}
+
+	// semantic matches are not restricted by the query, so the ones missed
+	// by the keyword search are checked against its filters
+	fq, err := q.filter()
+	if err != nil {
+		return nil, err
+	}
+	semantic := i.semanticMatches(q)
+	var ids []string
+	for _, sh := range semantic {
+		if _, ok := hits[sh.id]; !ok {
+			ids = append(ids, sh.id)
+		}
+	}
+	matching, err := filterDocIDs(idx, fq, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	semanticOnly := 0
+	for _, sh := range semantic {
+		fh, ok := hits[sh.id]
+		if !ok {
+			if !matching[sh.id] {
+				continue
+			}
+			fh = &fusedHit{id: sh.id}
+			hits[sh.id] = fh
+			semanticOnly++
+		}
+		fh.semantic = sh.semantic
+		fh.chunk = sh.chunk
+		fh.inSemantic = true
+	}
+
+	w := q.semanticWeight()
+	fused := make([]*fusedHit, 0, len(hits))
+	for _, fh := range hits {
+		fh.score = (1-w)*fh.keyword + w*fh.semantic
+		fused = append(fused, fh)
+	}
+	sort.Slice(fused, func(a, b int) bool {
+		if fused[a].score != fused[b].score {
+			return fused[a].score > fused[b].score
+		}
+		return fused[a].id < fused[b].id
+	})
+
+	offset := min(q.hybridOffset(), len(fused))
+	end := min(offset+req.Size, len(fused))
+	page := fused[offset:end]
+
+	docs, err := loadFusedDocuments(q, req, idx, fq, page)
+	if err != nil {
+		return nil, err
+	}
+	r := &Results{
+		Total:           res.Total + uint64(semanticOnly),
+		Query:           q,
+		Documents:       docs,
+		SemanticEnabled: true,
+	}
+	if q.Explain {
+		r.Explanations = make(map[string]*Explanation, len(page))
+		for _, h := range res.Hits {
+			if fh, ok := hits[h.ID]; ok && slices.Contains(page, fh) {
+				r.Explanations[h.ID] = newExplanation(h.Expl)
+			}
+		}
+	}
+	if q.Facets && len(res.Facets) > 0 {
+		r.Facets = extractFacets(res.Facets)
+	}
+	if end < len(fused) {
+		r.PageKey = hybridPageKeyPrefix + strconv.Itoa(end)
+		q.PageKey = r.PageKey
+	}
+	return r, nil
+}
+
+// semanticMatches returns the vector store matches of q aggregated by
+// document, keeping the best similarity and its chunk.
+func (i *indexer) semanticMatches(q *Query) []*fusedHit {
+	vec, err := i.embedder.EmbedQuery(q.Text)
+	if err != nil {
+		log.Warn().Err(err).Msg("semantic query embedding failed")
+		return nil
+	}
+	threshold := q.SemanticThreshold
+	if threshold <= 0 {
+		threshold = q.cfg.SemanticSearch.SimilarityThreshold
+	}
+	vsResults, err := i.vectorStore.Search(vec, q.cfg.SemanticSearch.ResultLimit, threshold, q.UserID)
+	if err != nil {
+		log.Warn().Err(err).Msg("vector store search failed")
+		return nil
+	}
+	byDoc := make(map[string]*fusedHit)
+	var r []*fusedHit
+	for _, vr := range vsResults {
+		if h, ok := byDoc[vr.DocID]; ok {
+			if vr.Similarity > h.semantic {
+				h.semantic = vr.Similarity
+				h.chunk = vr.ChunkText
+			}
+			continue
+		}
+		h := &fusedHit{id: vr.DocID, semantic: vr.Similarity, chunk: vr.ChunkText}
+		byDoc[vr.DocID] = h
+		r = append(r, h)
+	}
+	return r
+}
+
+// filterDocIDs returns the set of ids whose documents match fq.
+func filterDocIDs(idx bleve.Index, fq query.Query, ids []string) (map[string]bool, error) {
+	r := make(map[string]bool, len(ids))
+	if len(ids) == 0 {
+		return r, nil
+	}
+	req := bleve.NewSearchRequest(query.NewConjunctionQuery([]query.Query{fq, bleve.NewDocIDQuery(ids)}))
+	req.Size = len(ids)
+	res, err := idx.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range res.Hits {
+		r[h.ID] = true
+	}
+	return r, nil
+}
+
+// loadFusedDocuments fetches the documents of a fused result page. Keyword
+// matches are re-queried to keep their highlighted fragments, semantic only
+// matches are queried by the filter query fq.
+func loadFusedDocuments(q *Query, req *bleve.SearchRequest, idx bleve.Index, fq query.Query, page []*fusedHit) ([]*document.Document, error) {
+	var ids, semIDs []string
+	for _, fh := range page {
+		if fh.inKeyword {
+			ids = append(ids, fh.id)
+		} else {
+			semIDs = append(semIDs, fh.id)
+		}
+	}
+	byID := make(map[string]*document.Document, len(page))
+	if len(semIDs) > 0 {
+		sreq := bleve.NewSearchRequest(query.NewConjunctionQuery([]query.Query{fq, bleve.NewDocIDQuery(semIDs)}))
+		sreq.Size = len(semIDs)
+		sreq.Fields = allFields
+		res, err := idx.Search(sreq)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range res.Hits {
+			d := docFromHit(h)
+			d.Text = truncateText(d.Text, semanticTextPreviewLen)
+			if !q.IncludeHTML {
+				d.HTML = ""
+			}
+			byID[h.ID] = d
+		}
+	}
+	if len(ids) > 0 {
+		preq := bleve.NewSearchRequest(query.NewConjunctionQuery([]query.Query{req.Query, bleve.NewDocIDQuery(ids)}))
+		preq.Size = len(ids)
+		preq.Fields = req.Fields
+		preq.Highlight = req.Highlight
+		res, err := idx.Search(preq)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range res.Hits {
+			if q.IncludeHTML {
+				byID[h.ID] = docFromHit(h)
+			} else {
+				byID[h.ID] = resFromHit(h)
+			}
+		}
+	}
+	docs := make([]*document.Document, 0, len(page))
+	for _, fh := range page {
+		d := byID[fh.id]
+		if d == nil {
+			continue
+		}
+		d.Score = fh.score
+		d.Hybrid = &document.HybridScore{
+			Keyword:      fh.keyword,
+			Semantic:     fh.semantic,
+			Weight:       q.semanticWeight(),
+			Source:       fh.source(),
+			MatchedChunk: truncateText(fh.chunk, semanticTextPreviewLen),
+		}
+		docs = append(docs, d)
+	}
+	return docs, nil
+}
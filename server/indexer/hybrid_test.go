@@ -0,0 +1,130 @@
+package indexer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/vectorstore"
+)
+
+// fakeVectorStore returns the same results for every search.
+type fakeVectorStore struct {
+	results []vectorstore.Result
+}
+
+func (s *fakeVectorStore) Init() error                                       { return nil }
+func (s *fakeVectorStore) PutChunks(string, uint, []vectorstore.Chunk) error { return nil }
+func (s *fakeVectorStore) Delete(string) error                               { return nil }
+func (s *fakeVectorStore) Clear() error                                      { return nil }
+func (s *fakeVectorStore) Close() error                                      { return nil }
+func (s *fakeVectorStore) Search([]float32, int, float64, uint) ([]vectorstore.Result, error) {
+	return s.results, nil
+}
+
+// enableTestSemanticSearch turns on the semantic search of ti, returning
+// results as the vector store matches.
+func enableTestSemanticSearch(t *testing.T, ti *indexer, cfg *config.Config, results ...vectorstore.Result) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[{"embedding":[1,0]}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	cfg.SemanticSearch.EmbeddingEndpoint = srv.URL
+	cfg.SemanticSearch.Dimensions = 2
+	ti.embedder = vectorstore.NewEmbedder(&cfg.SemanticSearch)
+	ti.vectorStore = &fakeVectorStore{results: results}
+}
+
+func TestHybridSearchFiltersSemanticMatches(t *testing.T) {
+	ti := newTestIndexer(t)
+	addTestDocs(t,
+		&document.Document{URL: "https://go.dev/doc/modules", Title: "Go modules", Text: "managing dependencies"},
+		&document.Document{URL: "https://go.dev/doc/tutorial", Title: "Tutorial", Text: "getting started with packages"},
+		&document.Document{URL: "https://example.com/deps", Title: "Dependencies", Text: "package managers compared"},
+	)
+	cfg := config.CreateDefaultConfig()
+	enableTestSemanticSearch(t, ti, cfg,
+		vectorstore.Result{DocID: "https://go.dev/doc/tutorial", Similarity: 0.9, ChunkText: "getting started"},
+		vectorstore.Result{DocID: "https://example.com/deps", Similarity: 0.8, ChunkText: "package managers"},
+	)
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"modules", []string{"https://go.dev/doc/modules", "https://go.dev/doc/tutorial", "https://example.com/deps"}},
+		{"modules domain:go.dev", []string{"https://go.dev/doc/modules", "https://go.dev/doc/tutorial"}},
+		{"modules -tutorial", []string{"https://go.dev/doc/modules", "https://example.com/deps"}},
+		{"modules type:web -domain:go.dev", []string{"https://example.com/deps"}},
+		{"modules type:file", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			r, err := Search(cfg, &Query{Text: tc.query, SemanticEnabled: true, Limit: 10})
+			if err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+			got := resultURLs(r)
+			slices.Sort(got)
+			want := slices.Clone(tc.want)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+			if r.Total != uint64(len(want)) {
+				t.Fatalf("expected total %d, got %d", len(want), r.Total)
+			}
+		})
+	}
+}
+
+func TestSemanticWeight(t *testing.T) {
+	cfg := config.CreateDefaultConfig()
+	cases := []struct {
+		name   string
+		weight *float64
+		want   float64
+	}{
+		{"unset", nil, cfg.SemanticSearch.SemanticWeight},
+		{"keyword only", new(0.0), 0},
+		{"semantic only", new(1.0), 1},
+		{"custom", new(0.7), 0.7},
+		{"clamped", new(1.5), 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := &Query{SemanticWeight: tc.weight, cfg: cfg}
+			if got := q.semanticWeight(); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestHybridSearchKeywordOnlyWeight(t *testing.T) {
+	ti := newTestIndexer(t)
+	addTestDocs(t,
+		&document.Document{URL: "https://go.dev/doc/modules", Title: "Go modules", Text: "managing dependencies"},
+		&document.Document{URL: "https://example.com/deps", Title: "Dependencies", Text: "package managers compared"},
+	)
+	cfg := config.CreateDefaultConfig()
+	enableTestSemanticSearch(t, ti, cfg,
+		vectorstore.Result{DocID: "https://example.com/deps", Similarity: 1, ChunkText: "package managers"},
+	)
+	r, err := Search(cfg, &Query{Text: "modules", SemanticEnabled: true, SemanticWeight: new(0.0), Limit: 10})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	want := []string{"https://go.dev/doc/modules", "https://example.com/deps"}
+	if got := resultURLs(r); !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if h := r.Documents[1].Hybrid; h == nil || h.Weight != 0 || r.Documents[1].Score != 0 {
+		t.Fatalf("expected a zero score with weight 0, got %+v", r.Documents[1])
+	}
+}
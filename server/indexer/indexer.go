@@ -1,6 +1,7 @@
 package indexer
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -50,6 +51,9 @@ type indexer struct {
 	embedder          *vectorstore.Embedder
 	vectorStore       vectorstore.VectorStore
 	embedWg           sync.WaitGroup // tracks in-flight async embeddings
+	versions          bleve.Index    // superseded document snapshots, nil when disabled
+	versionsMu        sync.Mutex     // serializes the numbering of snapshots
+	maxVersions       int
 }
 
 const (
@@ -67,10 +71,12 @@ type Query struct {
 	UserID            uint    `json:"user_id"`
 	SemanticEnabled   bool    `json:"semantic_enabled"`
 	SemanticThreshold float64 `json:"semantic_threshold"`
-	SemanticWeight    float64 `json:"semantic_weight"`
-	PageKey           string  `json:"page_key"`
-	IncludeHTML       bool    `json:"include_html"`
-	Facets            bool    `json:"facets,omitempty"`
+	// SemanticWeight overrides the configured weight of the semantic score
+	// in hybrid search. 0 ranks by the keyword score only.
+	SemanticWeight *float64 `json:"semantic_weight,omitempty"`
+	PageKey        string   `json:"page_key"`
+	IncludeHTML    bool     `json:"include_html"`
+	Facets         bool     `json:"facets,omitempty"`
 	// FacetTermSize overrides the default top-N cap for term facets
 	// (domain, language). Zero uses the default. Useful for completion
 	// callers that want to post-filter a larger pool by prefix.
@@ -79,7 +85,13 @@ type Query struct {
 	// Combine with UserID / Facets / DateFrom / DateTo for cheap aggregate
 	// queries (e.g. completion sources). Text is ignored when set.
 	MatchAll bool `json:"match_all,omitempty"`
-	cfg      *config.Config
+	// AllVersions extends the search to stored snapshots of re-indexed
+	// documents. Requires indexer.keep_versions.
+	AllVersions bool `json:"all_versions,omitempty"`
+	// Explain adds the explanation of the keyword score of each result to
+	// Results.Explanations.
+	Explain bool `json:"explain,omitempty"`
+	cfg     *config.Config
 }
 
 const defaultFacetTermSize = 10
@@ -160,14 +172,6 @@ func extractFacets(facets search.FacetResults) *FacetsResult {
 	return fr
 }
 
-// SemanticHit represents a document found via vector similarity search.
-type SemanticHit struct {
-	DocID        string             `json:"doc_id"`
-	Similarity   float64            `json:"similarity"`
-	MatchedChunk string             `json:"matched_chunk,omitempty"`
-	Document     *document.Document `json:"document,omitempty"`
-}
-
 type Results struct {
 	Total           uint64               `json:"total"`
 	Query           *Query               `json:"query"`
@@ -176,9 +180,14 @@ type Results struct {
 	SearchDuration  string               `json:"search_duration"`
 	QuerySuggestion string               `json:"query_suggestion"`
 	PageKey         string               `json:"page_key"`
-	SemanticHits    []SemanticHit        `json:"semantic_hits,omitempty"`
 	SemanticEnabled bool                 `json:"semantic_enabled"`
 	Facets          *FacetsResult        `json:"facets,omitempty"`
+	// Explanations holds the explanations of the Documents requested by
+	// Query.Explain, keyed by document ID. Semantic only matches have none.
+	Explanations map[string]*Explanation `json:"explanations,omitempty"`
+	// Error explains why the query is invalid, for the websocket clients
+	// which get no status code.
+	Error string `json:"error,omitempty"`
 }
 
 type MultiBatch struct {
@@ -211,6 +220,12 @@ func Init(cfg *config.Config) error {
 	if cfg.Indexer.MaxFileSize > 0 {
 		maxFileSize = cfg.Indexer.MaxFileSize * 1024 * 1024 // bytes
 	}
+	if cfg.Indexer.MaxArchiveSize > 0 {
+		maxArchiveSize = cfg.Indexer.MaxArchiveSize * 1024 * 1024 // bytes
+	}
+	if cfg.Indexer.MaxArchiveMembers > 0 {
+		maxArchiveMembers = cfg.Indexer.MaxArchiveMembers
+	}
 	sp := make([]string, 0, len(cfg.SensitiveContentPatterns))
 	for _, v := range cfg.SensitiveContentPatterns {
 		sp = append(sp, v)
@@ -221,6 +236,13 @@ func Init(cfg *config.Config) error {
 	if err != nil {
 		return err
 	}
+	if cfg.Indexer.KeepVersions {
+		i.versions, err = openVersionIndex(cfg.FullPath(""))
+		if err != nil {
+			return err
+		}
+		i.maxVersions = cfg.Indexer.MaxVersions
+	}
 	if cfg.SemanticSearch.Enable {
 		vs, err := vectorstore.New(cfg)
 		if err != nil {
@@ -359,11 +381,15 @@ func Reindex(basePath string, rules *config.Rules, skipSensitiveChecks bool, det
 			if d.Type == types.Local {
 				pu, err := url.Parse(d.URL)
 				if err == nil {
-					if _, err := os.Stat(pu.Path); errors.Is(err, os.ErrNotExist) {
+					path := pu.Path
+					if archivePath, _, ok := files.SplitArchivePath(path); ok {
+						path = archivePath
+					}
+					if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
 						log.Warn().Str("URL", d.URL).Msg("Skipping document, file not found")
 						continue
 					}
-					if files.FindMatchingDir(dirs, pu.Path) == nil {
+					if files.FindMatchingDir(dirs, path) == nil {
 						log.Warn().Str("URL", d.URL).Msg("Skipping document, directory no longer configured")
 						continue
 					}
@@ -415,7 +441,9 @@ func Reindex(basePath string, rules *config.Rules, skipSensitiveChecks bool, det
 		latest = res.Hits[n-1].Fields["url"].(string)
 		log.Info().Msg(fmt.Sprintf("Reindexed [%d/%d]", page*batchSize, total))
 	}
+	versions, maxVersions := idx.versions, idx.maxVersions
 	idx.vectorStore = nil // prevent Close() from closing the store we're still using
+	idx.versions = nil
 	idx.Close()
 	tmpIdx.vectorStore = nil // already referenced by vs; prevent double-close
 	tmpIdx.Close()
@@ -445,6 +473,8 @@ func Reindex(basePath string, rules *config.Rules, skipSensitiveChecks bool, det
 		i.vectorStore = vs
 		i.embedder = embedder
 	}
+	i.versions = versions
+	i.maxVersions = maxVersions
 	return os.RemoveAll(tmpBasePath)
 }
 
@@ -533,7 +563,30 @@ func (i *indexer) AddDocument(d *document.Document) error {
 			embedDocumentChunks(i, d)
 		})
 	}
-	return i.getOrCreate(d.Language).Index(d.ID(), d)
+	i.snapshot(d)
+	idx := i.getOrCreate(d.Language)
+	i.dropOtherLanguageCopies(idx, d.ID())
+	return idx.Index(d.ID(), d)
+}
+
+// dropOtherLanguageCopies deletes the document id from the language indexes
+// other than idx, which happens when the detected language of a re-indexed
+// document changes.
+func (i *indexer) dropOtherLanguageCopies(idx bleve.Index, id string) {
+	if len(i.indexers) < 2 {
+		return
+	}
+	for name, other := range i.indexers {
+		if other == idx {
+			continue
+		}
+		if doc, err := other.Document(id); err != nil || doc == nil {
+			continue
+		}
+		if err := other.Delete(id); err != nil {
+			log.Warn().Err(err).Str("index", name).Str("id", id).Msg("failed to delete document from previous language index")
+		}
+	}
 }
 
 func GetLatestDocuments(limit int, latest string, userID uint) *Results {
@@ -621,6 +674,11 @@ func (i *indexer) Close() {
 			log.Warn().Err(err).Msg("failed to close vector store")
 		}
 	}
+	if i.versions != nil {
+		if err := i.versions.Close(); err != nil {
+			log.Warn().Err(err).Msg("failed to close versions index")
+		}
+	}
 	for name, idx := range i.indexers {
 		if err := idx.Close(); err != nil {
 			log.Warn().Err(err).Str("index", name).Msg("failed to close index")
@@ -631,6 +689,25 @@ func (i *indexer) Close() {
 	}
 }
 
+// runEvery calls fn right away and then once per every, until ctx is
+// cancelled. A zero every calls fn once.
+func runEvery(ctx context.Context, every time.Duration, fn func()) error {
+	fn()
+	if every <= 0 {
+		return nil
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+		fn()
+	}
+}
+
 func NewMultiBatch() *MultiBatch {
 	return newMultiBatch(i)
 }
@@ -658,7 +735,9 @@ func (b *MultiBatch) Add(d *document.Document) error {
 	if b.indexer.embedder != nil && b.indexer.vectorStore != nil {
 		embedDocumentChunks(b.indexer, d)
 	}
+	b.indexer.snapshot(d)
 	idx := b.indexer.getOrCreate(d.Language)
+	b.indexer.dropOtherLanguageCopies(idx, d.ID())
 	return b.getOrCreateBatch(idx.Name(), idx).Index(d.ID(), d)
 }
 
@@ -688,14 +767,17 @@ func Delete(id string) error {
 			return err
 		}
 	}
-	return nil
+	return i.deleteVersions(id)
 }
 
 func DeleteByQuery(text string, userID *uint, onDelete func(url string, userID uint)) (int, error) {
 	if strings.TrimSpace(text) == "" {
 		return 0, ErrEmptyFilter
 	}
-	q := querybuilder.Build(text)
+	q, err := querybuilder.Build(text)
+	if err != nil {
+		return 0, err
+	}
 	if userID != nil {
 		uid := float64(*userID)
 		userQ := bleve.NewNumericRangeInclusiveQuery(&uid, &uid, new(true), new(true))
@@ -736,6 +818,11 @@ func DeleteByQuery(text string, userID *uint, onDelete func(url string, userID u
 				}
 			}
 		}
+		for _, h := range res.Hits {
+			if err := i.deleteVersions(h.ID); err != nil {
+				log.Warn().Err(err).Str("id", h.ID).Msg("failed to delete document versions")
+			}
+		}
 		if onDelete != nil {
 			for _, h := range res.Hits {
 				url, _ := h.Fields["url"].(string)
@@ -756,8 +843,13 @@ func DeleteByQuery(text string, userID *uint, onDelete func(url string, userID u
 
 func Search(cfg *config.Config, q *Query) (*Results, error) {
 	q.cfg = cfg
-	req := bleve.NewSearchRequest(q.create())
+	sq, err := q.create()
+	if err != nil {
+		return nil, err
+	}
+	req := bleve.NewSearchRequest(sq)
 	req.Fields = allFields
+	req.Explain = q.Explain
 
 	if q.Limit > 0 {
 		req.Size = q.Limit
@@ -775,16 +867,17 @@ func Search(cfg *config.Config, q *Query) (*Results, error) {
 		req.Highlight = bleve.NewHighlightWithStyle("tui")
 	}
 
-	sortByScore := false
 	// TODO / question: should we store the length of the URL path and sort by it,
 	// prefering shorter path names for tied score?
-	switch q.Sort {
-	case "domain":
-		req.SortBy([]string{"domain", "_id"})
-	default:
-		sortByScore = true
-		req.SortBy([]string{"-_score", "_id"})
+	order, err := sortOrder(q.Sort)
+	if err != nil {
+		return nil, err
 	}
+	sortByScore := order == nil
+	if sortByScore {
+		order = []string{"-_score", "_id"}
+	}
+	req.SortBy(order)
 
 	if q.PageKey != "" {
 		var after []string
@@ -797,7 +890,18 @@ func Search(cfg *config.Config, q *Query) (*Results, error) {
 		addFacets(req, q.FacetTermSize)
 	}
 
-	res, err := i.idx.Search(req)
+	idx, err := i.searchIndex(q.AllVersions)
+	if err != nil {
+		return nil, err
+	}
+	if sortByScore && q.SemanticEnabled && SemanticSearchEnabled() && q.Text != "" {
+		r, err := i.hybridSearch(q, req, idx)
+		if err == nil {
+			r.Documents = groupThreads(r.Documents)
+		}
+		return r, err
+	}
+	res, err := idx.Search(req)
 	if err != nil {
 		return nil, err
 	}
@@ -809,11 +913,20 @@ func Search(cfg *config.Config, q *Query) (*Results, error) {
 			matches[j] = resFromHit(v)
 		}
 	}
+	if sortByScore {
+		matches = groupThreads(matches)
+	}
 	r := &Results{
 		Total:     res.Total,
 		Query:     q,
 		Documents: matches,
 	}
+	if q.Explain {
+		r.Explanations = make(map[string]*Explanation, len(res.Hits))
+		for _, h := range res.Hits {
+			r.Explanations[h.ID] = newExplanation(h.Expl)
+		}
+	}
 	if q.Facets && len(res.Facets) > 0 {
 		r.Facets = extractFacets(res.Facets)
 	}
@@ -834,71 +947,6 @@ func Search(cfg *config.Config, q *Query) (*Results, error) {
 		}
 	}
 
-	// Run semantic search if enabled and the embedding infrastructure is available.
-	if q.SemanticEnabled && i.embedder != nil && i.vectorStore != nil && q.Text != "" {
-		r.SemanticEnabled = true
-		vec, err := i.embedder.EmbedQuery(q.Text)
-		if err != nil {
-			log.Warn().Err(err).Msg("semantic query embedding failed")
-		} else {
-			threshold := q.SemanticThreshold
-			if threshold <= 0 {
-				threshold = cfg.SemanticSearch.SimilarityThreshold
-			}
-			resultLimit := cfg.SemanticSearch.ResultLimit
-			vsResults, err := i.vectorStore.Search(vec, resultLimit, threshold, q.UserID)
-			if err != nil {
-				log.Warn().Err(err).Msg("vector store search failed")
-			} else {
-				// Build a set of URLs already in keyword results to avoid duplicating docs.
-				keywordURLs := make(map[string]struct{}, len(matches))
-				for _, d := range matches {
-					keywordURLs[d.URL] = struct{}{}
-				}
-				// Aggregate chunk-level results by doc_id, keeping the best
-				// similarity and the text of the best-matching chunk.
-				type docHit struct {
-					similarity float64
-					chunkText  string
-				}
-				bestByDoc := make(map[string]*docHit)
-				// Preserve insertion order for stable output.
-				var docOrder []string
-				for _, vr := range vsResults {
-					if existing, ok := bestByDoc[vr.DocID]; ok {
-						if vr.Similarity > existing.similarity {
-							existing.similarity = vr.Similarity
-							existing.chunkText = vr.ChunkText
-						}
-					} else {
-						bestByDoc[vr.DocID] = &docHit{
-							similarity: vr.Similarity,
-							chunkText:  vr.ChunkText,
-						}
-						docOrder = append(docOrder, vr.DocID)
-					}
-				}
-				for _, docID := range docOrder {
-					dh := bestByDoc[docID]
-					hit := SemanticHit{
-						DocID:        docID,
-						Similarity:   dh.similarity,
-						MatchedChunk: truncateText(dh.chunkText, semanticTextPreviewLen),
-					}
-					// For semantic-only hits, populate the document with a truncated text preview.
-					d := GetByDocID(docID)
-					if d != nil {
-						if _, inKeyword := keywordURLs[d.URL]; !inKeyword {
-							d.Text = truncateText(d.Text, semanticTextPreviewLen)
-							hit.Document = d
-						}
-					}
-					r.SemanticHits = append(r.SemanticHits, hit)
-				}
-			}
-		}
-	}
-
 	return r, nil
 }
 
@@ -915,6 +963,10 @@ func GetByURLAndUser(u string, uid uint) *document.Document {
 // GetByDocID returns the document with the given bleve document ID, or nil if
 // none exists. The ID is the uid-prefixed form produced by document.GetDocID.
 func GetByDocID(id string) *document.Document {
+	return i.getByDocID(id)
+}
+
+func (i *indexer) getByDocID(id string) *document.Document {
 	q := bleve.NewDocIDQuery([]string{id})
 	req := bleve.NewSearchRequest(q)
 	req.Fields = allFields
@@ -950,6 +1002,109 @@ func Iterate(fn func(*document.Document)) {
 	}
 }
 
+// SetMetadata merges values into the metadata of the indexed document id
+// without processing it again. It is a no-op when the document does not
+// exist.
+func SetMetadata(id string, values map[string]any) error {
+	d := i.getByDocID(id)
+	if d == nil {
+		return nil
+	}
+	if d.Metadata == nil {
+		d.Metadata = make(map[string]any, len(values))
+	}
+	maps.Copy(d.Metadata, values)
+	d.Score = 0
+	for _, idx := range i.indexers {
+		if doc, err := idx.Document(id); err == nil && doc != nil {
+			return idx.Index(id, d)
+		}
+	}
+	return nil
+}
+
+// setAdded changes the indexing time of the indexed document id without
+// processing it again.
+func setAdded(id string, added int64) error {
+	d := i.getByDocID(id)
+	if d == nil {
+		return nil
+	}
+	d.Added = added
+	d.Score = 0
+	for _, idx := range i.indexers {
+		if doc, err := idx.Document(id); err == nil && doc != nil {
+			return idx.Index(id, d)
+		}
+	}
+	return nil
+}
+
+// IterateWeb calls fn for every indexed web page. Only the URL, favicon,
+// owner and indexing time of the documents are loaded, plus the stored
+// fields listed in extraFields (e.g. "metadata.link_checked").
+func IterateWeb(fn func(*document.Document), extraFields ...string) error {
+	t := float64(types.Web)
+	q := bleve.NewNumericRangeInclusiveQuery(&t, &t, new(true), new(true))
+	q.SetField("type")
+	req := bleve.NewSearchRequest(q)
+	req.Fields = append([]string{"url", "favicon", "added", "type", "user_id"}, extraFields...)
+	req.Size = 200
+	req.SortBy([]string{"_id"})
+	latest := ""
+	for {
+		if latest != "" {
+			req.SetSearchAfter([]string{latest})
+		}
+		res, err := i.idx.Search(req)
+		if err != nil {
+			return err
+		}
+		n := len(res.Hits)
+		if n < 1 {
+			return nil
+		}
+		for _, h := range res.Hits {
+			fn(resFromHit(h))
+		}
+		latest = res.Hits[n-1].ID
+	}
+}
+
+// ErrInvalidSort is returned by Search for unknown Query.Sort values.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// sortFields maps the names accepted in Query.Sort to the sorted fields.
+var sortFields = map[string]string{
+	"added":  "added",
+	"title":  "title_sort",
+	"url":    "url",
+	"domain": "domain",
+}
+
+// sortOrder returns the Bleve sort order of a Query.Sort value, which is
+// a field of sortFields or metadata.<key>, prefixed by "-" to sort in
+// descending order. Empty or "score" sorts by score and returns nil. The
+// document ID breaks the ties, keeping the page keys stable.
+func sortOrder(s string) ([]string, error) {
+	if s == "" || s == "score" {
+		return nil, nil
+	}
+	name, desc := strings.CutPrefix(s, "-")
+	field, ok := sortFields[name]
+	if !ok {
+		key, found := strings.CutPrefix(name, "metadata.")
+		if !found || key == "" || strings.ContainsAny(key, " \t\r\n") {
+			return nil, fmt.Errorf("%w %q", ErrInvalidSort, s)
+		}
+		field = name
+	}
+	if desc {
+		field = "-" + field
+	}
+	return []string{field, "_id"}, nil
+}
+
 func resFromHit(h *search.DocumentMatch) *document.Document {
 	d := &document.Document{}
 	if t, ok := h.Fragments["title"]; ok {
@@ -975,6 +1130,12 @@ func resFromHit(h *search.DocumentMatch) *document.Document {
 	if t, ok := h.Fields["type"].(float64); ok {
 		d.Type = types.DocType(t)
 	}
+	if s, ok := h.Fields["language"].(string); ok {
+		d.Language = s
+	}
+	if s, ok := h.Fields["content_type"].(string); ok {
+		d.ContentType = s
+	}
 	if t, ok := h.Fields["user_id"].(float64); ok {
 		d.UserID = uint(t)
 	}
@@ -1001,14 +1162,31 @@ func docFromHit(h *search.DocumentMatch) *document.Document {
 	return d
 }
 
-func (q *Query) create() query.Query {
+func (q *Query) create() (query.Query, error) {
 	var sq query.Query
 	if q.MatchAll {
 		sq = query.NewMatchAllQuery()
 	} else {
-		sq = querybuilder.Build(q.Text)
+		var err error
+		if sq, err = querybuilder.Build(q.Text); err != nil {
+			return nil, err
+		}
 	}
+	return q.restrict(sq), nil
+}
 
+// filter returns the query matching the filters of q, leaving out its free
+// text terms.
+func (q *Query) filter() (query.Query, error) {
+	sq, err := querybuilder.BuildFilter(q.Text)
+	if err != nil {
+		return nil, err
+	}
+	return q.restrict(sq), nil
+}
+
+// restrict limits sq to the date range and the user of q.
+func (q *Query) restrict(sq query.Query) query.Query {
 	if q.DateFrom != 0 || q.DateTo != 0 {
 		if q.DateFrom != 0 && q.DateTo == 0 {
 			q.DateTo = time.Now().Unix()
@@ -1089,12 +1267,21 @@ func createMapping(lang string) mapping.IndexMapping {
 	noIdxMap.IncludeInAll = false
 	noIdxMap.DocValues = false
 
+	// the whole title as a single term, the text field can't be sorted by
+	tsm := bleve.NewTextFieldMapping()
+	tsm.Name = "title_sort"
+	tsm.Analyzer = "url"
+	tsm.Store = false
+	tsm.IncludeTermVectors = false
+	tsm.IncludeInAll = false
+
 	docMapping := bleve.NewDocumentMapping()
-	docMapping.AddFieldMappingsAt("title", fm)
+	docMapping.AddFieldMappingsAt("title", fm, tsm)
 	docMapping.AddFieldMappingsAt("text", fm)
 	docMapping.AddFieldMappingsAt("url", um)
 	docMapping.AddFieldMappingsAt("domain", um)
 	docMapping.AddFieldMappingsAt("language", um)
+	docMapping.AddFieldMappingsAt("content_type", um)
 	docMapping.AddFieldMappingsAt("favicon", noIdxMap)
 	docMapping.AddFieldMappingsAt("html", noIdxMap)
 	docMapping.AddFieldMappingsAt("metadata", noIdxMap)
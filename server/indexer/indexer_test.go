@@ -0,0 +1,94 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/asciimoo/hister/server/document"
+)
+
+// newTestIndexer replaces the global indexer with an empty one stored in a
+// temporary directory for the duration of the test.
+func newTestIndexer(t *testing.T) *indexer {
+	t.Helper()
+	ti, err := initializeIndexer(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("failed to create indexer: %v", err)
+	}
+	prev := i
+	i = ti
+	t.Cleanup(func() {
+		ti.Close()
+		i = prev
+	})
+	return ti
+}
+
+// addTestDocs indexes docs, failing the test on error.
+func addTestDocs(t *testing.T, docs ...*document.Document) {
+	t.Helper()
+	for _, d := range docs {
+		if err := i.AddDocument(d); err != nil {
+			t.Fatalf("failed to add %s: %v", d.URL, err)
+		}
+	}
+}
+
+// resultURLs returns the URLs of the documents of r in order.
+func resultURLs(r *Results) []string {
+	urls := make([]string, 0, len(r.Documents))
+	for _, d := range r.Documents {
+		urls = append(urls, d.URL)
+	}
+	return urls
+}
+
+func TestSearchRegexp(t *testing.T) {
+	newTestIndexer(t)
+	addTestDocs(t,
+		&document.Document{URL: "https://go.dev/", Title: "The Go Programming Language", Text: "Golang is an open source language"},
+		&document.Document{URL: "https://example.com/usr/bin/", Title: "Binaries", Text: "contents of /usr/bin/"},
+	)
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"/Gol.*/", []string{"https://go.dev/"}},
+		{"title:/PROG[a-z]+/", []string{"https://go.dev/"}},
+		{`url:/HTTPS:\/\/GO\..*/`, []string{"https://go.dev/"}},
+		{"/usr/bin/", []string{"https://example.com/usr/bin/"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			r, err := Search(nil, &Query{Text: tc.query})
+			if err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+			if got := resultURLs(r); !slices.Equal(got, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRunEvery(t *testing.T) {
+	calls := 0
+	if err := runEvery(t.Context(), 0, func() { calls++ }); err != nil || calls != 1 {
+		t.Fatalf("expected a single call without an interval, got %d (%v)", calls, err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	calls = 0
+	err := runEvery(ctx, time.Millisecond, func() {
+		if calls++; calls == 3 {
+			cancel()
+		}
+	})
+	// ticks pending at the cancellation may still be picked
+	if !errors.Is(err, context.Canceled) || calls < 3 {
+		t.Errorf("expected at least 3 calls until cancelled, got %d (%v)", calls, err)
+	}
+}
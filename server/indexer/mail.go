@@ -0,0 +1,136 @@
+package indexer
+
+import (
+	"context"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/files"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/extractor"
+	"github.com/asciimoo/hister/server/extractor/extractors/mail"
+	"github.com/asciimoo/hister/server/mailbox"
+	"github.com/asciimoo/hister/server/types"
+)
+
+// mailboxFile is the state of a mailbox file when it was last read.
+type mailboxFile struct {
+	size    int64
+	modTime time.Time
+}
+
+var (
+	mailboxFilesMu sync.Mutex
+	// mailboxFiles records the mailbox files read by IndexMailboxes, so
+	// they are not read again until they change.
+	mailboxFiles = make(map[string]mailboxFile)
+)
+
+// IndexMailboxes indexes the messages of boxes which are not indexed yet.
+// Messages are identified by their Message-ID, so a message found in
+// several mailboxes is indexed once. Returns the number of indexed messages.
+func IndexMailboxes(boxes []*config.Mailbox) (int, error) {
+	indexed, unchanged, skipped := 0, 0, 0
+	seen := make(map[string]bool)
+	read := make(map[string]mailboxFile)
+	isUnchanged := func(path string, info fs.FileInfo) bool {
+		f := mailboxFile{size: info.Size(), modTime: info.ModTime()}
+		read[path] = f
+		mailboxFilesMu.Lock()
+		defer mailboxFilesMu.Unlock()
+		return mailboxFiles[path] == f
+	}
+	b := NewMultiBatch()
+	pending := 0
+	for _, box := range boxes {
+		path := files.ExpandHome(box.Path)
+		for m, err := range mailbox.Messages(path, isUnchanged) {
+			if err != nil {
+				log.Warn().Err(err).Str("mailbox", path).Msg("Failed to read mailbox")
+				skipped++
+				continue
+			}
+			u := m.URL()
+			if seen[u] || GetByURLAndUser(u, 0) != nil {
+				unchanged++
+				continue
+			}
+			seen[u] = true
+			d := &document.Document{
+				URL:         u,
+				Raw:         m.Raw,
+				ContentType: mailbox.ContentType,
+			}
+			if !m.Date.IsZero() {
+				d.Added = m.Date.Unix()
+			}
+			if err := d.Process(i.langDetector, extractor.Extract); err != nil {
+				log.Debug().Err(err).Str("URL", u).Str("path", m.Path).Msg("Skipping message")
+				skipped++
+				continue
+			}
+			if err := b.Add(d); err != nil {
+				log.Debug().Err(err).Str("URL", u).Msg("Skipping message")
+				skipped++
+				continue
+			}
+			indexed++
+			if pending++; pending == fileBatchSize {
+				if err := b.Save(); err != nil {
+					return indexed, err
+				}
+				b = NewMultiBatch()
+				pending = 0
+			}
+		}
+	}
+	if err := b.Save(); err != nil {
+		return indexed, err
+	}
+	mailboxFilesMu.Lock()
+	for path, f := range read {
+		mailboxFiles[path] = f
+	}
+	mailboxFilesMu.Unlock()
+	e := log.Debug()
+	if indexed > 0 {
+		e = log.Info()
+	}
+	e.Int("indexed", indexed).Int("unchanged", unchanged).Int("skipped", skipped).Msg("Mailboxes indexed")
+	return indexed, nil
+}
+
+// RunMailboxes indexes the new messages of boxes right away and then once
+// per every, until ctx is cancelled. A zero every reads the mailboxes once.
+func RunMailboxes(ctx context.Context, boxes []*config.Mailbox, every time.Duration) error {
+	return runEvery(ctx, every, func() {
+		if _, err := IndexMailboxes(boxes); err != nil {
+			log.Error().Err(err).Msg("Failed to index mailboxes")
+		}
+	})
+}
+
+// groupThreads moves the e-mail messages of docs that belong to the thread
+// of a better match, which precedes them, into the Thread of that match.
+func groupThreads(docs []*document.Document) []*document.Document {
+	heads := make(map[string]*document.Document)
+	r := docs[:0]
+	for _, d := range docs {
+		thread, _ := d.Metadata[mail.MetaThread].(string)
+		if d.Type != types.Mail || thread == "" {
+			r = append(r, d)
+			continue
+		}
+		if h, ok := heads[thread]; ok {
+			h.Thread = append(h.Thread, d)
+			continue
+		}
+		heads[thread] = d
+		r = append(r, d)
+	}
+	return r
+}
@@ -1,7 +1,13 @@
 package querybuilder
 
 import (
+	"errors"
+	"fmt"
+	"maps"
 	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -12,6 +18,12 @@ import (
 	"github.com/blevesearch/bleve/v2/search/query"
 )
 
+// maxFuzziness is the largest edit distance supported by Bleve.
+const maxFuzziness = 2
+
+// fuzzyRe matches the term~N fuzzy terms.
+var fuzzyRe = regexp.MustCompile(`^(.+)~(\d*)$`)
+
 var weights = map[string]float64{
 	"text":     1,
 	"language": 1,
@@ -20,35 +32,176 @@ var weights = map[string]float64{
 	"title":    12,
 }
 
-func Build(s string) query.Query {
+// Boosts returns the boost of each field, applied to the terms searched in
+// the field.
+func Boosts() map[string]float64 {
+	return maps.Clone(weights)
+}
+
+// Build parses s and returns the corresponding Bleve query. Invalid queries
+// return a *ParseError.
+func Build(s string) (query.Query, error) {
 	if strings.TrimSpace(s) == "" {
-		return query.NewMatchNoneQuery()
+		return query.NewMatchNoneQuery(), nil
 	}
 
-	qt, err := Tokenize(s)
+	n, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if n.Type != NodeAnd {
+		n = &Node{Type: NodeAnd, Children: []*Node{n}}
+	}
+	q, _, err := buildNode(n)
 	if err != nil {
-		return createSimpleQuery(s)
+		return nil, err
 	}
+	return q, nil
+}
+
+// filterFields are the fields of the filter terms besides the fields of
+// weights.
+var filterFields = map[string]bool{
+	"type":    true,
+	"user_id": true,
+	"status":  true,
+	"added":   true,
+	"symbol":  true,
+	"from":    true,
+	"to":      true,
+	"tag":     true,
+}
 
-	qs := []query.Query{}
-	nqs := []query.Query{}
+// BuildFilter parses s and returns the query of its filters, which are the
+// field terms and the excluded terms. The free text terms are left out, so
+// they can be matched by other means, like the semantic search. Invalid
+// queries return a *ParseError.
+func BuildFilter(s string) (query.Query, error) {
+	if strings.TrimSpace(s) == "" {
+		return query.NewMatchAllQuery(), nil
+	}
 
-	for _, t := range qt {
-		q, negated := getTokenQuery(t)
-		if negated {
-			nqs = append(nqs, q)
-		} else {
+	n, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if n = filterNode(n); n == nil {
+		return query.NewMatchAllQuery(), nil
+	}
+	if n.Type != NodeAnd {
+		n = &Node{Type: NodeAnd, Children: []*Node{n}}
+	}
+	q, _, err := buildNode(n)
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// filterNode returns n without its free text terms, or nil when nothing is
+// left to filter on. Negations are kept whole, and an OR with a free text
+// alternative filters on nothing.
+func filterNode(n *Node) *Node {
+	switch n.Type {
+	case NodeNot:
+		return n
+	case NodeAnd:
+		var cs []*Node
+		for _, c := range n.Children {
+			if fc := filterNode(c); fc != nil {
+				cs = append(cs, fc)
+			}
+		}
+		if len(cs) == 0 {
+			return nil
+		}
+		return &Node{Type: NodeAnd, Children: cs}
+	case NodeOr:
+		cs := make([]*Node, 0, len(n.Children))
+		for _, c := range n.Children {
+			fc := filterNode(c)
+			if fc == nil {
+				return nil
+			}
+			cs = append(cs, fc)
+		}
+		return &Node{Type: NodeOr, Children: cs}
+	}
+	if isFreeText(n.Token) {
+		return nil
+	}
+	return n
+}
+
+// isFreeText reports whether t is a term searched in all the text fields,
+// which is neither excluded nor restricted to a field.
+func isFreeText(t Token) bool {
+	if t.Type == TokenAlternation {
+		return slices.ContainsFunc(t.Parts, isFreeText)
+	}
+	if strings.HasPrefix(t.Value, "-") && len(t.Value) > 1 {
+		return false
+	}
+	field, _, found := strings.Cut(t.Value, ":")
+	if !found {
+		return true
+	}
+	_, ok := weights[field]
+	return !ok && (t.Type == TokenQuoted || !filterFields[field])
+}
+
+// buildNode returns the query of n and whether the matching documents are
+// excluded. The negated children of AND nodes become the must-not clauses of
+// their boolean query.
+func buildNode(n *Node) (query.Query, bool, error) {
+	switch n.Type {
+	case NodeNot:
+		q, negated, err := buildNode(n.Children[0])
+		return q, !negated, err
+	case NodeOr:
+		qs := make([]query.Query, 0, len(n.Children))
+		for _, c := range n.Children {
+			q, err := buildClause(c)
+			if err != nil {
+				return nil, false, err
+			}
 			qs = append(qs, q)
 		}
+		return bleve.NewDisjunctionQuery(qs...), false, nil
+	case NodeAnd:
+		qs := []query.Query{}
+		nqs := []query.Query{}
+		for _, c := range n.Children {
+			q, negated, err := buildNode(c)
+			if err != nil {
+				return nil, false, err
+			}
+			if negated {
+				nqs = append(nqs, q)
+			} else {
+				qs = append(qs, q)
+			}
+		}
+		return query.NewBooleanQuery(qs, nil, nqs), false, nil
+	}
+	q, negated, err := getTokenQuery(n.Token)
+	if err != nil {
+		return nil, false, &ParseError{Pos: n.Pos, Msg: err.Error()}
 	}
-	return query.NewBooleanQuery(qs, nil, nqs)
+	return q, negated, nil
 }
 
-func createSimpleQuery(s string) query.Query {
-	return bleve.NewQueryStringQuery(s)
+// buildClause returns the query of n, wrapped into a boolean query when it
+// excludes the documents it matches.
+func buildClause(n *Node) (query.Query, error) {
+	q, negated, err := buildNode(n)
+	if err != nil || !negated {
+		return q, err
+	}
+	return query.NewBooleanQuery(nil, nil, []query.Query{q}), nil
 }
 
-func getTokenQuery(t Token) (query.Query, bool) {
+func getTokenQuery(t Token) (query.Query, bool, error) {
 	negated := false
 	switch t.Type {
 	case TokenQuoted:
@@ -77,12 +230,12 @@ func getTokenQuery(t Token) (query.Query, bool) {
 				q := bleve.NewTermQuery(v)
 				q.SetField(field)
 				q.SetBoost(weights[field])
-				return q, negated
+				return q, negated, nil
 			}
 			q := bleve.NewMatchQuery(v)
 			q.SetField(field)
 			q.SetBoost(weights[field])
-			return q, negated
+			return q, negated, nil
 		}
 		titleq := bleve.NewMatchPhraseQuery(v)
 		titleq.SetField("title")
@@ -90,7 +243,7 @@ func getTokenQuery(t Token) (query.Query, bool) {
 		textq := bleve.NewMatchPhraseQuery(v)
 		textq.SetField("text")
 		textq.SetBoost(weights["text"])
-		return bleve.NewDisjunctionQuery(titleq, textq), negated
+		return bleve.NewDisjunctionQuery(titleq, textq), negated, nil
 	case TokenWord:
 		if strings.HasPrefix(t.Value, "-") && len(t.Value) > 1 {
 			negated = true
@@ -103,7 +256,7 @@ func getTokenQuery(t Token) (query.Query, bool) {
 				to := float64(t + 1)
 				q := bleve.NewNumericRangeQuery(&from, &to)
 				q.SetField("type")
-				return q, negated
+				return q, negated, nil
 			}
 		}
 		if v, ok := strings.CutPrefix(t.Value, "user_id:"); ok {
@@ -111,8 +264,50 @@ func getTokenQuery(t Token) (query.Query, bool) {
 				f := float64(uid)
 				q := bleve.NewNumericRangeInclusiveQuery(&f, &f, new(true), new(true))
 				q.SetField("user_id")
-				return q, negated
+				return q, negated, nil
+			}
+		}
+		// status: filters on the outcome of the last link check.
+		if v, ok := strings.CutPrefix(t.Value, "status:"); ok && v != "" {
+			q := bleve.NewTermQuery(strings.ToLower(v))
+			q.SetField("metadata.link_status")
+			return q, negated, nil
+		}
+		// added: filters on the indexing date, see addedQuery.
+		if v, ok := strings.CutPrefix(t.Value, "added:"); ok && v != "" {
+			q, err := addedQuery(v)
+			return q, negated, err
+		}
+		// symbol: matches the names defined by source code files.
+		if v, ok := strings.CutPrefix(t.Value, "symbol:"); ok && v != "" {
+			if strings.Contains(v, "*") {
+				q := bleve.NewWildcardQuery(strings.ToLower(v))
+				q.SetField("metadata.symbols")
+				return q, negated, nil
 			}
+			q := bleve.NewMatchQuery(v)
+			q.SetField("metadata.symbols")
+			return q, negated, nil
+		}
+		// from: and to: filter e-mail messages by their sender and
+		// recipients, matching names and addresses.
+		if v, ok := strings.CutPrefix(t.Value, "from:"); ok && v != "" {
+			q := bleve.NewMatchPhraseQuery(v)
+			q.SetField("metadata.from")
+			return q, negated, nil
+		}
+		if v, ok := strings.CutPrefix(t.Value, "to:"); ok && v != "" {
+			toq := bleve.NewMatchPhraseQuery(v)
+			toq.SetField("metadata.to")
+			ccq := bleve.NewMatchPhraseQuery(v)
+			ccq.SetField("metadata.cc")
+			return bleve.NewDisjunctionQuery(toq, ccq), negated, nil
+		}
+		// tag: filters on the tags of notes.
+		if v, ok := strings.CutPrefix(t.Value, "tag:"); ok && v != "" {
+			q := bleve.NewMatchPhraseQuery(strings.TrimPrefix(v, "#"))
+			q.SetField("metadata.tags")
+			return q, negated, nil
 		}
 		for f := range weights {
 			if strings.HasPrefix(t.Value, f+":") {
@@ -135,21 +330,41 @@ func getTokenQuery(t Token) (query.Query, bool) {
 						qs := []query.Query{}
 						for _, p := range parts {
 							partToken := Token{Type: TokenWord, Value: field + ":" + p.Value}
-							q, _ := getTokenQuery(partToken)
+							q, _, err := getTokenQuery(partToken)
+							if err != nil {
+								return nil, false, err
+							}
 							qs = append(qs, q)
 						}
-						return bleve.NewDisjunctionQuery(qs...), negated
+						return bleve.NewDisjunctionQuery(qs...), negated, nil
 					}
 					if len(parts) == 1 {
 						v = parts[0].Value
 					}
 				}
 			}
+			if re, ok := regexpTerm(v); ok {
+				q, err := regexpQuery(re, field)
+				return q, negated, err
+			}
+			term, fuzziness, err := fuzzyTerm(v)
+			if err != nil {
+				return nil, false, err
+			}
+			if term != "" {
+				return fuzzyQuery(term, fuzziness, field), negated, nil
+			}
+			if prefix, ok := prefixTerm(v); ok {
+				q := bleve.NewPrefixQuery(prefix)
+				q.SetField(field)
+				q.SetBoost(weights[field])
+				return q, negated, nil
+			}
 			if strings.Contains(v, "*") {
 				q := bleve.NewWildcardQuery(strings.ToLower(v))
 				q.SetField(field)
 				q.SetBoost(weights[field])
-				return q, negated
+				return q, negated, nil
 			}
 			if field == "url" || field == "domain" {
 				if field == "url" {
@@ -158,12 +373,24 @@ func getTokenQuery(t Token) (query.Query, bool) {
 				q := bleve.NewTermQuery(v)
 				q.SetField(field)
 				q.SetBoost(weights[field])
-				return q, negated
+				return q, negated, nil
 			}
 			q := bleve.NewMatchQuery(v)
 			q.SetField(field)
 			q.SetBoost(weights[field])
-			return q, negated
+			return q, negated, nil
+		}
+
+		if re, ok := regexpTerm(t.Value); ok {
+			q, err := regexpQuery(re, "title", "text", "url")
+			return q, negated, err
+		}
+		term, fuzziness, err := fuzzyTerm(t.Value)
+		if err != nil {
+			return nil, false, err
+		}
+		if term != "" {
+			return fuzzyQuery(term, fuzziness, "title", "text"), negated, nil
 		}
 
 		qs := []query.Query{}
@@ -197,17 +424,20 @@ func getTokenQuery(t Token) (query.Query, bool) {
 		domainq.SetField("domain")
 		domainq.SetBoost(weights["domain"])
 		qs = append(qs, domainq)
-		return bleve.NewDisjunctionQuery(qs...), negated
+		return bleve.NewDisjunctionQuery(qs...), negated, nil
 
 	case TokenAlternation:
 		qs := []query.Query{}
 		for _, p := range t.Parts {
-			r, _ := getTokenQuery(p)
+			r, _, err := getTokenQuery(p)
+			if err != nil {
+				return nil, false, err
+			}
 			qs = append(qs, r)
 		}
-		return bleve.NewDisjunctionQuery(qs...), negated
+		return bleve.NewDisjunctionQuery(qs...), negated, nil
 	}
-	return bleve.NewQueryStringQuery(t.Value), negated
+	return bleve.NewQueryStringQuery(t.Value), negated, nil
 }
 
 func normalizeFileURL(v string) string {
@@ -222,3 +452,97 @@ func normalizeFileURL(v string) string {
 	}
 	return files.PathToFileURL(v)
 }
+
+// regexpTerm returns the expression of a /regexp/ term. Slashes inside the
+// expression must be escaped, so paths like /usr/bin/ are not regexps.
+func regexpTerm(v string) (string, bool) {
+	if len(v) < 3 || v[0] != '/' || v[len(v)-1] != '/' {
+		return "", false
+	}
+	re := v[1 : len(v)-1]
+	for i := 0; i < len(re); i++ {
+		switch re[i] {
+		case '\\':
+			i++
+		case '/':
+			return "", false
+		}
+	}
+	return re, true
+}
+
+// regexpQuery returns the query matching the terms of the fields with re.
+// The terms of the fields are lowercased, so re is matched case-insensitively.
+func regexpQuery(re string, fields ...string) (query.Query, error) {
+	re = "(?i)" + re
+	if _, err := regexp.Compile(re); err != nil {
+		var se *syntax.Error
+		if errors.As(err, &se) {
+			return nil, fmt.Errorf("invalid regular expression: %s", se.Code)
+		}
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	qs := make([]query.Query, 0, len(fields))
+	for _, f := range fields {
+		q := bleve.NewRegexpQuery(re)
+		q.SetField(f)
+		q.SetBoost(weights[f])
+		qs = append(qs, q)
+	}
+	if len(qs) == 1 {
+		return qs[0], nil
+	}
+	return bleve.NewDisjunctionQuery(qs...), nil
+}
+
+// fuzzyTerm splits a term~N fuzzy term into the term and the edit distance
+// N, which defaults to 1. The term is empty if v is not a fuzzy term.
+func fuzzyTerm(v string) (string, int, error) {
+	m := fuzzyRe.FindStringSubmatch(v)
+	if m == nil {
+		return "", 0, nil
+	}
+	if m[2] == "" {
+		return m[1], 1, nil
+	}
+	n, err := strconv.Atoi(m[2])
+	if err != nil || n > maxFuzziness {
+		return "", 0, fmt.Errorf("fuzziness of %q must be at most %d", v, maxFuzziness)
+	}
+	return m[1], n, nil
+}
+
+// fuzzyQuery returns the query matching the terms of the fields within the
+// edit distance fuzziness of term.
+func fuzzyQuery(term string, fuzziness int, fields ...string) query.Query {
+	qs := make([]query.Query, 0, len(fields))
+	for _, f := range fields {
+		if f == "url" || f == "domain" {
+			q := bleve.NewFuzzyQuery(strings.ToLower(term))
+			q.SetFuzziness(fuzziness)
+			q.SetField(f)
+			q.SetBoost(weights[f])
+			qs = append(qs, q)
+			continue
+		}
+		q := bleve.NewMatchQuery(term)
+		q.SetFuzziness(fuzziness)
+		q.SetField(f)
+		q.SetBoost(weights[f])
+		qs = append(qs, q)
+	}
+	if len(qs) == 1 {
+		return qs[0]
+	}
+	return bleve.NewDisjunctionQuery(qs...)
+}
+
+// prefixTerm returns the lowercased prefix of a term whose only wildcard is
+// a trailing "*".
+func prefixTerm(v string) (string, bool) {
+	p, ok := strings.CutSuffix(v, "*")
+	if !ok || p == "" || strings.ContainsAny(p, "*?") {
+		return "", false
+	}
+	return strings.ToLower(p), true
+}
@@ -1,6 +1,8 @@
 package querybuilder
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -13,7 +15,10 @@ import (
 // buildBoolQ calls Build(s) and asserts the result is a *query.BooleanQuery.
 func buildBoolQ(t *testing.T, s string) *query.BooleanQuery {
 	t.Helper()
-	q := Build(s)
+	q, err := Build(s)
+	if err != nil {
+		t.Fatalf("Build(%q): %v", s, err)
+	}
 	bq, ok := q.(*query.BooleanQuery)
 	if !ok {
 		t.Fatalf("Build(%q): expected *query.BooleanQuery, got %T", s, q)
@@ -97,6 +102,36 @@ func asMatchPhrase(t *testing.T, q query.Query) *query.MatchPhraseQuery {
 	return mpq
 }
 
+// asPrefix type-asserts q to *query.PrefixQuery.
+func asPrefix(t *testing.T, q query.Query) *query.PrefixQuery {
+	t.Helper()
+	pq, ok := q.(*query.PrefixQuery)
+	if !ok {
+		t.Fatalf("expected *query.PrefixQuery, got %T", q)
+	}
+	return pq
+}
+
+// asFuzzy type-asserts q to *query.FuzzyQuery.
+func asFuzzy(t *testing.T, q query.Query) *query.FuzzyQuery {
+	t.Helper()
+	fq, ok := q.(*query.FuzzyQuery)
+	if !ok {
+		t.Fatalf("expected *query.FuzzyQuery, got %T", q)
+	}
+	return fq
+}
+
+// asRegexp type-asserts q to *query.RegexpQuery.
+func asRegexp(t *testing.T, q query.Query) *query.RegexpQuery {
+	t.Helper()
+	rq, ok := q.(*query.RegexpQuery)
+	if !ok {
+		t.Fatalf("expected *query.RegexpQuery, got %T", q)
+	}
+	return rq
+}
+
 // asNumericRange type-asserts q to *query.NumericRangeQuery.
 func asNumericRange(t *testing.T, q query.Query) *query.NumericRangeQuery {
 	t.Helper()
@@ -110,14 +145,16 @@ func asNumericRange(t *testing.T, q query.Query) *query.NumericRangeQuery {
 // --- Build() tests ---
 
 func Test_build_empty_string(t *testing.T) {
-	if _, ok := Build("").(*query.MatchNoneQuery); !ok {
-		t.Fatalf("expected *query.MatchNoneQuery, got %T", Build(""))
+	q, err := Build("")
+	if _, ok := q.(*query.MatchNoneQuery); !ok || err != nil {
+		t.Fatalf("expected *query.MatchNoneQuery, got %T (%v)", q, err)
 	}
 }
 
 func Test_build_whitespace_only(t *testing.T) {
-	if _, ok := Build("   ").(*query.MatchNoneQuery); !ok {
-		t.Fatalf("expected *query.MatchNoneQuery, got %T", Build("   "))
+	q, err := Build("   ")
+	if _, ok := q.(*query.MatchNoneQuery); !ok || err != nil {
+		t.Fatalf("expected *query.MatchNoneQuery, got %T (%v)", q, err)
 	}
 }
 
@@ -290,6 +327,98 @@ func Test_build_user_id(t *testing.T) {
 	}
 }
 
+func Test_build_status(t *testing.T) {
+	bq := buildBoolQ(t, "status:Dead")
+	clauses := mustClauses(t, bq)
+	if len(clauses) != 1 {
+		t.Fatalf("expected 1 must clause, got %d", len(clauses))
+	}
+	tq := asTerm(t, clauses[0])
+	if tq.FieldVal != "metadata.link_status" || tq.Term != "dead" {
+		t.Fatalf("expected metadata.link_status:dead, got %s:%s", tq.FieldVal, tq.Term)
+	}
+
+	bq = buildBoolQ(t, "-status:dead")
+	if len(mustNotClauses(t, bq)) != 1 {
+		t.Fatal("expected negated status filter in must-not clauses")
+	}
+}
+
+func Test_build_tag(t *testing.T) {
+	bq := buildBoolQ(t, "tag:#golang")
+	clauses := mustClauses(t, bq)
+	if len(clauses) != 1 {
+		t.Fatalf("expected 1 must clause, got %d", len(clauses))
+	}
+	pq, ok := clauses[0].(*query.MatchPhraseQuery)
+	if !ok {
+		t.Fatalf("expected *query.MatchPhraseQuery, got %T", clauses[0])
+	}
+	if pq.FieldVal != "metadata.tags" || pq.MatchPhrase != "golang" {
+		t.Fatalf("expected metadata.tags:golang, got %s:%s", pq.FieldVal, pq.MatchPhrase)
+	}
+
+	bq = buildBoolQ(t, "-tag:golang")
+	if len(mustNotClauses(t, bq)) != 1 {
+		t.Fatal("expected negated tag filter in must-not clauses")
+	}
+}
+
+func Test_build_symbol(t *testing.T) {
+	bq := buildBoolQ(t, "symbol:ParseFile")
+	clauses := mustClauses(t, bq)
+	if len(clauses) != 1 {
+		t.Fatalf("expected 1 must clause, got %d", len(clauses))
+	}
+	mq, ok := clauses[0].(*query.MatchQuery)
+	if !ok {
+		t.Fatalf("expected *query.MatchQuery, got %T", clauses[0])
+	}
+	if mq.FieldVal != "metadata.symbols" || mq.Match != "ParseFile" {
+		t.Fatalf("expected metadata.symbols:ParseFile, got %s:%s", mq.FieldVal, mq.Match)
+	}
+
+	bq = buildBoolQ(t, "symbol:Parse*")
+	wq, ok := mustClauses(t, bq)[0].(*query.WildcardQuery)
+	if !ok || wq.FieldVal != "metadata.symbols" || wq.Wildcard != "parse*" {
+		t.Fatalf("expected wildcard query on metadata.symbols, got %#v", mustClauses(t, bq)[0])
+	}
+}
+
+func Test_build_from(t *testing.T) {
+	bq := buildBoolQ(t, "from:jane@example.com")
+	clauses := mustClauses(t, bq)
+	if len(clauses) != 1 {
+		t.Fatalf("expected 1 must clause, got %d", len(clauses))
+	}
+	pq, ok := clauses[0].(*query.MatchPhraseQuery)
+	if !ok {
+		t.Fatalf("expected *query.MatchPhraseQuery, got %T", clauses[0])
+	}
+	if pq.FieldVal != "metadata.from" || pq.MatchPhrase != "jane@example.com" {
+		t.Fatalf("expected metadata.from:jane@example.com, got %s:%s", pq.FieldVal, pq.MatchPhrase)
+	}
+
+	bq = buildBoolQ(t, "-from:jane")
+	if len(mustNotClauses(t, bq)) != 1 {
+		t.Fatal("expected negated from filter in must-not clauses")
+	}
+}
+
+func Test_build_to(t *testing.T) {
+	bq := buildBoolQ(t, "to:golang-nuts")
+	dq := asDisjunction(t, mustClauses(t, bq)[0])
+	if len(dq.Disjuncts) != 2 {
+		t.Fatalf("expected 2 disjuncts (to, cc), got %d", len(dq.Disjuncts))
+	}
+	for i, f := range []string{"metadata.to", "metadata.cc"} {
+		pq, ok := dq.Disjuncts[i].(*query.MatchPhraseQuery)
+		if !ok || pq.FieldVal != f || pq.MatchPhrase != "golang-nuts" {
+			t.Fatalf("expected %s:golang-nuts, got %#v", f, dq.Disjuncts[i])
+		}
+	}
+}
+
 func Test_build_wildcard_word(t *testing.T) {
 	bq := buildBoolQ(t, "go*")
 	clauses := mustClauses(t, bq)
@@ -303,20 +432,165 @@ func Test_build_wildcard_word(t *testing.T) {
 }
 
 func Test_build_wildcard_field(t *testing.T) {
-	bq := buildBoolQ(t, "title:go*")
+	bq := buildBoolQ(t, "title:*go*")
 	clauses := mustClauses(t, bq)
 	if len(clauses) != 1 {
 		t.Fatalf("expected 1 must clause, got %d", len(clauses))
 	}
 	wq := asWildcard(t, clauses[0])
-	if wq.Wildcard != "go*" {
-		t.Fatalf("expected wildcard %q, got %q", "go*", wq.Wildcard)
+	if wq.Wildcard != "*go*" {
+		t.Fatalf("expected wildcard %q, got %q", "*go*", wq.Wildcard)
 	}
 	if wq.FieldVal != "title" {
 		t.Fatalf("expected field %q, got %q", "title", wq.FieldVal)
 	}
 }
 
+func Test_build_prefix_field(t *testing.T) {
+	bq := buildBoolQ(t, "title:Go*")
+	clauses := mustClauses(t, bq)
+	if len(clauses) != 1 {
+		t.Fatalf("expected 1 must clause, got %d", len(clauses))
+	}
+	pq := asPrefix(t, clauses[0])
+	if pq.Prefix != "go" {
+		t.Fatalf("expected prefix %q, got %q", "go", pq.Prefix)
+	}
+	if pq.FieldVal != "title" {
+		t.Fatalf("expected field %q, got %q", "title", pq.FieldVal)
+	}
+
+	pq = asPrefix(t, mustClauses(t, buildBoolQ(t, "url:https://github.com/asciimoo/*"))[0])
+	if pq.Prefix != "https://github.com/asciimoo/" || pq.FieldVal != "url" {
+		t.Fatalf("expected url prefix, got %s:%s", pq.FieldVal, pq.Prefix)
+	}
+
+	// a wildcard before the end is not a prefix
+	asWildcard(t, mustClauses(t, buildBoolQ(t, "domain:*.github.*"))[0])
+}
+
+func Test_build_fuzzy_word(t *testing.T) {
+	bq := buildBoolQ(t, "golnag~2")
+	dq := asDisjunction(t, mustClauses(t, bq)[0])
+	if len(dq.Disjuncts) != 2 {
+		t.Fatalf("expected 2 disjuncts (title, text), got %d", len(dq.Disjuncts))
+	}
+	for i, f := range []string{"title", "text"} {
+		mq := asMatch(t, dq.Disjuncts[i])
+		if mq.Match != "golnag" || mq.FieldVal != f || mq.Fuzziness != 2 {
+			t.Fatalf("expected fuzzy %s:golnag~2, got %s:%s~%d", f, mq.FieldVal, mq.Match, mq.Fuzziness)
+		}
+	}
+
+	mq := asMatch(t, asDisjunction(t, mustClauses(t, buildBoolQ(t, "golnag~"))[0]).Disjuncts[0])
+	if mq.Fuzziness != 1 {
+		t.Fatalf("expected default fuzziness 1, got %d", mq.Fuzziness)
+	}
+}
+
+func Test_build_fuzzy_field(t *testing.T) {
+	mq := asMatch(t, mustClauses(t, buildBoolQ(t, "title:kubernetes~1"))[0])
+	if mq.Match != "kubernetes" || mq.FieldVal != "title" || mq.Fuzziness != 1 {
+		t.Fatalf("expected fuzzy title:kubernetes~1, got %s:%s~%d", mq.FieldVal, mq.Match, mq.Fuzziness)
+	}
+
+	bq := buildBoolQ(t, "-domain:GitHb.com~1")
+	fq := asFuzzy(t, mustNotClauses(t, bq)[0])
+	if fq.Term != "githb.com" || fq.FieldVal != "domain" || fq.Fuzziness != 1 {
+		t.Fatalf("expected fuzzy domain:githb.com~1, got %s:%s~%d", fq.FieldVal, fq.Term, fq.Fuzziness)
+	}
+
+	// a tilde not followed by a number is part of the term
+	asTerm(t, mustClauses(t, buildBoolQ(t, "url:https://example.com/~user"))[0])
+}
+
+func Test_build_regexp_word(t *testing.T) {
+	bq := buildBoolQ(t, "/go(lang|pher)s?/ -tutorial")
+	dq := asDisjunction(t, mustClauses(t, bq)[0])
+	if len(dq.Disjuncts) != 3 {
+		t.Fatalf("expected 3 disjuncts (title, text, url), got %d", len(dq.Disjuncts))
+	}
+	for i, f := range []string{"title", "text", "url"} {
+		rq := asRegexp(t, dq.Disjuncts[i])
+		if rq.Regexp != "(?i)go(lang|pher)s?" || rq.FieldVal != f {
+			t.Fatalf("expected regexp %s:(?i)go(lang|pher)s?, got %s:%s", f, rq.FieldVal, rq.Regexp)
+		}
+	}
+	if len(mustNotClauses(t, bq)) != 1 {
+		t.Fatalf("expected 1 must_not clause")
+	}
+}
+
+func Test_build_regexp_field(t *testing.T) {
+	rq := asRegexp(t, mustClauses(t, buildBoolQ(t, `url:/.*\/issues\/[0-9]+/`))[0])
+	if rq.Regexp != `(?i).*\/issues\/[0-9]+` || rq.FieldVal != "url" {
+		t.Fatalf("unexpected regexp %s:%s", rq.FieldVal, rq.Regexp)
+	}
+
+	rq = asRegexp(t, mustClauses(t, buildBoolQ(t, "title:/V[0-9]+/"))[0])
+	if rq.Regexp != "(?i)V[0-9]+" || rq.FieldVal != "title" {
+		t.Fatalf("unexpected regexp %s:%s", rq.FieldVal, rq.Regexp)
+	}
+
+	// paths are not regexps
+	tq := asTerm(t, mustClauses(t, buildBoolQ(t, "url:/home/user/report.pdf"))[0])
+	if tq.Term != "file:///home/user/report.pdf" {
+		t.Fatalf("unexpected term %q", tq.Term)
+	}
+	tq = asTerm(t, mustClauses(t, buildBoolQ(t, "url:/home/user/"))[0])
+	if tq.Term != "file:///home/user" {
+		t.Fatalf("unexpected term %q", tq.Term)
+	}
+}
+
+func Test_build_regexp_unescaped_slash(t *testing.T) {
+	// a word wrapped in slashes is only a regexp without unescaped slashes
+	// inside, otherwise it is searched as a plain word
+	for _, s := range []string{"/usr/bin/", "-/usr/bin/", "title:/a/b/"} {
+		n, err := Parse(s)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", s, err)
+		}
+		if n.Type != NodeTerm || n.Token.Value != s {
+			t.Fatalf("Parse(%q): expected a single term, got %v", s, n)
+		}
+		q, err := Build(s)
+		if err != nil {
+			t.Fatalf("Build(%q): %v", s, err)
+		}
+		b, _ := json.Marshal(q)
+		if strings.Contains(string(b), "regexp") {
+			t.Fatalf("Build(%q): expected no regexp query, got %s", s, b)
+		}
+	}
+
+	rq := asRegexp(t, mustClauses(t, buildBoolQ(t, `url:/usr\/bin/`))[0])
+	if rq.Regexp != `(?i)usr\/bin` {
+		t.Fatalf("unexpected regexp %q", rq.Regexp)
+	}
+}
+
+func Test_build_term_operator_errors(t *testing.T) {
+	tests := map[string]string{
+		"golang~3":        `invalid query: fuzziness of "golang~3" must be at most 2 at position 1`,
+		"a title:x~99":    `invalid query: fuzziness of "x~99" must be at most 2 at position 3`,
+		"/go(lang/":       `invalid query: invalid regular expression: missing closing ) at position 1`,
+		"a OR url:/[a-/":  `invalid query: invalid regular expression: missing closing ] at position 6`,
+		"(title:/x**/ b)": `invalid query: invalid regular expression: invalid nested repetition operator at position 2`,
+	}
+	for q, want := range tests {
+		_, err := Build(q)
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("Build(%q): expected *ParseError, got %v", q, err)
+			continue
+		}
+		if err.Error() != want {
+			t.Errorf("Build(%q): got error %q, want %q", q, err, want)
+		}
+	}
+}
+
 func Test_build_alternation(t *testing.T) {
 	bq := buildBoolQ(t, "(foo|bar)")
 	clauses := mustClauses(t, bq)
@@ -443,6 +717,130 @@ func Test_build_multiple_tokens_positive_and_negative(t *testing.T) {
 	}
 }
 
+func Test_build_grouped_expression(t *testing.T) {
+	bq := buildBoolQ(t, "(golang OR rust) -tutorial (domain:github.com OR domain:gitlab.com)")
+	musts := mustClauses(t, bq)
+	if len(musts) != 2 {
+		t.Fatalf("expected 2 must clauses, got %d", len(musts))
+	}
+	langs := asDisjunction(t, musts[0])
+	if len(langs.Disjuncts) != 2 {
+		t.Fatalf("expected 2 disjuncts (golang, rust), got %d", len(langs.Disjuncts))
+	}
+	domains := asDisjunction(t, musts[1])
+	if len(domains.Disjuncts) != 2 {
+		t.Fatalf("expected 2 disjuncts, got %d", len(domains.Disjuncts))
+	}
+	if tq := asTerm(t, domains.Disjuncts[1]); tq.Term != "gitlab.com" || tq.FieldVal != "domain" {
+		t.Fatalf("unexpected term query %q on %q", tq.Term, tq.FieldVal)
+	}
+	if nots := mustNotClauses(t, bq); len(nots) != 1 {
+		t.Fatalf("expected 1 must_not clause, got %d", len(nots))
+	}
+}
+
+func Test_build_nested_and_inside_or(t *testing.T) {
+	bq := buildBoolQ(t, "a OR (b NOT c)")
+	musts := mustClauses(t, bq)
+	if len(musts) != 1 {
+		t.Fatalf("expected 1 must clause, got %d", len(musts))
+	}
+	dq := asDisjunction(t, musts[0])
+	if len(dq.Disjuncts) != 2 {
+		t.Fatalf("expected 2 disjuncts, got %d", len(dq.Disjuncts))
+	}
+	inner, ok := dq.Disjuncts[1].(*query.BooleanQuery)
+	if !ok {
+		t.Fatalf("expected *query.BooleanQuery, got %T", dq.Disjuncts[1])
+	}
+	if len(mustClauses(t, inner)) != 1 || len(mustNotClauses(t, inner)) != 1 {
+		t.Fatalf("expected 1 must and 1 must_not clause")
+	}
+}
+
+func Test_build_negated_group_inside_or(t *testing.T) {
+	bq := buildBoolQ(t, "a | -(b c)")
+	dq := asDisjunction(t, mustClauses(t, bq)[0])
+	neg, ok := dq.Disjuncts[1].(*query.BooleanQuery)
+	if !ok {
+		t.Fatalf("expected *query.BooleanQuery, got %T", dq.Disjuncts[1])
+	}
+	if neg.Must != nil {
+		t.Fatalf("expected Must to be nil, got %T", neg.Must)
+	}
+	if _, ok := mustNotClauses(t, neg)[0].(*query.BooleanQuery); !ok {
+		t.Fatalf("expected the excluded group to be a *query.BooleanQuery")
+	}
+}
+
+func Test_build_double_negation(t *testing.T) {
+	bq := buildBoolQ(t, "NOT -golang")
+	if len(mustClauses(t, bq)) != 1 || bq.MustNot != nil {
+		t.Fatalf("expected a single must clause")
+	}
+}
+
+func Test_build_parse_error(t *testing.T) {
+	q, err := Build("(golang OR rust")
+	if q != nil {
+		t.Fatalf("expected nil query, got %T", q)
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Pos != 1 {
+		t.Fatalf("expected parse error at position 1, got %v", err)
+	}
+}
+
+// --- BuildFilter tests ---
+
+func Test_build_filter(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string // query built by Build, "" for match all
+	}{
+		{input: "", want: ""},
+		{input: "golang", want: ""},
+		{input: `"go modules" tutorial`, want: ""},
+		{input: "golang domain:go.dev", want: "domain:go.dev"},
+		{input: "golang -rust type:web", want: "-rust type:web"},
+		{input: "golang AND NOT title:rust", want: "NOT title:rust"},
+		{input: "(golang OR domain:go.dev) url:*spec*", want: "url:*spec*"},
+		{input: "domain:go.dev OR domain:golang.org", want: "domain:go.dev OR domain:golang.org"},
+		{input: "golang (go|domain:go.dev)", want: ""},
+		{input: `foo:bar "type:web"`, want: ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			q, err := BuildFilter(tc.input)
+			if err != nil {
+				t.Fatalf("BuildFilter(%q): %v", tc.input, err)
+			}
+			if tc.want == "" {
+				if _, ok := q.(*query.MatchAllQuery); !ok {
+					t.Fatalf("BuildFilter(%q): expected *query.MatchAllQuery, got %T", tc.input, q)
+				}
+				return
+			}
+			want, err := Build(tc.want)
+			if err != nil {
+				t.Fatalf("Build(%q): %v", tc.want, err)
+			}
+			got, _ := json.Marshal(q)
+			exp, _ := json.Marshal(want)
+			if string(got) != string(exp) {
+				t.Fatalf("BuildFilter(%q):\n got %s\nwant %s", tc.input, got, exp)
+			}
+		})
+	}
+}
+
+func Test_build_filter_parse_error(t *testing.T) {
+	var pe *ParseError
+	if _, err := BuildFilter("domain:go.dev (golang"); !errors.As(err, &pe) {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
+
 // --- normalizeFileURL tests ---
 
 func Test_normalizeFileURL(t *testing.T) {
@@ -0,0 +1,178 @@
+package querybuilder
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// now returns the current time, the reference of relative dates.
+var now = time.Now
+
+var durationRe = regexp.MustCompile(`^(\d+)([hdwmy])$`)
+
+// period is the time range [start, end).
+type period struct {
+	start, end time.Time
+}
+
+// addedQuery returns the query of the value of an added: filter, which is
+// one of
+//
+//	2025, 2025-03, 2025-03-14  the year, month or day
+//	today, yesterday           the day
+//	this-week, last-week       the week, starting on Monday
+//	this-month, last-month     the month
+//	this-year, last-year       the year
+//	7d                         the last 7 hours (h), days (d), weeks (w), months (m) or years (y)
+//	>P, >=P, <P, <=P           after, from, before or until the period P
+//	P1..P2                     from P1 until P2, either can be left out
+//
+// Dates are in the local time zone of the server.
+func addedQuery(v string) (query.Query, error) {
+	t := now()
+	var from, to time.Time
+	switch {
+	case durationRe.MatchString(v):
+		from = durationStart(v, t)
+	case strings.HasPrefix(v, ">="):
+		p, err := parsePeriod(v[2:], t)
+		if err != nil {
+			return nil, err
+		}
+		from = p.start
+	case strings.HasPrefix(v, ">"):
+		p, err := parsePeriod(v[1:], t)
+		if err != nil {
+			return nil, err
+		}
+		from = p.end
+	case strings.HasPrefix(v, "<="):
+		p, err := parsePeriod(v[2:], t)
+		if err != nil {
+			return nil, err
+		}
+		to = p.end
+	case strings.HasPrefix(v, "<"):
+		p, err := parsePeriod(v[1:], t)
+		if err != nil {
+			return nil, err
+		}
+		to = p.start
+	case strings.Contains(v, ".."):
+		a, b, _ := strings.Cut(v, "..")
+		if a == "" && b == "" {
+			return nil, errors.New("empty date range")
+		}
+		if a != "" {
+			p, err := parsePeriod(a, t)
+			if err != nil {
+				return nil, err
+			}
+			from = p.start
+		}
+		if b != "" {
+			p, err := parsePeriod(b, t)
+			if err != nil {
+				return nil, err
+			}
+			to = p.end
+		}
+		if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+			return nil, fmt.Errorf("date range %q ends before it starts", v)
+		}
+	default:
+		p, err := parsePeriod(v, t)
+		if err != nil {
+			return nil, err
+		}
+		from, to = p.start, p.end
+	}
+	var min, max *float64
+	if !from.IsZero() {
+		min = new(float64(from.Unix()))
+	}
+	if !to.IsZero() {
+		max = new(float64(to.Unix()))
+	}
+	q := bleve.NewNumericRangeInclusiveQuery(min, max, new(true), new(false))
+	q.SetField("added")
+	return q, nil
+}
+
+// durationStart returns the start of the last n hours, days, weeks, months
+// or years described by a value matching durationRe.
+func durationStart(v string, t time.Time) time.Time {
+	m := durationRe.FindStringSubmatch(v)
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		// only overflows are possible, which cover all the documents
+		return time.Unix(0, 0)
+	}
+	switch m[2] {
+	case "h":
+		return t.Add(-time.Duration(n) * time.Hour)
+	case "d":
+		return t.AddDate(0, 0, -n)
+	case "w":
+		return t.AddDate(0, 0, -7*n)
+	case "m":
+		return t.AddDate(0, -n, 0)
+	}
+	return t.AddDate(-n, 0, 0)
+}
+
+// parsePeriod parses a date or a named period relative to t.
+func parsePeriod(s string, t time.Time) (period, error) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	// days since Monday
+	wd := (int(t.Weekday()) + 6) % 7
+	week := day.AddDate(0, 0, -wd)
+	month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	year := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	switch s {
+	case "today":
+		return period{day, day.AddDate(0, 0, 1)}, nil
+	case "yesterday":
+		return period{day.AddDate(0, 0, -1), day}, nil
+	case "this-week":
+		return period{week, week.AddDate(0, 0, 7)}, nil
+	case "last-week":
+		return period{week.AddDate(0, 0, -7), week}, nil
+	case "this-month":
+		return period{month, month.AddDate(0, 1, 0)}, nil
+	case "last-month":
+		return period{month.AddDate(0, -1, 0), month}, nil
+	case "this-year":
+		return period{year, year.AddDate(1, 0, 0)}, nil
+	case "last-year":
+		return period{year.AddDate(-1, 0, 0), year}, nil
+	}
+	for _, l := range []struct {
+		layout              string
+		years, months, days int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	} {
+		if len(s) != len(l.layout) {
+			continue
+		}
+		d, err := time.ParseInLocation(l.layout, s, t.Location())
+		if err != nil {
+			break
+		}
+		return period{d, d.AddDate(l.years, l.months, l.days)}, nil
+	}
+	if durationRe.MatchString(s) {
+		return period{}, fmt.Errorf("relative date %q can only be used alone", s)
+	}
+	return period{}, fmt.Errorf("invalid date %q", s)
+}
@@ -0,0 +1,111 @@
+package querybuilder
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// fixNow sets the reference time of relative dates for the test.
+func fixNow(t *testing.T, ref time.Time) {
+	t.Helper()
+	orig := now
+	now = func() time.Time { return ref }
+	t.Cleanup(func() { now = orig })
+}
+
+// addedBounds builds q and returns the bounds of its single added: range,
+// 0 standing for an open bound.
+func addedBounds(t *testing.T, q string) (int64, int64) {
+	t.Helper()
+	bq := buildBoolQ(t, q)
+	nq := asNumericRange(t, mustClauses(t, bq)[0])
+	if nq.FieldVal != "added" {
+		t.Fatalf("%s: expected field %q, got %q", q, "added", nq.FieldVal)
+	}
+	if nq.InclusiveMin != nil && !*nq.InclusiveMin || nq.InclusiveMax != nil && *nq.InclusiveMax {
+		t.Fatalf("%s: expected a [min, max) range", q)
+	}
+	var min, max int64
+	if nq.Min != nil {
+		min = int64(*nq.Min)
+	}
+	if nq.Max != nil {
+		max = int64(*nq.Max)
+	}
+	return min, max
+}
+
+func Test_build_added(t *testing.T) {
+	// Wednesday
+	ref := time.Date(2025, 7, 16, 15, 30, 0, 0, time.Local)
+	fixNow(t, ref)
+	date := func(y int, m time.Month, d int) int64 {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.Local).Unix()
+	}
+	tests := []struct {
+		q        string
+		min, max int64
+	}{
+		{"added:2025-01-01", date(2025, 1, 1), date(2025, 1, 2)},
+		{"added:2025-03", date(2025, 3, 1), date(2025, 4, 1)},
+		{"added:2024", date(2024, 1, 1), date(2025, 1, 1)},
+		{"added:>2025-01-01", date(2025, 1, 2), 0},
+		{"added:>=2025-01", date(2025, 1, 1), 0},
+		{"added:<2025-01-01", 0, date(2025, 1, 1)},
+		{"added:<=2025-01", 0, date(2025, 2, 1)},
+		{"added:2025-03..2025-06", date(2025, 3, 1), date(2025, 7, 1)},
+		{"added:2025-03..", date(2025, 3, 1), 0},
+		{"added:..2024", 0, date(2025, 1, 1)},
+		{"added:today", date(2025, 7, 16), date(2025, 7, 17)},
+		{"added:yesterday", date(2025, 7, 15), date(2025, 7, 16)},
+		{"added:this-week", date(2025, 7, 14), date(2025, 7, 21)},
+		{"added:last-week", date(2025, 7, 7), date(2025, 7, 14)},
+		{"added:this-month", date(2025, 7, 1), date(2025, 8, 1)},
+		{"added:last-month", date(2025, 6, 1), date(2025, 7, 1)},
+		{"added:last-year", date(2024, 1, 1), date(2025, 1, 1)},
+		{"added:>=last-month", date(2025, 6, 1), 0},
+		{"added:2025-01..yesterday", date(2025, 1, 1), date(2025, 7, 16)},
+		{"added:7d", ref.AddDate(0, 0, -7).Unix(), 0},
+		{"added:2w", ref.AddDate(0, 0, -14).Unix(), 0},
+		{"added:3m", ref.AddDate(0, -3, 0).Unix(), 0},
+		{"added:12h", ref.Add(-12 * time.Hour).Unix(), 0},
+	}
+	for _, tc := range tests {
+		min, max := addedBounds(t, tc.q)
+		if min != tc.min || max != tc.max {
+			t.Errorf("%s: expected [%d, %d), got [%d, %d)", tc.q, tc.min, tc.max, min, max)
+		}
+	}
+}
+
+func Test_build_added_negated(t *testing.T) {
+	bq := buildBoolQ(t, "golang -added:last-month")
+	if _, ok := mustNotClauses(t, bq)[0].(*query.NumericRangeQuery); !ok {
+		t.Fatalf("expected the date range in the must_not clauses")
+	}
+}
+
+func Test_build_added_errors(t *testing.T) {
+	tests := map[string]string{
+		"golang added:yesteday":   `invalid query: invalid date "yesteday" at position 8`,
+		"added:2025-13":           `invalid query: invalid date "2025-13" at position 1`,
+		"added:..":                `invalid query: empty date range at position 1`,
+		"added:2025-06..2025-03":  `invalid query: date range "2025-06..2025-03" ends before it starts at position 1`,
+		"a OR added:>7d":          `invalid query: relative date "7d" can only be used alone at position 6`,
+		"(a added:2025-01-01..x)": `invalid query: invalid date "x" at position 4`,
+	}
+	for q, want := range tests {
+		_, err := Build(q)
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("Build(%q): expected *ParseError, got %v", q, err)
+			continue
+		}
+		if err.Error() != want {
+			t.Errorf("Build(%q): got error %q, want %q", q, err, want)
+		}
+	}
+}
@@ -207,6 +207,7 @@ func (l *Lexer) readWord() (Token, error) {
 	return Token{Type: tt, Value: builder.String()}, nil
 }
 
+// Tokenize splits input into words, quoted phrases and alternation groups.
 func Tokenize(input string) ([]Token, error) {
 	lexer := New(input)
 	tokens := []Token{}
@@ -224,3 +225,400 @@ func Tokenize(input string) ([]Token, error) {
 
 	return tokens, nil
 }
+
+// NodeType is the type of a node of a parsed query.
+type NodeType int
+
+const (
+	// NodeTerm is a word or quoted phrase, optionally negated with a
+	// leading "-" or prefixed by a field.
+	NodeTerm NodeType = iota
+	// NodeAnd matches the documents matching all of its children.
+	NodeAnd
+	// NodeOr matches the documents matching any of its children.
+	NodeOr
+	// NodeNot matches the documents not matching its only child.
+	NodeNot
+)
+
+var nodeTypeNames = [...]string{"term", "and", "or", "not"}
+
+func (t NodeType) String() string {
+	if t < 0 || int(t) >= len(nodeTypeNames) {
+		return fmt.Sprintf("NodeType(%d)", int(t))
+	}
+	return nodeTypeNames[t]
+}
+
+// MarshalText encodes the node types of syntax trees returned in JSON by
+// their names.
+func (t NodeType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *NodeType) UnmarshalText(b []byte) error {
+	for i, n := range nodeTypeNames {
+		if n == string(b) {
+			*t = NodeType(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown node type %q", b)
+}
+
+// Node is a node of the syntax tree returned by Parse.
+type Node struct {
+	Type     NodeType `json:"type"`
+	Token    Token    `json:"-"` // set for NodeTerm
+	Raw      string   `json:"raw,omitempty"`
+	Pos      int      `json:"pos,omitempty"` // position of a NodeTerm, starting from 1
+	Children []*Node  `json:"children,omitempty"`
+}
+
+// String returns the query of the node with explicit operators and the
+// parentheses needed to keep its precedence.
+func (n *Node) String() string {
+	switch n.Type {
+	case NodeTerm:
+		return n.Raw
+	case NodeNot:
+		return "NOT " + n.Children[0].operand(NodeNot)
+	}
+	op := " AND "
+	if n.Type == NodeOr {
+		op = " OR "
+	}
+	parts := make([]string, len(n.Children))
+	for i, c := range n.Children {
+		parts[i] = c.operand(n.Type)
+	}
+	return strings.Join(parts, op)
+}
+
+// operand returns the string of n as an operand of a parent node, in
+// parentheses when n binds looser than the parent.
+func (n *Node) operand(parent NodeType) string {
+	if n.Type == NodeTerm || n.Type == NodeNot || n.Type == parent {
+		return n.String()
+	}
+	if n.Type == NodeAnd && parent == NodeOr {
+		return n.String()
+	}
+	return "(" + n.String() + ")"
+}
+
+// ParseError describes an invalid query. Pos is the position of the
+// offending character, starting from 1.
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid query: %s at position %d", e.Msg, e.Pos)
+}
+
+type itemType int
+
+const (
+	itemEOF itemType = iota
+	itemTerm
+	itemLParen
+	itemRParen
+	itemAnd
+	itemOr
+	itemNot
+)
+
+// item is a lexical item of the query grammar.
+type item struct {
+	typ   itemType
+	pos   int
+	raw   string
+	token Token
+}
+
+// nextItem returns the next item of the query grammar. Unlike NextToken,
+// it returns parentheses, "|" and the AND, OR and NOT keywords as separate
+// items. Parentheses inside a word, like in title:(a|b), stay part of the
+// word.
+func (l *Lexer) nextItem() item {
+	l.skipWhitespace()
+	start := l.pos - 1
+	it := item{pos: start + 1}
+	switch l.char {
+	case 0:
+		return it
+	case '(':
+		it.typ = itemLParen
+		l.readChar()
+	case ')':
+		it.typ = itemRParen
+		l.readChar()
+	case '|':
+		it.typ = itemOr
+		l.readChar()
+	case '-':
+		if l.peekChar() == '(' {
+			it.typ = itemNot
+			l.readChar()
+			break
+		}
+		it.typ = itemTerm
+		it.token = l.readTerm()
+	case '"':
+		it.typ = itemTerm
+		// readQuoted only fails on unclosed strings, which it accepts
+		it.token, _ = l.readQuoted()
+	default:
+		it.typ = itemTerm
+		it.token = l.readTerm()
+	}
+	it.raw = string(l.input[start : l.pos-1])
+	if it.typ == itemTerm {
+		switch it.raw {
+		case "AND":
+			it.typ = itemAnd
+		case "OR":
+			it.typ = itemOr
+		case "NOT":
+			it.typ = itemNot
+		}
+	}
+	return it
+}
+
+// readTerm reads a word like readWord, but stops at the ")" and "|"
+// operators. Parenthesized groups and /regexp/ terms are read verbatim.
+func (l *Lexer) readTerm() Token {
+	var builder strings.Builder
+	tt := TokenWord
+
+	quote := false
+	escaped := false
+	for l.char != 0 {
+		if !quote && !escaped {
+			if unicode.IsSpace(l.char) || l.char == ')' || l.char == '|' {
+				break
+			}
+			end := -1
+			switch {
+			case l.char == '(':
+				end = l.closingParen()
+			case l.char == '/' && regexpStart(builder.String()):
+				end = l.closingSlash()
+			}
+			if end >= 0 {
+				for l.pos-1 <= end {
+					builder.WriteRune(l.char)
+					l.readChar()
+				}
+				continue
+			}
+		}
+		skip := false
+		if l.char == '"' && !escaped {
+			tt = TokenQuoted
+			skip = true
+			quote = !quote
+		}
+		if l.char == '\\' && !escaped {
+			escaped = true
+		} else {
+			escaped = false
+			if !skip {
+				builder.WriteRune(l.char)
+			}
+		}
+		l.readChar()
+	}
+
+	return Token{Type: tt, Value: builder.String()}
+}
+
+// closingParen returns the index of the parenthesis closing the one at the
+// current character, or -1.
+func (l *Lexer) closingParen() int {
+	depth := 0
+	for i := l.pos - 1; i < len(l.input); i++ {
+		switch l.input[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// regexpStart reports whether a /regexp/ can start after the beginning of a
+// word, which can be a negation and a field.
+func regexpStart(word string) bool {
+	word = strings.TrimPrefix(word, "-")
+	return word == "" || strings.HasSuffix(word, ":")
+}
+
+// closingSlash returns the index of the slash ending the regexp starting at
+// the current character, or -1. The regexp ends with the first unescaped
+// slash, which must be followed by the end of the term, and cannot contain
+// whitespace.
+func (l *Lexer) closingSlash() int {
+	for i := l.pos; i < len(l.input); i++ {
+		switch c := l.input[i]; {
+		case c == '\\':
+			i++
+		case unicode.IsSpace(c):
+			return -1
+		case c == '/' && i > l.pos:
+			if i+1 == len(l.input) || unicode.IsSpace(l.input[i+1]) || l.input[i+1] == ')' || l.input[i+1] == '|' {
+				return i
+			}
+			return -1
+		}
+	}
+	return -1
+}
+
+// parser is a recursive-descent parser of the query grammar:
+//
+//	query   = or
+//	or      = and { ( "OR" | "|" ) and }
+//	and     = unary { [ "AND" ] unary }
+//	unary   = ( "NOT" | "-" ) unary | primary
+//	primary = "(" or ")" | term
+type parser struct {
+	lex  *Lexer
+	cur  item
+	prev item
+}
+
+// Parse parses a query into its syntax tree. Terms next to each other are
+// combined with AND, which binds tighter than OR, while NOT binds tighter
+// than both. The keywords are only recognised in upper case. Parse returns
+// nil for a query without terms.
+func Parse(input string) (*Node, error) {
+	p := &parser{lex: New(input)}
+	p.next()
+	if p.cur.typ == itemEOF {
+		return nil, nil
+	}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.cur.typ != itemEOF {
+		return nil, p.errorf(p.cur, "unexpected %q", p.cur.raw)
+	}
+	return n, nil
+}
+
+func (p *parser) next() {
+	p.prev = p.cur
+	p.cur = p.lex.nextItem()
+}
+
+func (p *parser) errorf(it item, format string, args ...any) error {
+	return &ParseError{Pos: it.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parseOr() (*Node, error) {
+	n, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.cur.typ == itemOr {
+		p.next()
+		c, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		n = join(NodeOr, n, c)
+	}
+	return n, nil
+}
+
+func (p *parser) parseAnd() (*Node, error) {
+	n, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.cur.typ {
+		case itemAnd:
+			p.next()
+		case itemTerm, itemLParen, itemNot:
+		default:
+			return n, nil
+		}
+		c, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		n = join(NodeAnd, n, c)
+	}
+}
+
+func (p *parser) parseUnary() (*Node, error) {
+	if p.cur.typ != itemNot {
+		return p.parsePrimary()
+	}
+	p.next()
+	c, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &Node{Type: NodeNot, Children: []*Node{c}}, nil
+}
+
+func (p *parser) parsePrimary() (*Node, error) {
+	switch p.cur.typ {
+	case itemTerm:
+		n := &Node{Type: NodeTerm, Token: p.cur.token, Raw: p.cur.raw, Pos: p.cur.pos}
+		p.next()
+		return n, nil
+	case itemLParen:
+		open := p.cur
+		p.next()
+		if p.cur.typ == itemRParen {
+			return nil, p.errorf(open, "empty parentheses")
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.cur.typ != itemRParen {
+			return nil, p.errorf(open, "unclosed %q", "(")
+		}
+		p.next()
+		return n, nil
+	case itemAnd, itemOr:
+		return nil, p.errorf(p.cur, "missing term before %q", p.cur.raw)
+	}
+	switch p.prev.typ {
+	case itemAnd, itemOr, itemNot:
+		return nil, p.errorf(p.prev, "missing term after %q", p.prev.raw)
+	case itemLParen:
+		return nil, p.errorf(p.prev, "unclosed %q", "(")
+	}
+	if p.cur.typ == itemEOF {
+		return nil, p.errorf(p.cur, "unexpected end of query")
+	}
+	return nil, p.errorf(p.cur, "unexpected %q", p.cur.raw)
+}
+
+// join combines a and b with the operator t, merging the children of the
+// nodes of the same type.
+func join(t NodeType, a, b *Node) *Node {
+	n := &Node{Type: t}
+	for _, c := range []*Node{a, b} {
+		if c.Type == t {
+			n.Children = append(n.Children, c.Children...)
+		} else {
+			n.Children = append(n.Children, c)
+		}
+	}
+	return n
+}
@@ -1,6 +1,10 @@
 package querybuilder
 
-import "testing"
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
 
 func Test_tokenize_arabic_word(t *testing.T) {
 	tokens, err := Tokenize("سلام")
@@ -250,3 +254,111 @@ func Test_tokenize_alternation_single_part(t *testing.T) {
 		t.Fatalf("expected 1 part, got %d", len(tokens[0].Parts))
 	}
 }
+
+func Test_parse_precedence(t *testing.T) {
+	tests := map[string]string{
+		"a b":                              "a AND b",
+		"a OR b c":                         "a OR b AND c",
+		"(a OR b) c":                       "(a OR b) AND c",
+		"a|b":                              "a OR b",
+		"NOT a b":                          "NOT a AND b",
+		"NOT (a OR b)":                     "NOT (a OR b)",
+		"-(a b) c":                         "NOT (a AND b) AND c",
+		"a AND (b OR (c d))":               "a AND (b OR c AND d)",
+		"((a))":                            "a",
+		"a OR b OR (c OR d)":               "a OR b OR c OR d",
+		"and or not":                       "and AND or AND not",
+		`"AND" -b`:                         `"AND" AND -b`,
+		"domain:(a.com|b.com) title:(x y)": "domain:(a.com|b.com) AND title:(x y)",
+		"url:https://x.org/Go_(lang)":      "url:https://x.org/Go_(lang)",
+		"/go(lang|pher)/ x":                "/go(lang|pher)/ AND x",
+		"-title:/a|b/|c":                   "-title:/a|b/ OR c",
+		`(/a\/b|c/)`:                       `/a\/b|c/`,
+		"/a b/":                            "/a AND b/",
+	}
+	for q, want := range tests {
+		n, err := Parse(q)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", q, err)
+			continue
+		}
+		if got := n.String(); got != want {
+			t.Errorf("Parse(%q) = %q, want %q", q, got, want)
+		}
+	}
+}
+
+func Test_parse_terms(t *testing.T) {
+	n, err := Parse(`(golang OR "go lang") -tutorial`)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if n.Type != NodeAnd || len(n.Children) != 2 {
+		t.Fatalf("expected AND node with 2 children, got %v", n)
+	}
+	or := n.Children[0]
+	if or.Type != NodeOr || len(or.Children) != 2 {
+		t.Fatalf("expected OR node with 2 children, got %v", or)
+	}
+	if tok := or.Children[1].Token; tok.Type != TokenQuoted || tok.Value != "go lang" {
+		t.Fatalf("expected quoted token %q, got %v", "go lang", tok)
+	}
+	if tok := n.Children[1].Token; tok.Type != TokenWord || tok.Value != "-tutorial" {
+		t.Fatalf("expected word token %q, got %v", "-tutorial", tok)
+	}
+}
+
+func Test_parse_json(t *testing.T) {
+	n, err := Parse(`go -(rust | c)`)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	b, err := json.Marshal(n)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	want := `{"type":"and","children":[{"type":"term","raw":"go","pos":1},` +
+		`{"type":"not","children":[{"type":"or","children":[{"type":"term","raw":"rust","pos":6},{"type":"term","raw":"c","pos":13}]}]}]}`
+	if string(b) != want {
+		t.Fatalf("unexpected JSON\n got: %s\nwant: %s", b, want)
+	}
+	var d *Node
+	if err := json.Unmarshal(b, &d); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if d.String() != n.String() {
+		t.Fatalf("expected decoded tree %q, got %q", n, d)
+	}
+}
+
+func Test_parse_empty(t *testing.T) {
+	n, err := Parse("  ")
+	if n != nil || err != nil {
+		t.Fatalf("expected nil node and error, got %v %v", n, err)
+	}
+}
+
+func Test_parse_errors(t *testing.T) {
+	tests := map[string]string{
+		"(a OR b":   `invalid query: unclosed "(" at position 1`,
+		"a (":       `invalid query: unclosed "(" at position 3`,
+		"a b)":      `invalid query: unexpected ")" at position 4`,
+		"()":        `invalid query: empty parentheses at position 1`,
+		"a OR":      `invalid query: missing term after "OR" at position 3`,
+		"OR a":      `invalid query: missing term before "OR" at position 1`,
+		"a AND | b": `invalid query: missing term before "|" at position 7`,
+		"a NOT":     `invalid query: missing term after "NOT" at position 3`,
+		"(a OR )":   `invalid query: missing term after "OR" at position 4`,
+	}
+	for q, want := range tests {
+		_, err := Parse(q)
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("Parse(%q): expected *ParseError, got %v", q, err)
+			continue
+		}
+		if err.Error() != want {
+			t.Errorf("Parse(%q): got error %q, want %q", q, err, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package indexer
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/asciimoo/hister/server/document"
+)
+
+func TestSortOrder(t *testing.T) {
+	cases := []struct {
+		sort string
+		want []string
+	}{
+		{"", nil},
+		{"score", nil},
+		{"added", []string{"added", "_id"}},
+		{"-added", []string{"-added", "_id"}},
+		{"title", []string{"title_sort", "_id"}},
+		{"-title", []string{"-title_sort", "_id"}},
+		{"url", []string{"url", "_id"}},
+		{"domain", []string{"domain", "_id"}},
+		{"metadata.link_checked", []string{"metadata.link_checked", "_id"}},
+		{"-metadata.link_checked", []string{"-metadata.link_checked", "_id"}},
+	}
+	for _, tc := range cases {
+		got, err := sortOrder(tc.sort)
+		if err != nil {
+			t.Errorf("sortOrder(%q) failed: %v", tc.sort, err)
+			continue
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("sortOrder(%q) = %v, expected %v", tc.sort, got, tc.want)
+		}
+	}
+	for _, s := range []string{"-", "-score", "text", "title_sort", "_id", "metadata.", "metadata.a b", "Added", "--added"} {
+		if _, err := sortOrder(s); !errors.Is(err, ErrInvalidSort) {
+			t.Errorf("sortOrder(%q) error = %v, expected ErrInvalidSort", s, err)
+		}
+	}
+}
+
+func TestSearchSort(t *testing.T) {
+	newTestIndexer(t)
+	docs := []*document.Document{
+		{URL: "https://b.test/2", Title: "Beta", Text: "sorted results"},
+		{URL: "https://a.test/1", Title: "alpha", Text: "sorted results"},
+		{URL: "https://c.test/3", Title: "Gamma", Text: "sorted results"},
+		{URL: "https://a.test/4", Title: "Delta", Text: "sorted results"},
+	}
+	addTestDocs(t, docs...)
+	// the last two documents share their indexing time, the document ID
+	// breaks the tie
+	for n, added := range []int64{300, 100, 200, 200} {
+		if err := setAdded(docs[n].ID(), added); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the last document has no rank, it is listed last in both directions
+	for n, rank := range []float64{2, 3, 1} {
+		if err := SetMetadata(docs[n].ID(), map[string]any{"rank": rank}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		sort string
+		want []string
+	}{
+		{"added", []string{"https://a.test/1", "https://a.test/4", "https://c.test/3", "https://b.test/2"}},
+		{"-added", []string{"https://b.test/2", "https://a.test/4", "https://c.test/3", "https://a.test/1"}},
+		{"title", []string{"https://a.test/1", "https://b.test/2", "https://a.test/4", "https://c.test/3"}},
+		{"-title", []string{"https://c.test/3", "https://a.test/4", "https://b.test/2", "https://a.test/1"}},
+		{"url", []string{"https://a.test/1", "https://a.test/4", "https://b.test/2", "https://c.test/3"}},
+		{"-url", []string{"https://c.test/3", "https://b.test/2", "https://a.test/4", "https://a.test/1"}},
+		{"-domain", []string{"https://c.test/3", "https://b.test/2", "https://a.test/1", "https://a.test/4"}},
+		{"metadata.rank", []string{"https://c.test/3", "https://b.test/2", "https://a.test/1", "https://a.test/4"}},
+		{"-metadata.rank", []string{"https://a.test/1", "https://b.test/2", "https://c.test/3", "https://a.test/4"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.sort, func(t *testing.T) {
+			r, err := Search(nil, &Query{Text: "sorted", Sort: tc.sort})
+			if err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+			if got := resultURLs(r); !slices.Equal(got, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			// page by page, each page continues after the last hit of the
+			// previous one
+			for _, limit := range []int{1, 3} {
+				var got []string
+				pageKey := ""
+				for range len(tc.want) + 1 {
+					r, err := Search(nil, &Query{Text: "sorted", Sort: tc.sort, Limit: limit, PageKey: pageKey})
+					if err != nil {
+						t.Fatalf("search failed: %v", err)
+					}
+					if len(r.Documents) == 0 {
+						break
+					}
+					got = append(got, resultURLs(r)...)
+					pageKey = r.PageKey
+				}
+				if !slices.Equal(got, tc.want) {
+					t.Errorf("expected %v in pages of %d, got %v", tc.want, limit, got)
+				}
+			}
+		})
+	}
+
+	if _, err := Search(nil, &Query{Text: "sorted", Sort: "text"}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("expected ErrInvalidSort, got %v", err)
+	}
+}
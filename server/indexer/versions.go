@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package indexer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/asciimoo/hister/server/document"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/rs/zerolog/log"
+)
+
+// versionsIndexerName is the Bleve index holding superseded snapshots of
+// documents. It is deliberately kept out of indexer.indexers so that regular
+// searches, reindexing and language routing never see it.
+const versionsIndexerName = "versions.db"
+
+// VersionInfo describes a single stored snapshot of a document. Snapshots
+// are numbered from 1 in the order they are stored, the current document is
+// version 0.
+type VersionInfo struct {
+	Version int64  `json:"version"`
+	Added   int64  `json:"added"`
+	Title   string `json:"title"`
+	Hash    string `json:"hash"`
+	Length  int    `json:"length"`
+	Current bool   `json:"current"`
+}
+
+// storedVersion is a snapshot read from the versions index.
+type storedVersion struct {
+	id      string
+	version int64
+	doc     *document.Document
+}
+
+func openVersionIndex(basePath string) (bleve.Index, error) {
+	p := filepath.Join(basePath, versionsIndexerName)
+	if _, err := os.Stat(p); err == nil {
+		return bleve.OpenUsing(p, bleveRuntimeConfig())
+	}
+	return bleve.NewUsing(p, createVersionMapping(), bleve.Config.DefaultIndexType, bleve.Config.DefaultMemKVStore, bleveRuntimeConfig())
+}
+
+func createVersionMapping() mapping.IndexMapping {
+	im := createMapping("default").(*mapping.IndexMappingImpl)
+	vm := bleve.NewTextFieldMapping()
+	vm.Analyzer = keyword.Name
+	vm.Store = true
+	vm.IncludeTermVectors = false
+	vm.IncludeInAll = false
+	im.DefaultMapping.AddFieldMappingsAt("version_of", vm)
+	im.DefaultMapping.AddFieldMappingsAt("version", bleve.NewNumericFieldMapping())
+	return im
+}
+
+// VersionsEnabled reports whether document snapshots are recorded.
+func VersionsEnabled() bool {
+	return i != nil && i.versions != nil
+}
+
+func contentHash(d *document.Document) string {
+	h := sha256.New()
+	h.Write([]byte(d.Title))
+	h.Write([]byte{0})
+	h.Write([]byte(d.Text))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func versionID(docID string, version int64) string {
+	return fmt.Sprintf("%s#v%d", docID, version)
+}
+
+// snapshot moves the currently indexed copy of d into the versions index
+// when its content differs from d. Unchanged revisits are not recorded.
+func (i *indexer) snapshot(d *document.Document) {
+	if i.versions == nil {
+		return
+	}
+	i.versionsMu.Lock()
+	defer i.versionsMu.Unlock()
+	prev := i.getByDocID(d.ID())
+	if prev == nil || contentHash(prev) == contentHash(d) {
+		return
+	}
+	if err := i.storeVersion(prev); err != nil {
+		log.Warn().Err(err).Str("url", d.URL).Msg("failed to store document version")
+		return
+	}
+	i.pruneVersions(d.ID())
+}
+
+// storeVersion stores d as the next version of its document.
+func (i *indexer) storeVersion(d *document.Document) error {
+	vs, err := i.storedVersions(d.ID())
+	if err != nil {
+		return err
+	}
+	version := int64(1)
+	if len(vs) > 0 {
+		version = vs[0].version + 1
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	var v map[string]any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	delete(v, "favicon")
+	delete(v, "score")
+	meta, _ := v["metadata"].(map[string]any)
+	if meta == nil {
+		meta = make(map[string]any)
+	}
+	meta["version"] = version
+	meta["content_hash"] = contentHash(d)
+	v["metadata"] = meta
+	v["version_of"] = d.ID()
+	v["version"] = version
+	return i.versions.Index(versionID(d.ID(), version), v)
+}
+
+// storedVersions returns the snapshots of docID, newest first.
+func (i *indexer) storedVersions(docID string) ([]*storedVersion, error) {
+	q := bleve.NewTermQuery(docID)
+	q.SetField("version_of")
+	req := bleve.NewSearchRequest(q)
+	req.Fields = []string{"title", "text", "added", "version"}
+	req.Size = 10000
+	req.SortBy([]string{"-version", "-added"})
+	res, err := i.versions.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	vs := make([]*storedVersion, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		v, _ := h.Fields["version"].(float64)
+		vs = append(vs, &storedVersion{id: h.ID, version: int64(v), doc: docFromHit(h)})
+	}
+	return vs, nil
+}
+
+func (i *indexer) pruneVersions(docID string) {
+	if i.maxVersions <= 0 {
+		return
+	}
+	vs, err := i.storedVersions(docID)
+	if err != nil || len(vs) <= i.maxVersions {
+		return
+	}
+	b := i.versions.NewBatch()
+	for _, v := range vs[i.maxVersions:] {
+		b.Delete(v.id)
+	}
+	if err := i.versions.Batch(b); err != nil {
+		log.Warn().Err(err).Str("id", docID).Msg("failed to prune document versions")
+	}
+}
+
+func (i *indexer) deleteVersions(docID string) error {
+	if i.versions == nil {
+		return nil
+	}
+	vs, err := i.storedVersions(docID)
+	if err != nil || len(vs) == 0 {
+		return err
+	}
+	b := i.versions.NewBatch()
+	for _, v := range vs {
+		b.Delete(v.id)
+	}
+	return i.versions.Batch(b)
+}
+
+// ListVersions returns the current document and its stored snapshots,
+// newest first. Returns nil when the document is unknown.
+func ListVersions(docID string) ([]*VersionInfo, error) {
+	var r []*VersionInfo
+	if cur := i.getByDocID(docID); cur != nil {
+		r = append(r, &VersionInfo{
+			Added:   cur.Added,
+			Title:   cur.Title,
+			Hash:    contentHash(cur),
+			Length:  len(cur.Text),
+			Current: true,
+		})
+	}
+	if i.versions == nil {
+		return r, nil
+	}
+	vs, err := i.storedVersions(docID)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vs {
+		r = append(r, &VersionInfo{
+			Version: v.version,
+			Added:   v.doc.Added,
+			Title:   v.doc.Title,
+			Hash:    contentHash(v.doc),
+			Length:  len(v.doc.Text),
+		})
+	}
+	return r, nil
+}
+
+// GetVersion returns the snapshot version of docID. A version of 0 returns
+// the current document.
+func GetVersion(docID string, version int64) *document.Document {
+	if version == 0 {
+		return i.getByDocID(docID)
+	}
+	if i.versions == nil {
+		return nil
+	}
+	q := bleve.NewDocIDQuery([]string{versionID(docID, version)})
+	req := bleve.NewSearchRequest(q)
+	req.Fields = allFields
+	res, err := i.versions.Search(req)
+	if err != nil || len(res.Hits) < 1 {
+		return nil
+	}
+	return docFromHit(res.Hits[0])
+}
+
+func (i *indexer) searchIndex(allVersions bool) (bleve.Index, error) {
+	if !allVersions {
+		return i.idx, nil
+	}
+	if i.versions == nil {
+		return nil, errors.New("document versions are not enabled")
+	}
+	idxs := make([]bleve.Index, 0, len(i.indexers)+1)
+	for _, idx := range i.indexers {
+		idxs = append(idxs, idx)
+	}
+	idxs = append(idxs, i.versions)
+	return bleve.NewIndexAlias(idxs...), nil
+}
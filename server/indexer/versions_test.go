@@ -0,0 +1,109 @@
+package indexer
+
+import (
+	"testing"
+
+	"github.com/asciimoo/hister/server/document"
+)
+
+const testVersionURL = "https://example.com/page"
+
+// newTestVersionIndexer returns a test indexer keeping up to maxVersions
+// snapshots.
+func newTestVersionIndexer(t *testing.T, maxVersions int) *indexer {
+	t.Helper()
+	ti := newTestIndexer(t)
+	vs, err := openVersionIndex(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open versions index: %v", err)
+	}
+	ti.versions = vs
+	ti.maxVersions = maxVersions
+	return ti
+}
+
+// addTestRevisions indexes a revision of the test page for each text.
+func addTestRevisions(t *testing.T, texts ...string) {
+	t.Helper()
+	for _, text := range texts {
+		addTestDocs(t, &document.Document{URL: testVersionURL, Title: "Page", Text: text})
+	}
+}
+
+func TestVersionsStore(t *testing.T) {
+	newTestVersionIndexer(t, 0)
+	// the revisions are indexed within the same second, which must not
+	// overwrite the previous snapshots
+	addTestRevisions(t, "first", "second", "second", "third")
+
+	vs, err := ListVersions(testVersionURL)
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+	if len(vs) != 3 {
+		t.Fatalf("expected 3 versions, got %d", len(vs))
+	}
+	for n, want := range []int64{0, 2, 1} {
+		if vs[n].Version != want {
+			t.Fatalf("expected version %d at position %d, got %d", want, n, vs[n].Version)
+		}
+		if vs[n].Current != (n == 0) {
+			t.Fatalf("unexpected current flag of version %d", vs[n].Version)
+		}
+	}
+
+	for version, want := range map[int64]string{0: "third", 1: "first", 2: "second"} {
+		d := GetVersion(testVersionURL, version)
+		if d == nil {
+			t.Fatalf("version %d not found", version)
+		}
+		if d.Text != want {
+			t.Fatalf("expected text %q for version %d, got %q", want, version, d.Text)
+		}
+	}
+	if d := GetVersion(testVersionURL, 3); d != nil {
+		t.Fatalf("expected no version 3, got %q", d.Text)
+	}
+}
+
+func TestVersionsPrune(t *testing.T) {
+	newTestVersionIndexer(t, 2)
+	addTestRevisions(t, "first", "second", "third", "fourth")
+
+	vs, err := ListVersions(testVersionURL)
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+	if len(vs) != 3 {
+		t.Fatalf("expected the current and 2 stored versions, got %d", len(vs))
+	}
+	if d := GetVersion(testVersionURL, 1); d != nil {
+		t.Fatalf("expected version 1 to be pruned, got %q", d.Text)
+	}
+	for version, want := range map[int64]string{2: "second", 3: "third"} {
+		if d := GetVersion(testVersionURL, version); d == nil || d.Text != want {
+			t.Fatalf("expected text %q for version %d, got %v", want, version, d)
+		}
+	}
+
+	// numbering continues after pruning
+	addTestRevisions(t, "fifth")
+	if d := GetVersion(testVersionURL, 4); d == nil || d.Text != "fourth" {
+		t.Fatalf("expected text %q for version 4, got %v", "fourth", d)
+	}
+}
+
+func TestVersionsDelete(t *testing.T) {
+	ti := newTestVersionIndexer(t, 0)
+	addTestRevisions(t, "first", "second")
+	if err := ti.deleteVersions(testVersionURL); err != nil {
+		t.Fatalf("failed to delete versions: %v", err)
+	}
+	vs, err := ListVersions(testVersionURL)
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+	if len(vs) != 1 || !vs[0].Current {
+		t.Fatalf("expected only the current version, got %d versions", len(vs))
+	}
+}
@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+// Package linkcheck detects indexed web pages that have disappeared. The
+// outcome of each check is stored in the metadata of the document, so dead
+// pages can be found with the status:dead query filter.
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/crawler"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/indexer"
+)
+
+// Metadata keys written by Check.
+const (
+	MetaStatus     = "link_status"
+	MetaStatusCode = "link_status_code"
+	MetaChecked    = "link_checked"
+)
+
+// Link status values.
+const (
+	StatusAlive = "alive"
+	StatusDead  = "dead"
+	// StatusUnknown is recorded when the page could not be checked, e.g.
+	// because of a server error or timeout, which may be temporary.
+	StatusUnknown = "unknown"
+)
+
+// checkInterval is how often the background job looks for pages due for a
+// check.
+const checkInterval = time.Hour
+
+// ErrRunning is returned when a link check is started while another one,
+// manual or background, is running.
+var ErrRunning = errors.New("a link check is already running")
+
+// Summary counts the outcome of a link check run.
+type Summary struct {
+	Checked int `json:"checked"`
+	Alive   int `json:"alive"`
+	Dead    int `json:"dead"`
+	Unknown int `json:"unknown"`
+}
+
+// Progress reports the progress of the current or last link check.
+type Progress struct {
+	Running  bool      `json:"running"`
+	Started  time.Time `json:"started,omitzero"`
+	Finished time.Time `json:"finished,omitzero"`
+	// Due is the number of pages to check, known once they are listed.
+	Due int `json:"due"`
+	Summary
+	Error string `json:"error,omitempty"`
+}
+
+var (
+	progressMu sync.Mutex
+	progress   Progress
+)
+
+// Status returns the progress of the current or last link check.
+func Status() Progress {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	return progress
+}
+
+// updateProgress applies fn to the progress of the link check.
+func updateProgress(fn func(p *Progress)) {
+	progressMu.Lock()
+	fn(&progress)
+	progressMu.Unlock()
+}
+
+// begin marks a link check as running, unless one already is.
+func begin() bool {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	if progress.Running {
+		return false
+	}
+	progress = Progress{Running: true, Started: time.Now()}
+	return true
+}
+
+// Run checks the pages not checked for cfg.Crawler.LinkCheck.Interval every
+// checkInterval until ctx is cancelled. It returns immediately when the link
+// check is disabled.
+func Run(ctx context.Context, cfg *config.Config) error {
+	every := cfg.Crawler.LinkCheck.Every()
+	if every <= 0 {
+		return nil
+	}
+	log.Info().Msg("Starting background link check")
+	for {
+		s, err := Check(ctx, &cfg.Crawler, every)
+		switch {
+		case errors.Is(err, context.Canceled):
+		case errors.Is(err, ErrRunning):
+			log.Debug().Msg("linkcheck: skipping the background check, a check is already running")
+		case err != nil:
+			log.Error().Err(err).Msg("linkcheck: failed to check links")
+		case s.Checked > 0:
+			log.Info().Int("alive", s.Alive).Int("dead", s.Dead).Int("unknown", s.Unknown).Msg("Checked indexed links")
+		}
+		t := time.NewTimer(checkInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Start runs Check in the background. When a check is already running, it
+// returns its progress and ErrRunning.
+func Start(cfg *config.CrawlerConfig, maxAge time.Duration) (Progress, error) {
+	if !begin() {
+		return Status(), ErrRunning
+	}
+	go func() {
+		s, err := check(context.Background(), cfg, maxAge)
+		if err != nil {
+			log.Error().Err(err).Msg("linkcheck: failed to check links")
+			return
+		}
+		log.Info().Int("alive", s.Alive).Int("dead", s.Dead).Int("unknown", s.Unknown).Msg("Checked indexed links")
+	}()
+	return Status(), nil
+}
+
+// Check probes the indexed web pages last checked more than maxAge ago, or
+// all of them when maxAge is zero, and records the result in their metadata.
+// Pages disallowed by robots.txt are left unchecked. Only one check runs at
+// a time, ErrRunning is returned while another one is running.
+func Check(ctx context.Context, cfg *config.CrawlerConfig, maxAge time.Duration) (*Summary, error) {
+	if !begin() {
+		return nil, ErrRunning
+	}
+	return check(ctx, cfg, maxAge)
+}
+
+// check runs a link check marked as running by begin.
+func check(ctx context.Context, cfg *config.CrawlerConfig, maxAge time.Duration) (s *Summary, err error) {
+	s = &Summary{}
+	defer func() {
+		updateProgress(func(p *Progress) {
+			p.Running = false
+			p.Finished = time.Now()
+			p.Summary = *s
+			if err != nil {
+				p.Error = err.Error()
+			}
+		})
+	}()
+	rf, err := crawler.NewRefetcher(cfg)
+	if err != nil {
+		return s, err
+	}
+	now := time.Now()
+	var due []*document.Document
+	err = indexer.IterateWeb(func(d *document.Document) {
+		if maxAge > 0 {
+			if t, ok := d.Metadata[MetaChecked].(float64); ok && now.Sub(time.Unix(int64(t), 0)) < maxAge {
+				return
+			}
+		}
+		due = append(due, d)
+	}, "metadata."+MetaChecked)
+	if err != nil {
+		return s, err
+	}
+	updateProgress(func(p *Progress) { p.Due = len(due) })
+
+	for _, d := range due {
+		code, err := rf.Probe(ctx, d.URL)
+		if ctx.Err() != nil {
+			return s, ctx.Err()
+		}
+		if errors.Is(err, crawler.ErrRobotsDisallowed) {
+			continue
+		}
+		status := classify(code, err)
+		if err := indexer.SetMetadata(d.ID(), map[string]any{
+			MetaStatus:     status,
+			MetaStatusCode: code,
+			MetaChecked:    time.Now().Unix(),
+		}); err != nil {
+			return s, err
+		}
+		s.Checked++
+		switch status {
+		case StatusAlive:
+			s.Alive++
+		case StatusDead:
+			s.Dead++
+		default:
+			s.Unknown++
+		}
+		updateProgress(func(p *Progress) { p.Summary = *s })
+	}
+	return s, nil
+}
+
+// classify maps the outcome of a probe to a link status. Only answers
+// stating that the page is gone, and domains which no longer resolve, count
+// as dead.
+func classify(code int, err error) string {
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return StatusDead
+		}
+		return StatusUnknown
+	}
+	switch {
+	case code == http.StatusNotFound || code == http.StatusGone:
+		return StatusDead
+	case code < 400:
+		return StatusAlive
+	}
+	return StatusUnknown
+}
@@ -0,0 +1,50 @@
+package linkcheck
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		code int
+		err  error
+		want string
+	}{
+		{200, nil, StatusAlive},
+		{301, nil, StatusAlive},
+		{404, nil, StatusDead},
+		{410, nil, StatusDead},
+		{403, nil, StatusUnknown},
+		{503, nil, StatusUnknown},
+		{0, &net.DNSError{Err: "no such host", IsNotFound: true}, StatusDead},
+		{0, &net.DNSError{Err: "server misbehaving", IsTemporary: true}, StatusUnknown},
+		{0, errors.New("timeout"), StatusUnknown},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.code, tt.err); got != tt.want {
+			t.Errorf("classify(%d, %v) = %q, want %q", tt.code, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestSingleRun(t *testing.T) {
+	if !begin() {
+		t.Fatal("begin() = false with no running check")
+	}
+	t.Cleanup(func() { updateProgress(func(p *Progress) { *p = Progress{} }) })
+	if !Status().Running {
+		t.Error("Status().Running = false after begin()")
+	}
+	if _, err := Check(t.Context(), nil, 0); !errors.Is(err, ErrRunning) {
+		t.Errorf("Check() error = %v, want ErrRunning", err)
+	}
+	p, err := Start(nil, 0)
+	if !errors.Is(err, ErrRunning) {
+		t.Errorf("Start() error = %v, want ErrRunning", err)
+	}
+	if !p.Running {
+		t.Error("Start() progress is not running")
+	}
+}
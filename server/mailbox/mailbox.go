@@ -0,0 +1,284 @@
+// Package mailbox reads the messages of mbox files and Maildir folders.
+package mailbox
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ContentType is the MIME type of a single message.
+const ContentType = "message/rfc822"
+
+// ErrNotMailbox is returned for paths which are neither an mbox file nor a
+// directory.
+var ErrNotMailbox = errors.New("not an mbox file or directory")
+
+// Message is a message read from a mailbox.
+type Message struct {
+	// ID is the Message-ID of the message without angle brackets, or a
+	// hash of its content for messages without one.
+	ID string
+	// Date is the time the message was sent, zero when unknown.
+	Date time.Time
+	// Path is the mbox file or the Maildir file containing the message.
+	Path string
+	// Raw is the message with its headers, without the "From " line of
+	// mbox files.
+	Raw []byte
+}
+
+// URL returns the mid: URL (RFC 2392) identifying the message.
+func (m *Message) URL() string {
+	return URL(m.ID)
+}
+
+// URL returns the mid: URL (RFC 2392) of the message with the Message-ID id.
+func URL(id string) string {
+	return "mid:" + url.PathEscape(id)
+}
+
+// Messages returns the messages of the mailbox at root, an mbox file or a
+// directory. Directories are searched recursively for mbox files and
+// Maildir folders, including the subfolders of Maildir++. Files for which
+// unchanged returns true are not read, unchanged may be nil. Errors of
+// single files are yielded, and the iteration continues with the next file.
+func Messages(root string, unchanged func(path string, info fs.FileInfo) bool) iter.Seq2[*Message, error] {
+	return func(yield func(*Message, error) bool) {
+		info, err := os.Stat(root)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		if !info.IsDir() {
+			if !IsMbox(root) {
+				yield(nil, fmt.Errorf("%w: %s", ErrNotMailbox, root))
+				return
+			}
+			if unchanged == nil || !unchanged(root, info) {
+				readMbox(root, yield)
+			}
+			return
+		}
+		stop := errors.New("stop")
+		err = filepath.WalkDir(root, func(path string, e fs.DirEntry, err error) error {
+			if err != nil {
+				if !yield(nil, err) {
+					return stop
+				}
+				return nil
+			}
+			if e.IsDir() {
+				// The message files of Maildir folders are read with the
+				// folder.
+				if path != root && IsMaildir(filepath.Dir(path)) && maildirSubdirs[e.Name()] {
+					return filepath.SkipDir
+				}
+				if IsMaildir(path) && !readMaildir(path, unchanged, yield) {
+					return stop
+				}
+				return nil
+			}
+			if !e.Type().IsRegular() || !IsMbox(path) {
+				return nil
+			}
+			if unchanged != nil {
+				if info, err := e.Info(); err == nil && unchanged(path, info) {
+					return nil
+				}
+			}
+			if !readMbox(path, yield) {
+				return stop
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, stop) {
+			yield(nil, err)
+		}
+	}
+}
+
+// maildirSubdirs are the directories of a Maildir folder.
+var maildirSubdirs = map[string]bool{"cur": true, "new": true, "tmp": true}
+
+// IsMaildir reports whether dir is a Maildir folder.
+func IsMaildir(dir string) bool {
+	for _, sub := range []string{"cur", "new"} {
+		if info, err := os.Stat(filepath.Join(dir, sub)); err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// IsMbox reports whether the file at path is an mbox file, which starts with
+// a "From " line.
+func IsMbox(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(f, b); err != nil {
+		return false
+	}
+	return string(b) == "From "
+}
+
+// readMaildir yields the messages of the cur and new directories of the
+// Maildir folder dir. Returns false when the iteration was stopped.
+func readMaildir(dir string, unchanged func(string, fs.FileInfo) bool, yield func(*Message, error) bool) bool {
+	for _, sub := range []string{"cur", "new"} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			if !yield(nil, err) {
+				return false
+			}
+			continue
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(dir, sub, e.Name())
+			if unchanged != nil {
+				if info, err := e.Info(); err == nil && unchanged(path, info) {
+					continue
+				}
+			}
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				if !yield(nil, err) {
+					return false
+				}
+				continue
+			}
+			if !yield(newMessage(path, raw)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// readMbox yields the messages of the mbox file at path. Messages start
+// with a "From " line at the beginning of the file or after an empty line.
+// Lines quoted as ">From " are unquoted, following the mboxrd format.
+// Returns false when the iteration was stopped.
+func readMbox(path string, yield func(*Message, error) bool) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return yield(nil, err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var msg bytes.Buffer
+	started, blank := false, true
+	flush := func() bool {
+		if !started {
+			return true
+		}
+		raw := bytes.Clone(msg.Bytes())
+		msg.Reset()
+		// The empty line separating the messages is not part of them.
+		raw = bytes.TrimSuffix(raw, []byte("\n"))
+		raw = bytes.TrimSuffix(raw, []byte("\r"))
+		return yield(newMessage(path, raw))
+	}
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case blank && bytes.HasPrefix(line, []byte("From ")):
+				if !flush() {
+					return false
+				}
+				started = true
+			case started:
+				if q := bytes.TrimLeft(line, ">"); len(q) < len(line) && bytes.HasPrefix(q, []byte("From ")) {
+					line = line[1:]
+				}
+				msg.Write(line)
+			}
+			blank = len(bytes.TrimRight(line, "\r\n")) == 0
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return yield(nil, err)
+			}
+			break
+		}
+	}
+	return flush()
+}
+
+// newMessage parses the headers of raw, the message read from path.
+func newMessage(path string, raw []byte) (*Message, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid message in %s: %w", path, err)
+	}
+	msg := &Message{
+		ID:   MessageID(m.Header.Get("Message-Id")),
+		Path: path,
+		Raw:  raw,
+	}
+	if msg.ID == "" {
+		sum := sha256.Sum256(raw)
+		msg.ID = hex.EncodeToString(sum[:16]) + "@hister"
+	}
+	if t, err := mail.ParseDate(m.Header.Get("Date")); err == nil {
+		msg.Date = t
+	}
+	return msg, nil
+}
+
+// MessageID returns the first message ID of a Message-ID, In-Reply-To or
+// References header value, without angle brackets.
+func MessageID(v string) string {
+	ids := MessageIDs(v)
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[0]
+}
+
+// MessageIDs returns the message IDs of a References or In-Reply-To header
+// value, without angle brackets. Values without brackets are returned as a
+// single ID.
+func MessageIDs(v string) []string {
+	var ids []string
+	for {
+		_, rest, ok := strings.Cut(v, "<")
+		if !ok {
+			break
+		}
+		id, rest, ok := strings.Cut(rest, ">")
+		if !ok {
+			break
+		}
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+		v = rest
+	}
+	if len(ids) == 0 {
+		if v = strings.TrimSpace(v); v != "" && !strings.ContainsAny(v, " \t<>") {
+			ids = append(ids, v)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,111 @@
+package mailbox
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testMbox = `From jane@example.com Mon Jan  6 10:00:00 2025
+Message-ID: <1@example.com>
+Date: Mon, 6 Jan 2025 10:00:00 +0000
+Subject: first
+
+Hello
+>From the start
+
+From bob@example.com Mon Jan  6 11:00:00 2025
+Subject: no id
+
+Bye
+`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func collect(t *testing.T, root string, unchanged func(string, fs.FileInfo) bool) []*Message {
+	t.Helper()
+	var msgs []*Message
+	for m, err := range Messages(root, unchanged) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
+
+func TestReadMbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.mbox")
+	writeFile(t, path, testMbox)
+	msgs := collect(t, path, nil)
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	if msgs[0].ID != "1@example.com" || msgs[0].Date.Unix() != 1736157600 {
+		t.Fatalf("unexpected first message %q %v", msgs[0].ID, msgs[0].Date)
+	}
+	if !strings.HasSuffix(string(msgs[0].Raw), "\n\nHello\nFrom the start\n") {
+		t.Fatalf("unexpected first message body %q", msgs[0].Raw)
+	}
+	if !strings.HasSuffix(msgs[1].ID, "@hister") || !msgs[1].Date.IsZero() {
+		t.Fatalf("expected generated ID and no date, got %q %v", msgs[1].ID, msgs[1].Date)
+	}
+	if id := collect(t, path, nil)[1].ID; id != msgs[1].ID {
+		t.Fatalf("expected stable generated ID, got %q and %q", msgs[1].ID, id)
+	}
+	if msgs[0].URL() != "mid:1@example.com" {
+		t.Fatalf("unexpected URL %q", msgs[0].URL())
+	}
+}
+
+func TestReadDirectory(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "archive", "2025-01.mbox"), testMbox)
+	writeFile(t, filepath.Join(root, "archive", "notes.txt"), "not a mailbox")
+	msg := "Message-ID: <%s>\nSubject: maildir\n\nBody\n"
+	writeFile(t, filepath.Join(root, "Maildir", "cur", "1.host:2,S"), strings.Replace(msg, "%s", "cur@example.com", 1))
+	writeFile(t, filepath.Join(root, "Maildir", "new", "2.host"), strings.Replace(msg, "%s", "new@example.com", 1))
+	writeFile(t, filepath.Join(root, "Maildir", "tmp", "3.host"), strings.Replace(msg, "%s", "tmp@example.com", 1))
+	writeFile(t, filepath.Join(root, "Maildir", ".Lists", "cur", "4.host"), strings.Replace(msg, "%s", "sub@example.com", 1))
+	if err := os.MkdirAll(filepath.Join(root, "Maildir", ".Lists", "new"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, m := range collect(t, root, nil) {
+		ids = append(ids, m.ID)
+	}
+	slices.Sort(ids)
+	if len(ids) != 5 || !slices.Contains(ids, "1@example.com") || !slices.Contains(ids, "cur@example.com") ||
+		!slices.Contains(ids, "new@example.com") || !slices.Contains(ids, "sub@example.com") {
+		t.Fatalf("unexpected messages %v", ids)
+	}
+	unchanged := func(path string, _ fs.FileInfo) bool {
+		return strings.HasSuffix(path, ".mbox")
+	}
+	if msgs := collect(t, root, unchanged); len(msgs) != 3 {
+		t.Fatalf("expected unchanged mbox to be skipped, got %d messages", len(msgs))
+	}
+}
+
+func TestMessageIDs(t *testing.T) {
+	if ids := MessageIDs("<a@x> (comment)\n <b@x>"); !slices.Equal(ids, []string{"a@x", "b@x"}) {
+		t.Fatalf("unexpected IDs %v", ids)
+	}
+	if id := MessageID("c@x"); id != "c@x" {
+		t.Fatalf("expected bare ID, got %q", id)
+	}
+	if id := MessageID(""); id != "" {
+		t.Fatalf("expected no ID, got %q", id)
+	}
+}
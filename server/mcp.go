@@ -126,7 +126,10 @@ func mcpToolList() []map[string]any {
 						"type": "string",
 						"description": `Search query. Supports plain keywords, "exact phrases", ` +
 							`field filters (url:, domain:, title:, text:, language:, type:web/local), ` +
-							`negation (-term), wildcards (term*), and disjunction (a|b|c).`,
+							`date filters (added:2025-03, added:>2025-01-01, added:2025-03..2025-06, added:7d, added:last-month), ` +
+							`negation (-term), wildcards (term*), prefixes (title:term*), fuzzy terms (term~1, term~2), ` +
+							`regular expressions (/go(lang|pher)/, url:/.*\/issues\/[0-9]+/), ` +
+							`disjunction (a|b|c) and AND/OR/NOT with parentheses.`,
 					},
 					"limit": map[string]any{
 						"type":        "integer",
@@ -190,6 +193,10 @@ func mcpToolSearch(c *webContext, id json.RawMessage, rawArgs json.RawMessage) {
 		SemanticEnabled: args.Semantic && c.Config.SemanticSearch.Enable,
 	}
 	res, err := doSearch(q, c.Config, c.effectiveRules(), c.UserID)
+	if isInvalidQuery(err) {
+		mcpWriteError(c, id, mcpErrInvalidParam, err.Error())
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Str("query", args.Query).Msg("MCP search failed")
 		mcpWriteError(c, id, mcpErrInternal, "search failed")
@@ -227,6 +234,9 @@ func mcpFormatResults(query string, res *indexer.Results) string {
 		if snippet := strings.TrimSpace(d.Text); snippet != "" {
 			fmt.Fprintf(&b, "   %s\n", mcpTruncate(snippet, 300))
 		}
+		for _, m := range d.Thread {
+			fmt.Fprintf(&b, "   In the same thread: %s\n   URL: %s\n", m.Title, m.URL)
+		}
 		n++
 	}
 	return b.String()
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // CrawlJobStatus values.
@@ -27,6 +28,13 @@ const (
 	CrawlURLSkipped    = "skipped"
 )
 
+// RefreshState values.
+const (
+	RefreshUnchanged = "unchanged"
+	RefreshChanged   = "changed"
+	RefreshFailed    = "failed"
+)
+
 // CrawlJob stores the configuration and status of a persistent crawl job.
 type CrawlJob struct {
 	ID             string    `gorm:"primaryKey" json:"id"`
@@ -45,10 +53,27 @@ type CrawlURL struct {
 	Depth     int       `json:"depth"`
 	Status    string    `gorm:"not null;default:pending" json:"status"`
 	Error     string    `json:"error"`
+	Refresh   bool      `json:"refresh"` // indexed page to index again, modified since
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// RefreshState stores the cache validators and the outcome of the last
+// background refresh of an indexed web page.
+type RefreshState struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       uint      `gorm:"uniqueIndex:idx_refresh_user_url" json:"user_id"`
+	URL          string    `gorm:"uniqueIndex:idx_refresh_user_url;not null" json:"url"`
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"last_modified"`
+	ContentHash  string    `json:"content_hash"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error"`
+	CheckedAt    time.Time `json:"checked_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
 // GenerateCrawlJobID returns a random 8-character hex string suitable as a job ID.
 func GenerateCrawlJobID() (string, error) {
 	b := make([]byte, 4)
@@ -89,14 +114,46 @@ func UpdateCrawlJobStatus(id, status string) error {
 // InsertCrawlURLIfNotExists adds a URL to the job's queue only when it has not
 // been seen before (the unique index on job_id+url enforces this).
 func InsertCrawlURLIfNotExists(jobID, rawURL string, depth int) error {
-	cu := CrawlURL{JobID: jobID, URL: rawURL}
-	result := DB.Where(cu).FirstOrCreate(&cu, CrawlURL{
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&CrawlURL{
 		JobID:  jobID,
 		URL:    rawURL,
 		Depth:  depth,
 		Status: CrawlURLPending,
-	})
-	return result.Error
+	}).Error
+}
+
+// InsertCrawlURLRefresh adds an already indexed URL to the job's queue to be
+// indexed again, unless it is already known to the job.
+func InsertCrawlURLRefresh(jobID, rawURL string, depth int) error {
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&CrawlURL{
+		JobID:   jobID,
+		URL:     rawURL,
+		Depth:   depth,
+		Status:  CrawlURLPending,
+		Refresh: true,
+	}).Error
+}
+
+// ListCrawlURLsToRefresh returns the URLs of the job queued to be indexed
+// again.
+func ListCrawlURLsToRefresh(jobID string) ([]string, error) {
+	var urls []string
+	err := DB.Model(&CrawlURL{}).
+		Where("job_id = ? AND refresh = ?", jobID, true).
+		Pluck("url", &urls).Error
+	return urls, err
+}
+
+// InsertCrawlURLSkipped records a URL as skipped with the given reason unless
+// it is already known to the job.
+func InsertCrawlURLSkipped(jobID, rawURL string, depth int, reason string) error {
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&CrawlURL{
+		JobID:  jobID,
+		URL:    rawURL,
+		Depth:  depth,
+		Status: CrawlURLSkipped,
+		Error:  reason,
+	}).Error
 }
 
 // InsertCrawlURLDone records a URL as already done without going through the
@@ -120,20 +177,36 @@ func InsertCrawlURLDone(jobID, rawURL string, depth int) error {
 	return nil
 }
 
-// NextPendingCrawlURL returns the oldest pending URL for the job.
-// Returns (nil, nil) when no pending URLs remain.
-func NextPendingCrawlURL(jobID string) (*CrawlURL, error) {
-	var cu CrawlURL
-	err := DB.Where("job_id = ? AND status = ?", jobID, CrawlURLPending).
-		Order("id ASC").
-		First(&cu).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-	return &cu, nil
+// ClaimPendingCrawlURLs marks up to limit of the oldest pending URLs of the
+// job as in_progress and returns them. A row is only claimed while it is
+// still pending, so URLs are never handed out twice, even to concurrent
+// crawlers working on the same job.
+func ClaimPendingCrawlURLs(jobID string, limit int) ([]*CrawlURL, error) {
+	var claimed []*CrawlURL
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var cus []*CrawlURL
+		err := tx.Where("job_id = ? AND status = ?", jobID, CrawlURLPending).
+			Order("id ASC").
+			Limit(limit).
+			Find(&cus).Error
+		if err != nil {
+			return err
+		}
+		for _, cu := range cus {
+			res := tx.Model(&CrawlURL{}).
+				Where("id = ? AND status = ?", cu.ID, CrawlURLPending).
+				Update("status", CrawlURLInProgress)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 1 {
+				cu.Status = CrawlURLInProgress
+				claimed = append(claimed, cu)
+			}
+		}
+		return nil
+	})
+	return claimed, err
 }
 
 // UpdateCrawlURLStatus sets the status and optional error message on a URL row.
@@ -215,3 +288,23 @@ func GetCrawlJobStats(jobID string) (CrawlJobStats, error) {
 	}
 	return s, nil
 }
+
+// ListRefreshStates returns the refresh state of every page refreshed so far.
+func ListRefreshStates() ([]*RefreshState, error) {
+	var states []*RefreshState
+	err := DB.Find(&states).Error
+	return states, err
+}
+
+// SaveRefreshState inserts or updates a refresh state.
+func SaveRefreshState(s *RefreshState) error {
+	return DB.Save(s).Error
+}
+
+// DeleteRefreshStates removes the refresh states with the given IDs.
+func DeleteRefreshStates(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return DB.Where("id IN ?", ids).Delete(&RefreshState{}).Error
+}
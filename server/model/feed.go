@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Feed is a subscription to an RSS, Atom or JSON feed together with the
+// state of its last poll.
+type Feed struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint   `gorm:"uniqueIndex:idx_feed_user_url" json:"user_id"`
+	URL        string `gorm:"uniqueIndex:idx_feed_user_url;not null" json:"url"`
+	Title      string `json:"title"`
+	FetchPages bool   `json:"fetch_pages"`
+	// Configured is set for the subscriptions of the configuration file,
+	// which are removed when they disappear from it.
+	Configured   bool      `json:"configured"`
+	ETag         string    `json:"-"`
+	LastModified string    `json:"-"`
+	Error        string    `json:"error"`
+	CheckedAt    time.Time `json:"checked_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// FeedEntry records an entry of a feed which has already been indexed.
+type FeedEntry struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	FeedID    uint      `gorm:"uniqueIndex:idx_feed_entry;not null" json:"feed_id"`
+	EntryID   string    `gorm:"uniqueIndex:idx_feed_entry;not null" json:"entry_id"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddFeed subscribes the user to the feed at u. Subscribing again updates
+// fetchPages. configured marks the subscription as one of the configuration
+// file; an existing mark is kept when configured is false.
+func AddFeed(userID uint, u string, fetchPages, configured bool) (*Feed, error) {
+	f, err := GetFeed(userID, u)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		f = &Feed{UserID: userID, URL: u}
+	}
+	f.FetchPages = fetchPages
+	if configured {
+		f.Configured = true
+	}
+	if err := DB.Save(f).Error; err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// GetFeed returns the subscription of the user to the feed at u, or (nil,
+// nil) when not found.
+func GetFeed(userID uint, u string) (*Feed, error) {
+	var f Feed
+	err := DB.Where("user_id = ? AND url = ?", userID, u).First(&f).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// ListFeeds returns the feed subscriptions of the user, or of every user
+// when userID is nil, ordered by URL.
+func ListFeeds(userID *uint) ([]*Feed, error) {
+	var feeds []*Feed
+	q := DB.Order("url ASC")
+	if userID != nil {
+		q = q.Where("user_id = ?", *userID)
+	}
+	err := q.Find(&feeds).Error
+	return feeds, err
+}
+
+// SaveFeed updates the poll state of a feed.
+func SaveFeed(f *Feed) error {
+	return DB.Save(f).Error
+}
+
+// DeleteFeed unsubscribes the user from the feed at u and forgets its
+// entries. It returns false when the user is not subscribed to it. The
+// documents indexed from the feed are kept.
+func DeleteFeed(userID uint, u string) (bool, error) {
+	f, err := GetFeed(userID, u)
+	if err != nil || f == nil {
+		return false, err
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("feed_id = ?", f.ID).Delete(&FeedEntry{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(f).Error
+	})
+	return err == nil, err
+}
+
+// HasFeedEntry reports whether the entry of the feed has been recorded.
+func HasFeedEntry(feedID uint, entryID string) (bool, error) {
+	var count int64
+	err := DB.Model(&FeedEntry{}).
+		Where("feed_id = ? AND entry_id = ?", feedID, entryID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// AddFeedEntry records an entry of the feed unless it is already known.
+func AddFeedEntry(feedID uint, entryID, u string) error {
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&FeedEntry{
+		FeedID:  feedID,
+		EntryID: entryID,
+		URL:     u,
+	}).Error
+}
@@ -73,6 +73,9 @@ func automigrate() error {
 		&User{},
 		&CrawlJob{},
 		&CrawlURL{},
+		&RefreshState{},
+		&Feed{},
+		&FeedEntry{},
 	)
 }
 
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+// Package refresher keeps indexed web pages up to date by periodically
+// re-fetching them in the background.
+package refresher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/crawler"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/indexer"
+	"github.com/asciimoo/hister/server/model"
+)
+
+// checkInterval is how often the index is scanned for pages due for a
+// refresh.
+const checkInterval = 10 * time.Minute
+
+// now returns the current time, replaced in tests.
+var now = time.Now
+
+type duePage struct {
+	doc   *document.Document
+	state *model.RefreshState
+	last  time.Time
+}
+
+// Run refreshes the pages that are due according to cfg.Crawler.Refresh
+// every checkInterval until ctx is cancelled. It returns immediately when no
+// refresh interval is configured.
+func Run(ctx context.Context, cfg *config.Config) error {
+	rc := &cfg.Crawler.Refresh
+	if !rc.Enabled() {
+		return nil
+	}
+	rf, err := crawler.NewRefetcher(&cfg.Crawler)
+	if err != nil {
+		return err
+	}
+	log.Info().Msg("Starting background page refresh")
+	for {
+		if err := refreshDue(ctx, rc, rf); err != nil && !errors.Is(err, context.Canceled) {
+			log.Error().Err(err).Msg("refresher: failed to refresh pages")
+		}
+		t := time.NewTimer(checkInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// refreshDue re-fetches the pages whose interval has elapsed since they were
+// indexed or last checked, least recently checked first.
+func refreshDue(ctx context.Context, rc *config.RefreshConfig, rf *crawler.Refetcher) error {
+	states, err := model.ListRefreshStates()
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*model.RefreshState, len(states))
+	for _, s := range states {
+		byID[document.GetDocID(s.UserID, s.URL)] = s
+	}
+
+	t := now()
+	var due []*duePage
+	seen := make(map[string]struct{})
+	err = indexer.IterateWeb(func(d *document.Document) {
+		if _, ok := seen[d.ID()]; ok {
+			return
+		}
+		seen[d.ID()] = struct{}{}
+		st := byID[d.ID()]
+		delete(byID, d.ID())
+		u, err := url.Parse(d.URL)
+		if err != nil {
+			return
+		}
+		interval := rc.IntervalFor(u)
+		if interval <= 0 {
+			return
+		}
+		last := time.Unix(d.Added, 0)
+		if st != nil && st.CheckedAt.After(last) {
+			last = st.CheckedAt
+		}
+		if t.Sub(last) >= interval {
+			due = append(due, &duePage{doc: d, state: st, last: last})
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// The states left belong to pages removed from the index.
+	orphans := make([]uint, 0, len(byID))
+	for _, s := range byID {
+		orphans = append(orphans, s.ID)
+	}
+	if err := model.DeleteRefreshStates(orphans); err != nil {
+		log.Warn().Err(err).Msg("refresher: failed to delete stale refresh states")
+	}
+
+	if len(due) == 0 {
+		return nil
+	}
+	sort.Slice(due, func(a, b int) bool {
+		return due[a].last.Before(due[b].last)
+	})
+	var changed, unchanged, failed int
+	for _, p := range due {
+		status, err := refreshPage(ctx, rf, p.doc, p.state)
+		if err != nil {
+			return err
+		}
+		switch status {
+		case model.RefreshChanged:
+			changed++
+		case model.RefreshUnchanged:
+			unchanged++
+		default:
+			failed++
+		}
+	}
+	log.Info().Int("changed", changed).Int("unchanged", unchanged).Int("failed", failed).Msg("Refreshed indexed pages")
+	return nil
+}
+
+// refreshPage conditionally re-fetches d and re-indexes it when its content
+// changed. It returns the resulting refresh status, and an error only when
+// the state cannot be saved or ctx is cancelled.
+func refreshPage(ctx context.Context, rf *crawler.Refetcher, d *document.Document, st *model.RefreshState) (string, error) {
+	if st == nil {
+		st = &model.RefreshState{UserID: d.UserID, URL: d.URL}
+	}
+	res, err := rf.Fetch(ctx, d.URL, crawler.Validators{ETag: st.ETag, LastModified: st.LastModified})
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	st.CheckedAt = now()
+	st.Error = ""
+	switch {
+	case err != nil:
+		st.Status = model.RefreshFailed
+		st.Error = err.Error()
+	case res.NotModified:
+		st.Status = model.RefreshUnchanged
+		st.ETag, st.LastModified = res.Validators.ETag, res.Validators.LastModified
+	default:
+		st.ETag, st.LastModified = res.Validators.ETag, res.Validators.LastModified
+		body := res.Raw
+		if body == nil {
+			body = []byte(res.HTML)
+		}
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])
+		if hash == st.ContentHash {
+			st.Status = model.RefreshUnchanged
+			break
+		}
+		nd := &document.Document{
+			URL:         d.URL,
+			HTML:        res.HTML,
+			Raw:         res.Raw,
+			ContentType: res.ContentType,
+			Favicon:     d.Favicon,
+			UserID:      d.UserID,
+		}
+		if err := indexer.Add(nd); err != nil {
+			st.Status = model.RefreshFailed
+			st.Error = err.Error()
+			break
+		}
+		st.Status = model.RefreshChanged
+		st.ContentHash = hash
+	}
+	if st.Status == model.RefreshFailed {
+		log.Debug().Str("url", d.URL).Str("error", st.Error).Msg("refresher: failed to refresh page")
+	}
+	return st.Status, model.SaveRefreshState(st)
+}
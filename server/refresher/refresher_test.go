@@ -0,0 +1,251 @@
+package refresher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/crawler"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/extractor"
+	"github.com/asciimoo/hister/server/indexer"
+	"github.com/asciimoo/hister/server/model"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "hister-refresher")
+	if err != nil {
+		panic(err)
+	}
+	cfg := config.CreateDefaultConfig()
+	cfg.App.Directory = dir
+	cfg.Indexer.DetectLanguages = false
+	if err := model.Init(cfg); err != nil {
+		panic(err)
+	}
+	if err := extractor.Init(nil, nil, nil); err != nil {
+		panic(err)
+	}
+	if err := indexer.Init(cfg); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testSite serves pages counting their requests. /static supports
+// conditional requests, /changing changes on every request and /missing
+// does not exist.
+type testSite struct {
+	mu       sync.Mutex
+	requests []string
+	version  int
+}
+
+func (s *testSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.URL.Path)
+	switch r.URL.Path {
+	case "/static":
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><title>Static</title></head><body><p>static page</p></body></html>")
+	case "/changing":
+		s.version++
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>Changing</title></head><body><p>revision%d</p></body></html>", s.version)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// takeRequests returns and resets the requested paths.
+func (s *testSite) takeRequests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.requests
+	s.requests = nil
+	return r
+}
+
+func newRefreshConfig(t *testing.T, rc *config.RefreshConfig) *config.RefreshConfig {
+	t.Helper()
+	if err := rc.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	return rc
+}
+
+func newTestRefetcher(t *testing.T) *crawler.Refetcher {
+	t.Helper()
+	rf, err := crawler.NewRefetcher(&config.CrawlerConfig{IgnoreRobots: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rf
+}
+
+// setNow sets the time seen by the refresher to d after start.
+func setNow(t *testing.T, start time.Time, d time.Duration) {
+	t.Helper()
+	prev := now
+	now = func() time.Time { return start.Add(d) }
+	t.Cleanup(func() { now = prev })
+}
+
+func refreshStates(t *testing.T) map[string]*model.RefreshState {
+	t.Helper()
+	states, err := model.ListRefreshStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := make(map[string]*model.RefreshState, len(states))
+	for _, s := range states {
+		r[s.URL] = s
+	}
+	return r
+}
+
+func checkRequests(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("expected requests %v, got %v", want, got)
+	}
+}
+
+func TestRefreshDue(t *testing.T) {
+	site := &testSite{}
+	srv := httptest.NewServer(site)
+	defer srv.Close()
+	start := time.Now()
+	for _, p := range []string{"/static", "/changing", "/missing", "/never"} {
+		d := &document.Document{URL: srv.URL + p, Title: p, Text: "initial " + p}
+		if err := indexer.Add(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rc := newRefreshConfig(t, &config.RefreshConfig{
+		Interval: "1h",
+		Rules:    []*config.RefreshRule{{Pattern: "/never$", Interval: "0"}},
+	})
+	rf := newTestRefetcher(t)
+	ctx := context.Background()
+
+	// nothing is due before the interval has passed
+	setNow(t, start, 30*time.Minute)
+	if err := refreshDue(ctx, rc, rf); err != nil {
+		t.Fatal(err)
+	}
+	checkRequests(t, site.takeRequests())
+
+	setNow(t, start, 2*time.Hour)
+	if err := refreshDue(ctx, rc, rf); err != nil {
+		t.Fatal(err)
+	}
+	// pages indexed in the same second are refreshed in the order of the index
+	reqs := site.takeRequests()
+	if len(reqs) != 3 {
+		t.Fatalf("expected 3 requests, got %v", reqs)
+	}
+	states := refreshStates(t)
+	for p, status := range map[string]string{"/static": model.RefreshChanged, "/changing": model.RefreshChanged, "/missing": model.RefreshFailed} {
+		st := states[srv.URL+p]
+		if st == nil || st.Status != status {
+			t.Fatalf("expected status %q of %s, got %+v", status, p, st)
+		}
+		if !st.CheckedAt.Equal(start.Add(2 * time.Hour)) {
+			t.Fatalf("unexpected check time of %s: %v", p, st.CheckedAt)
+		}
+	}
+	if st := states[srv.URL+"/static"]; st.ETag != `"v1"` {
+		t.Fatalf("expected the ETag of /static to be stored, got %q", st.ETag)
+	}
+	if _, ok := states[srv.URL+"/never"]; ok {
+		t.Fatal("expected /never not to be refreshed")
+	}
+	if d := indexer.GetByURLAndUser(srv.URL+"/changing", 0); d == nil || !strings.Contains(d.Text, "revision1") {
+		t.Fatalf("expected /changing to be re-indexed, got %v", d)
+	}
+
+	// pages are due again an interval after they were checked
+	setNow(t, start, 2*time.Hour+30*time.Minute)
+	if err := refreshDue(ctx, rc, rf); err != nil {
+		t.Fatal(err)
+	}
+	checkRequests(t, site.takeRequests())
+
+	// the page checked least recently comes first
+	st := states[srv.URL+"/missing"]
+	st.CheckedAt = start.Add(90 * time.Minute)
+	if err := model.SaveRefreshState(st); err != nil {
+		t.Fatal(err)
+	}
+	setNow(t, start, 3*time.Hour+15*time.Minute)
+	if err := refreshDue(ctx, rc, rf); err != nil {
+		t.Fatal(err)
+	}
+	reqs = site.takeRequests()
+	if len(reqs) != 3 || reqs[0] != "/missing" {
+		t.Fatalf("expected /missing to be refreshed first, got %v", reqs)
+	}
+	states = refreshStates(t)
+	if st := states[srv.URL+"/static"]; st.Status != model.RefreshUnchanged || st.ETag != `"v1"` {
+		t.Fatalf("expected /static to be unchanged, got %+v", st)
+	}
+	if st := states[srv.URL+"/changing"]; st.Status != model.RefreshChanged {
+		t.Fatalf("expected /changing to be changed, got %+v", st)
+	}
+
+	// states of pages removed from the index are deleted
+	if err := indexer.Delete(document.GetDocID(0, srv.URL+"/missing")); err != nil {
+		t.Fatal(err)
+	}
+	if err := refreshDue(ctx, rc, rf); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := refreshStates(t)[srv.URL+"/missing"]; ok {
+		t.Fatal("expected the state of a removed page to be deleted")
+	}
+}
+
+func TestRefreshPageUnchangedContent(t *testing.T) {
+	var etag string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a server without validators returning the same content
+		etag = r.Header.Get("If-None-Match")
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body><p>same content</p></body></html>")
+	}))
+	defer srv.Close()
+	d := &document.Document{URL: srv.URL + "/page", Title: "Page", Text: "same content"}
+	if err := indexer.Add(d); err != nil {
+		t.Fatal(err)
+	}
+	rf := newTestRefetcher(t)
+	st := &model.RefreshState{URL: d.URL}
+	for n, want := range []string{model.RefreshChanged, model.RefreshUnchanged} {
+		status, err := refreshPage(context.Background(), rf, d, st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != want {
+			t.Fatalf("refresh %d: expected status %q, got %q", n+1, want, status)
+		}
+	}
+	if etag != "" {
+		t.Fatalf("expected no If-None-Match header, got %q", etag)
+	}
+}
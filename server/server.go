@@ -8,22 +8,30 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"io"
 	iofs "io/fs"
 	"mime"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/asciimoo/hister/config"
 	"github.com/asciimoo/hister/files"
 	"github.com/asciimoo/hister/server/document"
 	"github.com/asciimoo/hister/server/extractor"
+	"github.com/asciimoo/hister/server/extractor/extractors/code"
 	"github.com/asciimoo/hister/server/indexer"
+	"github.com/asciimoo/hister/server/indexer/querybuilder"
+	"github.com/asciimoo/hister/server/linkcheck"
 	"github.com/asciimoo/hister/server/model"
 	"github.com/asciimoo/hister/server/static"
 	"github.com/asciimoo/hister/server/types"
@@ -604,7 +612,7 @@ func serveConfig(c *webContext) {
 		Username            string            `json:"username,omitempty"`
 		UserID              uint              `json:"userId,omitempty"`
 		SemanticEnabled     bool              `json:"semanticEnabled"`
-		SemanticWeight      float64           `json:"semanticWeight,omitempty"`
+		SemanticWeight      float64           `json:"semanticWeight"`
 		SimilarityThreshold float64           `json:"similarityThreshold,omitempty"`
 		OAuthProviders      []string          `json:"oauthProviders,omitempty"`
 	}
@@ -688,6 +696,9 @@ func serveSearch(c *webContext) {
 		if urlParams.Get("include_html") == "1" {
 			query.IncludeHTML = true
 		}
+		if urlParams.Get("all_versions") == "1" {
+			query.AllVersions = true
+		}
 		if pk := c.Request.URL.Query().Get("page_key"); pk != "" {
 			query.PageKey = pk
 		}
@@ -705,6 +716,10 @@ func serveSearch(c *webContext) {
 		}
 		r, err := doSearch(query, c.Config, c.effectiveRules(), c.UserID)
 		if err != nil {
+			if isInvalidQuery(err) {
+				http.Error(c.Response, err.Error(), http.StatusBadRequest)
+				return
+			}
 			fmt.Println(err)
 			serve500(c)
 			return
@@ -749,8 +764,12 @@ func serveSearch(c *webContext) {
 		}
 		res, err := doSearch(query, c.Config, c.effectiveRules(), c.UserID)
 		if err != nil {
-			log.Error().Err(err).Msg("search error")
-			continue
+			if !isInvalidQuery(err) {
+				log.Error().Err(err).Msg("search error")
+				continue
+			}
+			// the client waits for an answer to every query
+			res = &indexer.Results{Query: query, Error: err.Error()}
 		}
 		jr, err := json.Marshal(res)
 		if err != nil {
@@ -766,40 +785,187 @@ func serveSearch(c *webContext) {
 func doSearch(query *indexer.Query, cfg *config.Config, rules *config.Rules, userID uint) (*indexer.Results, error) {
 	start := time.Now()
 	oq := query.Text
+	res, err := searchIndex(query, cfg, rules, userID)
+	if err != nil {
+		return nil, err
+	}
+	if hr, err := model.GetURLsByQuery(userID, oq); err == nil && len(hr) > 0 {
+		pinHistory(res, hr)
+	}
+	if oq != "" {
+		res.QuerySuggestion = model.GetQuerySuggestion(userID, oq)
+	}
+	duration := float32(time.Since(start).Milliseconds()) / 1000.
+	res.SearchDuration = fmt.Sprintf("%.3f seconds", duration)
+	return res, nil
+}
+
+// searchIndex searches the documents of userID after resolving the aliases
+// of the query. Only invalid queries are returned as errors.
+func searchIndex(query *indexer.Query, cfg *config.Config, rules *config.Rules, userID uint) (*indexer.Results, error) {
 	query.Text = rules.ResolveAliases(query.Text)
 	query.UserID = userID
 	res, err := indexer.Search(cfg, query)
 	if err != nil {
+		if isInvalidQuery(err) {
+			return nil, err
+		}
 		log.Error().Err(err).Msg("failed to get indexer results")
 	}
 	if res == nil {
 		res = &indexer.Results{}
 	}
-	hr, err := model.GetURLsByQuery(userID, oq)
-	if err == nil && len(hr) > 0 {
-		res.History = hr
-		priorityByURL := make(map[string]*model.URLCount, len(hr))
-		for _, h := range hr {
-			priorityByURL[h.URL] = h
-		}
-		filtered := res.Documents[:0]
-		for _, d := range res.Documents {
-			if h, ok := priorityByURL[d.URL]; ok {
-				if h.Text == "" {
-					h.Text = d.Text
-				}
-				continue
+	return res, nil
+}
+
+// pinHistory moves the results previously opened from the same query to
+// res.History, which is listed before the other results.
+func pinHistory(res *indexer.Results, hr []*model.URLCount) {
+	res.History = hr
+	priorityByURL := make(map[string]*model.URLCount, len(hr))
+	for _, h := range hr {
+		priorityByURL[h.URL] = h
+	}
+	filtered := res.Documents[:0]
+	for _, d := range res.Documents {
+		if h, ok := priorityByURL[d.URL]; ok {
+			if h.Text == "" {
+				h.Text = d.Text
 			}
-			filtered = append(filtered, d)
+			continue
 		}
-		res.Documents = filtered
+		filtered = append(filtered, d)
 	}
-	if oq != "" {
-		res.QuerySuggestion = model.GetQuerySuggestion(userID, oq)
+	res.Documents = filtered
+}
+
+// explainResponse explains the ranking of the results of a query.
+type explainResponse struct {
+	// Query is the query as sent, ResolvedQuery is the query searched
+	// after resolving the aliases.
+	Query         string             `json:"query"`
+	ResolvedQuery string             `json:"resolved_query"`
+	ParsedQuery   string             `json:"parsed_query"`
+	Tree          *querybuilder.Node `json:"tree"`
+	Boosts        map[string]float64 `json:"boosts"`
+	Total         uint64             `json:"total"`
+	// Hits are ordered by rank.
+	Hits []*explainHit `json:"hits"`
+}
+
+// explainHit explains the rank of a search result.
+type explainHit struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	// Rank is the position of the hit in the listed results, SearchRank is
+	// its position in the results of the index, before the history
+	// adjustments. Results opened from the same query are listed even if
+	// the index does not match them, with a SearchRank of 0.
+	Rank        int                   `json:"rank"`
+	SearchRank  int                   `json:"search_rank"`
+	Score       float64               `json:"score"`
+	Hybrid      *document.HybridScore `json:"hybrid,omitempty"`
+	Explanation *indexer.Explanation  `json:"explanation,omitempty"`
+	// History is set for the hits listed first because they were opened
+	// from the results of the same query.
+	History *model.URLCount `json:"history,omitempty"`
+}
+
+// explainSearch runs query like doSearch and explains the rank of each
+// result.
+func explainSearch(query *indexer.Query, cfg *config.Config, rules *config.Rules, userID uint) (*explainResponse, error) {
+	oq := query.Text
+	query.Explain = true
+	res, err := searchIndex(query, cfg, rules, userID)
+	if err != nil {
+		return nil, err
+	}
+	// the query is valid, the search has parsed it
+	tree, _ := querybuilder.Parse(query.Text)
+	r := &explainResponse{
+		Query:         oq,
+		ResolvedQuery: query.Text,
+		Tree:          tree,
+		Boosts:        querybuilder.Boosts(),
+		Total:         res.Total,
+		Hits:          make([]*explainHit, 0, len(res.Documents)),
+	}
+	if tree != nil {
+		r.ParsedQuery = tree.String()
+	}
+	docs := slices.Clone(res.Documents)
+	if hr, err := model.GetURLsByQuery(userID, oq); err == nil && len(hr) > 0 {
+		pinHistory(res, hr)
+	}
+	ranks := make(map[string]int, len(res.History)+len(res.Documents))
+	for i, d := range res.Documents {
+		ranks[d.URL] = len(res.History) + i + 1
+	}
+	history := make(map[string]*model.URLCount, len(res.History))
+	for i, h := range res.History {
+		ranks[h.URL] = i + 1
+		history[h.URL] = h
+	}
+	matched := make(map[string]bool, len(docs))
+	for i, d := range docs {
+		matched[d.URL] = true
+		r.Hits = append(r.Hits, &explainHit{
+			URL:         d.URL,
+			Title:       d.Title,
+			Rank:        ranks[d.URL],
+			SearchRank:  i + 1,
+			Score:       d.Score,
+			Hybrid:      d.Hybrid,
+			Explanation: res.Explanations[d.ID()],
+			History:     history[d.URL],
+		})
 	}
-	duration := float32(time.Since(start).Milliseconds()) / 1000.
-	res.SearchDuration = fmt.Sprintf("%.3f seconds", duration)
-	return res, nil
+	for _, h := range res.History {
+		if !matched[h.URL] {
+			r.Hits = append(r.Hits, &explainHit{URL: h.URL, Title: h.Title, Rank: ranks[h.URL], History: h})
+		}
+	}
+	slices.SortStableFunc(r.Hits, func(a, b *explainHit) int {
+		return a.Rank - b.Rank
+	})
+	return r, nil
+}
+
+// isInvalidQuery reports whether err is caused by an invalid query or sort
+// order.
+func isInvalidQuery(err error) bool {
+	var pe *querybuilder.ParseError
+	return errors.As(err, &pe) || errors.Is(err, indexer.ErrInvalidSort)
+}
+
+func serveExplain(c *webContext) {
+	params := c.Request.URL.Query()
+	query := &indexer.Query{Text: params.Get("q")}
+	if strings.TrimSpace(query.Text) == "" {
+		http.Error(c.Response, "missing query", http.StatusBadRequest)
+		return
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			http.Error(c.Response, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		query.Limit = limit
+	}
+	if v := params.Get("semantic"); v != "" && c.Config.SemanticSearch.Enable {
+		query.SemanticEnabled = v == "1" || v == "true"
+	}
+	r, err := explainSearch(query, c.Config, c.effectiveRules(), c.UserID)
+	if err != nil {
+		if isInvalidQuery(err) {
+			http.Error(c.Response, err.Error(), http.StatusBadRequest)
+			return
+		}
+		serve500(c)
+		return
+	}
+	c.JSON(r)
 }
 
 func serveAdd(c *webContext) {
@@ -995,6 +1161,7 @@ func serveGet(c *webContext) {
 		http.Error(c.Response, "document not found", http.StatusNotFound)
 		return
 	}
+	c.Response.Header().Set("Last-Modified", time.Unix(doc.Added, 0).UTC().Format(http.TimeFormat))
 	// We skip generating the body on HEAD requests, since those only check the status.
 	// Note that we want to return the same status as a GET request, so **no faillible processing**
 	// is to be made inside of this block!
@@ -1005,14 +1172,27 @@ func serveGet(c *webContext) {
 
 func servePreview(c *webContext) {
 	u := c.Request.URL.Query().Get("url")
-	doc := indexer.GetByURLAndUser(u, c.UserID)
+	docID := document.GetDocID(c.UserID, u)
+	var version int64
+	if v := c.Request.URL.Query().Get("version"); v != "" {
+		var err error
+		if version, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(c.Response, "invalid version", http.StatusBadRequest)
+			return
+		}
+	}
+	doc := indexer.GetVersion(docID, version)
 	if doc == nil {
-		serve500(c)
+		msg := "version not found"
+		if version == 0 {
+			msg = "document not found"
+		}
+		http.Error(c.Response, msg, http.StatusNotFound)
 		return
 	}
 	var resp types.PreviewResponse
 	var err error
-	if doc.HTML == "" {
+	if doc.HTML == "" && doc.ContentType == "" {
 		resp = types.PreviewResponse{Content: doc.Text}
 	} else {
 		resp, err = extractor.Preview(doc)
@@ -1031,15 +1211,81 @@ func servePreview(c *webContext) {
 	if meta := doc.GetPreviewMeta(); meta != nil {
 		payload["meta"] = meta
 	}
+	if indexer.VersionsEnabled() {
+		if vs, err := indexer.ListVersions(docID); err == nil && len(vs) > 1 {
+			payload["versions"] = vs
+		}
+	}
+	if v := c.Request.URL.Query().Get("diff"); v != "" {
+		from, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(c.Response, "invalid diff version", http.StatusBadRequest)
+			return
+		}
+		old := indexer.GetVersion(docID, from)
+		if old == nil {
+			http.Error(c.Response, "version not found", http.StatusNotFound)
+			return
+		}
+		payload["diff"] = document.Diff(old.Text, doc.Text)
+	}
 	c.JSON(payload)
 }
 
+func serveVersions(c *webContext) {
+	u := c.Request.URL.Query().Get("url")
+	vs, err := indexer.ListVersions(document.GetDocID(c.UserID, u))
+	if err != nil {
+		log.Error().Err(err).Str("url", u).Msg("failed to list document versions")
+		serve500(c)
+		return
+	}
+	if len(vs) == 0 {
+		http.Error(c.Response, "document not found", http.StatusNotFound)
+		return
+	}
+	c.JSON(map[string]any{
+		"url":      u,
+		"versions": vs,
+	})
+}
+
+func serveVersion(c *webContext) {
+	u := c.Request.URL.Query().Get("url")
+	version, err := strconv.ParseInt(c.Request.URL.Query().Get("version"), 10, 64)
+	if err != nil {
+		http.Error(c.Response, "invalid version", http.StatusBadRequest)
+		return
+	}
+	doc := indexer.GetVersion(document.GetDocID(c.UserID, u), version)
+	if doc == nil {
+		http.Error(c.Response, "version not found", http.StatusNotFound)
+		return
+	}
+	c.JSON(doc)
+}
+
 func serveFile(c *webContext) {
 	filePath := c.Request.URL.Query().Get("path")
 	if filePath == "" {
 		http.Error(c.Response, "missing path parameter", http.StatusBadRequest)
 		return
 	}
+	highlight := false
+	if v := c.Request.URL.Query().Get("highlight"); v != "" {
+		var err error
+		if highlight, err = strconv.ParseBool(v); err != nil {
+			http.Error(c.Response, "invalid highlight parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Members of archives are read from the archive, which has to be in a
+	// configured directory
+	archivePath, member, inArchive := files.SplitArchivePath(filePath)
+	if inArchive {
+		filePath = archivePath
+	}
 
 	// Resolve to absolute and clean the path to prevent traversal
 	filePath = filepath.Clean(filePath)
@@ -1074,12 +1320,23 @@ func serveFile(c *webContext) {
 		return
 	}
 
-	content, err := os.ReadFile(filePath)
+	var content []byte
+	if inArchive {
+		content, err = files.ReadArchiveMember(filePath, member, max(c.Config.Indexer.MaxFileSize, 1)*1024*1024)
+		filePath = files.ArchiveMemberPath(filePath, member)
+	} else {
+		content, err = os.ReadFile(filePath)
+	}
 	if err != nil {
 		http.Error(c.Response, "file not found", http.StatusNotFound)
 		return
 	}
 
+	if highlight && code.Detect(filePath) != nil && utf8.Valid(content) {
+		serveHighlightedFile(c, filePath, string(content))
+		return
+	}
+
 	ext := filepath.Ext(filePath)
 	mimeType := mime.TypeByExtension(ext)
 	if mimeType == "" {
@@ -1091,6 +1348,22 @@ func serveFile(c *webContext) {
 	}
 }
 
+// serveHighlightedFile renders a source file as a syntax-highlighted HTML
+// page.
+func serveHighlightedFile(c *webContext, filePath, content string) {
+	h, err := code.Highlight(filePath, content)
+	if err != nil {
+		log.Warn().Err(err).Str("path", filePath).Msg("failed to highlight file")
+		serve500(c)
+		return
+	}
+	c.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	page := `<!DOCTYPE html><html><head><meta charset="utf-8"><title>` + html.EscapeString(filepath.Base(filePath)) + `</title></head><body style="margin:0">` + h + `</body></html>`
+	if _, err := c.Response.Write([]byte(page)); err != nil {
+		log.Warn().Err(err).Msg("failed to write file response")
+	}
+}
+
 func serveAPI(c *webContext) {
 	type endpointArg struct {
 		Name        string `json:"name"`
@@ -1145,6 +1418,10 @@ func serveStats(c *webContext) {
 	})
 }
 
+func serveIndexing(c *webContext) {
+	c.JSON(indexer.IndexProgress())
+}
+
 func serveExtractors(c *webContext) {
 	infos := extractor.List()
 	if !c.Config.App.DisplayExtractorConfig {
@@ -1294,7 +1571,7 @@ func serveDelete(c *webContext) {
 		}
 	})
 	if err != nil {
-		if errors.Is(err, indexer.ErrEmptyFilter) {
+		if errors.Is(err, indexer.ErrEmptyFilter) || isInvalidQuery(err) {
 			http.Error(c.Response, err.Error(), http.StatusBadRequest)
 			return
 		}
@@ -1412,6 +1689,84 @@ func serveBatch(c *webContext) {
 	c.JSON(batchResponse{Results: results})
 }
 
+type checkLinksRequest struct {
+	MaxAge int64 `json:"maxAge"`
+}
+
+func serveCheckLinks(c *webContext) {
+	var req checkLinksRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(c.Response, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// the check outlives the request, its progress is served by
+	// serveLinkCheckStatus
+	p, err := linkcheck.Start(&c.Config.Crawler, time.Duration(req.MaxAge)*time.Second)
+	if errors.Is(err, linkcheck.ErrRunning) {
+		c.JSONStatus(http.StatusConflict, p)
+		return
+	}
+	c.JSONStatus(http.StatusAccepted, p)
+}
+
+func serveLinkCheckStatus(c *webContext) {
+	c.JSON(linkcheck.Status())
+}
+
+type feedRequest struct {
+	URL        string `json:"url"`
+	FetchPages bool   `json:"fetchPages"`
+}
+
+func serveFeeds(c *webContext) {
+	feeds, err := model.ListFeeds(&c.UserID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list feeds")
+		serve500(c)
+		return
+	}
+	c.JSON(feeds)
+}
+
+func serveAddFeed(c *webContext) {
+	var req feedRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		http.Error(c.Response, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(c.Response, "invalid feed URL", http.StatusBadRequest)
+		return
+	}
+	f, err := model.AddFeed(c.UserID, u.String(), req.FetchPages, false)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to add feed")
+		serve500(c)
+		return
+	}
+	c.JSON(f)
+}
+
+func serveDeleteFeed(c *webContext) {
+	var req feedRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		http.Error(c.Response, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ok, err := model.DeleteFeed(c.UserID, strings.TrimSpace(req.URL))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete feed")
+		serve500(c)
+		return
+	}
+	if !ok {
+		http.Error(c.Response, "feed not found", http.StatusNotFound)
+		return
+	}
+	serve200(c)
+}
+
 type reindexRequest struct {
 	SkipSensitive   bool `json:"skipSensitive"`
 	DetectLanguages bool `json:"detectLanguages"`
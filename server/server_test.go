@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/document"
+	"github.com/asciimoo/hister/server/extractor"
+	"github.com/asciimoo/hister/server/indexer"
+	"github.com/asciimoo/hister/server/model"
+)
+
+var testConfig *config.Config
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "hister-server")
+	if err != nil {
+		panic(err)
+	}
+	testConfig = config.CreateDefaultConfig()
+	testConfig.App.Directory = dir
+	testConfig.Indexer.DetectLanguages = false
+	if err := testConfig.LoadRules(); err != nil {
+		panic(err)
+	}
+	if err := model.Init(testConfig); err != nil {
+		panic(err)
+	}
+	if err := extractor.Init(nil, nil, nil); err != nil {
+		panic(err)
+	}
+	if err := indexer.Init(testConfig); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// addTestDocs indexes docs, failing the test on error.
+func addTestDocs(t *testing.T, docs ...*document.Document) {
+	t.Helper()
+	for _, d := range docs {
+		if err := indexer.Add(d); err != nil {
+			t.Fatalf("failed to add %s: %v", d.URL, err)
+		}
+	}
+}
+
+func TestExplainSearchHistory(t *testing.T) {
+	addTestDocs(t,
+		&document.Document{URL: "https://explain.test/title", Title: "Quokka", Text: "The quokka is a small marsupial"},
+		&document.Document{URL: "https://explain.test/text", Title: "Marsupials", Text: "Marsupials like the quokka"},
+		&document.Document{URL: "https://explain.test/opened", Title: "Rottnest Island", Text: "Rottnest Island is home to the quokka"},
+	)
+	// results opened from the query as typed, before resolving the alias
+	for range 2 {
+		if err := model.UpdateHistory(0, "qk", "https://explain.test/opened", "Rottnest Island"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := model.UpdateHistory(0, "qk", "https://explain.test/unindexed", "Not indexed"); err != nil {
+		t.Fatal(err)
+	}
+	rules := &config.Rules{Aliases: config.Aliases{"qk": "quokka"}}
+
+	r, err := explainSearch(&indexer.Query{Text: "qk"}, testConfig, rules, 0)
+	if err != nil {
+		t.Fatalf("explain failed: %v", err)
+	}
+	if r.Query != "qk" || r.ResolvedQuery != "quokka" {
+		t.Errorf("expected the query qk resolved to quokka, got %q and %q", r.Query, r.ResolvedQuery)
+	}
+	if r.Total != 3 {
+		t.Errorf("expected 3 matches, got %d", r.Total)
+	}
+	// the opened results are listed first, the others keep their order
+	want := []struct {
+		url        string
+		searchRank int
+		history    bool
+	}{
+		{"https://explain.test/opened", 3, true},
+		{"https://explain.test/unindexed", 0, true},
+		{"https://explain.test/title", 1, false},
+		{"https://explain.test/text", 2, false},
+	}
+	if len(r.Hits) != len(want) {
+		t.Fatalf("expected %d hits, got %d", len(want), len(r.Hits))
+	}
+	for n, w := range want {
+		h := r.Hits[n]
+		if h.URL != w.url || h.Rank != n+1 || h.SearchRank != w.searchRank || (h.History != nil) != w.history {
+			t.Errorf("hit %d: expected %s ranked %d (search rank %d, history %v), got %s ranked %d (search rank %d, history %v)",
+				n, w.url, n+1, w.searchRank, w.history, h.URL, h.Rank, h.SearchRank, h.History != nil)
+		}
+		if (h.Explanation != nil) != (w.searchRank > 0) {
+			t.Errorf("%s: unexpected explanation %v", h.URL, h.Explanation)
+		}
+	}
+	if h := r.Hits[0].History; h != nil && h.Count != 2 {
+		t.Errorf("expected the opened result to be counted twice, got %d", h.Count)
+	}
+}
+
+func TestServeSearchSort(t *testing.T) {
+	addTestDocs(t, &document.Document{URL: "https://sort.test/", Title: "Wombat", Text: "The wombat digs burrows"})
+	cases := []struct {
+		sort   string
+		status int
+	}{
+		{"", http.StatusOK},
+		{"-added", http.StatusOK},
+		{"metadata.link_checked", http.StatusOK},
+		{"text", http.StatusBadRequest},
+		{"metadata.", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/search?q=wombat&sort="+tc.sort, nil)
+		req.Header.Set("Origin", "hister://")
+		w := httptest.NewRecorder()
+		serveSearch(&webContext{Request: req, Response: w, Config: testConfig})
+		if w.Code != tc.status {
+			t.Errorf("sort %q: expected status %d, got %d: %s", tc.sort, tc.status, w.Code, w.Body)
+		}
+	}
+}
+
+func TestServeAddFeedKeepsConfigured(t *testing.T) {
+	const u = "https://feeds.test/configured.xml"
+	if _, err := model.AddFeed(0, u, false, true); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/feeds", strings.NewReader(`{"url":"`+u+`","fetchPages":true}`))
+	w := httptest.NewRecorder()
+	serveAddFeed(&webContext{Request: req, Response: w, Config: testConfig})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+	}
+	var f model.Feed
+	if err := json.Unmarshal(w.Body.Bytes(), &f); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Configured || !f.FetchPages {
+		t.Errorf("expected a configured feed fetching pages, got %+v", f)
+	}
+	if f, err := model.GetFeed(0, u); err != nil || f == nil || !f.Configured {
+		t.Errorf("expected the stored feed to stay configured, got %+v (%v)", f, err)
+	}
+}
+
+func TestServeFileHighlight(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := *testConfig
+	cfg.Indexer.Directories = []*config.Directory{{Path: dir}}
+	cases := []struct {
+		highlight   string
+		status      int
+		highlighted bool
+	}{
+		{"", http.StatusOK, false},
+		{"1", http.StatusOK, true},
+		{"true", http.StatusOK, true},
+		{"0", http.StatusOK, false},
+		{"false", http.StatusOK, false},
+		{"yes", http.StatusBadRequest, false},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/file?path="+url.QueryEscape(path)+"&highlight="+tc.highlight, nil)
+		w := httptest.NewRecorder()
+		serveFile(&webContext{Request: req, Response: w, Config: &cfg})
+		if w.Code != tc.status {
+			t.Errorf("highlight=%s: expected status %d, got %d", tc.highlight, tc.status, w.Code)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		if html := strings.HasPrefix(w.Header().Get("Content-Type"), "text/html"); html != tc.highlighted {
+			t.Errorf("highlight=%s: expected a highlighted page %v, got %q", tc.highlight, tc.highlighted, w.Body)
+		}
+	}
+}
+
+func TestServePreviewMissingVersion(t *testing.T) {
+	addTestDocs(t, &document.Document{URL: "https://preview.test/", Title: "Numbat", Text: "The numbat eats termites"})
+	cases := []struct {
+		query  string
+		status int
+	}{
+		{"url=https://preview.test/", http.StatusOK},
+		{"url=https://preview.test/&version=0", http.StatusOK},
+		{"url=https://preview.test/&version=42", http.StatusNotFound},
+		{"url=https://preview.test/missing", http.StatusNotFound},
+		{"url=https://preview.test/&version=x", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/preview?"+tc.query, nil)
+		w := httptest.NewRecorder()
+		servePreview(&webContext{Request: req, Response: w, Config: testConfig})
+		if w.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tc.query, tc.status, w.Code, w.Body)
+		}
+	}
+}
+
+func TestServeCheckLinksInvalidJSON(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/check-links", strings.NewReader(`{"maxAge":`))
+	w := httptest.NewRecorder()
+	serveCheckLinks(&webContext{Request: req, Response: w, Config: testConfig})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d: %s", w.Code, w.Body)
+	}
+}
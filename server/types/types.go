@@ -6,12 +6,17 @@ type DocType int
 const (
 	Web DocType = iota
 	Local
+	Mail
+	Git
 )
 
 var DocTypeNames = map[string]DocType{
 	"web":   Web,
 	"file":  Local,
 	"local": Local,
+	"mail":  Mail,
+	"email": Mail,
+	"git":   Git,
 }
 
 // PreviewResponse holds the result of a document preview operation.
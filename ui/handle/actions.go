@@ -36,11 +36,7 @@ func DispatchCommonAction(m *model.Model, action config.Action) (tea.Cmd, bool)
 		m.OpenOverlay(model.StateSettings)
 		return nil, true
 	case config.ActionToggleSort:
-		if m.SortMode == "" {
-			m.SortMode = "domain"
-		} else {
-			m.SortMode = ""
-		}
+		m.CycleSortMode()
 		return startSearch(m, m.FlashHint(config.ActionToggleSort)), true
 	case config.ActionScrollUp:
 		if m.SelectedIdx > 0 {
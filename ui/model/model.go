@@ -57,7 +57,7 @@ type Model struct {
 	SelectedIdx int
 	Limit       int
 	IsSearching bool
-	SortMode    string // "" for relevance, "domain" for domain
+	SortMode    string // Sort of one of SortOptions, "" for relevance
 
 	// Rendering
 	Styles theme.Styles
@@ -239,6 +239,23 @@ func (m *Model) GetTotalResults() int {
 	return c
 }
 
+// CycleSortMode switches to the sort order following the current one in
+// SortOptions.
+func (m *Model) CycleSortMode() {
+	i := slices.IndexFunc(SortOptions, func(o SortOption) bool { return o.Sort == m.SortMode })
+	m.SortMode = SortOptions[(i+1)%len(SortOptions)].Sort
+}
+
+// SortLabel returns the label of the current sort order.
+func (m *Model) SortLabel() string {
+	for _, o := range SortOptions {
+		if o.Sort == m.SortMode {
+			return o.Label
+		}
+	}
+	return m.SortMode
+}
+
 func (m *Model) GetSelectedURL() string {
 	if m.Results == nil || m.SelectedIdx < 0 || m.SelectedIdx == m.Limit {
 		return ""
@@ -34,6 +34,23 @@ func (s ViewState) String() string {
 	return []string{"INPUT", "RESULTS", "DIALOG", "HELP", "THEME_PICKER", "CONTEXT_MENU", "SETTINGS", "PRIORITIZE_INPUT"}[s]
 }
 
+// SortOption is a sort order of the results, Sort being the value of
+// SearchQuery.Sort.
+type SortOption struct {
+	Sort  string
+	Label string
+}
+
+// SortOptions are the sort orders cycled through by the toggle_sort action.
+var SortOptions = []SortOption{
+	{"", "relevance"},
+	{"-added", "newest"},
+	{"added", "oldest"},
+	{"title", "title"},
+	{"url", "url"},
+	{"domain", "domain"},
+}
+
 // sent over WebSocket to the search server
 type SearchQuery struct {
 	Text      string `json:"text"`
@@ -70,7 +70,7 @@ func ConnectWebSocket(wsURL, origin, token string, wsChan chan tea.Msg, wsDone c
 						continue
 					}
 					if len(res.Documents) == 0 && len(res.History) == 0 {
-						res = &indexer.Results{}
+						res = &indexer.Results{Error: res.Error}
 					}
 					select {
 					case wsChan <- model.ResultsMsg{Results: res}:
@@ -115,8 +115,8 @@ func Header(m *model.Model) string {
 		}
 	}
 	tabBar := " " + strings.Join(tabs, " ")
-	if m.SortMode == "domain" {
-		tabBar += "  " + m.Styles.Conn.Render("[domain]")
+	if m.SortMode != "" {
+		tabBar += "  " + m.Styles.Conn.Render("["+m.SortLabel()+"]")
 	}
 
 	cs := m.Styles.Disc.Render("● disconnected")
@@ -434,7 +434,7 @@ func GenerateHelpText(m *model.Model) string {
 			{"toggle_help", "Toggle help"},
 			{"toggle_theme", "Toggle theme picker"},
 			{"toggle_settings", "Toggle settings"},
-			{"toggle_sort", "Toggle sort mode"},
+			{"toggle_sort", "Cycle sort mode"},
 			{"tab_search", "Search tab"},
 			{"tab_history", "History tab"},
 			{"tab_rules", "Rules tab"},
@@ -21,6 +21,9 @@ func Results(m *model.Model) string {
 		if m.IsSearching {
 			return m.Styles.Gray.Render("  " + m.Spinner.View() + " searching…")
 		}
+		if m.Results != nil && m.Results.Error != "" {
+			return m.Styles.Disc.Render("  " + m.Results.Error)
+		}
 		if m.TextInput.Value() != "" {
 			return m.Styles.Gray.Render("  No results found")
 		}
@@ -172,7 +175,11 @@ func Document(m *model.Model, d *document.Document, sel bool, contentW int) stri
 	}
 
 	titleMaxW := max(1, contentW-timeW-domainBadgeW)
-	titleRendered := ts.Render(truncateLine(strings.Join(strings.Fields(d.Title), " "), titleMaxW))
+	title := strings.Join(strings.Fields(d.Title), " ")
+	if n := len(d.Thread); n > 0 {
+		title += fmt.Sprintf(" (+%d in thread)", n)
+	}
+	titleRendered := ts.Render(truncateLine(title, titleMaxW))
 	titleLine := domainBadge + rightPad(titleRendered, contentW-timeW-domainBadgeW) +
 		strings.Repeat(" ", max(0, timeW-lipgloss.Width(timeRendered))) + timeRendered
 
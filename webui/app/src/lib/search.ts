@@ -19,6 +19,7 @@ interface SearchMessage {
   highlight?: string;
   semantic_enabled?: boolean;
   semantic_threshold?: number;
+  semantic_weight?: number;
 }
 
 interface SearchResult {
@@ -29,13 +30,17 @@ interface SearchResult {
   text?: string;
   favicon?: string;
   added?: number;
+  metadata?: Record<string, any>;
+  hybrid?: HybridScore;
+  thread?: SearchResult[];
 }
 
-export interface SemanticHit {
-  doc_id: string;
-  similarity: number;
+export interface HybridScore {
+  keyword: number;
+  semantic: number;
+  weight: number;
+  source: 'keyword' | 'semantic' | 'both';
   matched_chunk?: string;
-  document?: SearchResult;
 }
 
 export interface SearchResults {
@@ -45,8 +50,9 @@ export interface SearchResults {
   search_duration?: string;
   query?: { text: string };
   query_suggestion?: string;
-  semantic_hits?: SemanticHit[];
+  page_key?: string;
   semantic_enabled?: boolean;
+  error?: string;
 }
 
 export function escapeHTML(s: string): string {
@@ -335,6 +341,7 @@ interface QueryParams {
   highlight?: string;
   semantic_enabled?: boolean;
   semantic_threshold?: number;
+  semantic_weight?: number;
 }
 
 export function buildSearchQuery(
@@ -342,7 +349,7 @@ export function buildSearchQuery(
   sort?: string,
   dateFrom?: string,
   dateTo?: string,
-  semantic?: { enabled: boolean; threshold: number },
+  semantic?: { enabled: boolean; threshold: number; weight: number },
 ): QueryParams {
   return {
     text,
@@ -352,7 +359,11 @@ export function buildSearchQuery(
       date_from: Math.floor(new Date(dateFrom).getTime() / 1000),
     }),
     ...(dateTo && { date_to: Math.floor(new Date(dateTo).getTime() / 1000) }),
-    ...(semantic && { semantic_enabled: semantic.enabled, semantic_threshold: semantic.threshold }),
+    ...(semantic && {
+      semantic_enabled: semantic.enabled,
+      semantic_threshold: semantic.threshold,
+      semantic_weight: semantic.weight,
+    }),
   };
 }
 